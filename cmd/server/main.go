@@ -1,33 +1,228 @@
 package main
 
 import (
-	"fmt"
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/THECHAMP95821/chess-backend/internal/account"
+	"github.com/THECHAMP95821/chess-backend/internal/api"
+	"github.com/THECHAMP95821/chess-backend/internal/arena"
+	"github.com/THECHAMP95821/chess-backend/internal/bot"
+	"github.com/THECHAMP95821/chess-backend/internal/cluster"
+	"github.com/THECHAMP95821/chess-backend/internal/docstore"
+	"github.com/THECHAMP95821/chess-backend/internal/fanout"
+	"github.com/THECHAMP95821/chess-backend/internal/game"
+	"github.com/THECHAMP95821/chess-backend/internal/gamelog"
+	"github.com/THECHAMP95821/chess-backend/internal/livecache"
+	"github.com/THECHAMP95821/chess-backend/internal/lobby"
+	"github.com/THECHAMP95821/chess-backend/internal/matchmaking"
+	"github.com/THECHAMP95821/chess-backend/internal/rating"
+	"github.com/THECHAMP95821/chess-backend/internal/store"
+	"github.com/THECHAMP95821/chess-backend/internal/tournament"
 )
 
-type order struct {
-	id      string
-	amount  int32
-	created time.Time
-}
+const shutdownTimeout = 20 * time.Second
 
-func (o order) changeAmt(amt int) {
-	o.amount = int32(amt)
+func getenv(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
 }
 
-const (
-	a = iota + 10
-	b
-	c
-	d
-)
+func nodeID() string {
+	if id := os.Getenv("CHESS_NODE_ID"); id != "" {
+		return id
+	}
+	host, _ := os.Hostname()
+	return host
+}
 
 func main() {
-	o1 := order{
-		id:      "1",
-		amount:  10,
-		created: time.Now(),
+	if err := run(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run() error {
+	addr := getenv("CHESS_ADDR", ":8080")
+	games := game.NewService()
+	handler := api.NewServer(games)
+	var root http.Handler = handler
+	// Game hooks must be in place before any game is created or restored.
+	tournaments := tournament.NewService(games)
+	arenas := arena.NewService(games)
+	bots := bot.NewFeed()
+	games.OnGameStart(bots.GameStarted)
+	// Tournaments and arenas score every game in the cluster, so with Redis
+	// endings go through a stream any node may handle; alone, this node
+	// scores them as they end.
+	scoreGame := func(ctx context.Context, v game.View) error {
+		return errors.Join(tournaments.GameEnded(ctx, v), arenas.GameEnded(ctx, v))
+	}
+	sendEnding := func(v game.View) {
+		if err := scoreGame(context.Background(), v); err != nil {
+			log.Printf("score game %s: %v", v.ID, err)
+		}
+	}
+	games.OnGameEnd(func(v game.View) {
+		sendEnding(v)
+		bots.GameEnded(v)
+	})
+	handler.SetBots(bots)
+	lob := lobby.New(games)
+
+	// Background loops stop on bg; they must be gone before games are
+	// handed off, or this node could pick them straight back up.
+	bg, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+	var loops sync.WaitGroup
+	spawn := func(fn func(ctx context.Context)) {
+		loops.Add(1)
+		go func() {
+			defer loops.Done()
+			fn(bg)
+		}()
+	}
+	handoff := func() { games.Handoff() }
+	var queue matchmaking.Queue = matchmaking.NewMemory()
+	var ratings matchmaking.Ratings
+	var accountStore account.Store = store.NewMemory()
+
+	if dsn := getenv("CHESS_DATABASE_URL", ""); dsn != "" {
+		pg, err := store.OpenPostgres(context.Background(), dsn)
+		if err != nil {
+			return err
+		}
+		defer pg.Close()
+		games.SetStore(pg)
+		games.SetJournal(gamelog.NewJournal(pg))
+		rater := rating.NewService(pg)
+		games.SetRater(rater)
+		ratings = rater
+		accountStore = pg
+	}
+
+	accounts := account.NewService(accountStore)
+	tournaments.SetUsers(accountStore)
+	hasher, err := account.ParseHasher(getenv("CHESS_PASSWORD_HASHER", ""))
+	if err != nil {
+		return err
+	}
+	accounts.SetHasher(hasher)
+	if path := getenv("CHESS_NOTIFY_LOG", ""); path != "" {
+		notifier, err := account.OpenLogNotifier(path)
+		if err != nil {
+			return err
+		}
+		defer notifier.Close()
+		accounts.SetNotifier(notifier)
+	}
+	handler.SetAccounts(accounts)
+
+	if redisAddr := getenv("CHESS_REDIS_ADDR", ""); redisAddr != "" {
+		rdb := redis.NewClient(&redis.Options{Addr: redisAddr})
+		defer rdb.Close()
+
+		pub := fanout.NewPublisher(rdb)
+		defer pub.Close()
+		games.OnEvent(pub.Send)
+		hub := fanout.NewHub(rdb)
+		defer hub.Close()
+		handler.SetHub(hub)
+
+		docs := docstore.NewRedis(rdb)
+		tournaments.SetStore(docs)
+		arenas.SetStore(docs)
+		lob.SetStore(docs)
+		relay := lobby.NewRedis(rdb)
+		lob.SetRelay(relay)
+		spawn(func(ctx context.Context) { relay.Run(ctx, lob.Deliver) })
+		endings := fanout.NewEndings(rdb, nodeID())
+		defer endings.Close()
+		sendEnding = endings.Send
+		spawn(func(ctx context.Context) { endings.Run(ctx, scoreGame) })
+
+		cache := livecache.New(rdb, nodeID())
+		games.SetCache(cache)
+		spawn(func(ctx context.Context) { cache.RunHeartbeat(ctx, 5*time.Second) })
+
+		// The first round of the node loop also resumes the games this
+		// node or a dead one left behind.
+		leases := cluster.NewLeases(rdb, nodeID(), 15*time.Second)
+		games.SetLeaser(leases)
+		node := cluster.NewNode(rdb, leases, getenv("CHESS_ADVERTISE_ADDR", "http://localhost"+addr), games, cache)
+		spawn(func(ctx context.Context) { node.Run(ctx, 5*time.Second) })
+		root = node.Forward(handler)
+		handoff = func() { node.Leave(context.Background()) }
+		queue = matchmaking.NewRedis(rdb)
+	}
+
+	pairing := matchmaking.NewService(queue, games)
+	if ratings != nil {
+		pairing.SetRatings(ratings)
+	}
+	handler.SetMatchmaking(pairing)
+	if ratings != nil {
+		lob.SetRatings(ratings)
+	}
+	handler.SetLobby(lob)
+	if ratings != nil {
+		tournaments.SetRatings(ratings)
+		arenas.SetRatings(ratings)
+	}
+	handler.SetTournaments(tournaments)
+	handler.SetArenas(arenas)
+	spawn(func(ctx context.Context) { pairing.Run(ctx, time.Second) })
+	spawn(func(ctx context.Context) { arenas.Run(ctx, 2*time.Second) })
+
+	spawn(func(ctx context.Context) { games.RunClockSync(ctx, 5*time.Second) })
+	srv := &http.Server{
+		Addr:    addr,
+		Handler: root,
+	}
+	// Bot streams never end by themselves; cut them as soon as shutdown
+	// starts, so that Shutdown does not wait on them while the bots' clocks
+	// run with no way to move.
+	srv.RegisterOnShutdown(handler.CloseStreams)
+
+	serveErr := make(chan error, 1)
+	go func() {
+		log.Printf("listening on %s", addr)
+		serveErr <- srv.ListenAndServe()
+	}()
+
+	sig, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	select {
+	case err := <-serveErr:
+		if !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+	case <-sig.Done():
+	}
+
+	log.Printf("shutting down")
+	games.Drain()
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Printf("http shutdown: %v", err)
 	}
-	fmt.Println(o1)
-	o1.changeAmt(55)
+	stopBackground()
+	loops.Wait()
+	// Saves every game, disconnects its clients so they reconnect to the
+	// next owner, and gives up this node's leases.
+	handoff()
+	log.Printf("handed off live games")
+	return nil
 }
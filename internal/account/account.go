@@ -0,0 +1,412 @@
+// Package account registers users and logs them in: password credentials,
+// per-device sessions and password resets.
+package account
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/mail"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/THECHAMP95821/chess-backend/internal/store"
+)
+
+var (
+	ErrInvalidName        = errors.New("invalid user name")
+	ErrInvalidEmail       = errors.New("invalid email address")
+	ErrInvalidPassword    = errors.New("unacceptable password")
+	ErrNameTaken          = errors.New("user name is taken")
+	ErrEmailTaken         = errors.New("email is already registered")
+	ErrInvalidCredentials = errors.New("wrong user name or password")
+	ErrUnauthenticated    = errors.New("not logged in or session expired")
+	ErrSessionNotFound    = errors.New("session not found")
+	ErrInvalidResetToken  = errors.New("invalid or expired password reset token")
+	ErrAlreadyBot         = errors.New("account is already a bot")
+)
+
+const (
+	sessionTTL = 30 * 24 * time.Hour
+	resetTTL   = time.Hour
+	// touchEvery limits how often Authenticate records that a session was
+	// seen, so a busy client does not write on every request.
+	touchEvery = time.Minute
+
+	minPassword = 8
+	// maxPassword is bcrypt's limit; Argon2id has none, but a password
+	// should not stop working when the hasher changes.
+	maxPassword = 72
+)
+
+var validName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]{1,29}$`)
+
+// Store keeps users and their credentials and sessions; store.Memory and
+// store.Postgres are both one.
+type Store interface {
+	User(ctx context.Context, id string) (store.User, error)
+	UserByName(ctx context.Context, name string) (store.User, error)
+	MarkBot(ctx context.Context, id string) error
+	store.Accounts
+}
+
+type Service struct {
+	store    Store
+	notifier Notifier
+	hasher   Hasher
+	now      func() time.Time
+
+	// dummy is checked against when a login names nobody, so that it takes
+	// as long as one with a wrong password.
+	dummyOnce sync.Once
+	dummy     string
+}
+
+// NewService stores new passwords with Argon2id and writes password resets
+// to standard error until told otherwise.
+func NewService(st Store) *Service {
+	return &Service{
+		store:    st,
+		notifier: NewLogNotifier(os.Stderr),
+		hasher:   Argon2id,
+		now:      time.Now,
+	}
+}
+
+// SetNotifier delivers password reset tokens through n.
+func (s *Service) SetNotifier(n Notifier) {
+	s.notifier = n
+}
+
+// SetHasher stores new passwords with h. Existing ones are rehashed the
+// next time their user logs in.
+func (s *Service) SetHasher(h Hasher) {
+	s.hasher = h
+}
+
+// Identity is who a request's token belongs to. A session token sets
+// SessionID; an API token sets TokenID and the Scopes it is limited to.
+type Identity struct {
+	UserID    string
+	SessionID string
+	TokenID   string
+	Scopes    []Scope
+}
+
+// SessionView is a session as its owner sees it; the token itself is only
+// ever shown at login.
+type SessionView struct {
+	ID        string    `json:"id"`
+	Device    string    `json:"device,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	LastSeen  time.Time `json:"last_seen"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func sessionView(s store.Session) SessionView {
+	return SessionView{
+		ID:        s.ID,
+		Device:    s.Device,
+		CreatedAt: s.CreatedAt,
+		LastSeen:  s.LastSeen,
+		ExpiresAt: s.ExpiresAt,
+	}
+}
+
+// Login is a new session: Token authenticates its requests.
+type Login struct {
+	Token   string      `json:"token"`
+	UserID  string      `json:"user_id"`
+	Name    string      `json:"name"`
+	Session SessionView `json:"session"`
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// newToken returns a random bearer token and the hash it is stored under.
+func newToken() (token, hash string) {
+	b := make([]byte, 32)
+	rand.Read(b)
+	token = base64.RawURLEncoding.EncodeToString(b)
+	return token, hashToken(token)
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func checkPassword(password string) error {
+	switch {
+	case len(password) < minPassword:
+		return fmt.Errorf("%w: at least %d characters", ErrInvalidPassword, minPassword)
+	case len(password) > maxPassword:
+		return fmt.Errorf("%w: at most %d bytes", ErrInvalidPassword, maxPassword)
+	}
+	return nil
+}
+
+func normalizeEmail(email string) (string, error) {
+	email = strings.TrimSpace(email)
+	if email == "" {
+		return "", nil
+	}
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email {
+		return "", fmt.Errorf("%w: %q", ErrInvalidEmail, email)
+	}
+	return strings.ToLower(email), nil
+}
+
+// Register creates a user who logs in with name and password. Email is
+// optional; without one, password reset tokens go to the notifier under
+// the user's name only.
+func (s *Service) Register(ctx context.Context, name, email, password string) (store.User, error) {
+	if !validName.MatchString(name) {
+		return store.User{}, fmt.Errorf("%w: 2 to 30 letters, digits, '_' or '-'", ErrInvalidName)
+	}
+	email, err := normalizeEmail(email)
+	if err != nil {
+		return store.User{}, err
+	}
+	if err := checkPassword(password); err != nil {
+		return store.User{}, err
+	}
+	if email != "" {
+		if _, err := s.store.CredentialsByEmail(ctx, email); err == nil {
+			return store.User{}, ErrEmailTaken
+		} else if !errors.Is(err, store.ErrNotFound) {
+			return store.User{}, err
+		}
+	}
+	hash, err := s.hasher.hash(password)
+	if err != nil {
+		return store.User{}, err
+	}
+
+	now := s.now()
+	u := store.User{ID: newID(), Name: name, CreatedAt: now}
+	c := store.Credentials{UserID: u.ID, Email: email, PasswordHash: hash, UpdatedAt: now}
+	if err := s.store.CreateAccount(ctx, u, c); err != nil {
+		if !errors.Is(err, store.ErrDuplicate) {
+			return store.User{}, err
+		}
+		// Another registration took the name or, since the check above,
+		// the email.
+		if _, err := s.store.UserByName(ctx, name); err == nil {
+			return store.User{}, ErrNameTaken
+		}
+		return store.User{}, ErrEmailTaken
+	}
+	return u, nil
+}
+
+// lookup finds a user by name or, if login looks like one, by email.
+func (s *Service) lookup(ctx context.Context, login string) (store.User, store.Credentials, error) {
+	var (
+		u   store.User
+		c   store.Credentials
+		err error
+	)
+	if strings.Contains(login, "@") {
+		if c, err = s.store.CredentialsByEmail(ctx, strings.ToLower(strings.TrimSpace(login))); err == nil {
+			u, err = s.store.User(ctx, c.UserID)
+		}
+	} else if u, err = s.store.UserByName(ctx, login); err == nil {
+		c, err = s.store.Credentials(ctx, u.ID)
+	}
+	return u, c, err
+}
+
+// Login checks a password and opens a session for device, which only
+// labels the session for its owner. Login names a user or their email.
+func (s *Service) Login(ctx context.Context, login, password, device string) (Login, error) {
+	u, c, err := s.lookup(ctx, login)
+	if errors.Is(err, store.ErrNotFound) {
+		s.dummyOnce.Do(func() { s.dummy, _ = s.hasher.hash(newID()) })
+		s.hasher.check(s.dummy, password)
+		return Login{}, ErrInvalidCredentials
+	}
+	if err != nil {
+		return Login{}, err
+	}
+	ok, stale, err := s.hasher.check(c.PasswordHash, password)
+	if err != nil {
+		return Login{}, fmt.Errorf("user %s: %w", u.ID, err)
+	}
+	if !ok {
+		return Login{}, ErrInvalidCredentials
+	}
+
+	now := s.now()
+	if stale {
+		if hash, err := s.hasher.hash(password); err == nil {
+			c.PasswordHash, c.UpdatedAt = hash, now
+			s.store.SaveCredentials(ctx, c)
+		}
+	}
+	token, hash := newToken()
+	sess := store.Session{
+		ID:        newID(),
+		TokenHash: hash,
+		UserID:    u.ID,
+		Device:    device,
+		CreatedAt: now,
+		LastSeen:  now,
+		ExpiresAt: now.Add(sessionTTL),
+	}
+	if err := s.store.CreateSession(ctx, sess); err != nil {
+		return Login{}, err
+	}
+	return Login{Token: token, UserID: u.ID, Name: u.Name, Session: sessionView(sess)}, nil
+}
+
+// Authenticate resolves a session or API token to its user. It fails with
+// ErrUnauthenticated for unknown, revoked and expired tokens alike.
+func (s *Service) Authenticate(ctx context.Context, token string) (Identity, error) {
+	if token == "" {
+		return Identity{}, ErrUnauthenticated
+	}
+	if strings.HasPrefix(token, apiTokenPrefix) {
+		return s.authenticateToken(ctx, token)
+	}
+	sess, err := s.store.SessionByToken(ctx, hashToken(token))
+	if errors.Is(err, store.ErrNotFound) {
+		return Identity{}, ErrUnauthenticated
+	}
+	if err != nil {
+		return Identity{}, err
+	}
+	now := s.now()
+	if !sess.RevokedAt.IsZero() || !now.Before(sess.ExpiresAt) {
+		return Identity{}, ErrUnauthenticated
+	}
+	if now.Sub(sess.LastSeen) >= touchEvery {
+		s.store.TouchSession(ctx, sess.ID, now)
+	}
+	return Identity{UserID: sess.UserID, SessionID: sess.ID}, nil
+}
+
+func (s *Service) User(ctx context.Context, id string) (store.User, error) {
+	return s.store.User(ctx, id)
+}
+
+// UpgradeToBot turns the user into a bot for good. Bots play through the
+// bot API and are rated in a pool of their own.
+func (s *Service) UpgradeToBot(ctx context.Context, userID string) (store.User, error) {
+	u, err := s.store.User(ctx, userID)
+	if err != nil {
+		return store.User{}, err
+	}
+	if u.Bot {
+		return store.User{}, ErrAlreadyBot
+	}
+	if err := s.store.MarkBot(ctx, userID); err != nil {
+		return store.User{}, err
+	}
+	u.Bot = true
+	return u, nil
+}
+
+// Sessions lists the live sessions of a user, newest first.
+func (s *Service) Sessions(ctx context.Context, userID string) ([]SessionView, error) {
+	sessions, err := s.store.Sessions(ctx, userID, s.now())
+	if err != nil {
+		return nil, err
+	}
+	views := make([]SessionView, len(sessions))
+	for i, sess := range sessions {
+		views[i] = sessionView(sess)
+	}
+	return views, nil
+}
+
+// Revoke ends one of the user's sessions, such as the one they are using
+// to log out.
+func (s *Service) Revoke(ctx context.Context, userID, sessionID string) error {
+	err := s.store.RevokeSession(ctx, userID, sessionID, s.now())
+	if errors.Is(err, store.ErrNotFound) {
+		return ErrSessionNotFound
+	}
+	return err
+}
+
+// RevokeAll logs the user out everywhere.
+func (s *Service) RevokeAll(ctx context.Context, userID string) error {
+	return s.store.RevokeSessions(ctx, userID, s.now())
+}
+
+// RequestReset sends a password reset token to the user named by login. It
+// succeeds whether or not there is such a user, so that it cannot be used
+// to find out who has an account.
+func (s *Service) RequestReset(ctx context.Context, login string) error {
+	u, c, err := s.lookup(ctx, login)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	now := s.now()
+	token, hash := newToken()
+	err = s.store.CreatePasswordReset(ctx, store.PasswordReset{
+		TokenHash: hash,
+		UserID:    u.ID,
+		CreatedAt: now,
+		ExpiresAt: now.Add(resetTTL),
+	})
+	if err != nil {
+		return err
+	}
+	return s.notifier.Notify(ctx, Message{
+		UserID:  u.ID,
+		Name:    u.Name,
+		Email:   c.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Someone asked to reset the password of %s. If it was you, use this token within %v:\n\n%s",
+			u.Name, resetTTL, token),
+	})
+}
+
+// ResetPassword redeems a reset token, sets a new password and logs the
+// user out of every session.
+func (s *Service) ResetPassword(ctx context.Context, token, password string) error {
+	if err := checkPassword(password); err != nil {
+		return err
+	}
+	now := s.now()
+	r, err := s.store.UsePasswordReset(ctx, hashToken(token), now)
+	if errors.Is(err, store.ErrNotFound) {
+		return ErrInvalidResetToken
+	}
+	if err != nil {
+		return err
+	}
+	if !now.Before(r.ExpiresAt) {
+		return ErrInvalidResetToken
+	}
+	c, err := s.store.Credentials(ctx, r.UserID)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		return err
+	}
+	hash, err := s.hasher.hash(password)
+	if err != nil {
+		return err
+	}
+	c.UserID, c.PasswordHash, c.UpdatedAt = r.UserID, hash, now
+	if err := s.store.SaveCredentials(ctx, c); err != nil {
+		return err
+	}
+	return s.store.RevokeSessions(ctx, r.UserID, now)
+}
@@ -0,0 +1,253 @@
+package account
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/THECHAMP95821/chess-backend/internal/store"
+)
+
+type recorder struct {
+	messages []Message
+}
+
+func (r *recorder) Notify(ctx context.Context, m Message) error {
+	r.messages = append(r.messages, m)
+	return nil
+}
+
+func newTestService() (*Service, *store.Memory, *recorder, *time.Time) {
+	now := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	st := store.NewMemory()
+	s := NewService(st)
+	rec := &recorder{}
+	s.SetNotifier(rec)
+	s.now = func() time.Time { return now }
+	return s, st, rec, &now
+}
+
+func TestHashers(t *testing.T) {
+	for _, h := range []Hasher{Argon2id, Bcrypt} {
+		enc, err := h.hash("correct horse")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok, stale, err := h.check(enc, "correct horse"); !ok || stale || err != nil {
+			t.Errorf("%s: check = %v, %v, %v", h, ok, stale, err)
+		}
+		if ok, _, err := h.check(enc, "battery staple"); ok || err != nil {
+			t.Errorf("%s: wrong password: check = %v, %v", h, ok, err)
+		}
+	}
+	enc, _ := Bcrypt.hash("correct horse")
+	if ok, stale, _ := Argon2id.check(enc, "correct horse"); !ok || !stale {
+		t.Errorf("bcrypt hash under argon2id: ok = %v, stale = %v", ok, stale)
+	}
+	if _, _, err := Argon2id.check("$argon2id$v=19$m=1$x$y", "pw"); err == nil {
+		t.Error("malformed hash accepted")
+	}
+}
+
+func TestSessions(t *testing.T) {
+	ctx := context.Background()
+	s, _, _, now := newTestService()
+
+	u, err := s.Register(ctx, "alice", "Alice@Example.com", "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Register(ctx, "alice", "", "correct horse"); !errors.Is(err, ErrNameTaken) {
+		t.Errorf("same name: err = %v, want ErrNameTaken", err)
+	}
+	if _, err := s.Register(ctx, "bob", "alice@example.com", "correct horse"); !errors.Is(err, ErrEmailTaken) {
+		t.Errorf("same email: err = %v, want ErrEmailTaken", err)
+	}
+	if _, err := s.Register(ctx, "bob", "", "short"); !errors.Is(err, ErrInvalidPassword) {
+		t.Errorf("short password: err = %v, want ErrInvalidPassword", err)
+	}
+	if _, err := s.Register(ctx, "b", "", "correct horse"); !errors.Is(err, ErrInvalidName) {
+		t.Errorf("short name: err = %v, want ErrInvalidName", err)
+	}
+
+	if _, err := s.Login(ctx, "alice", "wrong password", "phone"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("wrong password: err = %v", err)
+	}
+	if _, err := s.Login(ctx, "nobody", "correct horse", "phone"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("unknown user: err = %v", err)
+	}
+	phone, err := s.Login(ctx, "alice", "correct horse", "phone")
+	if err != nil {
+		t.Fatal(err)
+	}
+	*now = now.Add(time.Hour)
+	laptop, err := s.Login(ctx, "alice@example.com", "correct horse", "laptop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if phone.Token == laptop.Token || laptop.UserID != u.ID {
+		t.Fatalf("logins = %+v, %+v", phone, laptop)
+	}
+
+	id, err := s.Authenticate(ctx, phone.Token)
+	if err != nil || id.UserID != u.ID || id.SessionID != phone.Session.ID {
+		t.Errorf("Authenticate = %+v, %v", id, err)
+	}
+	if _, err := s.Authenticate(ctx, "forged"); !errors.Is(err, ErrUnauthenticated) {
+		t.Errorf("forged token: err = %v", err)
+	}
+	sessions, _ := s.Sessions(ctx, u.ID)
+	if len(sessions) != 2 || sessions[0].Device != "laptop" || !sessions[1].LastSeen.Equal(*now) {
+		t.Errorf("sessions = %+v", sessions)
+	}
+
+	if err := s.Revoke(ctx, u.ID, phone.Session.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Revoke(ctx, u.ID, phone.Session.ID); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("revoking twice: err = %v", err)
+	}
+	if _, err := s.Authenticate(ctx, phone.Token); !errors.Is(err, ErrUnauthenticated) {
+		t.Errorf("revoked token: err = %v", err)
+	}
+	if _, err := s.Authenticate(ctx, laptop.Token); err != nil {
+		t.Errorf("other device logged out too: %v", err)
+	}
+	*now = now.Add(sessionTTL)
+	if _, err := s.Authenticate(ctx, laptop.Token); !errors.Is(err, ErrUnauthenticated) {
+		t.Errorf("expired token: err = %v", err)
+	}
+}
+
+func TestPasswordReset(t *testing.T) {
+	ctx := context.Background()
+	s, st, rec, now := newTestService()
+	s.SetHasher(Bcrypt)
+	u, err := s.Register(ctx, "alice", "alice@example.com", "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetHasher(Argon2id)
+	login, err := s.Login(ctx, "alice", "correct horse", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c, _ := st.Credentials(ctx, u.ID); !strings.HasPrefix(c.PasswordHash, "$argon2id$") {
+		t.Errorf("bcrypt hash not upgraded at login: %s", c.PasswordHash)
+	}
+
+	if err := s.RequestReset(ctx, "nobody"); err != nil || len(rec.messages) != 0 {
+		t.Errorf("reset for unknown user: %v, %d messages", err, len(rec.messages))
+	}
+	if err := s.RequestReset(ctx, "alice@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if len(rec.messages) != 1 || rec.messages[0].Email != "alice@example.com" {
+		t.Fatalf("messages = %+v", rec.messages)
+	}
+	body := rec.messages[0].Body
+	token := body[strings.LastIndex(body, "\n")+1:]
+
+	if err := s.ResetPassword(ctx, "forged", "battery staple"); !errors.Is(err, ErrInvalidResetToken) {
+		t.Errorf("forged token: err = %v", err)
+	}
+	if err := s.ResetPassword(ctx, token, "battery staple"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.ResetPassword(ctx, token, "battery staple"); !errors.Is(err, ErrInvalidResetToken) {
+		t.Errorf("reused token: err = %v", err)
+	}
+	if _, err := s.Authenticate(ctx, login.Token); !errors.Is(err, ErrUnauthenticated) {
+		t.Errorf("session survived reset: err = %v", err)
+	}
+	if _, err := s.Login(ctx, "alice", "correct horse", ""); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("old password: err = %v", err)
+	}
+	if _, err := s.Login(ctx, "alice", "battery staple", ""); err != nil {
+		t.Errorf("new password: %v", err)
+	}
+
+	s.RequestReset(ctx, "alice")
+	body = rec.messages[1].Body
+	*now = now.Add(resetTTL)
+	if err := s.ResetPassword(ctx, body[strings.LastIndex(body, "\n")+1:], "another one"); !errors.Is(err, ErrInvalidResetToken) {
+		t.Errorf("expired token: err = %v", err)
+	}
+}
+
+func TestLogNotifier(t *testing.T) {
+	var b strings.Builder
+	n := NewLogNotifier(&b)
+	n.now = func() time.Time { return time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC) }
+	n.Notify(context.Background(), Message{UserID: "u1", Name: "alice", Email: "a@example.com", Subject: "Hi", Body: "token"})
+	want := "2024-03-01T10:00:00Z to alice <a@example.com> (u1): Hi\ntoken\n\n"
+	if b.String() != want {
+		t.Errorf("log = %q, want %q", b.String(), want)
+	}
+}
+
+func TestTokens(t *testing.T) {
+	ctx := context.Background()
+	s, _, _, now := newTestService()
+	u, err := s.Register(ctx, "alice", "", "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.CreateToken(ctx, u.ID, "bot", nil); !errors.Is(err, ErrInvalidScope) {
+		t.Errorf("no scopes: err = %v", err)
+	}
+	if _, err := s.CreateToken(ctx, u.ID, "bot", []Scope{"games:delete"}); !errors.Is(err, ErrInvalidScope) {
+		t.Errorf("unknown scope: err = %v", err)
+	}
+	bot, err := s.CreateToken(ctx, u.ID, "bot", []Scope{ScopeReadGames, ScopePlayGames, ScopeReadGames})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(bot.Token, apiTokenPrefix) || len(bot.Scopes) != 2 || !bot.LastUsed.IsZero() {
+		t.Errorf("token = %+v", bot)
+	}
+
+	*now = now.Add(time.Hour)
+	id, err := s.Authenticate(ctx, bot.Token)
+	if err != nil || id.UserID != u.ID || id.TokenID != bot.ID || id.SessionID != "" {
+		t.Fatalf("Authenticate = %+v, %v", id, err)
+	}
+	if !id.Allows(ScopePlayGames) || id.Allows(ScopeTournaments) {
+		t.Errorf("scopes = %v", id.Scopes)
+	}
+	if !(Identity{UserID: u.ID, SessionID: "s"}).Allows(ScopeTournaments) {
+		t.Error("session limited by scopes")
+	}
+	tokens, _ := s.Tokens(ctx, u.ID)
+	if len(tokens) != 1 || !tokens[0].LastUsed.Equal(*now) {
+		t.Errorf("tokens = %+v", tokens)
+	}
+
+	if err := s.RevokeToken(ctx, u.ID, bot.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RevokeToken(ctx, u.ID, bot.ID); !errors.Is(err, ErrTokenNotFound) {
+		t.Errorf("revoking twice: err = %v", err)
+	}
+	if _, err := s.Authenticate(ctx, bot.Token); !errors.Is(err, ErrUnauthenticated) {
+		t.Errorf("revoked token: err = %v", err)
+	}
+}
+
+func TestUpgradeToBot(t *testing.T) {
+	ctx := context.Background()
+	s, _, _, _ := newTestService()
+	u, _ := s.Register(ctx, "engine", "", "correct horse")
+	if got, err := s.UpgradeToBot(ctx, u.ID); err != nil || !got.Bot {
+		t.Fatalf("UpgradeToBot = %+v, %v", got, err)
+	}
+	if _, err := s.UpgradeToBot(ctx, u.ID); !errors.Is(err, ErrAlreadyBot) {
+		t.Errorf("second upgrade: err = %v", err)
+	}
+	if got, _ := s.User(ctx, u.ID); !got.Bot {
+		t.Errorf("user = %+v", got)
+	}
+}
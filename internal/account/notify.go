@@ -0,0 +1,70 @@
+package account
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Message is something to tell a user outside the game, such as a password
+// reset token. Email is empty for users who did not give one.
+type Message struct {
+	UserID  string
+	Name    string
+	Email   string
+	Subject string
+	Body    string
+}
+
+// Notifier delivers messages to users, by email or whatever else a
+// deployment has.
+type Notifier interface {
+	Notify(ctx context.Context, m Message) error
+}
+
+// LogNotifier writes messages to a log instead of delivering them, for
+// running locally.
+type LogNotifier struct {
+	mu  sync.Mutex
+	w   io.Writer
+	c   io.Closer
+	now func() time.Time
+}
+
+func NewLogNotifier(w io.Writer) *LogNotifier {
+	return &LogNotifier{w: w, now: time.Now}
+}
+
+// OpenLogNotifier appends messages to the file at path, creating it if
+// needed.
+func OpenLogNotifier(path string) (*LogNotifier, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	n := NewLogNotifier(f)
+	n.c = f
+	return n, nil
+}
+
+func (n *LogNotifier) Notify(ctx context.Context, m Message) error {
+	to := m.Name
+	if m.Email != "" {
+		to = fmt.Sprintf("%s <%s>", m.Name, m.Email)
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	_, err := fmt.Fprintf(n.w, "%s to %s (%s): %s\n%s\n\n",
+		n.now().UTC().Format(time.RFC3339), to, m.UserID, m.Subject, m.Body)
+	return err
+}
+
+func (n *LogNotifier) Close() error {
+	if n.c == nil {
+		return nil
+	}
+	return n.c.Close()
+}
@@ -0,0 +1,112 @@
+package account
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Hasher names a password hashing scheme. Passwords are always checked
+// against the scheme that hashed them; the hasher only decides how new and
+// rehashed passwords are stored.
+type Hasher string
+
+const (
+	Argon2id Hasher = "argon2id"
+	Bcrypt   Hasher = "bcrypt"
+)
+
+func ParseHasher(s string) (Hasher, error) {
+	switch h := Hasher(s); h {
+	case Argon2id, Bcrypt:
+		return h, nil
+	case "":
+		return Argon2id, nil
+	default:
+		return "", fmt.Errorf("unknown password hasher %q", s)
+	}
+}
+
+// argonParams are the Argon2id costs of new hashes, the second option
+// recommended by RFC 9106.
+type argonParams struct {
+	time    uint32
+	memory  uint32 // KiB
+	threads uint8
+}
+
+var argonCost = argonParams{time: 3, memory: 64 * 1024, threads: 4}
+
+var bcryptCost = bcrypt.DefaultCost
+
+const (
+	saltLen = 16
+	keyLen  = 32
+)
+
+var errMalformedHash = errors.New("malformed password hash")
+
+// hash encodes password with h: Argon2id in the PHC string format, bcrypt in
+// its own.
+func (h Hasher) hash(password string) (string, error) {
+	if h == Bcrypt {
+		b, err := bcrypt.GenerateFromPassword([]byte(password), bcryptCost)
+		return string(b), err
+	}
+	salt := make([]byte, saltLen)
+	rand.Read(salt)
+	p := argonCost
+	key := argon2.IDKey([]byte(password), salt, p.time, p.memory, p.threads, keyLen)
+	b64 := base64.RawStdEncoding
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, p.memory, p.time, p.threads, b64.EncodeToString(salt), b64.EncodeToString(key)), nil
+}
+
+// check reports whether password matches encoded, and whether encoded
+// should be replaced by a fresh hash from h because it used another scheme
+// or weaker costs.
+func (h Hasher) check(encoded, password string) (ok, stale bool, err error) {
+	if !strings.HasPrefix(encoded, "$argon2id$") {
+		err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, false, nil
+		}
+		if err != nil {
+			return false, false, err
+		}
+		cost, _ := bcrypt.Cost([]byte(encoded))
+		return true, h != Bcrypt || cost < bcryptCost, nil
+	}
+
+	var (
+		version int
+		p       argonParams
+	)
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 {
+		return false, false, errMalformedHash
+	}
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return false, false, errMalformedHash
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.memory, &p.time, &p.threads); err != nil {
+		return false, false, errMalformedHash
+	}
+	salt, err1 := base64.RawStdEncoding.DecodeString(parts[4])
+	key, err2 := base64.RawStdEncoding.DecodeString(parts[5])
+	if err1 != nil || err2 != nil || len(key) == 0 {
+		return false, false, errMalformedHash
+	}
+	got := argon2.IDKey([]byte(password), salt, p.time, p.memory, p.threads, uint32(len(key)))
+	if subtle.ConstantTimeCompare(got, key) != 1 {
+		return false, false, nil
+	}
+	weaker := p.time < argonCost.time || p.memory < argonCost.memory || p.threads < argonCost.threads
+	return true, h != Argon2id || weaker, nil
+}
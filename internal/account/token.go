@@ -0,0 +1,157 @@
+package account
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/THECHAMP95821/chess-backend/internal/store"
+)
+
+var (
+	ErrInvalidScope  = errors.New("unknown token scope")
+	ErrTokenNotFound = errors.New("api token not found")
+	ErrTooManyTokens = errors.New("too many api tokens")
+)
+
+// Scope is something an API token may be used for. Sessions may do
+// everything.
+type Scope string
+
+const (
+	ScopeReadGames   Scope = "games:read"
+	ScopePlayGames   Scope = "games:play"
+	ScopeChallenges  Scope = "challenges:write"
+	ScopeTournaments Scope = "tournaments:write"
+)
+
+var scopes = []Scope{ScopeReadGames, ScopePlayGames, ScopeChallenges, ScopeTournaments}
+
+// apiTokenPrefix tells API tokens from session tokens, and makes them easy
+// to spot when one leaks into a log or a repository.
+const apiTokenPrefix = "chb_"
+
+// maxTokens is how many live API tokens one user may have.
+const maxTokens = 50
+
+// TokenView is an API token as its owner sees it. LastUsed is zero until
+// the token is first used.
+type TokenView struct {
+	ID          string    `json:"id"`
+	Description string    `json:"description,omitempty"`
+	Scopes      []Scope   `json:"scopes"`
+	CreatedAt   time.Time `json:"created_at"`
+	LastUsed    time.Time `json:"last_used,omitzero"`
+}
+
+func tokenView(t store.APIToken) TokenView {
+	v := TokenView{
+		ID:          t.ID,
+		Description: t.Description,
+		Scopes:      make([]Scope, len(t.Scopes)),
+		CreatedAt:   t.CreatedAt,
+		LastUsed:    t.LastUsed,
+	}
+	for i, sc := range t.Scopes {
+		v.Scopes[i] = Scope(sc)
+	}
+	return v
+}
+
+// NewToken is a freshly made API token. Token is shown this once.
+type NewToken struct {
+	Token string `json:"token"`
+	TokenView
+}
+
+// Allows reports whether the identity may act within scope.
+func (id Identity) Allows(scope Scope) bool {
+	return id.TokenID == "" || slices.Contains(id.Scopes, scope)
+}
+
+// CreateToken makes an API token for the user limited to the given scopes,
+// of which there must be at least one.
+func (s *Service) CreateToken(ctx context.Context, userID, description string, want []Scope) (NewToken, error) {
+	if len(want) == 0 {
+		return NewToken{}, fmt.Errorf("%w: at least one is required", ErrInvalidScope)
+	}
+	var names []string
+	for _, sc := range want {
+		if !slices.Contains(scopes, sc) {
+			return NewToken{}, fmt.Errorf("%w: %q", ErrInvalidScope, sc)
+		}
+		if !slices.Contains(names, string(sc)) {
+			names = append(names, string(sc))
+		}
+	}
+	live, err := s.store.APITokens(ctx, userID)
+	if err != nil {
+		return NewToken{}, err
+	}
+	if len(live) >= maxTokens {
+		return NewToken{}, fmt.Errorf("%w: at most %d", ErrTooManyTokens, maxTokens)
+	}
+
+	token, _ := newToken()
+	token = apiTokenPrefix + token
+	t := store.APIToken{
+		ID:          newID(),
+		TokenHash:   hashToken(token),
+		UserID:      userID,
+		Description: strings.TrimSpace(description),
+		Scopes:      names,
+		CreatedAt:   s.now(),
+	}
+	if err := s.store.CreateAPIToken(ctx, t); err != nil {
+		return NewToken{}, err
+	}
+	return NewToken{Token: token, TokenView: tokenView(t)}, nil
+}
+
+// Tokens lists the user's live API tokens, newest first.
+func (s *Service) Tokens(ctx context.Context, userID string) ([]TokenView, error) {
+	tokens, err := s.store.APITokens(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	views := make([]TokenView, len(tokens))
+	for i, t := range tokens {
+		views[i] = tokenView(t)
+	}
+	return views, nil
+}
+
+func (s *Service) RevokeToken(ctx context.Context, userID, id string) error {
+	err := s.store.RevokeAPIToken(ctx, userID, id, s.now())
+	if errors.Is(err, store.ErrNotFound) {
+		return ErrTokenNotFound
+	}
+	return err
+}
+
+// authenticateToken resolves an API token, recording when it was last
+// used.
+func (s *Service) authenticateToken(ctx context.Context, token string) (Identity, error) {
+	t, err := s.store.APITokenByHash(ctx, hashToken(token))
+	if errors.Is(err, store.ErrNotFound) {
+		return Identity{}, ErrUnauthenticated
+	}
+	if err != nil {
+		return Identity{}, err
+	}
+	if !t.RevokedAt.IsZero() {
+		return Identity{}, ErrUnauthenticated
+	}
+	now := s.now()
+	if now.Sub(t.LastUsed) >= touchEvery {
+		s.store.TouchAPIToken(ctx, t.ID, now)
+	}
+	id := Identity{UserID: t.UserID, TokenID: t.ID}
+	for _, sc := range t.Scopes {
+		id.Scopes = append(id.Scopes, Scope(sc))
+	}
+	return id, nil
+}
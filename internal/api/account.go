@@ -0,0 +1,357 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/THECHAMP95821/chess-backend/internal/account"
+	"github.com/THECHAMP95821/chess-backend/internal/chess"
+	"github.com/THECHAMP95821/chess-backend/internal/store"
+)
+
+var (
+	errWrongUser       = errors.New("request names a user other than the one logged in")
+	errNotYourSeat     = errors.New("that side is played by another user")
+	errMissingScope    = errors.New("api token lacks the scope for this request")
+	errSessionRequired = errors.New("api tokens cannot manage sessions or tokens")
+)
+
+// SetAccounts serves registration and login through a. From then on,
+// requests act for the user whose session or API token they carry rather
+// than for the user_id they name.
+func (s *Server) SetAccounts(a *account.Service) {
+	s.accounts = a
+}
+
+func (s *Server) withAccounts(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.accounts == nil {
+			writeError(w, errUnavailable)
+			return
+		}
+		h(w, r)
+	}
+}
+
+type identityKey struct{}
+
+// bearerToken reads the session token from the Authorization header or,
+// for WebSocket clients that cannot set headers, the access_token query
+// parameter.
+func bearerToken(r *http.Request) string {
+	if h := r.Header.Get("Authorization"); h != "" {
+		if token, ok := strings.CutPrefix(h, "Bearer "); ok {
+			return strings.TrimSpace(token)
+		}
+		return ""
+	}
+	return r.URL.Query().Get("access_token")
+}
+
+// authenticate attaches the identity of the request's session, if it has
+// one. A token that does not check out fails the request rather than
+// letting it through anonymously.
+func (s *Server) authenticate(w http.ResponseWriter, r *http.Request) (*http.Request, bool) {
+	token := bearerToken(r)
+	if s.accounts == nil || token == "" {
+		return r, true
+	}
+	id, err := s.accounts.Authenticate(r.Context(), token)
+	if err != nil {
+		writeError(w, err)
+		return r, false
+	}
+	return r.WithContext(context.WithValue(r.Context(), identityKey{}, id)), true
+}
+
+func identity(r *http.Request) (account.Identity, bool) {
+	id, ok := r.Context().Value(identityKey{}).(account.Identity)
+	return id, ok
+}
+
+// allow checks that a request made with an API token has scope.
+func allow(r *http.Request, scope account.Scope) error {
+	if id, ok := identity(r); ok && !id.Allows(scope) {
+		return fmt.Errorf("%w: %s", errMissingScope, scope)
+	}
+	return nil
+}
+
+func (s *Server) scoped(scope account.Scope, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := allow(r, scope); err != nil {
+			writeError(w, err)
+			return
+		}
+		h(w, r)
+	}
+}
+
+// actingUser settles who a request acts for. With accounts it is the
+// logged-in user, whom claimed may only repeat; without, it is claimed.
+func (s *Server) actingUser(r *http.Request, claimed string) (string, error) {
+	if s.accounts == nil {
+		return userRequest{UserID: claimed}.user()
+	}
+	id, ok := identity(r)
+	if !ok {
+		return "", account.ErrUnauthenticated
+	}
+	if claimed != "" && claimed != id.UserID {
+		return "", errWrongUser
+	}
+	return id.UserID, nil
+}
+
+// organizer is who runs the event a request creates or manages. Without
+// accounts events have no organizer and anyone may run them.
+func (s *Server) organizer(r *http.Request) (string, error) {
+	if s.accounts == nil {
+		return "", nil
+	}
+	id, ok := identity(r)
+	if !ok {
+		return "", account.ErrUnauthenticated
+	}
+	return id.UserID, nil
+}
+
+// takeSeat checks that the request may play color c in game id. With
+// accounts, a side with a registered player is theirs alone; sides of
+// games created without players are open to anyone.
+func (s *Server) takeSeat(r *http.Request, id string, c chess.Color) error {
+	if s.accounts == nil {
+		return nil
+	}
+	v, err := s.games.Get(id)
+	if err != nil {
+		return err
+	}
+	owner := v.WhiteID
+	if c == chess.ColorBlack {
+		owner = v.BlackID
+	}
+	if owner == "" {
+		return nil
+	}
+	user, ok := identity(r)
+	if !ok {
+		return account.ErrUnauthenticated
+	}
+	if user.UserID != owner {
+		return errNotYourSeat
+	}
+	return nil
+}
+
+type registerRequest struct {
+	Name     string `json:"name"`
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+type userView struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Bot       bool      `json:"bot,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func newUserView(u store.User) userView {
+	return userView{ID: u.ID, Name: u.Name, Bot: u.Bot, CreatedAt: u.CreatedAt}
+}
+
+func (s *Server) handleRegister(w http.ResponseWriter, r *http.Request) {
+	var req registerRequest
+	if err := decode(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+	u, err := s.accounts.Register(r.Context(), req.Name, req.Email, req.Password)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, newUserView(u))
+}
+
+type loginRequest struct {
+	Login    string `json:"login"`
+	Password string `json:"password"`
+	Device   string `json:"device"`
+}
+
+// handleLogin opens a session for a user name or email and password. The
+// token in the reply goes in an "Authorization: Bearer" header from then on.
+func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
+	var req loginRequest
+	if err := decode(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+	if req.Device == "" {
+		req.Device = r.UserAgent()
+	}
+	login, err := s.accounts.Login(r.Context(), req.Login, req.Password, req.Device)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, login)
+}
+
+// loggedIn serves requests that carry a valid session.
+func (s *Server) loggedIn(h func(w http.ResponseWriter, r *http.Request, id account.Identity)) http.HandlerFunc {
+	return s.withAccounts(func(w http.ResponseWriter, r *http.Request) {
+		id, ok := identity(r)
+		if !ok {
+			writeError(w, account.ErrUnauthenticated)
+			return
+		}
+		h(w, r, id)
+	})
+}
+
+// sessionOnly serves requests from a logged-in session, so that a leaked
+// API token cannot be used to mint more or to lock its owner out.
+func (s *Server) sessionOnly(h func(w http.ResponseWriter, r *http.Request, id account.Identity)) http.HandlerFunc {
+	return s.loggedIn(func(w http.ResponseWriter, r *http.Request, id account.Identity) {
+		if id.SessionID == "" {
+			writeError(w, errSessionRequired)
+			return
+		}
+		h(w, r, id)
+	})
+}
+
+func (s *Server) handleLogout(w http.ResponseWriter, r *http.Request, id account.Identity) {
+	if err := s.accounts.Revoke(r.Context(), id.UserID, id.SessionID); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleMe(w http.ResponseWriter, r *http.Request, id account.Identity) {
+	u, err := s.accounts.User(r.Context(), id.UserID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, newUserView(u))
+}
+
+type sessionView struct {
+	account.SessionView
+	Current bool `json:"current"`
+}
+
+func (s *Server) handleListSessions(w http.ResponseWriter, r *http.Request, id account.Identity) {
+	sessions, err := s.accounts.Sessions(r.Context(), id.UserID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	views := make([]sessionView, len(sessions))
+	for i, sess := range sessions {
+		views[i] = sessionView{sess, sess.ID == id.SessionID}
+	}
+	writeJSON(w, http.StatusOK, map[string][]sessionView{"sessions": views})
+}
+
+func (s *Server) handleRevokeSession(w http.ResponseWriter, r *http.Request, id account.Identity) {
+	if err := s.accounts.Revoke(r.Context(), id.UserID, r.PathValue("id")); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleRevokeAllSessions logs the user out on every device, this one
+// included.
+func (s *Server) handleRevokeAllSessions(w http.ResponseWriter, r *http.Request, id account.Identity) {
+	if err := s.accounts.RevokeAll(r.Context(), id.UserID); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type createTokenRequest struct {
+	Description string          `json:"description"`
+	Scopes      []account.Scope `json:"scopes"`
+}
+
+// handleCreateToken replies with the new token, which is never shown
+// again.
+func (s *Server) handleCreateToken(w http.ResponseWriter, r *http.Request, id account.Identity) {
+	var req createTokenRequest
+	if err := decode(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+	t, err := s.accounts.CreateToken(r.Context(), id.UserID, req.Description, req.Scopes)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, t)
+}
+
+func (s *Server) handleListTokens(w http.ResponseWriter, r *http.Request, id account.Identity) {
+	tokens, err := s.accounts.Tokens(r.Context(), id.UserID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string][]account.TokenView{"tokens": tokens})
+}
+
+func (s *Server) handleRevokeToken(w http.ResponseWriter, r *http.Request, id account.Identity) {
+	if err := s.accounts.RevokeToken(r.Context(), id.UserID, r.PathValue("id")); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type forgotPasswordRequest struct {
+	Login string `json:"login"`
+}
+
+// handleForgotPassword always answers 202, whether or not the login names
+// anyone.
+func (s *Server) handleForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var req forgotPasswordRequest
+	if err := decode(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := s.accounts.RequestReset(r.Context(), req.Login); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+type resetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+func (s *Server) handleResetPassword(w http.ResponseWriter, r *http.Request) {
+	var req resetPasswordRequest
+	if err := decode(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := s.accounts.ResetPassword(r.Context(), req.Token, req.Password); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
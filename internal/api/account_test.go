@@ -0,0 +1,236 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/THECHAMP95821/chess-backend/internal/account"
+	"github.com/THECHAMP95821/chess-backend/internal/game"
+	"github.com/THECHAMP95821/chess-backend/internal/lobby"
+	"github.com/THECHAMP95821/chess-backend/internal/store"
+	"github.com/THECHAMP95821/chess-backend/internal/tournament"
+)
+
+// doAs is do with a session token.
+func doAs(t *testing.T, h http.Handler, token, method, path string, body any) (*httptest.ResponseRecorder, map[string]any) {
+	t.Helper()
+	var buf bytes.Buffer
+	if body != nil {
+		json.NewEncoder(&buf).Encode(body)
+	}
+	req := httptest.NewRequest(method, path, &buf)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	var out map[string]any
+	json.Unmarshal(rec.Body.Bytes(), &out)
+	return rec, out
+}
+
+func TestAccounts(t *testing.T) {
+	games := game.NewService()
+	h := NewServer(games)
+	accounts := account.NewService(store.NewMemory())
+	accounts.SetNotifier(account.NewLogNotifier(io.Discard))
+	h.SetAccounts(accounts)
+	h.SetLobby(lobby.New(games))
+
+	login := func(name, device string) (token, id string) {
+		t.Helper()
+		rec, body := do(t, h, "POST", "/api/auth/login", map[string]string{"login": name, "password": "correct horse", "device": device})
+		if rec.Code != http.StatusOK {
+			t.Fatalf("login %s: status %d, body %v", name, rec.Code, body)
+		}
+		return body["token"].(string), body["user_id"].(string)
+	}
+	for _, name := range []string{"alice", "bob"} {
+		rec, body := do(t, h, "POST", "/api/auth/register", map[string]string{"name": name, "password": "correct horse"})
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("register %s: status %d, body %v", name, rec.Code, body)
+		}
+	}
+	rec, body := do(t, h, "POST", "/api/auth/register", map[string]string{"name": "alice", "password": "correct horse"})
+	if rec.Code != http.StatusConflict || errorCode(body) != "name_taken" {
+		t.Errorf("taken name: status %d, body %v", rec.Code, body)
+	}
+	rec, body = do(t, h, "POST", "/api/auth/login", map[string]string{"login": "alice", "password": "wrong password"})
+	if rec.Code != http.StatusUnauthorized || errorCode(body) != "invalid_credentials" {
+		t.Errorf("wrong password: status %d, body %v", rec.Code, body)
+	}
+
+	alice, aliceID := login("alice", "phone")
+	aliceLaptop, _ := login("alice", "laptop")
+	bob, bobID := login("bob", "phone")
+
+	rec, body = doAs(t, h, alice, "GET", "/api/auth/me", nil)
+	if rec.Code != http.StatusOK || body["name"] != "alice" || body["id"] != aliceID {
+		t.Errorf("me: status %d, body %v", rec.Code, body)
+	}
+	rec, body = doAs(t, h, "forged", "GET", "/api/lobby/seeks", nil)
+	if rec.Code != http.StatusUnauthorized || errorCode(body) != "unauthenticated" {
+		t.Errorf("forged token: status %d, body %v", rec.Code, body)
+	}
+
+	rec, body = do(t, h, "POST", "/api/lobby/seeks", map[string]string{"user_id": aliceID, "time_control": "180+2"})
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("seek without a session: status %d, body %v", rec.Code, body)
+	}
+	rec, body = doAs(t, h, bob, "POST", "/api/lobby/seeks", map[string]string{"user_id": aliceID, "time_control": "180+2"})
+	if rec.Code != http.StatusForbidden || errorCode(body) != "wrong_user" {
+		t.Errorf("seek as someone else: status %d, body %v", rec.Code, body)
+	}
+	rec, body = doAs(t, h, alice, "POST", "/api/lobby/seeks", map[string]string{"time_control": "180+2"})
+	if rec.Code != http.StatusCreated || body["user_id"] != aliceID {
+		t.Errorf("seek: status %d, body %v", rec.Code, body)
+	}
+
+	g, err := games.Create(game.CreateOptions{WhiteID: aliceID, BlackID: bobID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec, body = doAs(t, h, bob, "POST", "/api/games/"+g.ID+"/moves", map[string]string{"color": "white", "uci": "e2e4"})
+	if rec.Code != http.StatusForbidden || errorCode(body) != "not_your_seat" {
+		t.Errorf("move for the other side: status %d, body %v", rec.Code, body)
+	}
+	rec, body = doAs(t, h, aliceLaptop, "POST", "/api/games/"+g.ID+"/moves", map[string]string{"color": "white", "uci": "e2e4"})
+	if rec.Code != http.StatusOK {
+		t.Errorf("move: status %d, body %v", rec.Code, body)
+	}
+
+	rec, body = doAs(t, h, alice, "GET", "/api/auth/sessions", nil)
+	sessions, _ := body["sessions"].([]any)
+	if rec.Code != http.StatusOK || len(sessions) != 2 {
+		t.Fatalf("sessions: status %d, body %v", rec.Code, body)
+	}
+	laptop := sessions[0].(map[string]any)
+	if laptop["device"] != "laptop" || laptop["current"] != false {
+		t.Errorf("laptop session = %v", laptop)
+	}
+	rec, _ = doAs(t, h, alice, "DELETE", "/api/auth/sessions/"+laptop["id"].(string), nil)
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("revoke laptop: status %d", rec.Code)
+	}
+	if rec, _ = doAs(t, h, aliceLaptop, "GET", "/api/auth/me", nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("revoked session: status %d", rec.Code)
+	}
+	if rec, _ = doAs(t, h, alice, "POST", "/api/auth/logout", nil); rec.Code != http.StatusNoContent {
+		t.Errorf("logout: status %d", rec.Code)
+	}
+	if rec, _ = doAs(t, h, alice, "GET", "/api/auth/me", nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("after logout: status %d", rec.Code)
+	}
+	if rec, _ = do(t, h, "POST", "/api/auth/password/forgot", map[string]string{"login": "nobody"}); rec.Code != http.StatusAccepted {
+		t.Errorf("forgot password: status %d", rec.Code)
+	}
+}
+
+func TestAPITokens(t *testing.T) {
+	games := game.NewService()
+	h := NewServer(games)
+	h.SetAccounts(account.NewService(store.NewMemory()))
+	h.SetLobby(lobby.New(games))
+
+	do(t, h, "POST", "/api/auth/register", map[string]string{"name": "alice", "password": "correct horse"})
+	_, body := do(t, h, "POST", "/api/auth/login", map[string]string{"login": "alice", "password": "correct horse"})
+	session := body["token"].(string)
+
+	rec, body := doAs(t, h, session, "POST", "/api/auth/tokens", map[string]any{"description": "bot", "scopes": []string{"games:fly"}})
+	if rec.Code != http.StatusBadRequest || errorCode(body) != "invalid_scope" {
+		t.Errorf("unknown scope: status %d, body %v", rec.Code, body)
+	}
+	rec, body = doAs(t, h, session, "POST", "/api/auth/tokens", map[string]any{"description": "bot", "scopes": []string{"games:read", "games:play"}})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create token: status %d, body %v", rec.Code, body)
+	}
+	token, tokenID := body["token"].(string), body["id"].(string)
+
+	g, _ := games.Create(game.CreateOptions{})
+	if rec, body = doAs(t, h, token, "GET", "/api/games/"+g.ID, nil); rec.Code != http.StatusOK {
+		t.Errorf("read game: status %d, body %v", rec.Code, body)
+	}
+	rec, body = doAs(t, h, token, "POST", "/api/lobby/seeks", map[string]string{"time_control": "180+2"})
+	if rec.Code != http.StatusForbidden || errorCode(body) != "missing_scope" {
+		t.Errorf("seek without scope: status %d, body %v", rec.Code, body)
+	}
+	rec, body = doAs(t, h, token, "POST", "/api/auth/tokens", map[string]any{"scopes": []string{"tournaments:write"}})
+	if rec.Code != http.StatusForbidden || errorCode(body) != "session_required" {
+		t.Errorf("token minting a token: status %d, body %v", rec.Code, body)
+	}
+	if rec, body = doAs(t, h, token, "GET", "/api/auth/me", nil); rec.Code != http.StatusOK || body["name"] != "alice" {
+		t.Errorf("me with token: status %d, body %v", rec.Code, body)
+	}
+
+	rec, body = doAs(t, h, session, "GET", "/api/auth/tokens", nil)
+	tokens, _ := body["tokens"].([]any)
+	if rec.Code != http.StatusOK || len(tokens) != 1 || tokens[0].(map[string]any)["last_used"] == nil {
+		t.Errorf("tokens: status %d, body %v", rec.Code, body)
+	}
+	if rec, _ = doAs(t, h, session, "DELETE", "/api/auth/tokens/"+tokenID, nil); rec.Code != http.StatusNoContent {
+		t.Errorf("revoke token: status %d", rec.Code)
+	}
+	if rec, _ = doAs(t, h, token, "GET", "/api/games/"+g.ID, nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("revoked token: status %d", rec.Code)
+	}
+}
+
+func TestTournamentOrganizer(t *testing.T) {
+	games := game.NewService()
+	h := NewServer(games)
+	h.SetAccounts(account.NewService(store.NewMemory()))
+	h.SetTournaments(tournament.NewService(games))
+
+	login := func(name string) string {
+		t.Helper()
+		do(t, h, "POST", "/api/auth/register", map[string]string{"name": name, "password": "correct horse"})
+		_, body := do(t, h, "POST", "/api/auth/login", map[string]string{"login": name, "password": "correct horse"})
+		return body["token"].(string)
+	}
+	alice, bob := login("alice"), login("bob")
+
+	opts := map[string]any{"name": "Club", "rounds": 1}
+	rec, body := do(t, h, "POST", "/api/tournaments", opts)
+	if rec.Code != http.StatusUnauthorized || errorCode(body) != "unauthenticated" {
+		t.Errorf("anonymous create: status %d, body %v", rec.Code, body)
+	}
+	rec, body = doAs(t, h, alice, "POST", "/api/tournaments", opts)
+	if rec.Code != http.StatusCreated || body["organizer"] == nil {
+		t.Fatalf("create: status %d, body %v", rec.Code, body)
+	}
+	path := "/api/tournaments/" + body["id"].(string)
+	for _, token := range []string{alice, bob} {
+		if rec, body = doAs(t, h, token, "POST", path+"/join", nil); rec.Code != http.StatusOK {
+			t.Fatalf("join: status %d, body %v", rec.Code, body)
+		}
+	}
+
+	if rec, body = do(t, h, "POST", path+"/start", nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("anonymous start: status %d, body %v", rec.Code, body)
+	}
+	rec, body = doAs(t, h, bob, "POST", path+"/start", nil)
+	if rec.Code != http.StatusForbidden || errorCode(body) != "not_organizer" {
+		t.Errorf("start by a player: status %d, body %v", rec.Code, body)
+	}
+	if rec, body = doAs(t, h, alice, "POST", path+"/start", nil); rec.Code != http.StatusOK {
+		t.Fatalf("start: status %d, body %v", rec.Code, body)
+	}
+
+	result := map[string]string{"result": "1-0"}
+	if rec, body = do(t, h, "POST", path+"/rounds/1/boards/1/result", result); rec.Code != http.StatusUnauthorized {
+		t.Errorf("anonymous result: status %d, body %v", rec.Code, body)
+	}
+	rec, body = doAs(t, h, bob, "POST", path+"/rounds/1/boards/1/result", result)
+	if rec.Code != http.StatusForbidden || errorCode(body) != "not_organizer" {
+		t.Errorf("result by a player: status %d, body %v", rec.Code, body)
+	}
+	if rec, body = doAs(t, h, alice, "POST", path+"/rounds/1/boards/1/result", result); rec.Code != http.StatusOK {
+		t.Errorf("result: status %d, body %v", rec.Code, body)
+	}
+
+	if rec, body = do(t, h, "POST", "/api/tournaments/trf", nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("anonymous import: status %d, body %v", rec.Code, body)
+	}
+}
@@ -0,0 +1,91 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/THECHAMP95821/chess-backend/internal/arena"
+)
+
+// SetArenas serves arena tournaments through a.
+func (s *Server) SetArenas(a *arena.Service) {
+	s.arenas = a
+}
+
+func (s *Server) withArenas(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.arenas == nil {
+			writeError(w, errUnavailable)
+			return
+		}
+		h(w, r)
+	}
+}
+
+type createArenaRequest struct {
+	Name        string    `json:"name"`
+	TimeControl string    `json:"time_control"`
+	Rated       bool      `json:"rated"`
+	StartsAt    time.Time `json:"starts_at"`
+	Minutes     int       `json:"minutes"`
+	NoBerserk   bool      `json:"no_berserk"`
+}
+
+func (s *Server) handleCreateArena(w http.ResponseWriter, r *http.Request) {
+	var req createArenaRequest
+	if err := decode(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+	by, err := s.organizer(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	v, err := s.arenas.Create(r.Context(), arena.Options{
+		Name:        req.Name,
+		TimeControl: req.TimeControl,
+		Rated:       req.Rated,
+		StartsAt:    req.StartsAt,
+		Duration:    time.Duration(req.Minutes) * time.Minute,
+		NoBerserk:   req.NoBerserk,
+		Organizer:   by,
+	})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, v)
+}
+
+func (s *Server) handleGetArena(w http.ResponseWriter, r *http.Request) {
+	v, err := s.arenas.Get(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, v)
+}
+
+func (s *Server) arenaAction(w http.ResponseWriter, r *http.Request, action func(ctx context.Context, id, user string) (arena.View, error)) {
+	user, err := s.decodeUser(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	v, err := action(r.Context(), r.PathValue("id"), user)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, v)
+}
+
+func (s *Server) handleJoinArena(w http.ResponseWriter, r *http.Request) {
+	s.arenaAction(w, r, s.arenas.Join)
+}
+
+func (s *Server) handlePauseArena(w http.ResponseWriter, r *http.Request) {
+	s.arenaAction(w, r, s.arenas.Pause)
+}
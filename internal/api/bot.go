@@ -0,0 +1,323 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/THECHAMP95821/chess-backend/internal/account"
+	"github.com/THECHAMP95821/chess-backend/internal/bot"
+	"github.com/THECHAMP95821/chess-backend/internal/chess"
+	"github.com/THECHAMP95821/chess-backend/internal/game"
+	"github.com/THECHAMP95821/chess-backend/internal/lobby"
+	"github.com/THECHAMP95821/chess-backend/internal/store"
+)
+
+var (
+	errNotBot        = errors.New("only bot accounts may use the bot api")
+	errBotNotAllowed = errors.New("bots play through challenges only")
+	errNotPlayer     = errors.New("not playing in this game")
+)
+
+// ndjsonKeepAlive is how often an idle NDJSON stream gets an empty line,
+// so that clients and proxies can tell it from a dead one.
+const ndjsonKeepAlive = 6 * time.Second
+
+// SetBots serves the bot API, telling bots of their games through f.
+func (s *Server) SetBots(f *bot.Feed) {
+	s.bots = f
+}
+
+// botAction serves requests from a bot account with scope.
+func (s *Server) botAction(scope account.Scope, h func(w http.ResponseWriter, r *http.Request, u store.User)) http.HandlerFunc {
+	return s.scoped(scope, s.loggedIn(func(w http.ResponseWriter, r *http.Request, id account.Identity) {
+		if s.bots == nil {
+			writeError(w, errUnavailable)
+			return
+		}
+		u, err := s.accounts.User(r.Context(), id.UserID)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if !u.Bot {
+			writeError(w, errNotBot)
+			return
+		}
+		h(w, r, u)
+	}))
+}
+
+// refuseBots keeps bots out of seeks and quick pairing.
+func (s *Server) refuseBots(r *http.Request) error {
+	id, ok := identity(r)
+	if !ok {
+		return nil
+	}
+	u, err := s.accounts.User(r.Context(), id.UserID)
+	if err != nil {
+		return err
+	}
+	if u.Bot {
+		return errBotNotAllowed
+	}
+	return nil
+}
+
+func (s *Server) handleUpgradeToBot(w http.ResponseWriter, r *http.Request, id account.Identity) {
+	u, err := s.accounts.UpgradeToBot(r.Context(), id.UserID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, newUserView(u))
+}
+
+// ndjson writes a stream of JSON values, one per line, flushing each.
+type ndjson struct {
+	rc  *http.ResponseController
+	enc *json.Encoder
+	w   http.ResponseWriter
+}
+
+func startNDJSON(w http.ResponseWriter) *ndjson {
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	// Answer at once, or a bot with nothing to hear of yet waits for the
+	// first keepalive to learn it is connected.
+	rc := http.NewResponseController(w)
+	rc.Flush()
+	return &ndjson{rc: rc, enc: json.NewEncoder(w), w: w}
+}
+
+func (n *ndjson) send(v any) error {
+	if err := n.enc.Encode(v); err != nil {
+		return err
+	}
+	return n.rc.Flush()
+}
+
+func (n *ndjson) keepAlive() error {
+	if _, err := n.w.Write([]byte("\n")); err != nil {
+		return err
+	}
+	return n.rc.Flush()
+}
+
+// handleBotEvents streams what the bot must act on: challenges and the
+// start and end of its games. It opens with the games already under way
+// and the challenges still pending, so a bot that reconnects misses
+// nothing, though it may hear of a game twice.
+func (s *Server) handleBotEvents(w http.ResponseWriter, r *http.Request, u store.User) {
+	feed := s.bots.Subscribe(u.ID)
+	defer feed.Close()
+	var (
+		challenges <-chan lobby.Event
+		pending    []lobby.Challenge
+	)
+	if s.lobby != nil {
+		snap, sub, err := s.lobby.Subscribe(r.Context(), u.ID)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		defer sub.Close()
+		challenges, pending = sub.C, snap.Challenges
+	}
+
+	out := startNDJSON(w)
+	for _, v := range s.games.Ongoing(u.ID) {
+		info := bot.Info(v, u.ID)
+		if out.send(bot.Event{Type: bot.EventGameStart, Game: &info}) != nil {
+			return
+		}
+	}
+	for _, c := range pending {
+		if out.send(bot.Event{Type: bot.EventChallenge, Challenge: &c}) != nil {
+			return
+		}
+	}
+
+	keepAlive := time.NewTicker(ndjsonKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		var err error
+		select {
+		case <-r.Context().Done():
+			return
+		case <-s.streams.Done():
+			return
+		case e, ok := <-feed.C:
+			if !ok {
+				return
+			}
+			err = out.send(e)
+		case e, ok := <-challenges:
+			if !ok {
+				return
+			}
+			if be, ok := bot.ChallengeEvent(e); ok {
+				err = out.send(be)
+			}
+		case <-keepAlive.C:
+			err = out.keepAlive()
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// botColor is the side u plays in game id.
+func (s *Server) botColor(id string, u store.User) (chess.Color, error) {
+	v, err := s.games.Get(id)
+	if err != nil {
+		return chess.ColorWhite, err
+	}
+	return playerColor(v, u.ID)
+}
+
+func playerColor(v game.View, userID string) (chess.Color, error) {
+	switch userID {
+	case v.WhiteID:
+		return chess.ColorWhite, nil
+	case v.BlackID:
+		return chess.ColorBlack, nil
+	}
+	return chess.ColorWhite, errNotPlayer
+}
+
+// handleBotGameStream streams one of the bot's games: the full game first,
+// then its state after every move or offer, and chat. The stream ends with
+// the game; a stream cut short before that is resumed by reconnecting.
+func (s *Server) handleBotGameStream(w http.ResponseWriter, r *http.Request, u store.User) {
+	v, sub, err := s.games.Subscribe(r.PathValue("id"), true)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer sub.Close()
+	if _, err := playerColor(v, u.ID); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	out := startNDJSON(w)
+	st, full := bot.NewStream(v)
+	if out.send(full) != nil || st.Over() {
+		return
+	}
+	keepAlive := time.NewTicker(ndjsonKeepAlive)
+	defer keepAlive.Stop()
+	for !st.Over() {
+		var err error
+		select {
+		case <-r.Context().Done():
+			return
+		case <-s.streams.Done():
+			return
+		case e, ok := <-sub.C:
+			if !ok {
+				return
+			}
+			if line, ok := st.Next(e); ok {
+				err = out.send(line)
+			}
+		case <-keepAlive.C:
+			err = out.keepAlive()
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (s *Server) handleBotMove(w http.ResponseWriter, r *http.Request, u store.User) {
+	id := r.PathValue("id")
+	c, err := s.botColor(id, u)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	v, err := s.games.Move(id, game.MoveRequest{Color: c, UCI: r.PathValue("move")})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, v)
+}
+
+func (s *Server) handleBotResign(w http.ResponseWriter, r *http.Request, u store.User) {
+	id := r.PathValue("id")
+	c, err := s.botColor(id, u)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	v, err := s.games.Resign(id, c)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, v)
+}
+
+type chatRequest struct {
+	Room string `json:"room"`
+	Text string `json:"text"`
+}
+
+// handleBotChat posts to the players' room unless the request names
+// another.
+func (s *Server) handleBotChat(w http.ResponseWriter, r *http.Request, u store.User) {
+	var req chatRequest
+	if err := decode(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+	if req.Room == "" {
+		req.Room = game.RoomPlayer
+	}
+	id := r.PathValue("id")
+	if _, err := s.botColor(id, u); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := s.games.Chat(id, game.ChatView{Room: req.Room, User: u.Name, Text: req.Text}); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleBotAcceptChallenge(w http.ResponseWriter, r *http.Request, u store.User) {
+	if s.lobby == nil {
+		writeError(w, errUnavailable)
+		return
+	}
+	v, err := s.lobby.AcceptChallenge(r.Context(), r.PathValue("id"), u.ID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, v)
+}
+
+func (s *Server) handleBotDeclineChallenge(w http.ResponseWriter, r *http.Request, u store.User) {
+	if s.lobby == nil {
+		writeError(w, errUnavailable)
+		return
+	}
+	var req declineRequest
+	if err := decode(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+	c, err := s.lobby.DeclineChallenge(r.Context(), r.PathValue("id"), u.ID, req.Reason)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, c)
+}
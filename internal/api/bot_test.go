@@ -0,0 +1,168 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/THECHAMP95821/chess-backend/internal/account"
+	"github.com/THECHAMP95821/chess-backend/internal/bot"
+	"github.com/THECHAMP95821/chess-backend/internal/game"
+	"github.com/THECHAMP95821/chess-backend/internal/lobby"
+	"github.com/THECHAMP95821/chess-backend/internal/store"
+)
+
+// ndjsonLines opens an NDJSON stream and returns a function reading its
+// next non-empty line.
+func ndjsonLines(t *testing.T, ctx context.Context, url, token string) func() map[string]any {
+	t.Helper()
+	req, _ := http.NewRequestWithContext(ctx, "GET", url, nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusOK || res.Header.Get("Content-Type") != "application/x-ndjson" {
+		t.Fatalf("%s: status %d, content type %q", url, res.StatusCode, res.Header.Get("Content-Type"))
+	}
+	t.Cleanup(func() { res.Body.Close() })
+	sc := bufio.NewScanner(res.Body)
+	return func() map[string]any {
+		t.Helper()
+		for sc.Scan() {
+			if len(sc.Bytes()) == 0 {
+				continue
+			}
+			var line map[string]any
+			if err := json.Unmarshal(sc.Bytes(), &line); err != nil {
+				t.Fatal(err)
+			}
+			return line
+		}
+		t.Fatalf("%s: stream ended: %v", url, sc.Err())
+		return nil
+	}
+}
+
+func TestBotAPI(t *testing.T) {
+	games := game.NewService()
+	feed := bot.NewFeed()
+	games.OnGameStart(feed.GameStarted)
+	games.OnGameEnd(feed.GameEnded)
+	h := NewServer(games)
+	h.SetAccounts(account.NewService(store.NewMemory()))
+	h.SetLobby(lobby.New(games))
+	h.SetBots(feed)
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	login := func(name string) (token, id string) {
+		t.Helper()
+		do(t, h, "POST", "/api/auth/register", map[string]string{"name": name, "password": "correct horse"})
+		_, body := do(t, h, "POST", "/api/auth/login", map[string]string{"login": name, "password": "correct horse"})
+		return body["token"].(string), body["user_id"].(string)
+	}
+	alice, aliceID := login("alice")
+	engine, engineID := login("engine")
+
+	rec, body := doAs(t, h, engine, "GET", "/api/bot/stream/event", nil)
+	if rec.Code != http.StatusForbidden || errorCode(body) != "not_a_bot" {
+		t.Errorf("stream before upgrade: status %d, body %v", rec.Code, body)
+	}
+	rec, body = doAs(t, h, engine, "POST", "/api/bot/account/upgrade", nil)
+	if rec.Code != http.StatusOK || body["bot"] != true {
+		t.Fatalf("upgrade: status %d, body %v", rec.Code, body)
+	}
+	rec, body = doAs(t, h, engine, "POST", "/api/bot/account/upgrade", nil)
+	if rec.Code != http.StatusConflict || errorCode(body) != "already_bot" {
+		t.Errorf("second upgrade: status %d, body %v", rec.Code, body)
+	}
+	rec, body = doAs(t, h, engine, "POST", "/api/lobby/seeks", map[string]string{"time_control": "180+2"})
+	if rec.Code != http.StatusForbidden || errorCode(body) != "bot_not_allowed" {
+		t.Errorf("bot seek: status %d, body %v", rec.Code, body)
+	}
+
+	rec, body = doAs(t, h, alice, "POST", "/api/challenges", map[string]string{"to": engineID, "time_control": "180+2", "color": "black"})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("challenge: status %d, body %v", rec.Code, body)
+	}
+	challengeID := body["id"].(string)
+
+	events := ndjsonLines(t, ctx, srv.URL+"/api/bot/stream/event", engine)
+	if e := events(); e["type"] != string(bot.EventChallenge) || e["challenge"].(map[string]any)["id"] != challengeID {
+		t.Fatalf("first event: %v", e)
+	}
+	rec, body = doAs(t, h, engine, "POST", "/api/bot/challenge/"+challengeID+"/accept", nil)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("accept: status %d, body %v", rec.Code, body)
+	}
+	gameID := body["id"].(string)
+	e := events()
+	for e["type"] == string(bot.EventChallenge) {
+		e = events()
+	}
+	info, _ := e["game"].(map[string]any)
+	if e["type"] != string(bot.EventGameStart) || info["id"] != gameID || info["color"] != "white" || info["is_my_turn"] != true {
+		t.Fatalf("game start: %v", e)
+	}
+
+	moves := ndjsonLines(t, ctx, srv.URL+"/api/bot/game/stream/"+gameID, engine)
+	if full := moves(); full["type"] != "game_full" || full["white_id"] != engineID || full["black_id"] != aliceID {
+		t.Fatalf("game full: %v", full)
+	}
+	rec, body = doAs(t, h, engine, "POST", "/api/bot/game/"+gameID+"/move/e2e4", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("move: status %d, body %v", rec.Code, body)
+	}
+	if st := moves(); st["type"] != "game_state" || st["moves"] != "e2e4" || st["status"] != "started" {
+		t.Errorf("state after move: %v", st)
+	}
+	rec, body = doAs(t, h, engine, "POST", "/api/bot/game/"+gameID+"/chat", map[string]string{"text": "good luck"})
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("chat: status %d, body %v", rec.Code, body)
+	}
+	if line := moves(); line["type"] != "chat_line" || line["text"] != "good luck" || line["user"] != "engine" {
+		t.Errorf("chat line: %v", line)
+	}
+	rec, body = doAs(t, h, engine, "POST", "/api/bot/game/"+gameID+"/resign", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("resign: status %d, body %v", rec.Code, body)
+	}
+	if st := moves(); st["status"] == "started" || st["result"] != "0-1" {
+		t.Errorf("state after resign: %v", st)
+	}
+	if e := events(); e["type"] != string(bot.EventGameFinish) {
+		t.Errorf("game finish: %v", e)
+	}
+}
+
+func TestBotStreamsEndOnShutdown(t *testing.T) {
+	games := game.NewService()
+	h := NewServer(games)
+	h.SetAccounts(account.NewService(store.NewMemory()))
+	h.SetBots(bot.NewFeed())
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+	srv.Config.RegisterOnShutdown(h.CloseStreams)
+
+	do(t, h, "POST", "/api/auth/register", map[string]string{"name": "engine", "password": "correct horse"})
+	_, body := do(t, h, "POST", "/api/auth/login", map[string]string{"login": "engine", "password": "correct horse"})
+	token := body["token"].(string)
+	doAs(t, h, token, "POST", "/api/bot/account/upgrade", nil)
+	// Hanging up lets srv.Close return even if the stream outlives Shutdown.
+	client, hangUp := context.WithCancel(context.Background())
+	defer hangUp()
+	ndjsonLines(t, client, srv.URL+"/api/bot/stream/event", token)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := srv.Config.Shutdown(ctx); err != nil {
+		t.Fatalf("shutdown with an open bot stream: %v", err)
+	}
+}
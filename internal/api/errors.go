@@ -0,0 +1,137 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/THECHAMP95821/chess-backend/internal/account"
+	"github.com/THECHAMP95821/chess-backend/internal/arena"
+	"github.com/THECHAMP95821/chess-backend/internal/chess"
+	"github.com/THECHAMP95821/chess-backend/internal/clock"
+	"github.com/THECHAMP95821/chess-backend/internal/fanout"
+	"github.com/THECHAMP95821/chess-backend/internal/game"
+	"github.com/THECHAMP95821/chess-backend/internal/lobby"
+	"github.com/THECHAMP95821/chess-backend/internal/matchmaking"
+	"github.com/THECHAMP95821/chess-backend/internal/swiss"
+	"github.com/THECHAMP95821/chess-backend/internal/tournament"
+	"github.com/THECHAMP95821/chess-backend/internal/trf"
+)
+
+var errBadRequest = errors.New("malformed request")
+
+type apiError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type errorBody struct {
+	Error apiError `json:"error"`
+}
+
+var errorCodes = []struct {
+	err    error
+	status int
+	code   string
+}{
+	{errBadRequest, http.StatusBadRequest, "bad_request"},
+	{game.ErrGameNotFound, http.StatusNotFound, "game_not_found"},
+	{fanout.ErrNoEvents, http.StatusNotFound, "game_not_found"},
+	{game.ErrShuttingDown, http.StatusServiceUnavailable, "shutting_down"},
+	{game.ErrNoJournal, http.StatusNotFound, "no_game_log"},
+	{game.ErrInvalidFEN, http.StatusBadRequest, "invalid_fen"},
+	{game.ErrInvalidTime, http.StatusBadRequest, "invalid_time_control"},
+	{game.ErrUnsupportedVariant, http.StatusBadRequest, "unsupported_variant"},
+	{chess.ErrInvalidNotation, http.StatusBadRequest, "invalid_move_notation"},
+	{chess.ErrIllegalMove, http.StatusUnprocessableEntity, "illegal_move"},
+	{game.ErrNotYourTurn, http.StatusConflict, "not_your_turn"},
+	{game.ErrStalePosition, http.StatusConflict, "stale_position"},
+	{chess.ErrGameOver, http.StatusConflict, "game_over"},
+	{game.ErrNoDrawOffer, http.StatusConflict, "no_draw_offer"},
+	{game.ErrOwnDrawOffer, http.StatusConflict, "own_draw_offer"},
+	{game.ErrNoTakeback, http.StatusConflict, "no_takeback_offer"},
+	{game.ErrOwnTakeback, http.StatusConflict, "own_takeback_offer"},
+	{game.ErrNothingToUndo, http.StatusConflict, "nothing_to_undo"},
+	{game.ErrBerserkNotAllowed, http.StatusConflict, "berserk_not_allowed"},
+	{clock.ErrNoBerserk, http.StatusConflict, "berserk_not_allowed"},
+	{clock.ErrAlreadyBerserk, http.StatusConflict, "already_berserk"},
+	{clock.ErrBerserkTooLate, http.StatusConflict, "berserk_too_late"},
+	{errSpectator, http.StatusForbidden, "spectator"},
+	{errUnavailable, http.StatusNotFound, "unavailable"},
+	{matchmaking.ErrAlreadyQueued, http.StatusConflict, "already_queued"},
+	{matchmaking.ErrNotQueued, http.StatusNotFound, "not_queued"},
+	{lobby.ErrSeekNotFound, http.StatusNotFound, "seek_not_found"},
+	{lobby.ErrNotYourSeek, http.StatusForbidden, "not_your_seek"},
+	{lobby.ErrOwnSeek, http.StatusConflict, "own_seek"},
+	{lobby.ErrRatingRange, http.StatusForbidden, "rating_out_of_range"},
+	{lobby.ErrTooManySeeks, http.StatusTooManyRequests, "too_many_seeks"},
+	{lobby.ErrInvalidColor, http.StatusBadRequest, "invalid_color"},
+	{lobby.ErrRatedPosition, http.StatusBadRequest, "rated_position"},
+	{lobby.ErrChallengeNotFound, http.StatusNotFound, "challenge_not_found"},
+	{lobby.ErrNotParticipant, http.StatusForbidden, "not_participant"},
+	{lobby.ErrSelfChallenge, http.StatusBadRequest, "self_challenge"},
+	{lobby.ErrChallengeClosed, http.StatusConflict, "challenge_closed"},
+	{lobby.ErrInvalidReason, http.StatusBadRequest, "invalid_reason"},
+	{tournament.ErrNotFound, http.StatusNotFound, "tournament_not_found"},
+	{tournament.ErrInvalidOptions, http.StatusBadRequest, "invalid_tournament"},
+	{tournament.ErrStarted, http.StatusConflict, "tournament_started"},
+	{tournament.ErrNotStarted, http.StatusConflict, "tournament_not_started"},
+	{tournament.ErrFinished, http.StatusConflict, "tournament_finished"},
+	{tournament.ErrAlreadyJoined, http.StatusConflict, "already_joined"},
+	{tournament.ErrNotJoined, http.StatusConflict, "not_joined"},
+	{tournament.ErrNotEnoughPlayers, http.StatusConflict, "not_enough_players"},
+	{tournament.ErrNoSuchBoard, http.StatusNotFound, "no_such_board"},
+	{tournament.ErrNotOrganizer, http.StatusForbidden, "not_organizer"},
+	{tournament.ErrUnknownPlayer, http.StatusUnprocessableEntity, "unknown_player"},
+	{swiss.ErrNoPairing, http.StatusConflict, "no_pairing"},
+	{trf.ErrSyntax, http.StatusBadRequest, "invalid_trf"},
+	{trf.ErrUnsupported, http.StatusUnprocessableEntity, "unsupported_trf"},
+	{arena.ErrNotFound, http.StatusNotFound, "arena_not_found"},
+	{arena.ErrInvalidOptions, http.StatusBadRequest, "invalid_arena"},
+	{arena.ErrFinished, http.StatusConflict, "arena_finished"},
+	{arena.ErrAlreadyJoined, http.StatusConflict, "already_joined"},
+	{arena.ErrNotJoined, http.StatusConflict, "not_joined"},
+	{account.ErrInvalidName, http.StatusBadRequest, "invalid_name"},
+	{account.ErrInvalidEmail, http.StatusBadRequest, "invalid_email"},
+	{account.ErrInvalidPassword, http.StatusBadRequest, "invalid_password"},
+	{account.ErrNameTaken, http.StatusConflict, "name_taken"},
+	{account.ErrEmailTaken, http.StatusConflict, "email_taken"},
+	{account.ErrInvalidCredentials, http.StatusUnauthorized, "invalid_credentials"},
+	{account.ErrUnauthenticated, http.StatusUnauthorized, "unauthenticated"},
+	{account.ErrSessionNotFound, http.StatusNotFound, "session_not_found"},
+	{account.ErrInvalidResetToken, http.StatusBadRequest, "invalid_reset_token"},
+	{errWrongUser, http.StatusForbidden, "wrong_user"},
+	{errNotYourSeat, http.StatusForbidden, "not_your_seat"},
+	{account.ErrInvalidScope, http.StatusBadRequest, "invalid_scope"},
+	{account.ErrTokenNotFound, http.StatusNotFound, "token_not_found"},
+	{account.ErrTooManyTokens, http.StatusConflict, "too_many_tokens"},
+	{errMissingScope, http.StatusForbidden, "missing_scope"},
+	{errSessionRequired, http.StatusForbidden, "session_required"},
+	{account.ErrAlreadyBot, http.StatusConflict, "already_bot"},
+	{errNotBot, http.StatusForbidden, "not_a_bot"},
+	{errBotNotAllowed, http.StatusForbidden, "bot_not_allowed"},
+	{errNotPlayer, http.StatusForbidden, "not_a_player"},
+	{game.ErrInvalidChat, http.StatusBadRequest, "invalid_chat"},
+	{game.ErrChatTooFast, http.StatusTooManyRequests, "chat_too_fast"},
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// classify maps err to its HTTP status and the body clients see.
+func classify(err error) (int, apiError) {
+	for _, e := range errorCodes {
+		if errors.Is(err, e.err) {
+			return e.status, apiError{Code: e.code, Message: err.Error()}
+		}
+	}
+	return http.StatusInternalServerError, apiError{Code: "internal", Message: "internal server error"}
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	status, body := classify(err)
+	writeJSON(w, status, errorBody{body})
+}
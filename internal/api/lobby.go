@@ -0,0 +1,304 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/THECHAMP95821/chess-backend/internal/lobby"
+)
+
+// SetLobby serves seeks and challenges through l.
+func (s *Server) SetLobby(l *lobby.Lobby) {
+	s.lobby = l
+}
+
+type termsRequest struct {
+	TimeControl string `json:"time_control"`
+	Variant     string `json:"variant"`
+	Rated       bool   `json:"rated"`
+	Color       string `json:"color"`
+	FEN         string `json:"fen"`
+}
+
+func (req termsRequest) terms() lobby.Terms {
+	return lobby.Terms(req)
+}
+
+type userRequest struct {
+	UserID string `json:"user_id"`
+}
+
+func (req userRequest) user() (string, error) {
+	if req.UserID == "" {
+		return "", fmt.Errorf("%w: user_id is required", errBadRequest)
+	}
+	return req.UserID, nil
+}
+
+// decodeUser reads a request body naming the acting user.
+func (s *Server) decodeUser(r *http.Request) (string, error) {
+	var req userRequest
+	if err := decode(r, &req); err != nil {
+		return "", err
+	}
+	return s.actingUser(r, req.UserID)
+}
+
+func (s *Server) queryUser(r *http.Request) (string, error) {
+	return s.actingUser(r, r.URL.Query().Get("user_id"))
+}
+
+// withLobby answers requests with errUnavailable while there is no lobby.
+func (s *Server) withLobby(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.lobby == nil {
+			writeError(w, errUnavailable)
+			return
+		}
+		h(w, r)
+	}
+}
+
+func (s *Server) handleListSeeks(w http.ResponseWriter, r *http.Request) {
+	seeks, err := s.lobby.Seeks(r.Context())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string][]lobby.Seek{"seeks": seeks})
+}
+
+type seekRequest struct {
+	userRequest
+	termsRequest
+	MinRating float64 `json:"min_rating"`
+	MaxRating float64 `json:"max_rating"`
+}
+
+func (s *Server) handlePostSeek(w http.ResponseWriter, r *http.Request) {
+	var req seekRequest
+	if err := decode(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+	user, err := s.actingUser(r, req.UserID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := s.refuseBots(r); err != nil {
+		writeError(w, err)
+		return
+	}
+	seek, err := s.lobby.Post(r.Context(), lobby.Seek{
+		UserID:    user,
+		Terms:     req.terms(),
+		MinRating: req.MinRating,
+		MaxRating: req.MaxRating,
+	})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, seek)
+}
+
+func (s *Server) handleWithdrawSeek(w http.ResponseWriter, r *http.Request) {
+	user, err := s.queryUser(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := s.lobby.Withdraw(r.Context(), r.PathValue("id"), user); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleAcceptSeek(w http.ResponseWriter, r *http.Request) {
+	user, err := s.decodeUser(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := s.refuseBots(r); err != nil {
+		writeError(w, err)
+		return
+	}
+	v, err := s.lobby.AcceptSeek(r.Context(), r.PathValue("id"), user)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, v)
+}
+
+type challengeRequest struct {
+	userRequest
+	termsRequest
+	To string `json:"to"`
+}
+
+func (s *Server) handleChallenge(w http.ResponseWriter, r *http.Request) {
+	var req challengeRequest
+	if err := decode(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+	user, err := s.actingUser(r, req.UserID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if req.To == "" {
+		writeError(w, fmt.Errorf("%w: to is required", errBadRequest))
+		return
+	}
+	c, err := s.lobby.Challenge(r.Context(), lobby.Challenge{From: user, To: req.To, Terms: req.terms()})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, c)
+}
+
+func (s *Server) handleGetChallenge(w http.ResponseWriter, r *http.Request) {
+	user, err := s.queryUser(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	c, err := s.lobby.GetChallenge(r.Context(), r.PathValue("id"), user)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, c)
+}
+
+func (s *Server) handleAcceptChallenge(w http.ResponseWriter, r *http.Request) {
+	user, err := s.decodeUser(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	v, err := s.lobby.AcceptChallenge(r.Context(), r.PathValue("id"), user)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, v)
+}
+
+type declineRequest struct {
+	userRequest
+	Reason string `json:"reason"`
+}
+
+func (s *Server) handleDeclineChallenge(w http.ResponseWriter, r *http.Request) {
+	var req declineRequest
+	if err := decode(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+	user, err := s.actingUser(r, req.UserID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	c, err := s.lobby.DeclineChallenge(r.Context(), r.PathValue("id"), user, req.Reason)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, c)
+}
+
+func (s *Server) handleCancelChallenge(w http.ResponseWriter, r *http.Request) {
+	user, err := s.decodeUser(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	c, err := s.lobby.CancelChallenge(r.Context(), r.PathValue("id"), user)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, c)
+}
+
+type lobbySnapshotMessage struct {
+	Type string `json:"type"`
+	lobby.Snapshot
+}
+
+// handleLobbySocket streams the lobby as ?user_id= sees it: a snapshot,
+// then every change. The socket is read only to notice the client leaving.
+func (s *Server) handleLobbySocket(w http.ResponseWriter, r *http.Request) {
+	user, err := s.queryUser(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	snap, sub, err := s.lobby.Subscribe(r.Context(), user)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer sub.Close()
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		conn.SetReadLimit(wsMaxMessage)
+		conn.SetReadDeadline(time.Now().Add(wsPongWait))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(wsPongWait))
+		})
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ping := time.NewTicker(wsPingPeriod)
+	defer ping.Stop()
+	write := func(v any) error {
+		conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+		return conn.WriteJSON(v)
+	}
+	if err := write(lobbySnapshotMessage{Type: "snapshot", Snapshot: snap}); err != nil {
+		return
+	}
+	for {
+		var err error
+		select {
+		case <-done:
+			return
+		case e, ok := <-sub.C:
+			if !ok {
+				conn.WriteControl(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "resubscribe"),
+					time.Now().Add(wsWriteWait))
+				return
+			}
+			err = write(e)
+		case <-ping.C:
+			err = conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteWait))
+		}
+		if err != nil {
+			return
+		}
+	}
+}
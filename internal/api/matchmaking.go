@@ -0,0 +1,91 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/THECHAMP95821/chess-backend/internal/matchmaking"
+)
+
+var errUnavailable = errors.New("not available on this server")
+
+// SetMatchmaking serves quick pairing through m.
+func (s *Server) SetMatchmaking(m *matchmaking.Service) {
+	s.matchmaking = m
+}
+
+type quickPairingRequest struct {
+	UserID      string `json:"user_id"`
+	TimeControl string `json:"time_control"`
+	Variant     string `json:"variant"`
+	Rated       bool   `json:"rated"`
+}
+
+// handleQuickPairing queues a player. They then poll their status until it
+// names the game they were paired into.
+func (s *Server) handleQuickPairing(w http.ResponseWriter, r *http.Request) {
+	if s.matchmaking == nil {
+		writeError(w, errUnavailable)
+		return
+	}
+	var req quickPairingRequest
+	if err := decode(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+	user, err := s.actingUser(r, req.UserID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := s.refuseBots(r); err != nil {
+		writeError(w, err)
+		return
+	}
+	t, err := s.matchmaking.Join(r.Context(), matchmaking.Request{
+		UserID:      user,
+		TimeControl: req.TimeControl,
+		Variant:     req.Variant,
+		Rated:       req.Rated,
+	})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, matchmaking.Status{Waiting: &t})
+}
+
+func (s *Server) handlePairingStatus(w http.ResponseWriter, r *http.Request) {
+	if s.matchmaking == nil {
+		writeError(w, errUnavailable)
+		return
+	}
+	user, err := s.actingUser(r, r.PathValue("user"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	st, err := s.matchmaking.Status(r.Context(), user)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, st)
+}
+
+func (s *Server) handleCancelPairing(w http.ResponseWriter, r *http.Request) {
+	if s.matchmaking == nil {
+		writeError(w, errUnavailable)
+		return
+	}
+	user, err := s.actingUser(r, r.PathValue("user"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := s.matchmaking.Leave(r.Context(), user); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
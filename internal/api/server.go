@@ -0,0 +1,293 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/THECHAMP95821/chess-backend/internal/account"
+	"github.com/THECHAMP95821/chess-backend/internal/arena"
+	"github.com/THECHAMP95821/chess-backend/internal/bot"
+	"github.com/THECHAMP95821/chess-backend/internal/chess"
+	"github.com/THECHAMP95821/chess-backend/internal/fanout"
+	"github.com/THECHAMP95821/chess-backend/internal/game"
+	"github.com/THECHAMP95821/chess-backend/internal/lobby"
+	"github.com/THECHAMP95821/chess-backend/internal/matchmaking"
+	"github.com/THECHAMP95821/chess-backend/internal/tournament"
+)
+
+type Server struct {
+	games *game.Service
+	hub   *fanout.Hub
+	mux   *http.ServeMux
+
+	matchmaking *matchmaking.Service
+	lobby       *lobby.Lobby
+	tournaments *tournament.Service
+	arenas      *arena.Service
+	accounts    *account.Service
+	bots        *bot.Feed
+
+	// streams is done once long-lived responses must end.
+	streams      context.Context
+	closeStreams context.CancelFunc
+}
+
+func NewServer(games *game.Service) *Server {
+	s := &Server{
+		games: games,
+		mux:   http.NewServeMux(),
+	}
+	s.streams, s.closeStreams = context.WithCancel(context.Background())
+	s.routes()
+	return s
+}
+
+// CloseStreams ends every open NDJSON stream, now and in future.
+// http.Server.Shutdown waits for them otherwise, so register it with
+// RegisterOnShutdown.
+func (s *Server) CloseStreams() {
+	s.closeStreams()
+}
+
+// SetHub lets spectators watch games hosted on other nodes through h.
+func (s *Server) SetHub(h *fanout.Hub) {
+	s.hub = h
+}
+
+func (s *Server) routes() {
+	s.mux.HandleFunc("POST /api/games", s.scoped(account.ScopePlayGames, s.handleCreateGame))
+	s.mux.HandleFunc("GET /api/games/{id}", s.scoped(account.ScopeReadGames, s.handleGetGame))
+	s.mux.HandleFunc("POST /api/games/{id}/moves", s.scoped(account.ScopePlayGames, s.handleMove))
+	s.mux.HandleFunc("GET /api/games/{id}/log", s.scoped(account.ScopeReadGames, s.handleGameLog))
+	s.mux.HandleFunc("GET /api/games/{id}/moves/{square}", s.scoped(account.ScopeReadGames, s.handleLegalMovesFrom))
+	s.mux.HandleFunc("POST /api/games/{id}/resign", s.scoped(account.ScopePlayGames, s.handleResign))
+	s.mux.HandleFunc("POST /api/games/{id}/draw/offer", s.scoped(account.ScopePlayGames, s.handleOfferDraw))
+	s.mux.HandleFunc("POST /api/games/{id}/draw/accept", s.scoped(account.ScopePlayGames, s.handleAcceptDraw))
+	s.mux.HandleFunc("POST /api/games/{id}/draw/decline", s.scoped(account.ScopePlayGames, s.handleDeclineDraw))
+	s.mux.HandleFunc("POST /api/games/{id}/takeback/offer", s.scoped(account.ScopePlayGames, s.handleOfferTakeback))
+	s.mux.HandleFunc("POST /api/games/{id}/takeback/accept", s.scoped(account.ScopePlayGames, s.handleAcceptTakeback))
+	s.mux.HandleFunc("POST /api/games/{id}/takeback/decline", s.scoped(account.ScopePlayGames, s.handleDeclineTakeback))
+	s.mux.HandleFunc("POST /api/games/{id}/berserk", s.scoped(account.ScopePlayGames, s.handleBerserk))
+	s.mux.HandleFunc("GET /api/games/{id}/ws", s.handleWebSocket)
+	s.mux.HandleFunc("POST /api/pairing", s.scoped(account.ScopePlayGames, s.handleQuickPairing))
+	s.mux.HandleFunc("GET /api/pairing/{user}", s.scoped(account.ScopePlayGames, s.handlePairingStatus))
+	s.mux.HandleFunc("DELETE /api/pairing/{user}", s.scoped(account.ScopePlayGames, s.handleCancelPairing))
+	s.mux.HandleFunc("GET /api/lobby/seeks", s.scoped(account.ScopeReadGames, s.withLobby(s.handleListSeeks)))
+	s.mux.HandleFunc("POST /api/lobby/seeks", s.scoped(account.ScopeChallenges, s.withLobby(s.handlePostSeek)))
+	s.mux.HandleFunc("DELETE /api/lobby/seeks/{id}", s.scoped(account.ScopeChallenges, s.withLobby(s.handleWithdrawSeek)))
+	s.mux.HandleFunc("POST /api/lobby/seeks/{id}/accept", s.scoped(account.ScopeChallenges, s.withLobby(s.handleAcceptSeek)))
+	s.mux.HandleFunc("GET /api/lobby/ws", s.scoped(account.ScopeReadGames, s.withLobby(s.handleLobbySocket)))
+	s.mux.HandleFunc("POST /api/challenges", s.scoped(account.ScopeChallenges, s.withLobby(s.handleChallenge)))
+	s.mux.HandleFunc("GET /api/challenges/{id}", s.scoped(account.ScopeChallenges, s.withLobby(s.handleGetChallenge)))
+	s.mux.HandleFunc("POST /api/challenges/{id}/accept", s.scoped(account.ScopeChallenges, s.withLobby(s.handleAcceptChallenge)))
+	s.mux.HandleFunc("POST /api/challenges/{id}/decline", s.scoped(account.ScopeChallenges, s.withLobby(s.handleDeclineChallenge)))
+	s.mux.HandleFunc("POST /api/challenges/{id}/cancel", s.scoped(account.ScopeChallenges, s.withLobby(s.handleCancelChallenge)))
+	s.mux.HandleFunc("POST /api/tournaments", s.scoped(account.ScopeTournaments, s.withTournaments(s.handleCreateTournament)))
+	s.mux.HandleFunc("GET /api/tournaments/{id}", s.scoped(account.ScopeReadGames, s.withTournaments(s.handleGetTournament)))
+	s.mux.HandleFunc("POST /api/tournaments/{id}/join", s.scoped(account.ScopeTournaments, s.withTournaments(s.handleJoinTournament)))
+	s.mux.HandleFunc("POST /api/tournaments/{id}/withdraw", s.scoped(account.ScopeTournaments, s.withTournaments(s.handleWithdrawTournament)))
+	s.mux.HandleFunc("POST /api/tournaments/{id}/start", s.scoped(account.ScopeTournaments, s.withTournaments(s.handleStartTournament)))
+	s.mux.HandleFunc("POST /api/tournaments/{id}/rounds/{round}/boards/{board}/result", s.scoped(account.ScopeTournaments, s.withTournaments(s.handleSetResult)))
+	s.mux.HandleFunc("GET /api/tournaments/{id}/trf", s.scoped(account.ScopeReadGames, s.withTournaments(s.handleTournamentReport)))
+	s.mux.HandleFunc("POST /api/tournaments/trf", s.scoped(account.ScopeTournaments, s.withTournaments(s.handleImportTournament)))
+	s.mux.HandleFunc("POST /api/arenas", s.scoped(account.ScopeTournaments, s.withArenas(s.handleCreateArena)))
+	s.mux.HandleFunc("GET /api/arenas/{id}", s.scoped(account.ScopeReadGames, s.withArenas(s.handleGetArena)))
+	s.mux.HandleFunc("POST /api/arenas/{id}/join", s.scoped(account.ScopeTournaments, s.withArenas(s.handleJoinArena)))
+	s.mux.HandleFunc("POST /api/arenas/{id}/pause", s.scoped(account.ScopeTournaments, s.withArenas(s.handlePauseArena)))
+	s.mux.HandleFunc("POST /api/auth/register", s.withAccounts(s.handleRegister))
+	s.mux.HandleFunc("POST /api/auth/login", s.withAccounts(s.handleLogin))
+	s.mux.HandleFunc("POST /api/auth/logout", s.sessionOnly(s.handleLogout))
+	s.mux.HandleFunc("GET /api/auth/me", s.loggedIn(s.handleMe))
+	s.mux.HandleFunc("GET /api/auth/sessions", s.sessionOnly(s.handleListSessions))
+	s.mux.HandleFunc("DELETE /api/auth/sessions", s.sessionOnly(s.handleRevokeAllSessions))
+	s.mux.HandleFunc("DELETE /api/auth/sessions/{id}", s.sessionOnly(s.handleRevokeSession))
+	s.mux.HandleFunc("GET /api/auth/tokens", s.sessionOnly(s.handleListTokens))
+	s.mux.HandleFunc("POST /api/auth/tokens", s.sessionOnly(s.handleCreateToken))
+	s.mux.HandleFunc("DELETE /api/auth/tokens/{id}", s.sessionOnly(s.handleRevokeToken))
+	s.mux.HandleFunc("POST /api/auth/password/forgot", s.withAccounts(s.handleForgotPassword))
+	s.mux.HandleFunc("POST /api/auth/password/reset", s.withAccounts(s.handleResetPassword))
+	s.mux.HandleFunc("POST /api/bot/account/upgrade", s.scoped(account.ScopePlayGames, s.loggedIn(s.handleUpgradeToBot)))
+	s.mux.HandleFunc("GET /api/bot/stream/event", s.botAction(account.ScopePlayGames, s.handleBotEvents))
+	s.mux.HandleFunc("GET /api/bot/game/stream/{id}", s.botAction(account.ScopePlayGames, s.handleBotGameStream))
+	s.mux.HandleFunc("POST /api/bot/game/{id}/move/{move}", s.botAction(account.ScopePlayGames, s.handleBotMove))
+	s.mux.HandleFunc("POST /api/bot/game/{id}/resign", s.botAction(account.ScopePlayGames, s.handleBotResign))
+	s.mux.HandleFunc("POST /api/bot/game/{id}/chat", s.botAction(account.ScopePlayGames, s.handleBotChat))
+	s.mux.HandleFunc("POST /api/bot/challenge/{id}/accept", s.botAction(account.ScopeChallenges, s.handleBotAcceptChallenge))
+	s.mux.HandleFunc("POST /api/bot/challenge/{id}/decline", s.botAction(account.ScopeChallenges, s.handleBotDeclineChallenge))
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	r, ok := s.authenticate(w, r)
+	if !ok {
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+func decode(r *http.Request, v any) error {
+	if r.Body == nil {
+		return nil
+	}
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("%w: %v", errBadRequest, err)
+	}
+	return nil
+}
+
+type createGameRequest struct {
+	FEN         string `json:"fen"`
+	TimeControl string `json:"time_control"`
+}
+
+func (s *Server) handleCreateGame(w http.ResponseWriter, r *http.Request) {
+	var req createGameRequest
+	if err := decode(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+	v, err := s.games.Create(game.CreateOptions{FEN: req.FEN, TimeControl: req.TimeControl})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, v)
+}
+
+func (s *Server) handleGetGame(w http.ResponseWriter, r *http.Request) {
+	v, err := s.games.Get(r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, v)
+}
+
+// handleGameLog shows a game's event log and whether replaying it gives
+// the game, for audits.
+func (s *Server) handleGameLog(w http.ResponseWriter, r *http.Request) {
+	a, err := s.games.Audit(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, a)
+}
+
+type colorRequest struct {
+	Color string `json:"color"`
+}
+
+func (req colorRequest) color() (chess.Color, error) {
+	c, err := chess.ParseColor(req.Color)
+	if err != nil {
+		return c, fmt.Errorf("%w: %v", errBadRequest, err)
+	}
+	return c, nil
+}
+
+type moveRequest struct {
+	colorRequest
+	UCI    string `json:"uci"`
+	SAN    string `json:"san"`
+	Ply    *int   `json:"ply"`
+	MoveID string `json:"move_id"`
+}
+
+func (s *Server) handleMove(w http.ResponseWriter, r *http.Request) {
+	var req moveRequest
+	if err := decode(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+	c, err := req.color()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := s.takeSeat(r, r.PathValue("id"), c); err != nil {
+		writeError(w, err)
+		return
+	}
+	v, err := s.games.Move(r.PathValue("id"), game.MoveRequest{
+		Color:       c,
+		UCI:         req.UCI,
+		SAN:         req.SAN,
+		ExpectedPly: req.Ply,
+		ID:          req.MoveID,
+	})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, v)
+}
+
+func (s *Server) handleLegalMovesFrom(w http.ResponseWriter, r *http.Request) {
+	moves, err := s.games.LegalMovesFrom(r.PathValue("id"), r.PathValue("square"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string][]string{"moves": moves})
+}
+
+func (s *Server) colorAction(action func(id string, c chess.Color) (game.View, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req colorRequest
+		if err := decode(r, &req); err != nil {
+			writeError(w, err)
+			return
+		}
+		c, err := req.color()
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if err := s.takeSeat(r, r.PathValue("id"), c); err != nil {
+			writeError(w, err)
+			return
+		}
+		v, err := action(r.PathValue("id"), c)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, v)
+	}
+}
+
+func (s *Server) handleResign(w http.ResponseWriter, r *http.Request) {
+	s.colorAction(s.games.Resign)(w, r)
+}
+
+func (s *Server) handleBerserk(w http.ResponseWriter, r *http.Request) {
+	s.colorAction(s.games.Berserk)(w, r)
+}
+
+func (s *Server) handleOfferDraw(w http.ResponseWriter, r *http.Request) {
+	s.colorAction(s.games.OfferDraw)(w, r)
+}
+
+func (s *Server) handleAcceptDraw(w http.ResponseWriter, r *http.Request) {
+	s.colorAction(s.games.AcceptDraw)(w, r)
+}
+
+func (s *Server) handleDeclineDraw(w http.ResponseWriter, r *http.Request) {
+	s.colorAction(s.games.DeclineDraw)(w, r)
+}
+
+func (s *Server) handleOfferTakeback(w http.ResponseWriter, r *http.Request) {
+	s.colorAction(s.games.OfferTakeback)(w, r)
+}
+
+func (s *Server) handleAcceptTakeback(w http.ResponseWriter, r *http.Request) {
+	s.colorAction(s.games.AcceptTakeback)(w, r)
+}
+
+func (s *Server) handleDeclineTakeback(w http.ResponseWriter, r *http.Request) {
+	s.colorAction(s.games.DeclineTakeback)(w, r)
+}
@@ -0,0 +1,154 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/THECHAMP95821/chess-backend/internal/game"
+	"github.com/THECHAMP95821/chess-backend/internal/matchmaking"
+)
+
+func do(t *testing.T, h http.Handler, method, path string, body any) (*httptest.ResponseRecorder, map[string]any) {
+	t.Helper()
+	var buf bytes.Buffer
+	if body != nil {
+		json.NewEncoder(&buf).Encode(body)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(method, path, &buf))
+	var out map[string]any
+	json.Unmarshal(rec.Body.Bytes(), &out)
+	return rec, out
+}
+
+func errorCode(body map[string]any) string {
+	e, _ := body["error"].(map[string]any)
+	code, _ := e["code"].(string)
+	return code
+}
+
+func TestGameLifecycle(t *testing.T) {
+	h := NewServer(game.NewService())
+
+	rec, created := do(t, h, "POST", "/api/games", map[string]string{"time_control": "300+2"})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create: status %d, body %v", rec.Code, created)
+	}
+	id := created["id"].(string)
+	if got := len(created["legal_moves"].([]any)); got != 20 {
+		t.Errorf("legal moves at start = %d, want 20", got)
+	}
+
+	rec, body := do(t, h, "POST", "/api/games/"+id+"/moves", map[string]string{"color": "white", "uci": "e2e4"})
+	if rec.Code != http.StatusOK || body["side_to_move"] != "black" {
+		t.Fatalf("move: status %d, body %v", rec.Code, body)
+	}
+
+	rec, body = do(t, h, "POST", "/api/games/"+id+"/moves", map[string]string{"color": "white", "san": "d4"})
+	if rec.Code != http.StatusConflict || errorCode(body) != "not_your_turn" {
+		t.Errorf("out of turn: status %d, body %v", rec.Code, body)
+	}
+	rec, body = do(t, h, "POST", "/api/games/"+id+"/moves", map[string]string{"color": "black", "san": "e4"})
+	if rec.Code != http.StatusUnprocessableEntity || errorCode(body) != "illegal_move" {
+		t.Errorf("illegal move: status %d, body %v", rec.Code, body)
+	}
+	rec, body = do(t, h, "POST", "/api/games/"+id+"/moves", map[string]string{"color": "black", "san": "e5"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("e5: status %d, body %v", rec.Code, body)
+	}
+
+	rec, body = do(t, h, "GET", "/api/games/"+id+"/moves/g1", nil)
+	if rec.Code != http.StatusOK || len(body["moves"].([]any)) != 3 {
+		t.Errorf("moves from g1: status %d, body %v", rec.Code, body)
+	}
+
+	do(t, h, "POST", "/api/games/"+id+"/draw/offer", map[string]string{"color": "white"})
+	rec, body = do(t, h, "POST", "/api/games/"+id+"/draw/accept", map[string]string{"color": "white"})
+	if rec.Code != http.StatusConflict || errorCode(body) != "own_draw_offer" {
+		t.Errorf("accept own offer: status %d, body %v", rec.Code, body)
+	}
+	rec, body = do(t, h, "POST", "/api/games/"+id+"/draw/accept", map[string]string{"color": "black"})
+	outcome := body["outcome"].(map[string]any)
+	if rec.Code != http.StatusOK || outcome["result"] != "1/2-1/2" || outcome["termination"] != "agreement" {
+		t.Errorf("accept draw: status %d, body %v", rec.Code, body)
+	}
+
+	rec, body = do(t, h, "POST", "/api/games/"+id+"/resign", map[string]string{"color": "black"})
+	if rec.Code != http.StatusConflict || errorCode(body) != "game_over" {
+		t.Errorf("resign after draw: status %d, body %v", rec.Code, body)
+	}
+}
+
+func TestCreateGameErrors(t *testing.T) {
+	h := NewServer(game.NewService())
+	tests := []struct {
+		body any
+		code string
+	}{
+		{map[string]string{"fen": "8/8/8/8/8/8/8/8 w - - 0 1"}, "invalid_fen"},
+		{map[string]string{"time_control": "fast"}, "invalid_time_control"},
+		{"not an object", "bad_request"},
+	}
+	for _, tt := range tests {
+		rec, body := do(t, h, "POST", "/api/games", tt.body)
+		if rec.Code != http.StatusBadRequest || errorCode(body) != tt.code {
+			t.Errorf("create %v: status %d, body %v; want %s", tt.body, rec.Code, body, tt.code)
+		}
+	}
+
+	rec, body := do(t, h, "GET", "/api/games/missing", nil)
+	if rec.Code != http.StatusNotFound || errorCode(body) != "game_not_found" {
+		t.Errorf("get missing: status %d, body %v", rec.Code, body)
+	}
+}
+
+func TestCreateGameFromFEN(t *testing.T) {
+	h := NewServer(game.NewService())
+	fen := "6k1/5ppp/8/8/8/8/8/R5K1 w - - 0 1"
+	_, created := do(t, h, "POST", "/api/games", map[string]string{"fen": fen})
+	_, body := do(t, h, "POST", "/api/games/"+created["id"].(string)+"/moves", map[string]string{"color": "white", "san": "Ra8#"})
+	outcome := body["outcome"].(map[string]any)
+	if body["initial_fen"] != fen || outcome["result"] != "1-0" || outcome["termination"] != "checkmate" {
+		t.Errorf("body = %v", body)
+	}
+}
+
+func TestQuickPairing(t *testing.T) {
+	games := game.NewService()
+	h := NewServer(games)
+	pairing := matchmaking.NewService(matchmaking.NewMemory(), games)
+	h.SetMatchmaking(pairing)
+
+	for _, user := range []string{"alice", "bob"} {
+		rec, body := do(t, h, "POST", "/api/pairing", map[string]any{"user_id": user, "time_control": "180+2", "rated": true})
+		if rec.Code != http.StatusAccepted || body["waiting"] == nil {
+			t.Fatalf("join %s: status %d, body %v", user, rec.Code, body)
+		}
+	}
+	rec, body := do(t, h, "POST", "/api/pairing", map[string]any{"user_id": "alice", "time_control": "180+2"})
+	if rec.Code != http.StatusConflict || errorCode(body) != "already_queued" {
+		t.Errorf("join twice: status %d, body %v", rec.Code, body)
+	}
+
+	if err := pairing.Tick(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	rec, body = do(t, h, "GET", "/api/pairing/bob", nil)
+	match, _ := body["match"].(map[string]any)
+	if rec.Code != http.StatusOK || match["opponent"] != "alice" {
+		t.Fatalf("status: %d, body %v", rec.Code, body)
+	}
+	rec, body = do(t, h, "GET", "/api/games/"+match["game_id"].(string), nil)
+	if rec.Code != http.StatusOK || body["rated"] != true {
+		t.Errorf("paired game: status %d, body %v", rec.Code, body)
+	}
+
+	rec, body = do(t, h, "DELETE", "/api/pairing/carol", nil)
+	if rec.Code != http.StatusNotFound || errorCode(body) != "not_queued" {
+		t.Errorf("cancel without joining: status %d, body %v", rec.Code, body)
+	}
+}
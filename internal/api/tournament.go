@@ -0,0 +1,184 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/THECHAMP95821/chess-backend/internal/swiss"
+	"github.com/THECHAMP95821/chess-backend/internal/tournament"
+	"github.com/THECHAMP95821/chess-backend/internal/trf"
+)
+
+// SetTournaments serves tournaments through t.
+func (s *Server) SetTournaments(t *tournament.Service) {
+	s.tournaments = t
+}
+
+func (s *Server) withTournaments(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.tournaments == nil {
+			writeError(w, errUnavailable)
+			return
+		}
+		h(w, r)
+	}
+}
+
+type createTournamentRequest struct {
+	Name        string              `json:"name"`
+	Format      string              `json:"format"`
+	TimeControl string              `json:"time_control"`
+	Rated       bool                `json:"rated"`
+	Rounds      int                 `json:"rounds"`
+	ByePoints   float64             `json:"bye_points"`
+	Tiebreaks   []swiss.Tiebreak    `json:"tiebreaks"`
+	Cycles      int                 `json:"cycles"`
+	Knockout    tournament.Knockout `json:"knockout"`
+}
+
+func (s *Server) handleCreateTournament(w http.ResponseWriter, r *http.Request) {
+	var req createTournamentRequest
+	if err := decode(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+	by, err := s.organizer(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	v, err := s.tournaments.Create(r.Context(), tournament.Options{
+		Name:        req.Name,
+		Format:      tournament.Format(req.Format),
+		TimeControl: req.TimeControl,
+		Rated:       req.Rated,
+		Rounds:      req.Rounds,
+		ByePoints:   req.ByePoints,
+		Tiebreaks:   req.Tiebreaks,
+		Cycles:      req.Cycles,
+		Knockout:    req.Knockout,
+		Organizer:   by,
+	})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, v)
+}
+
+func (s *Server) handleGetTournament(w http.ResponseWriter, r *http.Request) {
+	v, err := s.tournaments.Get(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, v)
+}
+
+// tournamentAction serves a tournament change made by the user named in
+// the request body.
+func (s *Server) tournamentAction(w http.ResponseWriter, r *http.Request, action func(ctx context.Context, id, user string) (tournament.View, error)) {
+	user, err := s.decodeUser(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	v, err := action(r.Context(), r.PathValue("id"), user)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, v)
+}
+
+func (s *Server) handleJoinTournament(w http.ResponseWriter, r *http.Request) {
+	s.tournamentAction(w, r, s.tournaments.Join)
+}
+
+func (s *Server) handleWithdrawTournament(w http.ResponseWriter, r *http.Request) {
+	s.tournamentAction(w, r, s.tournaments.Withdraw)
+}
+
+func (s *Server) handleStartTournament(w http.ResponseWriter, r *http.Request) {
+	by, err := s.organizer(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	v, err := s.tournaments.Start(r.Context(), r.PathValue("id"), by)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, v)
+}
+
+type resultRequest struct {
+	Result swiss.Result `json:"result"`
+}
+
+func (s *Server) handleSetResult(w http.ResponseWriter, r *http.Request) {
+	by, err := s.organizer(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	round, err1 := strconv.Atoi(r.PathValue("round"))
+	board, err2 := strconv.Atoi(r.PathValue("board"))
+	if err1 != nil || err2 != nil {
+		writeError(w, fmt.Errorf("%w: round and board must be numbers", errBadRequest))
+		return
+	}
+	var req resultRequest
+	if err := decode(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+	v, err := s.tournaments.SetResult(r.Context(), r.PathValue("id"), by, round, board, req.Result)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, v)
+}
+
+// maxReport bounds the size of an uploaded TRF report.
+const maxReport = 1 << 20
+
+func (s *Server) handleTournamentReport(w http.ResponseWriter, r *http.Request) {
+	rep, err := s.tournaments.Report(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", r.PathValue("id")+".trf"))
+	trf.Write(w, rep)
+}
+
+// handleImportTournament takes a TRF report as the request body.
+func (s *Server) handleImportTournament(w http.ResponseWriter, r *http.Request) {
+	by, err := s.organizer(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	rep, err := trf.Read(http.MaxBytesReader(w, r.Body, maxReport))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			err = fmt.Errorf("%w: %v", errBadRequest, err)
+		}
+		writeError(w, err)
+		return
+	}
+	v, err := s.tournaments.Import(r.Context(), rep, by)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, v)
+}
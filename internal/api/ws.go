@@ -0,0 +1,260 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/THECHAMP95821/chess-backend/internal/account"
+	"github.com/THECHAMP95821/chess-backend/internal/chess"
+	"github.com/THECHAMP95821/chess-backend/internal/game"
+)
+
+var errSpectator = errors.New("spectators cannot act on a game")
+
+const (
+	wsWriteWait  = 10 * time.Second
+	wsPongWait   = 60 * time.Second
+	wsPingPeriod = wsPongWait * 9 / 10
+	wsMaxMessage = 4096
+	wsSendBuffer = 16
+)
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+}
+
+// clientMessage is anything a client sends over the game socket.
+type clientMessage struct {
+	Type   string `json:"type"`
+	UCI    string `json:"uci,omitempty"`
+	SAN    string `json:"san,omitempty"`
+	Ply    *int   `json:"ply,omitempty"`
+	MoveID string `json:"move_id,omitempty"`
+}
+
+type snapshotMessage struct {
+	Type string `json:"type"`
+	game.View
+}
+
+type errorMessage struct {
+	Type  string   `json:"type"`
+	Error apiError `json:"error"`
+}
+
+// handleWebSocket joins a game channel. Players pass ?color=white|black and
+// may submit moves and offers; without a color the connection only watches.
+// The first message is a snapshot, followed by every event after its seq.
+// A client reconnecting with ?since=<seq> instead gets just the events it
+// missed, or a snapshot if it is too far behind.
+func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if err := allow(r, account.ScopeReadGames); err != nil {
+		writeError(w, err)
+		return
+	}
+	var player *chess.Color
+	if q := r.URL.Query().Get("color"); q != "" {
+		c, err := colorRequest{Color: q}.color()
+		if err == nil {
+			err = allow(r, account.ScopePlayGames)
+		}
+		if err == nil {
+			err = s.takeSeat(r, id, c)
+		}
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		player = &c
+	}
+
+	var first []any
+	var sub *game.Subscription
+	var err error
+	if q := r.URL.Query().Get("since"); q != "" {
+		since, perr := strconv.ParseUint(q, 10, 64)
+		if perr != nil {
+			writeError(w, fmt.Errorf("%w: invalid since %q", errBadRequest, q))
+			return
+		}
+		var catchup game.Catchup
+		catchup, sub, err = s.games.Resume(id, since, player != nil)
+		if err == nil {
+			if catchup.Snapshot != nil {
+				first = append(first, snapshotMessage{Type: "snapshot", View: *catchup.Snapshot})
+			}
+			for _, e := range catchup.Missed {
+				first = append(first, e)
+			}
+		}
+	} else {
+		var snapshot game.View
+		snapshot, sub, err = s.games.Subscribe(id, player != nil)
+		first = []any{snapshotMessage{Type: "snapshot", View: snapshot}}
+	}
+	if errors.Is(err, game.ErrGameNotFound) && player == nil && s.hub != nil {
+		s.watchRemote(w, r, id)
+		return
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer sub.Close()
+
+	s.serveSocket(w, r, id, player, first, sub.C)
+}
+
+// watchRemote serves a spectator of a game hosted on another node from the
+// events that node publishes. The first message is the latest of them.
+func (s *Server) watchRemote(w http.ResponseWriter, r *http.Request, id string) {
+	last, watcher, err := s.hub.Watch(r.Context(), id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer watcher.Close()
+
+	s.serveSocket(w, r, id, nil, []any{last}, watcher.C)
+}
+
+func (s *Server) serveSocket(w http.ResponseWriter, r *http.Request, id string, player *chess.Color, first []any, events <-chan game.Event) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	if player != nil {
+		release, err := s.games.Connect(id, *player)
+		if err != nil {
+			return
+		}
+		defer release()
+	}
+
+	replies := make(chan errorMessage, wsSendBuffer)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.readSocket(conn, id, player, replies)
+	}()
+
+	s.writeSocket(conn, first, events, replies, done)
+}
+
+// writeSocket is the only goroutine writing to conn. It returns when the
+// reader stops or the event stream is cut off, because the client fell
+// behind or the game moved to another node; either way the client is
+// expected to reconnect and resume from the last seq it saw.
+func (s *Server) writeSocket(conn *websocket.Conn, first []any, events <-chan game.Event, replies <-chan errorMessage, done <-chan struct{}) {
+	ping := time.NewTicker(wsPingPeriod)
+	defer ping.Stop()
+
+	write := func(v any) error {
+		conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+		return conn.WriteJSON(v)
+	}
+	for _, msg := range first {
+		if err := write(msg); err != nil {
+			return
+		}
+	}
+	for {
+		var err error
+		select {
+		case <-done:
+			return
+		case e, ok := <-events:
+			if !ok {
+				conn.WriteControl(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "resubscribe"),
+					time.Now().Add(wsWriteWait))
+				return
+			}
+			err = write(e)
+		case msg := <-replies:
+			err = write(msg)
+		case <-ping.C:
+			err = conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteWait))
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (s *Server) readSocket(conn *websocket.Conn, id string, player *chess.Color, replies chan<- errorMessage) {
+	conn.SetReadLimit(wsMaxMessage)
+	conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		var msg clientMessage
+		if err = json.Unmarshal(data, &msg); err != nil {
+			err = fmt.Errorf("%w: %v", errBadRequest, err)
+		} else {
+			err = s.dispatch(id, player, msg)
+		}
+		if err != nil {
+			_, body := classify(err)
+			select {
+			case replies <- errorMessage{Type: "error", Error: body}:
+			default:
+			}
+		}
+	}
+}
+
+// dispatch applies a client message. Successful actions are reported back
+// through the event stream like everyone else's, so only errors are returned.
+func (s *Server) dispatch(id string, player *chess.Color, msg clientMessage) error {
+	if player == nil {
+		return errSpectator
+	}
+	c := *player
+	var err error
+	switch msg.Type {
+	case "move":
+		_, err = s.games.Move(id, game.MoveRequest{
+			Color:       c,
+			UCI:         msg.UCI,
+			SAN:         msg.SAN,
+			ExpectedPly: msg.Ply,
+			ID:          msg.MoveID,
+		})
+	case "resign":
+		_, err = s.games.Resign(id, c)
+	case "draw_offer":
+		_, err = s.games.OfferDraw(id, c)
+	case "draw_accept":
+		_, err = s.games.AcceptDraw(id, c)
+	case "draw_decline":
+		_, err = s.games.DeclineDraw(id, c)
+	case "takeback_offer":
+		_, err = s.games.OfferTakeback(id, c)
+	case "takeback_accept":
+		_, err = s.games.AcceptTakeback(id, c)
+	case "takeback_decline":
+		_, err = s.games.DeclineTakeback(id, c)
+	case "berserk":
+		_, err = s.games.Berserk(id, c)
+	default:
+		err = fmt.Errorf("%w: unknown message type %q", errBadRequest, msg.Type)
+	}
+	return err
+}
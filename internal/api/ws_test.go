@@ -0,0 +1,151 @@
+package api
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/THECHAMP95821/chess-backend/internal/chess"
+	"github.com/THECHAMP95821/chess-backend/internal/game"
+	"github.com/THECHAMP95821/chess-backend/internal/lobby"
+)
+
+func dial(t *testing.T, srv *httptest.Server, path string) *websocket.Conn {
+	t.Helper()
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+path, nil)
+	if err != nil {
+		t.Fatalf("dial %s: %v", path, err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func readMessage(t *testing.T, conn *websocket.Conn) map[string]any {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var msg map[string]any
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	return msg
+}
+
+func TestWebSocketPlay(t *testing.T) {
+	games := game.NewService()
+	v, err := games.Create(game.CreateOptions{TimeControl: "60+1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(NewServer(games))
+	defer srv.Close()
+
+	white := dial(t, srv, "/api/games/"+v.ID+"/ws?color=white")
+	watcher := dial(t, srv, "/api/games/"+v.ID+"/ws")
+	for _, conn := range []*websocket.Conn{white, watcher} {
+		if msg := readMessage(t, conn); msg["type"] != "snapshot" || msg["seq"] != float64(0) {
+			t.Fatalf("first message = %v, want snapshot at seq 0", msg)
+		}
+	}
+
+	white.WriteJSON(clientMessage{Type: "move", UCI: "e2e4"})
+	for _, conn := range []*websocket.Conn{white, watcher} {
+		msg := readMessage(t, conn)
+		if msg["type"] != "move" || msg["seq"] != float64(1) || msg["ply"] != float64(1) {
+			t.Errorf("move event = %v", msg)
+		}
+	}
+
+	white.WriteJSON(clientMessage{Type: "move", UCI: "d2d4"})
+	if msg := readMessage(t, white); msg["type"] != "error" || errorCode(msg) != "not_your_turn" {
+		t.Errorf("out of turn reply = %v", msg)
+	}
+	watcher.WriteJSON(clientMessage{Type: "resign"})
+	if msg := readMessage(t, watcher); msg["type"] != "error" || errorCode(msg) != "spectator" {
+		t.Errorf("spectator reply = %v", msg)
+	}
+
+	white.WriteJSON(clientMessage{Type: "resign"})
+	msg := readMessage(t, watcher)
+	outcome, _ := msg["outcome"].(map[string]any)
+	if msg["type"] != "game_end" || msg["seq"] != float64(2) || outcome["result"] != "0-1" {
+		t.Errorf("game end event = %v", msg)
+	}
+}
+
+func TestWebSocketUnknownGame(t *testing.T) {
+	srv := httptest.NewServer(NewServer(game.NewService()))
+	defer srv.Close()
+
+	_, resp, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/api/games/nope/ws", nil)
+	if err == nil || resp == nil || resp.StatusCode != 404 {
+		t.Errorf("dial unknown game: err %v, resp %v", err, resp)
+	}
+}
+
+func TestWebSocketResume(t *testing.T) {
+	games := game.NewService()
+	v, _ := games.Create(game.CreateOptions{})
+	games.Move(v.ID, game.MoveRequest{Color: chess.ColorWhite, UCI: "e2e4"})
+	games.Move(v.ID, game.MoveRequest{Color: chess.ColorBlack, UCI: "c7c5"})
+	srv := httptest.NewServer(NewServer(games))
+	defer srv.Close()
+
+	conn := dial(t, srv, "/api/games/"+v.ID+"/ws?color=black&since=1")
+	msg := readMessage(t, conn)
+	move, _ := msg["move"].(map[string]any)
+	if msg["type"] != "move" || msg["seq"] != float64(2) || move["san"] != "c5" {
+		t.Errorf("first message after resume = %v, want the missed move", msg)
+	}
+
+	got, _ := games.Get(v.ID)
+	if !got.Presence["black"].Connected {
+		t.Errorf("presence = %+v, want black connected", got.Presence)
+	}
+}
+
+func TestLobbySocket(t *testing.T) {
+	games := game.NewService()
+	h := NewServer(games)
+	h.SetLobby(lobby.New(games))
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	bob := dial(t, srv, "/api/lobby/ws?user_id=bob")
+	if msg := readMessage(t, bob); msg["type"] != "snapshot" {
+		t.Fatalf("first message = %v, want snapshot", msg)
+	}
+
+	rec, seek := do(t, h, "POST", "/api/lobby/seeks", map[string]any{"user_id": "alice", "time_control": "300+0", "color": "white"})
+	if rec.Code != 201 {
+		t.Fatalf("post seek: status %d, body %v", rec.Code, seek)
+	}
+	if msg := readMessage(t, bob); msg["type"] != "seek_added" {
+		t.Errorf("after posting = %v, want seek_added", msg)
+	}
+	rec, v := do(t, h, "POST", "/api/lobby/seeks/"+seek["id"].(string)+"/accept", map[string]any{"user_id": "bob"})
+	if rec.Code != 201 || v["white_id"] != "alice" || v["black_id"] != "bob" {
+		t.Fatalf("accept seek: status %d, body %v", rec.Code, v)
+	}
+	if msg := readMessage(t, bob); msg["type"] != "seek_removed" || msg["game_id"] != v["id"] {
+		t.Errorf("after accepting = %v, want seek_removed with the game", msg)
+	}
+
+	rec, c := do(t, h, "POST", "/api/challenges", map[string]any{"user_id": "alice", "to": "bob", "time_control": "60+0"})
+	if rec.Code != 201 {
+		t.Fatalf("challenge: status %d, body %v", rec.Code, c)
+	}
+	if msg := readMessage(t, bob); msg["type"] != "challenge" {
+		t.Errorf("after challenging = %v, want challenge", msg)
+	}
+	rec, body := do(t, h, "POST", "/api/challenges/"+c["id"].(string)+"/decline", map[string]any{"user_id": "bob", "reason": "too_fast"})
+	if rec.Code != 200 || body["status"] != "declined" {
+		t.Errorf("decline: status %d, body %v", rec.Code, body)
+	}
+	rec, body = do(t, h, "POST", "/api/challenges/"+c["id"].(string)+"/accept", map[string]any{"user_id": "bob"})
+	if rec.Code != 409 || errorCode(body) != "challenge_closed" {
+		t.Errorf("accept after declining: status %d, body %v", rec.Code, body)
+	}
+}
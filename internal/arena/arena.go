@@ -0,0 +1,391 @@
+// Package arena runs arena tournaments: for a fixed time, players who are
+// not in a game are paired again as soon as possible, and the leaderboard
+// rewards winning streaks and berserk wins.
+package arena
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/THECHAMP95821/chess-backend/internal/clock"
+	"github.com/THECHAMP95821/chess-backend/internal/docstore"
+	"github.com/THECHAMP95821/chess-backend/internal/game"
+	"github.com/THECHAMP95821/chess-backend/internal/rating"
+)
+
+var (
+	ErrNotFound       = errors.New("arena not found")
+	ErrInvalidOptions = errors.New("invalid arena options")
+	ErrFinished       = errors.New("arena is over")
+	ErrAlreadyJoined  = errors.New("already in the arena")
+	ErrNotJoined      = errors.New("not in the arena")
+)
+
+type Status string
+
+const (
+	StatusCreated  Status = "created"
+	StatusRunning  Status = "running"
+	StatusFinished Status = "finished"
+)
+
+const (
+	// recentOpponents is how many of a player's last opponents pairing
+	// tries to avoid.
+	recentOpponents = 2
+	// rematchWait is how long a player waits before pairing gives up on
+	// avoiding recent opponents.
+	rematchWait = 20 * time.Second
+)
+
+// Options describe an arena. It starts at StartsAt, or at once if that is
+// zero, and stops pairing after Duration. NoBerserk keeps players from
+// going berserk.
+type Options struct {
+	Name        string        `json:"name"`
+	TimeControl string        `json:"time_control"`
+	Rated       bool          `json:"rated"`
+	StartsAt    time.Time     `json:"starts_at"`
+	Duration    time.Duration `json:"duration"`
+	NoBerserk   bool          `json:"no_berserk,omitempty"`
+	// Organizer is the user who created the arena, if any.
+	Organizer string `json:"organizer,omitempty"`
+}
+
+// Games starts arena games.
+type Games interface {
+	Create(opts game.CreateOptions) (game.View, error)
+}
+
+// Ratings looks up a player's current rating in a rating pool.
+type Ratings interface {
+	Current(ctx context.Context, userID string, pool rating.Pool) (rating.Rating, int, error)
+}
+
+type player struct {
+	id     string
+	rating float64
+	sheet  []Entry
+	// streak counts the wins in a row since the last game that was not one.
+	streak int
+	paused bool
+	// playing is the game the player is in, if any; idleSince is when they
+	// were last free to be paired.
+	playing   string
+	idleSince time.Time
+	recent    []string
+	// colorBalance is the number of games with White minus those with
+	// Black.
+	colorBalance int
+	lastWhite    bool
+}
+
+type arena struct {
+	id        string
+	opts      Options
+	pool      rating.Pool
+	status    Status
+	players   map[string]*player
+	endsAt    time.Time
+	createdAt time.Time
+}
+
+const (
+	arenasColl = "arenas"
+	// openColl lists the arenas that are not over yet, for Tick to visit.
+	openColl = "arenas_open"
+	// gamesColl finds the arena a game is played in.
+	gamesColl = "arena_games"
+)
+
+// Service runs the arenas kept in its store. Every change is made under
+// the arena's lock, so any number of nodes may share a store and all run
+// Run.
+type Service struct {
+	docs    docstore.Store
+	games   Games
+	ratings Ratings
+	now     func() time.Time
+}
+
+// NewService runs arenas whose games are created through games. GameEnded
+// must learn of every finished game, and Run must be running for arenas to
+// start, pair and finish.
+func NewService(games Games) *Service {
+	return &Service{
+		docs:  docstore.NewMemory(),
+		games: games,
+		now:   time.Now,
+	}
+}
+
+// SetStore keeps arenas in st. Without it they only live as long as this
+// process.
+func (s *Service) SetStore(st docstore.Store) {
+	s.docs = st
+}
+
+// SetRatings makes players join with their rating from r. Without it
+// everyone has the default rating.
+func (s *Service) SetRatings(r Ratings) {
+	s.ratings = r
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func (s *Service) Create(ctx context.Context, opts Options) (View, error) {
+	if opts.Duration <= 0 {
+		return View{}, fmt.Errorf("%w: duration must be positive", ErrInvalidOptions)
+	}
+	if opts.TimeControl == "" {
+		return View{}, fmt.Errorf("%w: arenas need a time control", ErrInvalidOptions)
+	}
+	tc, err := clock.ParseTimeControl(opts.TimeControl)
+	if err != nil {
+		return View{}, fmt.Errorf("%w: %v", game.ErrInvalidTime, err)
+	}
+	opts.TimeControl = tc.String()
+	pool, err := rating.PoolFor(opts.TimeControl, "")
+	if err != nil {
+		return View{}, err
+	}
+	now := s.now()
+	if opts.StartsAt.IsZero() {
+		opts.StartsAt = now
+	}
+	a := &arena{
+		id:        newID(),
+		opts:      opts,
+		pool:      pool,
+		status:    StatusCreated,
+		players:   make(map[string]*player),
+		endsAt:    opts.StartsAt.Add(opts.Duration),
+		createdAt: now,
+	}
+	s.settle(a, now)
+	if err := docstore.Save(ctx, s.docs, arenasColl, a.id, a); err != nil {
+		return View{}, err
+	}
+	if err := docstore.Save(ctx, s.docs, openColl, a.id, struct{}{}); err != nil {
+		return View{}, err
+	}
+	return a.view(), nil
+}
+
+func (s *Service) load(ctx context.Context, id string) (*arena, error) {
+	a := &arena{}
+	err := docstore.Load(ctx, s.docs, arenasColl, id, a)
+	if errors.Is(err, docstore.ErrNotFound) {
+		return nil, ErrNotFound
+	}
+	return a, err
+}
+
+// update changes arena id through fn under its lock, and saves it unless
+// fn fails.
+func (s *Service) update(ctx context.Context, id string, fn func(a *arena) error) (View, error) {
+	a := &arena{}
+	err := docstore.Update(ctx, s.docs, arenasColl, id, a, func() error { return fn(a) })
+	if errors.Is(err, docstore.ErrNotFound) {
+		return View{}, ErrNotFound
+	}
+	if err != nil {
+		return View{}, err
+	}
+	return a.view(), nil
+}
+
+func (s *Service) Get(ctx context.Context, id string) (View, error) {
+	a, err := s.load(ctx, id)
+	if err != nil {
+		return View{}, err
+	}
+	return a.view(), nil
+}
+
+// Join enters a player, or brings back one who paused. Players can join at
+// any time until the arena is over.
+func (s *Service) Join(ctx context.Context, id, userID string) (View, error) {
+	a, err := s.load(ctx, id)
+	if err != nil {
+		return View{}, err
+	}
+	r := rating.DefaultRating
+	if s.ratings != nil {
+		current, _, err := s.ratings.Current(ctx, userID, a.pool)
+		if err != nil {
+			return View{}, err
+		}
+		r = current.Rating
+	}
+
+	return s.update(ctx, id, func(a *arena) error {
+		if a.status == StatusFinished {
+			return ErrFinished
+		}
+		now := s.now()
+		if p, ok := a.players[userID]; ok {
+			if !p.paused {
+				return ErrAlreadyJoined
+			}
+			p.paused = false
+			p.idleSince = now
+			return nil
+		}
+		a.players[userID] = &player{id: userID, rating: r, idleSince: now}
+		return nil
+	})
+}
+
+// Pause stops a player being paired. They keep their place on the
+// leaderboard, and a game they are playing still counts.
+func (s *Service) Pause(ctx context.Context, id, userID string) (View, error) {
+	return s.update(ctx, id, func(a *arena) error {
+		p, ok := a.players[userID]
+		if !ok || p.paused {
+			return ErrNotJoined
+		}
+		if a.status == StatusFinished {
+			return ErrFinished
+		}
+		p.paused = true
+		return nil
+	})
+}
+
+// GameEnded scores a finished game, if it was played in an arena. Any node
+// may take it, whichever node the game was played on, and a game already
+// scored is not scored again. Games that end after their arena do not
+// count.
+func (s *Service) GameEnded(ctx context.Context, v game.View) error {
+	var id string
+	err := docstore.Load(ctx, s.docs, gamesColl, v.ID, &id)
+	if errors.Is(err, docstore.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	_, err = s.update(ctx, id, func(a *arena) error {
+		white, black := a.players[v.WhiteID], a.players[v.BlackID]
+		if white == nil || black == nil || white.playing != v.ID {
+			return nil
+		}
+		now := s.now()
+		for _, p := range []*player{white, black} {
+			p.playing = ""
+			p.idleSince = now
+		}
+		if a.status == StatusFinished {
+			return nil
+		}
+		var whiteBerserk, blackBerserk bool
+		if v.Clock != nil {
+			whiteBerserk, blackBerserk = v.Clock.WhiteBerserk, v.Clock.BlackBerserk
+		}
+		white.score(v.ID, black.id, true, whiteScore(v.Outcome.Result), whiteBerserk, v.Ply)
+		black.score(v.ID, white.id, false, 1-whiteScore(v.Outcome.Result), blackBerserk, v.Ply)
+		return nil
+	})
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	return s.docs.Delete(ctx, gamesColl, v.ID)
+}
+
+func whiteScore(result string) float64 {
+	switch result {
+	case "1-0":
+		return 1
+	case "0-1":
+		return 0
+	default:
+		return 0.5
+	}
+}
+
+// Tick starts arenas that are due, ends those whose time is up and pairs
+// the idle players of the rest.
+func (s *Service) Tick(ctx context.Context) {
+	ids, err := s.docs.IDs(ctx, openColl)
+	if err != nil {
+		log.Printf("arena: list open arenas: %v", err)
+		return
+	}
+	for _, id := range ids {
+		v, err := s.update(ctx, id, func(a *arena) error {
+			now := s.now()
+			s.settle(a, now)
+			if a.status == StatusRunning {
+				s.pairIdle(ctx, a, now)
+			}
+			return nil
+		})
+		if err != nil {
+			log.Printf("arena %s: %v", id, err)
+			continue
+		}
+		if v.Status == StatusFinished {
+			s.docs.Delete(ctx, openColl, id)
+		}
+	}
+}
+
+func (s *Service) settle(a *arena, now time.Time) {
+	if a.status == StatusCreated && !now.Before(a.opts.StartsAt) {
+		a.status = StatusRunning
+	}
+	if a.status == StatusRunning && !now.Before(a.endsAt) {
+		a.status = StatusFinished
+	}
+}
+
+// Run ticks every interval until ctx is done.
+func (s *Service) Run(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			s.Tick(ctx)
+		}
+	}
+}
+
+// pairIdle pairs players who are waiting for a game and starts their games.
+func (s *Service) pairIdle(ctx context.Context, a *arena, now time.Time) {
+	for _, pr := range pair(a.idle(), now) {
+		white, black := pr[0], pr[1]
+		v, err := s.games.Create(game.CreateOptions{
+			TimeControl: a.opts.TimeControl,
+			WhiteID:     white.id,
+			BlackID:     black.id,
+			Rated:       a.opts.Rated,
+			Berserk:     !a.opts.NoBerserk,
+		})
+		if err != nil {
+			// Both stay idle and are tried again on the next tick.
+			log.Printf("arena %s: %v", a.id, err)
+			continue
+		}
+		if err := docstore.Save(ctx, s.docs, gamesColl, v.ID, a.id); err != nil {
+			// The arena would never learn how the game ends. It goes on
+			// unscored, and both players stay free rather than stuck.
+			log.Printf("arena %s: game %s: %v", a.id, v.ID, err)
+			continue
+		}
+		white.startGame(v.ID, black.id, true)
+		black.startGame(v.ID, white.id, false)
+	}
+}
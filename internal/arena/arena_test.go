@@ -0,0 +1,166 @@
+package arena
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/THECHAMP95821/chess-backend/internal/chess"
+	"github.com/THECHAMP95821/chess-backend/internal/game"
+)
+
+func TestPoints(t *testing.T) {
+	for _, tc := range []struct {
+		score         float64
+		fire, berserk bool
+		moves, ply    int
+		want          int
+	}{
+		{1, false, false, 20, 40, 2},
+		{0.5, false, false, 20, 40, 1},
+		{0, false, false, 20, 40, 0},
+		{1, true, false, 20, 40, 4},
+		{0.5, true, false, 20, 40, 2},
+		{1, false, true, 20, 40, 3},
+		{1, true, true, 20, 40, 5},
+		{1, false, true, 6, 11, 2},
+		{0.5, false, false, 9, 19, 0},
+		{0.5, false, true, 20, 40, 1},
+	} {
+		if got := points(tc.score, tc.fire, tc.berserk, tc.moves, tc.ply); got != tc.want {
+			t.Errorf("points(%v, fire %v, berserk %v, %d moves) = %d, want %d", tc.score, tc.fire, tc.berserk, tc.moves, got, tc.want)
+		}
+	}
+}
+
+type fakeClock struct {
+	t time.Time
+}
+
+func (f *fakeClock) now() time.Time {
+	return f.t
+}
+
+func newTestService() (*Service, *game.Service, *fakeClock) {
+	fc := &fakeClock{t: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+	games := game.NewService()
+	s := NewService(games)
+	s.now = fc.now
+	games.OnGameEnd(func(v game.View) { s.GameEnded(context.Background(), v) })
+	return s, games, fc
+}
+
+func standing(v View, id string) Standing {
+	for _, st := range v.Leaderboard {
+		if st.PlayerID == id {
+			return st
+		}
+	}
+	return Standing{}
+}
+
+// win makes winner win the game they are playing.
+func win(t *testing.T, s *Service, games *game.Service, arenaID, winner string) {
+	t.Helper()
+	v, _ := s.Get(context.Background(), arenaID)
+	g, err := games.Get(standing(v, winner).Playing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loser := chess.ColorWhite
+	if g.WhiteID == winner {
+		loser = chess.ColorBlack
+	}
+	if _, err := games.Resign(g.ID, loser); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestArena(t *testing.T) {
+	ctx := context.Background()
+	s, games, fc := newTestService()
+	if _, err := s.Create(ctx, Options{TimeControl: "180+0"}); !errors.Is(err, ErrInvalidOptions) {
+		t.Errorf("no duration: err = %v", err)
+	}
+	v, err := s.Create(ctx, Options{Name: "hourly", TimeControl: "180+0", Duration: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Status != StatusRunning || !v.Berserk {
+		t.Fatalf("arena = %+v", v)
+	}
+	for _, user := range []string{"alice", "bob", "carol"} {
+		if _, err := s.Join(ctx, v.ID, user); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := s.Join(ctx, v.ID, "bob"); !errors.Is(err, ErrAlreadyJoined) {
+		t.Errorf("joining twice: err = %v", err)
+	}
+
+	s.Tick(ctx)
+	v, _ = s.Get(ctx, v.ID)
+	var playing, waiting []string
+	for _, st := range v.Leaderboard {
+		if st.Playing != "" {
+			playing = append(playing, st.PlayerID)
+		} else {
+			waiting = append(waiting, st.PlayerID)
+		}
+	}
+	if len(playing) != 2 || len(waiting) != 1 {
+		t.Fatalf("after the first tick %v play and %v wait", playing, waiting)
+	}
+
+	// The winner goes berserk and wins on move 1: no berserk point.
+	winner, loser := playing[0], playing[1]
+	g, _ := games.Get(standing(v, winner).Playing)
+	color := chess.ColorWhite
+	if g.BlackID == winner {
+		color = chess.ColorBlack
+	}
+	if _, err := games.Berserk(g.ID, color); err != nil {
+		t.Fatal(err)
+	}
+	win(t, s, games, v.ID, winner)
+	v, _ = s.Get(ctx, v.ID)
+	if st := standing(v, winner); st.Points != 2 || len(st.Sheet) != 1 || !st.Sheet[0].Berserk {
+		t.Errorf("winner = %+v", st)
+	}
+
+	// The leader is paired first, and not with their last opponent.
+	s.Tick(ctx)
+	v, _ = s.Get(ctx, v.ID)
+	g, _ = games.Get(standing(v, winner).Playing)
+	if g.WhiteID == loser || g.BlackID == loser || standing(v, loser).Playing != "" {
+		t.Errorf("second game %s vs %s, want %s against the third player", g.WhiteID, g.BlackID, winner)
+	}
+
+	// Two wins in a row set the winner on fire.
+	win(t, s, games, v.ID, winner)
+	v, _ = s.Get(ctx, v.ID)
+	if st := standing(v, winner); st.Points != 4 || !st.Fire || st.Rank != 1 {
+		t.Errorf("winner after two wins = %+v", st)
+	}
+	fc.t = fc.t.Add(rematchWait)
+	s.Tick(ctx)
+	win(t, s, games, v.ID, winner)
+	v, _ = s.Get(ctx, v.ID)
+	if st := standing(v, winner); st.Points != 8 || !st.Sheet[2].Fire {
+		t.Errorf("winner after a win on fire = %+v", st)
+	}
+
+	if _, err := s.Pause(ctx, v.ID, winner); err != nil {
+		t.Fatal(err)
+	}
+	fc.t = fc.t.Add(time.Hour)
+	s.Tick(ctx)
+	v, _ = s.Get(ctx, v.ID)
+	if v.Status != StatusFinished {
+		t.Errorf("status after an hour = %s", v.Status)
+	}
+	if _, err := s.Join(ctx, v.ID, winner); !errors.Is(err, ErrFinished) {
+		t.Errorf("join after the end: err = %v", err)
+	}
+}
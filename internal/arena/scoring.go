@@ -0,0 +1,180 @@
+package arena
+
+import (
+	"cmp"
+	"slices"
+	"time"
+)
+
+const (
+	// berserkMoves is how many moves a berserk player must make for a win
+	// to earn the berserk point.
+	berserkMoves = 7
+	// earlyDrawPly is the length below which a draw scores nothing.
+	earlyDrawPly = 20
+)
+
+// Entry is one game on a player's score sheet. Fire is set when the game
+// was played on a winning streak and so counted double.
+type Entry struct {
+	GameID   string  `json:"game_id"`
+	Opponent string  `json:"opponent"`
+	White    bool    `json:"white"`
+	Score    float64 `json:"score"`
+	Points   int     `json:"points"`
+	Berserk  bool    `json:"berserk,omitempty"`
+	Fire     bool    `json:"fire,omitempty"`
+}
+
+// points scores a game: two for a win and one for a draw, doubled on a
+// streak of two wins or more, plus one for winning berserk after making
+// at least berserkMoves moves. Quick draws score nothing.
+func points(score float64, fire, berserk bool, moves, ply int) int {
+	var pts int
+	switch {
+	case score == 1:
+		pts = 2
+	case score == 0.5 && ply >= earlyDrawPly:
+		pts = 1
+	}
+	if fire {
+		pts *= 2
+	}
+	if score == 1 && berserk && moves >= berserkMoves {
+		pts++
+	}
+	return pts
+}
+
+// onFire reports whether the next game counts double.
+func (p *player) onFire() bool {
+	return p.streak >= 2
+}
+
+// score adds a finished game to p's sheet. ply is the length of the game,
+// from the standard starting position.
+func (p *player) score(gameID, opponent string, white bool, score float64, berserk bool, ply int) {
+	moves := ply / 2
+	if white {
+		moves = (ply + 1) / 2
+	}
+	e := Entry{
+		GameID:   gameID,
+		Opponent: opponent,
+		White:    white,
+		Score:    score,
+		Berserk:  berserk,
+		Fire:     p.onFire(),
+	}
+	e.Points = points(score, e.Fire, berserk, moves, ply)
+	p.sheet = append(p.sheet, e)
+	if score == 1 {
+		p.streak++
+	} else {
+		p.streak = 0
+	}
+}
+
+func (p *player) startGame(gameID, opponent string, white bool) {
+	p.playing = gameID
+	p.recent = append(p.recent, opponent)
+	if len(p.recent) > recentOpponents {
+		p.recent = p.recent[1:]
+	}
+	p.lastWhite = white
+	if white {
+		p.colorBalance++
+	} else {
+		p.colorBalance--
+	}
+}
+
+func (p *player) points() int {
+	total := 0
+	for _, e := range p.sheet {
+		total += e.Points
+	}
+	return total
+}
+
+// performance is the rating at which p's results would be expected: the
+// average rating of their opponents, adjusted by 500 points per game for
+// the balance of wins and losses.
+func (a *arena) performance(p *player) int {
+	if len(p.sheet) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, e := range p.sheet {
+		if opp, ok := a.players[e.Opponent]; ok {
+			sum += opp.rating
+		}
+		sum += (e.Score*2 - 1) * 500
+	}
+	return int(sum / float64(len(p.sheet)))
+}
+
+// ranked orders players by points, then performance, then rating.
+func (a *arena) ranked() []*player {
+	players := make([]*player, 0, len(a.players))
+	for _, p := range a.players {
+		players = append(players, p)
+	}
+	perf := make(map[string]int, len(players))
+	for _, p := range players {
+		perf[p.id] = a.performance(p)
+	}
+	slices.SortFunc(players, func(x, y *player) int {
+		return cmp.Or(
+			cmp.Compare(y.points(), x.points()),
+			cmp.Compare(perf[y.id], perf[x.id]),
+			cmp.Compare(y.rating, x.rating),
+			cmp.Compare(x.id, y.id),
+		)
+	})
+	return players
+}
+
+// idle lists the players waiting for a game, in leaderboard order.
+func (a *arena) idle() []*player {
+	var idle []*player
+	for _, p := range a.ranked() {
+		if !p.paused && p.playing == "" {
+			idle = append(idle, p)
+		}
+	}
+	return idle
+}
+
+func (p *player) metRecently(q *player) bool {
+	return slices.Contains(p.recent, q.id) || slices.Contains(q.recent, p.id)
+}
+
+// pair matches idle players, given in leaderboard order, each with the
+// nearest player below them they have not met recently. Someone who has
+// waited rematchWait takes a recent opponent rather than wait any longer.
+// Each pair comes White first: the player who has had White less often,
+// or who had Black last.
+func pair(idle []*player, now time.Time) [][2]*player {
+	var pairs [][2]*player
+	taken := make([]bool, len(idle))
+	for i, p := range idle {
+		if taken[i] {
+			continue
+		}
+		impatient := now.Sub(p.idleSince) >= rematchWait
+		for j := i + 1; j < len(idle); j++ {
+			q := idle[j]
+			if taken[j] || (p.metRecently(q) && !impatient && now.Sub(q.idleSince) < rematchWait) {
+				continue
+			}
+			taken[i], taken[j] = true, true
+			if q.colorBalance < p.colorBalance || (q.colorBalance == p.colorBalance && p.lastWhite) {
+				p, q = q, p
+			}
+			pairs = append(pairs, [2]*player{p, q})
+			break
+		}
+	}
+	return pairs
+}
@@ -0,0 +1,88 @@
+package arena
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/THECHAMP95821/chess-backend/internal/rating"
+)
+
+// stored is an arena as it is kept in the store.
+type stored struct {
+	ID        string                  `json:"id"`
+	Options   Options                 `json:"options"`
+	Pool      rating.Pool             `json:"pool"`
+	Status    Status                  `json:"status"`
+	Players   map[string]storedPlayer `json:"players"`
+	EndsAt    time.Time               `json:"ends_at"`
+	CreatedAt time.Time               `json:"created_at"`
+}
+
+type storedPlayer struct {
+	Rating       float64   `json:"rating"`
+	Sheet        []Entry   `json:"sheet,omitempty"`
+	Streak       int       `json:"streak,omitempty"`
+	Paused       bool      `json:"paused,omitempty"`
+	Playing      string    `json:"playing,omitempty"`
+	IdleSince    time.Time `json:"idle_since"`
+	Recent       []string  `json:"recent,omitempty"`
+	ColorBalance int       `json:"color_balance,omitempty"`
+	LastWhite    bool      `json:"last_white,omitempty"`
+}
+
+func (a *arena) MarshalJSON() ([]byte, error) {
+	st := stored{
+		ID:        a.id,
+		Options:   a.opts,
+		Pool:      a.pool,
+		Status:    a.status,
+		Players:   make(map[string]storedPlayer, len(a.players)),
+		EndsAt:    a.endsAt,
+		CreatedAt: a.createdAt,
+	}
+	for id, p := range a.players {
+		st.Players[id] = storedPlayer{
+			Rating:       p.rating,
+			Sheet:        p.sheet,
+			Streak:       p.streak,
+			Paused:       p.paused,
+			Playing:      p.playing,
+			IdleSince:    p.idleSince,
+			Recent:       p.recent,
+			ColorBalance: p.colorBalance,
+			LastWhite:    p.lastWhite,
+		}
+	}
+	return json.Marshal(st)
+}
+
+func (a *arena) UnmarshalJSON(data []byte) error {
+	var st stored
+	if err := json.Unmarshal(data, &st); err != nil {
+		return err
+	}
+	*a = arena{
+		id:        st.ID,
+		opts:      st.Options,
+		pool:      st.Pool,
+		status:    st.Status,
+		players:   make(map[string]*player, len(st.Players)),
+		endsAt:    st.EndsAt,
+		createdAt: st.CreatedAt,
+	}
+	for id, p := range st.Players {
+		a.players[id] = &player{
+			id:           id,
+			rating:       p.Rating,
+			sheet:        p.Sheet,
+			streak:       p.Streak,
+			paused:       p.Paused,
+			playing:      p.Playing,
+			idleSince:    p.IdleSince,
+			recent:       p.Recent,
+			colorBalance: p.ColorBalance,
+			lastWhite:    p.LastWhite,
+		}
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package arena
+
+import "time"
+
+// Standing is a player's place on the leaderboard. Fire is set while their
+// next game counts double.
+type Standing struct {
+	Rank        int     `json:"rank"`
+	PlayerID    string  `json:"player_id"`
+	Rating      float64 `json:"rating"`
+	Points      int     `json:"points"`
+	Performance int     `json:"performance"`
+	Fire        bool    `json:"fire,omitempty"`
+	Paused      bool    `json:"paused,omitempty"`
+	Playing     string  `json:"playing,omitempty"`
+	Sheet       []Entry `json:"sheet"`
+}
+
+// View is the externally visible state of an arena.
+type View struct {
+	ID          string     `json:"id"`
+	Name        string     `json:"name"`
+	TimeControl string     `json:"time_control"`
+	Rated       bool       `json:"rated"`
+	Berserk     bool       `json:"berserk"`
+	Organizer   string     `json:"organizer,omitempty"`
+	Status      Status     `json:"status"`
+	StartsAt    time.Time  `json:"starts_at"`
+	EndsAt      time.Time  `json:"ends_at"`
+	Leaderboard []Standing `json:"leaderboard"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+func (a *arena) view() View {
+	v := View{
+		ID:          a.id,
+		Name:        a.opts.Name,
+		TimeControl: a.opts.TimeControl,
+		Rated:       a.opts.Rated,
+		Berserk:     !a.opts.NoBerserk,
+		Organizer:   a.opts.Organizer,
+		Status:      a.status,
+		StartsAt:    a.opts.StartsAt,
+		EndsAt:      a.endsAt,
+		Leaderboard: []Standing{},
+		CreatedAt:   a.createdAt,
+	}
+	for i, p := range a.ranked() {
+		v.Leaderboard = append(v.Leaderboard, Standing{
+			Rank:        i + 1,
+			PlayerID:    p.id,
+			Rating:      p.rating,
+			Points:      p.points(),
+			Performance: a.performance(p),
+			Fire:        p.onFire(),
+			Paused:      p.paused,
+			Playing:     p.playing,
+			Sheet:       append([]Entry{}, p.sheet...),
+		})
+	}
+	return v
+}
@@ -0,0 +1,83 @@
+package bot
+
+import (
+	"testing"
+
+	"github.com/THECHAMP95821/chess-backend/internal/chess"
+	"github.com/THECHAMP95821/chess-backend/internal/game"
+	"github.com/THECHAMP95821/chess-backend/internal/lobby"
+)
+
+func TestFeed(t *testing.T) {
+	games := game.NewService()
+	feed := NewFeed()
+	games.OnGameStart(feed.GameStarted)
+	games.OnGameEnd(feed.GameEnded)
+	sub := feed.Subscribe("engine")
+	defer sub.Close()
+
+	games.Create(game.CreateOptions{WhiteID: "alice", BlackID: "bob"})
+	v, _ := games.Create(game.CreateOptions{WhiteID: "alice", BlackID: "engine", TimeControl: "60+0"})
+	e := <-sub.C
+	if e.Type != EventGameStart || e.Game.ID != v.ID || e.Game.Color != "black" || e.Game.Opponent != "alice" || e.Game.IsMyTurn {
+		t.Errorf("start = %+v, game %+v", e, e.Game)
+	}
+	games.Resign(v.ID, chess.ColorWhite)
+	e = <-sub.C
+	if e.Type != EventGameFinish || e.Game.Outcome == nil || e.Game.Outcome.Result != "0-1" {
+		t.Errorf("finish = %+v, game %+v", e, e.Game)
+	}
+	select {
+	case e := <-sub.C:
+		t.Errorf("unexpected event %+v", e)
+	default:
+	}
+
+	c := &lobby.Challenge{ID: "c1", From: "alice", To: "engine"}
+	if e, ok := ChallengeEvent(lobby.Event{Type: lobby.EventChallenge, Challenge: c}); !ok || e.Type != EventChallenge {
+		t.Errorf("challenge event = %+v, %v", e, ok)
+	}
+	if _, ok := ChallengeEvent(lobby.Event{Type: lobby.EventChallengeAccepted, Challenge: c}); ok {
+		t.Error("accepted challenge made an event")
+	}
+}
+
+func TestStream(t *testing.T) {
+	games := game.NewService()
+	v, _ := games.Create(game.CreateOptions{WhiteID: "alice", BlackID: "engine", TimeControl: "60+0"})
+	games.Move(v.ID, game.MoveRequest{Color: chess.ColorWhite, UCI: "e2e4"})
+	v, sub, _ := games.Subscribe(v.ID, true)
+	defer sub.Close()
+
+	st, full := NewStream(v)
+	if full.Type != "game_full" || full.Clock != "60" || full.State.Moves != "e2e4" || full.State.Status != "started" {
+		t.Errorf("full = %+v", full)
+	}
+
+	games.Move(v.ID, game.MoveRequest{Color: chess.ColorBlack, UCI: "e7e5"})
+	games.OfferDraw(v.ID, chess.ColorWhite)
+	games.Chat(v.ID, game.ChatView{Room: game.RoomPlayer, User: "engine", Text: "no thanks"})
+	games.Resign(v.ID, chess.ColorBlack)
+
+	var lines []any
+	for !st.Over() {
+		if line, ok := st.Next(<-sub.C); ok {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) != 4 {
+		t.Fatalf("lines = %+v", lines)
+	}
+	if s := lines[0].(GameState); s.Moves != "e2e4 e7e5" || s.WhiteMs == 0 {
+		t.Errorf("after e7e5 = %+v", s)
+	}
+	if s := lines[1].(GameState); s.DrawOffer != "white" {
+		t.Errorf("after draw offer = %+v", s)
+	}
+	if c := lines[2].(ChatLine); c.Type != "chat_line" || c.Text != "no thanks" {
+		t.Errorf("chat = %+v", c)
+	}
+	if s := lines[3].(GameState); s.Status != "resignation" || s.Result != "1-0" || s.DrawOffer != "" {
+		t.Errorf("final = %+v", s)
+	}
+}
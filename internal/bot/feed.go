@@ -0,0 +1,159 @@
+// Package bot serves accounts played by engines: a feed of the events that
+// tell a bot when to play, and the state of each game it plays as a stream
+// of lines, after Lichess's bot API.
+package bot
+
+import (
+	"sync"
+
+	"github.com/THECHAMP95821/chess-backend/internal/game"
+	"github.com/THECHAMP95821/chess-backend/internal/lobby"
+)
+
+type EventType string
+
+const (
+	EventGameStart         EventType = "game_start"
+	EventGameFinish        EventType = "game_finish"
+	EventChallenge         EventType = "challenge"
+	EventChallengeCanceled EventType = "challenge_canceled"
+	EventChallengeDeclined EventType = "challenge_declined"
+)
+
+// Event is one line of a bot's event stream.
+type Event struct {
+	Type      EventType        `json:"type"`
+	Game      *GameInfo        `json:"game,omitempty"`
+	Challenge *lobby.Challenge `json:"challenge,omitempty"`
+}
+
+// GameInfo is a game as one of its players sees it.
+type GameInfo struct {
+	ID       string            `json:"id"`
+	Color    string            `json:"color"`
+	Opponent string            `json:"opponent,omitempty"`
+	Rated    bool              `json:"rated"`
+	FEN      string            `json:"fen"`
+	IsMyTurn bool              `json:"is_my_turn"`
+	Outcome  *game.OutcomeView `json:"outcome,omitempty"`
+}
+
+// Info describes v to the player userID, who must be one of its players.
+func Info(v game.View, userID string) GameInfo {
+	info := GameInfo{ID: v.ID, Color: "white", Opponent: v.BlackID, Rated: v.Rated, FEN: v.FEN}
+	if v.BlackID == userID && v.WhiteID != userID {
+		info.Color, info.Opponent = "black", v.WhiteID
+	}
+	if v.Outcome.Result == "*" {
+		info.IsMyTurn = v.SideToMove == info.Color
+	} else {
+		outcome := v.Outcome
+		info.Outcome = &outcome
+	}
+	return info
+}
+
+// ChallengeEvent maps a lobby event about a challenge to the bot event it
+// makes, if any. Accepted challenges are left out: the game start that
+// follows says the same.
+func ChallengeEvent(e lobby.Event) (Event, bool) {
+	if e.Challenge == nil {
+		return Event{}, false
+	}
+	var t EventType
+	switch e.Type {
+	case lobby.EventChallenge:
+		t = EventChallenge
+	case lobby.EventChallengeCancelled:
+		t = EventChallengeCanceled
+	case lobby.EventChallengeDeclined:
+		t = EventChallengeDeclined
+	default:
+		return Event{}, false
+	}
+	return Event{Type: t, Challenge: e.Challenge}, true
+}
+
+const subscriberBuffer = 64
+
+// Subscription delivers the game starts and finishes of one user. If the
+// subscriber falls more than subscriberBuffer events behind, C is closed
+// and it must reconnect.
+type Subscription struct {
+	C      <-chan Event
+	ch     chan Event
+	cancel func()
+}
+
+func (sub *Subscription) Close() {
+	sub.cancel()
+}
+
+// Feed fans the start and end of every game out to the players' event
+// streams. Its GameStarted and GameEnded go to the game service's hooks.
+type Feed struct {
+	mu   sync.Mutex
+	subs map[string]map[*Subscription]struct{}
+}
+
+func NewFeed() *Feed {
+	return &Feed{subs: make(map[string]map[*Subscription]struct{})}
+}
+
+func (f *Feed) Subscribe(userID string) *Subscription {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	ch := make(chan Event, subscriberBuffer)
+	sub := &Subscription{C: ch, ch: ch}
+	if f.subs[userID] == nil {
+		f.subs[userID] = make(map[*Subscription]struct{})
+	}
+	f.subs[userID][sub] = struct{}{}
+	sub.cancel = func() {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if _, ok := f.subs[userID][sub]; ok {
+			f.drop(userID, sub)
+		}
+	}
+	return sub
+}
+
+func (f *Feed) drop(userID string, sub *Subscription) {
+	delete(f.subs[userID], sub)
+	if len(f.subs[userID]) == 0 {
+		delete(f.subs, userID)
+	}
+	close(sub.ch)
+}
+
+func (f *Feed) GameStarted(v game.View) {
+	f.publish(EventGameStart, v)
+}
+
+// GameEnded may run with the game locked, so it never blocks.
+func (f *Feed) GameEnded(v game.View) {
+	f.publish(EventGameFinish, v)
+}
+
+func (f *Feed) publish(t EventType, v game.View) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, userID := range []string{v.WhiteID, v.BlackID} {
+		if userID == "" {
+			continue
+		}
+		info := Info(v, userID)
+		for sub := range f.subs[userID] {
+			select {
+			case sub.ch <- Event{Type: t, Game: &info}:
+			default:
+				f.drop(userID, sub)
+			}
+		}
+		if v.WhiteID == v.BlackID {
+			// Someone playing themselves hears of it once.
+			break
+		}
+	}
+}
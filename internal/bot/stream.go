@@ -0,0 +1,138 @@
+package bot
+
+import (
+	"strings"
+	"time"
+
+	"github.com/THECHAMP95821/chess-backend/internal/game"
+)
+
+// GameFull is the first line of a game stream: the game as it is now.
+type GameFull struct {
+	Type       string    `json:"type"`
+	ID         string    `json:"id"`
+	WhiteID    string    `json:"white_id,omitempty"`
+	BlackID    string    `json:"black_id,omitempty"`
+	Rated      bool      `json:"rated"`
+	Clock      string    `json:"clock,omitempty"`
+	InitialFEN string    `json:"initial_fen"`
+	CreatedAt  time.Time `json:"created_at"`
+	State      GameState `json:"state"`
+}
+
+// GameState is the game after each change: every move from the initial
+// position, in UCI and separated by spaces, and the clocks. Status is
+// "started" until the game ends, then its termination.
+type GameState struct {
+	Type          string `json:"type"`
+	Moves         string `json:"moves"`
+	WhiteMs       int64  `json:"white_ms,omitempty"`
+	BlackMs       int64  `json:"black_ms,omitempty"`
+	WhiteBerserk  bool   `json:"white_berserk,omitempty"`
+	BlackBerserk  bool   `json:"black_berserk,omitempty"`
+	Status        string `json:"status"`
+	Result        string `json:"result,omitempty"`
+	DrawOffer     string `json:"draw_offer,omitempty"`
+	TakebackOffer string `json:"takeback_offer,omitempty"`
+}
+
+// ChatLine is a chat message posted in the game.
+type ChatLine struct {
+	Type string `json:"type"`
+	game.ChatView
+}
+
+// Stream turns the events of one game into the lines of its stream.
+type Stream struct {
+	moves []string
+	state GameState
+}
+
+// NewStream starts from the snapshot v and returns the stream's first
+// line.
+func NewStream(v game.View) (*Stream, GameFull) {
+	st := &Stream{state: GameState{
+		Type:          "game_state",
+		Status:        "started",
+		DrawOffer:     v.DrawOffer,
+		TakebackOffer: v.Takeback,
+	}}
+	for _, m := range v.Moves {
+		st.moves = append(st.moves, m.UCI)
+	}
+	st.setClock(v.Clock)
+	st.setOutcome(&v.Outcome)
+	full := GameFull{
+		Type:       "game_full",
+		ID:         v.ID,
+		WhiteID:    v.WhiteID,
+		BlackID:    v.BlackID,
+		Rated:      v.Rated,
+		InitialFEN: v.InitialFEN,
+		CreatedAt:  v.CreatedAt,
+		State:      st.snapshot(),
+	}
+	if v.Clock != nil {
+		full.Clock = v.Clock.Control
+	}
+	return st, full
+}
+
+// Next returns the line for e, or false if e makes none: clock
+// resynchronisations and presence changes are left out.
+func (st *Stream) Next(e game.Event) (line any, ok bool) {
+	switch e.Type {
+	case game.EventMove:
+		st.moves = append(st.moves, e.Move.UCI)
+	case game.EventTakeback:
+		st.moves = st.moves[:min(e.Ply, len(st.moves))]
+		st.state.TakebackOffer = ""
+	case game.EventDrawOffer:
+		st.state.DrawOffer = e.By
+	case game.EventDrawDeclined:
+		st.state.DrawOffer = ""
+	case game.EventTakebackOffer:
+		st.state.TakebackOffer = e.By
+	case game.EventTakebackDeclined:
+		st.state.TakebackOffer = ""
+	case game.EventGameEnd:
+		st.state.DrawOffer, st.state.TakebackOffer = "", ""
+		st.setOutcome(e.Outcome)
+	case game.EventBerserk:
+	case game.EventChat:
+		return ChatLine{Type: "chat_line", ChatView: *e.Chat}, true
+	default:
+		return nil, false
+	}
+	st.setClock(e.Clock)
+	return st.snapshot(), true
+}
+
+// Over reports whether the game has ended.
+func (st *Stream) Over() bool {
+	return st.state.Status != "started"
+}
+
+func (st *Stream) setClock(cv *game.ClockView) {
+	if cv == nil {
+		return
+	}
+	st.state.WhiteMs, st.state.BlackMs = cv.WhiteMs, cv.BlackMs
+	st.state.WhiteBerserk, st.state.BlackBerserk = cv.WhiteBerserk, cv.BlackBerserk
+}
+
+func (st *Stream) setOutcome(o *game.OutcomeView) {
+	if o == nil || o.Result == "*" {
+		return
+	}
+	st.state.Status, st.state.Result = o.Termination, o.Result
+	if o.Scored != "" {
+		st.state.Result = o.Scored
+	}
+}
+
+func (st *Stream) snapshot() GameState {
+	s := st.state
+	s.Moves = strings.Join(st.moves, " ")
+	return s
+}
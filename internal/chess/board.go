@@ -46,7 +46,7 @@ func (b *Board) setPawnsOnRank(c Color) {
 }
 
 func (b *Board) setPiecesOnRank(c Color) {
-	pieces := [8]PieceType{Rook, Knight, Bishop, King, Queen, Bishop, Knight, Rook}
+	pieces := [8]PieceType{Rook, Knight, Bishop, Queen, King, Bishop, Knight, Rook}
 	var rank int
 	if c == ColorWhite {
 		rank = 0
@@ -0,0 +1,174 @@
+package chess
+
+type materialCount struct {
+	pawns, knights, rooks, queens int
+	lightBishops, darkBishops     int
+}
+
+func countMaterial(board *Board, c Color) materialCount {
+	var m materialCount
+	for sq := Square(0); sq < 64; sq++ {
+		p := board[sq]
+		if p.IsEmpty() || p.Color != c {
+			continue
+		}
+		switch p.PieceType {
+		case Pawn:
+			m.pawns++
+		case Knight:
+			m.knights++
+		case Bishop:
+			if isLightSquare(sq) {
+				m.lightBishops++
+			} else {
+				m.darkBishops++
+			}
+		case Rook:
+			m.rooks++
+		case Queen:
+			m.queens++
+		}
+	}
+	return m
+}
+
+func (m materialCount) bishops() int {
+	return m.lightBishops + m.darkBishops
+}
+
+func (m materialCount) onlyKing() bool {
+	return m.pawns+m.knights+m.bishops()+m.rooks+m.queens == 0
+}
+
+// CanPossiblyMate reports whether c could deliver checkmate by any sequence of
+// legal moves, with the opponent cooperating. It is the test applied when the
+// opponent's flag falls.
+func CanPossiblyMate(state *GameState, c Color) bool {
+	if isBlockedPawnFortress(state) {
+		return false
+	}
+	return hasMatingMaterial(&state.Board, c)
+}
+
+func hasMatingMaterial(board *Board, c Color) bool {
+	own := countMaterial(board, c)
+	opp := countMaterial(board, c.Opponent())
+
+	switch {
+	case own.onlyKing():
+		return false
+	case own.pawns > 0 || own.rooks > 0 || own.queens > 0:
+		return true
+	case own.knights > 0 && own.knights+own.bishops() > 1:
+		return true
+	case own.knights == 1:
+		// A lone knight needs the defender to block his own king in, which
+		// queens alone cannot usefully do.
+		return opp.pawns+opp.knights+opp.bishops()+opp.rooks > 0
+	case own.lightBishops > 0 && own.darkBishops > 0:
+		return true
+	}
+
+	// Only bishops of a single square colour remain; mate needs an enemy
+	// blocker that is not itself confined to that colour.
+	sameColorBishops := opp.lightBishops
+	if own.darkBishops > 0 {
+		sameColorBishops = opp.darkBishops
+	}
+	return opp.pawns+opp.knights+opp.rooks+opp.queens+opp.bishops()-sameColorBishops > 0
+}
+
+// IsDeadPosition reports whether neither side can ever checkmate: the FIDE
+// dead position for bare kings, lone minors, same-coloured bishops and fully
+// locked pawn structures that no king can break into.
+func IsDeadPosition(state *GameState) bool {
+	if isBlockedPawnFortress(state) {
+		return true
+	}
+	return !hasMatingMaterial(&state.Board, ColorWhite) && !hasMatingMaterial(&state.Board, ColorBlack)
+}
+
+func pawnForward(c Color) int {
+	if c == ColorWhite {
+		return 8
+	}
+	return -8
+}
+
+func pawnAttacksSquare(board *Board, sq Square, byColor Color) bool {
+	for _, side := range []int{-1, 1} {
+		from := sq.applyOffset(-pawnForward(byColor) + side)
+		if from.isValid() && abs(from.File()-sq.File()) == 1 && board[from] == NewPiece(Pawn, byColor) {
+			return true
+		}
+	}
+	return false
+}
+
+// isBlockedPawnFortress reports positions with only kings and pawns where no
+// pawn can ever move again and neither king can reach an enemy pawn.
+func isBlockedPawnFortress(state *GameState) bool {
+	board := &state.Board
+	hasPawns := false
+	for sq := Square(0); sq < 64; sq++ {
+		p := board[sq]
+		if p.IsEmpty() || p.PieceType == King {
+			continue
+		}
+		if p.PieceType != Pawn {
+			return false
+		}
+		hasPawns = true
+
+		ahead := sq.applyOffset(pawnForward(p.Color))
+		if board[ahead] != NewPiece(Pawn, p.Color.Opponent()) {
+			return false
+		}
+		for _, side := range []int{-1, 1} {
+			target := ahead.applyOffset(side)
+			if target.isValid() && abs(target.File()-sq.File()) == 1 &&
+				board[target] == NewPiece(Pawn, p.Color.Opponent()) {
+				return false
+			}
+		}
+	}
+	if !hasPawns {
+		return false
+	}
+
+	for _, c := range []Color{ColorWhite, ColorBlack} {
+		if kingCanReachEnemyPawn(board, state.GetKingSquare(c), c) {
+			return false
+		}
+	}
+	return true
+}
+
+func kingCanReachEnemyPawn(board *Board, start Square, c Color) bool {
+	enemyPawn := NewPiece(Pawn, c.Opponent())
+	visited := [64]bool{}
+	visited[start] = true
+	queue := []Square{start}
+	for len(queue) > 0 {
+		sq := queue[0]
+		queue = queue[1:]
+		for _, offset := range KingOffsets {
+			next := sq.applyOffset(offset)
+			if !next.isValid() || !checkValidRankDiff(sq, next) || visited[next] {
+				continue
+			}
+			visited[next] = true
+			if pawnAttacksSquare(board, next, c.Opponent()) {
+				continue
+			}
+			if board[next] == enemyPawn {
+				return true
+			}
+			if board[next].PieceType == Pawn {
+				continue
+			}
+			queue = append(queue, next)
+		}
+	}
+	return false
+}
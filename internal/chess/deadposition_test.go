@@ -0,0 +1,64 @@
+package chess
+
+import "testing"
+
+func TestIsDeadPosition(t *testing.T) {
+	tests := []struct {
+		name string
+		fen  string
+		want bool
+	}{
+		{"bare kings", "4k3/8/8/8/8/8/8/4K3 w - - 0 1", true},
+		{"king and knight", "4k3/8/8/8/8/8/8/4KN2 w - - 0 1", true},
+		{"same-colored bishops", "4kb2/8/8/8/8/8/8/2B1K3 w - - 0 1", true},
+		{"opposite-colored bishops", "4k1b1/8/8/8/8/8/8/2B1K3 w - - 0 1", false},
+		{"knight against knight", "4kn2/8/8/8/8/8/8/4KN2 w - - 0 1", false},
+		{"rook", "4k3/8/8/8/8/8/8/4KR2 w - - 0 1", false},
+		{"locked pawn chain", "8/8/1k6/p1p1p1p1/P1P1P1P1/8/8/2K5 w - - 0 1", true},
+		{"pawn chain with a capture", "8/8/1k6/p1p1p1p1/PP2P1P1/8/8/2K5 w - - 0 1", false},
+		{"king can reach a pawn", "8/8/1k6/p1p1p3/P1P1P3/8/8/6K1 w - - 0 1", false},
+	}
+	for _, tt := range tests {
+		state, err := ParseFEN(tt.fen)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got := IsDeadPosition(state); got != tt.want {
+			t.Errorf("%s: IsDeadPosition = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestEvaluateTimeout(t *testing.T) {
+	tests := []struct {
+		name    string
+		fen     string
+		flagged Color
+		want    Outcome
+	}{
+		{"against lone king", "4k3/8/8/8/8/8/8/4KQ2 w - - 0 1", ColorWhite, Outcome{Result: GameDraw, DrawReason: DrawTimeoutVsInsufficientMaterial}},
+		{"against queen", "4k3/8/8/8/8/8/8/4KQ2 b - - 0 1", ColorBlack, Outcome{Result: GameWhiteWins}},
+		{"knight against pawn", "4k3/4p3/8/8/8/8/8/4KN2 b - - 0 1", ColorBlack, Outcome{Result: GameWhiteWins}},
+		{"knight against queen", "4k3/4q3/8/8/8/8/8/4KN2 b - - 0 1", ColorBlack, Outcome{Result: GameDraw, DrawReason: DrawTimeoutVsInsufficientMaterial}},
+	}
+	for _, tt := range tests {
+		state, err := ParseFEN(tt.fen)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got := EvaluateTimeout(state, tt.flagged); got != tt.want {
+			t.Errorf("%s: EvaluateTimeout = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestEvaluateGameOutcomeDeadPosition(t *testing.T) {
+	state, err := ParseFEN("8/8/1k6/p1p1p1p1/P1P1P1P1/8/8/2K5 w - - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := EvaluateGameOutcome(state)
+	if got.Result != GameDraw || got.DrawReason != DrawDeadPosition {
+		t.Errorf("EvaluateGameOutcome = %+v, want dead position draw", got)
+	}
+}
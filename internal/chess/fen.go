@@ -59,8 +59,12 @@ func pieceToFEN(p Piece) string {
 	return string(c)
 }
 
+func isValidSquareString(s string) bool {
+	return len(s) == 2 && s[0] >= 'a' && s[0] <= 'h' && s[1] >= '1' && s[1] <= '8'
+}
+
 func ParseFEN(fen string) (*GameState, error) {
-	parts := strings.Split(fen, " ")
+	parts := strings.Fields(fen)
 	if len(parts) != 6 {
 		return nil, errors.New("invalid FEN")
 	}
@@ -78,20 +82,34 @@ func ParseFEN(fen string) (*GameState, error) {
 			if ch >= '1' && ch <= '8' {
 				file += int(ch - '0')
 			} else {
+				if !strings.ContainsRune("pnbrqkPNBRQK", ch) || file >= 8 {
+					return nil, errors.New("invalid board in FEN")
+				}
 				board[sq+Square(file)] = pieceFromFEN(ch)
 				file++
 			}
 		}
+		if file != 8 {
+			return nil, errors.New("invalid board in FEN")
+		}
 		sq -= 8
 	}
 
-	side := ColorWhite
-	if parts[1] == "b" {
+	var side Color
+	switch parts[1] {
+	case "w":
+		side = ColorWhite
+	case "b":
 		side = ColorBlack
+	default:
+		return nil, errors.New("invalid side to move in FEN")
 	}
 
 	cr := CastlingRights{}
 	if parts[2] != "-" {
+		if strings.Trim(parts[2], "KQkq") != "" {
+			return nil, errors.New("invalid castling rights in FEN")
+		}
 		cr.WhiteKingSide = strings.Contains(parts[2], "K")
 		cr.WhiteQueenSide = strings.Contains(parts[2], "Q")
 		cr.BlackKingSide = strings.Contains(parts[2], "k")
@@ -100,13 +118,22 @@ func ParseFEN(fen string) (*GameState, error) {
 
 	ep := Square(-1)
 	if parts[3] != "-" {
+		if !isValidSquareString(parts[3]) {
+			return nil, errors.New("invalid en passant square in FEN")
+		}
 		file := int(parts[3][0] - 'a')
 		rank := int(parts[3][1] - '1')
 		ep = NewSquare(file, rank)
 	}
 
-	halfMove, _ := strconv.Atoi(parts[4])
-	fullMove, _ := strconv.Atoi(parts[5])
+	halfMove, err := strconv.Atoi(parts[4])
+	if err != nil || halfMove < 0 {
+		return nil, errors.New("invalid halfmove clock in FEN")
+	}
+	fullMove, err := strconv.Atoi(parts[5])
+	if err != nil || fullMove < 1 {
+		return nil, errors.New("invalid fullmove number in FEN")
+	}
 
 	state := &GameState{
 		Board:           board,
@@ -121,6 +148,19 @@ func ParseFEN(fen string) (*GameState, error) {
 	return state, nil
 }
 
+// ParseLegalFEN parses fen and rejects positions that cannot arise from a
+// legal game.
+func ParseLegalFEN(fen string) (*GameState, error) {
+	state, err := ParseFEN(fen)
+	if err != nil {
+		return nil, err
+	}
+	if err := ValidatePosition(state); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
 func (gs *GameState) ToFEN() string {
 	var sb strings.Builder
 
@@ -186,3 +226,22 @@ func (gs *GameState) ToFEN() string {
 
 	return sb.String()
 }
+
+// PositionKey identifies a position for repetition purposes: placement, side
+// to move, castling rights and an en passant square only if it is capturable.
+func (gs *GameState) PositionKey() string {
+	fields := strings.Fields(gs.ToFEN())
+	if gs.EnPassantSquare != -1 {
+		capturable := false
+		for _, m := range GenerateLegalMoves(gs) {
+			if m.IsEnPassant() {
+				capturable = true
+				break
+			}
+		}
+		if !capturable {
+			fields[3] = "-"
+		}
+	}
+	return strings.Join(fields[:4], " ")
+}
@@ -0,0 +1,283 @@
+package chess
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrGameOver      = errors.New("game is over")
+	ErrIllegalMove   = errors.New("illegal move")
+	ErrNoMoves       = errors.New("no moves to take back")
+	ErrPlyOutOfRange = errors.New("ply out of range")
+)
+
+type Termination int
+
+const (
+	TerminationNone Termination = iota
+	TerminationCheckmate
+	TerminationStalemate
+	TerminationDrawRule
+	TerminationResignation
+	TerminationTimeout
+	TerminationAgreement
+	TerminationAbandonment
+	TerminationAdjudication
+)
+
+func (t Termination) String() string {
+	switch t {
+	case TerminationNone:
+		return "none"
+	case TerminationCheckmate:
+		return "checkmate"
+	case TerminationStalemate:
+		return "stalemate"
+	case TerminationDrawRule:
+		return "draw"
+	case TerminationResignation:
+		return "resignation"
+	case TerminationTimeout:
+		return "timeout"
+	case TerminationAgreement:
+		return "agreement"
+	case TerminationAbandonment:
+		return "abandonment"
+	case TerminationAdjudication:
+		return "adjudication"
+	default:
+		return "INVALID TERMINATION"
+	}
+}
+
+type PlayedMove struct {
+	Move        Move
+	Undo        UndoInfo
+	SAN         string
+	PlayedAt    time.Time
+	PositionKey string
+}
+
+// Game is a whole game: the starting position, every move played from it and
+// how the game ended. The current position is kept in sync with the moves.
+type Game struct {
+	start       GameState
+	current     GameState
+	startKey    string
+	moves       []PlayedMove
+	outcome     Outcome
+	termination Termination
+}
+
+func NewGame() *Game {
+	return NewGameFromState(NewInitialGameState())
+}
+
+func NewGameFromState(start GameState) *Game {
+	start.cacheKingSquares()
+	g := &Game{
+		start:   start,
+		current: start,
+	}
+	g.startKey = g.current.PositionKey()
+	g.evaluate()
+	return g
+}
+
+func (g *Game) StartPosition() GameState {
+	return g.start
+}
+
+func (g *Game) Position() GameState {
+	return g.current
+}
+
+func (g *Game) Ply() int {
+	return len(g.moves)
+}
+
+func (g *Game) Moves() []PlayedMove {
+	moves := make([]PlayedMove, len(g.moves))
+	copy(moves, g.moves)
+	return moves
+}
+
+func (g *Game) Outcome() Outcome {
+	return g.outcome
+}
+
+func (g *Game) Termination() Termination {
+	return g.termination
+}
+
+func (g *Game) IsOver() bool {
+	return g.outcome.Result != GameOngoing
+}
+
+func (g *Game) LegalMoves() []Move {
+	if g.IsOver() {
+		return nil
+	}
+	return GenerateLegalMoves(&g.current)
+}
+
+func (g *Game) PlayMove(m Move, at time.Time) (PlayedMove, error) {
+	if g.IsOver() {
+		return PlayedMove{}, ErrGameOver
+	}
+	legal := false
+	for _, lm := range GenerateLegalMoves(&g.current) {
+		if lm.From == m.From && lm.To == m.To && lm.Promotion == m.Promotion {
+			m = lm
+			legal = true
+			break
+		}
+	}
+	if !legal {
+		return PlayedMove{}, ErrIllegalMove
+	}
+
+	san := g.current.MoveToSAN(m)
+	undo := g.current.MakeMove(m)
+	played := PlayedMove{
+		Move:        m,
+		Undo:        undo,
+		SAN:         san,
+		PlayedAt:    at,
+		PositionKey: g.current.PositionKey(),
+	}
+	g.moves = append(g.moves, played)
+	g.evaluate()
+	return played, nil
+}
+
+func (g *Game) PlayUCI(s string, at time.Time) (PlayedMove, error) {
+	if g.IsOver() {
+		return PlayedMove{}, ErrGameOver
+	}
+	m, err := g.current.ParseUCI(s)
+	if err != nil {
+		return PlayedMove{}, err
+	}
+	return g.PlayMove(m, at)
+}
+
+func (g *Game) PlaySAN(s string, at time.Time) (PlayedMove, error) {
+	if g.IsOver() {
+		return PlayedMove{}, ErrGameOver
+	}
+	m, err := g.current.ParseSAN(s)
+	if err != nil {
+		return PlayedMove{}, err
+	}
+	return g.PlayMove(m, at)
+}
+
+// Takeback undoes the last move. A game that ended by the move on the board,
+// such as checkmate, becomes ongoing again; other terminations are final.
+func (g *Game) Takeback() error {
+	if len(g.moves) == 0 {
+		return ErrNoMoves
+	}
+	switch g.termination {
+	case TerminationNone, TerminationCheckmate, TerminationStalemate, TerminationDrawRule:
+	default:
+		return ErrGameOver
+	}
+	last := g.moves[len(g.moves)-1]
+	g.current.UnmakeMove(last.Move, last.Undo)
+	g.moves = g.moves[:len(g.moves)-1]
+	g.outcome = Outcome{}
+	g.termination = TerminationNone
+	g.evaluate()
+	return nil
+}
+
+// PositionAt returns the position after the first ply moves.
+func (g *Game) PositionAt(ply int) (GameState, error) {
+	if ply < 0 || ply > len(g.moves) {
+		return GameState{}, ErrPlyOutOfRange
+	}
+	gs := g.start
+	for _, pm := range g.moves[:ply] {
+		gs.MakeMove(pm.Move)
+	}
+	return gs, nil
+}
+
+func (g *Game) repetitions() int {
+	if len(g.moves) == 0 {
+		return 1
+	}
+	key := g.moves[len(g.moves)-1].PositionKey
+	count := 0
+	if g.startKey == key {
+		count++
+	}
+	for _, pm := range g.moves {
+		if pm.PositionKey == key {
+			count++
+		}
+	}
+	return count
+}
+
+func (g *Game) evaluate() {
+	outcome := EvaluateGameOutcome(&g.current)
+	if outcome.Result == GameOngoing && g.repetitions() >= 3 {
+		outcome = Outcome{Result: GameDraw, DrawReason: DrawThreefoldRepetition}
+	}
+	if outcome.Result == GameOngoing {
+		return
+	}
+
+	g.outcome = outcome
+	switch {
+	case outcome.Result != GameDraw:
+		g.termination = TerminationCheckmate
+	case outcome.DrawReason == DrawStalemate:
+		g.termination = TerminationStalemate
+	default:
+		g.termination = TerminationDrawRule
+	}
+}
+
+func winFor(c Color) Outcome {
+	if c == ColorWhite {
+		return Outcome{Result: GameWhiteWins}
+	}
+	return Outcome{Result: GameBlackWins}
+}
+
+func (g *Game) end(outcome Outcome, termination Termination) error {
+	if g.IsOver() {
+		return ErrGameOver
+	}
+	g.outcome = outcome
+	g.termination = termination
+	return nil
+}
+
+func (g *Game) Resign(c Color) error {
+	return g.end(winFor(c.Opponent()), TerminationResignation)
+}
+
+func (g *Game) AgreeDraw() error {
+	return g.end(Outcome{Result: GameDraw}, TerminationAgreement)
+}
+
+func (g *Game) Timeout(flagged Color) error {
+	return g.end(EvaluateTimeout(&g.current, flagged), TerminationTimeout)
+}
+
+func (g *Game) Abandon(c Color) error {
+	return g.end(winFor(c.Opponent()), TerminationAbandonment)
+}
+
+func (g *Game) Adjudicate(result GameResult) error {
+	if result == GameOngoing {
+		return errors.New("adjudication needs a result")
+	}
+	return g.end(Outcome{Result: result}, TerminationAdjudication)
+}
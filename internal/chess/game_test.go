@@ -0,0 +1,118 @@
+package chess
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func playSAN(t *testing.T, g *Game, moves ...string) {
+	t.Helper()
+	for _, san := range moves {
+		if _, err := g.PlaySAN(san, time.Time{}); err != nil {
+			t.Fatalf("PlaySAN(%q): %v", san, err)
+		}
+	}
+}
+
+func TestGameCheckmateAndTakeback(t *testing.T) {
+	g := NewGame()
+	playSAN(t, g, "f3", "e5", "g4", "Qh4#")
+
+	if !g.IsOver() || g.Termination() != TerminationCheckmate || g.Outcome().Result != GameBlackWins {
+		t.Fatalf("got %v %+v, want checkmate won by Black", g.Termination(), g.Outcome())
+	}
+	if got := g.Moves()[3].SAN; got != "Qh4#" {
+		t.Errorf("SAN = %q, want Qh4#", got)
+	}
+	if _, err := g.PlayUCI("e2e4", time.Time{}); !errors.Is(err, ErrGameOver) {
+		t.Errorf("move after mate: err = %v, want ErrGameOver", err)
+	}
+
+	if err := g.Takeback(); err != nil {
+		t.Fatal(err)
+	}
+	if g.IsOver() || g.Ply() != 3 {
+		t.Errorf("after takeback: over=%v ply=%d", g.IsOver(), g.Ply())
+	}
+	pos := g.Position()
+	if got, want := pos.ToFEN(), "rnbqkbnr/pppp1ppp/8/4p3/6P1/5P2/PPPPP2P/RNBQKBNR b KQkq g3 0 2"; got != want {
+		t.Errorf("FEN after takeback = %q, want %q", got, want)
+	}
+}
+
+func TestGameThreefoldRepetition(t *testing.T) {
+	g := NewGame()
+	playSAN(t, g, "Nf3", "Nf6", "Ng1", "Ng8", "Nf3", "Nf6", "Ng1")
+	if g.IsOver() {
+		t.Fatal("game over before third repetition")
+	}
+	playSAN(t, g, "Ng8")
+	if g.Outcome().DrawReason != DrawThreefoldRepetition || g.Termination() != TerminationDrawRule {
+		t.Errorf("got %v %+v, want threefold repetition", g.Termination(), g.Outcome())
+	}
+}
+
+func TestGamePositionAt(t *testing.T) {
+	g := NewGame()
+	playSAN(t, g, "e4", "e5", "Nf3")
+	gs, err := g.PositionAt(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := gs.ToFEN(), "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e3 0 1"; got != want {
+		t.Errorf("PositionAt(1) = %q, want %q", got, want)
+	}
+	if _, err := g.PositionAt(4); !errors.Is(err, ErrPlyOutOfRange) {
+		t.Errorf("PositionAt(4): err = %v, want ErrPlyOutOfRange", err)
+	}
+}
+
+func TestGameTerminations(t *testing.T) {
+	g := NewGame()
+	if err := g.Resign(ColorWhite); err != nil {
+		t.Fatal(err)
+	}
+	if g.Outcome().Result != GameBlackWins || g.Termination() != TerminationResignation {
+		t.Errorf("resignation: got %v %+v", g.Termination(), g.Outcome())
+	}
+	if err := g.AgreeDraw(); !errors.Is(err, ErrGameOver) {
+		t.Errorf("draw after resignation: err = %v, want ErrGameOver", err)
+	}
+
+	g = NewGame()
+	if err := g.Adjudicate(GameDraw); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Takeback(); !errors.Is(err, ErrNoMoves) {
+		t.Errorf("takeback with no moves: err = %v, want ErrNoMoves", err)
+	}
+}
+
+func TestMoveToSAN(t *testing.T) {
+	state, err := ParseFEN("1k6/4P3/8/8/8/8/R6R/4K3 w - - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		uci string
+		san string
+	}{
+		{"a2d2", "Rad2"},
+		{"e7e8q", "e8=Q+"},
+		{"e1f1", "Kf1"},
+	}
+	for _, tt := range tests {
+		m, err := state.ParseUCI(tt.uci)
+		if err != nil {
+			t.Fatalf("ParseUCI(%q): %v", tt.uci, err)
+		}
+		if got := state.MoveToSAN(m); got != tt.san {
+			t.Errorf("MoveToSAN(%s) = %q, want %q", tt.uci, got, tt.san)
+		}
+		back, err := state.ParseSAN(tt.san)
+		if err != nil || back != m {
+			t.Errorf("ParseSAN(%q) = %v, %v; want %v", tt.san, back, err, m)
+		}
+	}
+}
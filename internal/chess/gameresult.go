@@ -1,5 +1,7 @@
 package chess
 
+import "fmt"
+
 type GameResult int
 
 const (
@@ -9,6 +11,58 @@ const (
 	GameDraw
 )
 
+func (r GameResult) String() string {
+	switch r {
+	case GameWhiteWins:
+		return "1-0"
+	case GameBlackWins:
+		return "0-1"
+	case GameDraw:
+		return "1/2-1/2"
+	default:
+		return "*"
+	}
+}
+
+// ResultRule decides which result counts for a game that has ended. Under
+// the standard rule it is the result on the board.
+type ResultRule int
+
+const (
+	StandardRule ResultRule = iota
+	// ArmageddonRule gives Black draw odds: a drawn game counts as a win
+	// for Black.
+	ArmageddonRule
+)
+
+func (r ResultRule) String() string {
+	switch r {
+	case ArmageddonRule:
+		return "armageddon"
+	default:
+		return "standard"
+	}
+}
+
+func ParseResultRule(s string) (ResultRule, error) {
+	switch s {
+	case "", "standard":
+		return StandardRule, nil
+	case "armageddon":
+		return ArmageddonRule, nil
+	default:
+		return 0, fmt.Errorf("unknown result rule %q", s)
+	}
+}
+
+// Score maps the outcome on the board to the result that counts.
+func (r ResultRule) Score(o Outcome) GameResult {
+	if r == ArmageddonRule && o.Result == GameDraw {
+		return GameBlackWins
+	}
+	return o.Result
+}
+
 type DrawReason int
 
 const (
@@ -17,8 +71,31 @@ const (
 	DrawInsufficientMaterial
 	DrawFiftyMoveRule
 	DrawThreefoldRepetition
+	DrawDeadPosition
+	DrawTimeoutVsInsufficientMaterial
 )
 
+func (d DrawReason) String() string {
+	switch d {
+	case DrawNone:
+		return "none"
+	case DrawStalemate:
+		return "stalemate"
+	case DrawInsufficientMaterial:
+		return "insufficient material"
+	case DrawFiftyMoveRule:
+		return "fifty-move rule"
+	case DrawThreefoldRepetition:
+		return "threefold repetition"
+	case DrawDeadPosition:
+		return "dead position"
+	case DrawTimeoutVsInsufficientMaterial:
+		return "timeout vs insufficient material"
+	default:
+		return "INVALID DRAW REASON"
+	}
+}
+
 type Outcome struct {
 	Result     GameResult
 	DrawReason DrawReason
@@ -54,6 +131,13 @@ func EvaluateGameOutcome(state *GameState) Outcome {
 		}
 	}
 
+	if isBlockedPawnFortress(state) {
+		return Outcome{
+			Result:     GameDraw,
+			DrawReason: DrawDeadPosition,
+		}
+	}
+
 	//if state.IsThreefoldRepetition() {
 	//	return Outcome{
 	//		Result:     GameDraw,
@@ -65,37 +149,20 @@ func EvaluateGameOutcome(state *GameState) Outcome {
 }
 
 func hasInsufficientMaterial(state *GameState) bool {
-	board := state.Board
-
-	whiteMinor := 0
-	blackMinor := 0
-
-	for sq := Square(0); sq < 64; sq++ {
-		p := board[sq]
-		if p.IsEmpty() {
-			continue
-		}
+	return !hasMatingMaterial(&state.Board, ColorWhite) && !hasMatingMaterial(&state.Board, ColorBlack)
+}
 
-		switch p.PieceType {
-		case Pawn, Queen, Rook:
-			return false
-		case Bishop, Knight:
-			if p.Color == ColorWhite {
-				whiteMinor++
-			} else {
-				blackMinor++
-			}
+// EvaluateTimeout scores a game in which flagged ran out of time: the opponent
+// wins unless they could not possibly have delivered mate.
+func EvaluateTimeout(state *GameState, flagged Color) Outcome {
+	if !CanPossiblyMate(state, flagged.Opponent()) {
+		return Outcome{
+			Result:     GameDraw,
+			DrawReason: DrawTimeoutVsInsufficientMaterial,
 		}
 	}
-
-	if whiteMinor == 0 && blackMinor == 0 {
-		return true
-	}
-
-	if (whiteMinor == 1 && blackMinor == 0) ||
-		(whiteMinor == 0 && blackMinor == 1) {
-		return true
+	if flagged == ColorWhite {
+		return Outcome{Result: GameBlackWins}
 	}
-
-	return false
+	return Outcome{Result: GameWhiteWins}
 }
@@ -80,9 +80,7 @@ func (gs *GameState) switchSides() {
 	gs.SideToMove = gs.SideToMove.Opponent()
 }
 
-func (gs *GameState) updateClocks(m Move) {
-	from := m.From
-	piece := gs.Board[from]
+func (gs *GameState) updateClocks(piece Piece, m Move) {
 	if piece.Color == ColorBlack {
 		gs.FullMoveCounter++
 	}
@@ -94,39 +92,40 @@ func (gs *GameState) updateClocks(m Move) {
 }
 
 func (gs *GameState) MakeMove(m Move) UndoInfo {
-	undoInfo := UndoInfo{}
-	switch m.Flags {
-	case MoveFlagNone, MoveFlagCapture:
-		undoInfo = gs.MakeNormalMove(m)
-	case MoveFlagDoublePush:
-		undoInfo = gs.MakeDoublePush(m)
-	case MoveFlagCastle:
+	var undoInfo UndoInfo
+	piece := gs.Board[m.From]
+	switch {
+	case m.IsCastle():
 		undoInfo = gs.MakeCastle(m)
-	case MoveFlagEnPassant:
+	case m.IsEnPassant():
 		undoInfo = gs.MakeEnPassant(m)
-	case MoveFlagPromotion:
+	case m.IsPromotion():
 		undoInfo = gs.MakePromotion(m)
+	case m.IsDoublePush():
+		undoInfo = gs.MakeDoublePush(m)
+	default:
+		undoInfo = gs.MakeNormalMove(m)
 	}
-	if m.Flags != MoveFlagDoublePush {
+	if !m.IsDoublePush() {
 		gs.EnPassantSquare = Square(-1)
 	}
 	gs.switchSides()
-	gs.updateClocks(m)
+	gs.updateClocks(piece, m)
 	return undoInfo
 }
 
 func (gs *GameState) UnmakeMove(m Move, ui UndoInfo) {
 	gs.switchSides()
-	switch m.Flags {
-	case MoveFlagNone, MoveFlagCapture:
-		gs.UnmakeNormalMove(m, ui)
-	case MoveFlagDoublePush:
-		gs.UnmakeDoublePush(m, ui)
-	case MoveFlagCastle:
+	switch {
+	case m.IsCastle():
 		gs.UnmakeCastle(m, ui)
-	case MoveFlagEnPassant:
+	case m.IsEnPassant():
 		gs.UnmakeEnPassantSquare(m, ui)
-	case MoveFlagPromotion:
+	case m.IsPromotion():
 		gs.UnmakePromotion(m, ui)
+	case m.IsDoublePush():
+		gs.UnmakeDoublePush(m, ui)
+	default:
+		gs.UnmakeNormalMove(m, ui)
 	}
 }
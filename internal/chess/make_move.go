@@ -43,7 +43,7 @@ func (gs *GameState) updateEnPassantSquare(s Square, dir int) {
 }
 
 func (gs *GameState) MakeNormalMove(m Move) UndoInfo {
-	board := gs.Board
+	board := &gs.Board
 	from := m.From
 	to := m.To
 	piece := board[from]
@@ -76,12 +76,16 @@ func (gs *GameState) MakeNormalMove(m Move) UndoInfo {
 }
 
 func (gs *GameState) MakeDoublePush(m Move) UndoInfo {
+	board := &gs.Board
 	from := m.From
+	to := m.To
 	dir := -1
-	if gs.Board[m.From].Color == ColorWhite {
+	if board[from].Color == ColorWhite {
 		dir = 1
 	}
 	prevEnPassantSquare := gs.EnPassantSquare
+	board[to] = board[from]
+	board[from] = EmptyPiece()
 	gs.updateEnPassantSquare(from, dir)
 	return UndoInfo{
 		CapturedPiece: Piece{
@@ -99,7 +103,7 @@ func (gs *GameState) MakeDoublePush(m Move) UndoInfo {
 }
 
 func (gs *GameState) MakeCastle(m Move) UndoInfo {
-	board := gs.Board
+	board := &gs.Board
 	from := m.From
 	to := m.To
 	color := board[from].Color
@@ -137,7 +141,7 @@ func (gs *GameState) MakeCastle(m Move) UndoInfo {
 }
 
 func (gs *GameState) MakeEnPassant(m Move) UndoInfo {
-	board := gs.Board
+	board := &gs.Board
 	from := m.From
 	to := m.To
 	dir := -8 // White captures
@@ -162,7 +166,7 @@ func (gs *GameState) MakeEnPassant(m Move) UndoInfo {
 }
 
 func (gs *GameState) MakePromotion(m Move) UndoInfo {
-	board := gs.Board
+	board := &gs.Board
 	from := m.From
 	to := m.To
 	color := board[from].Color
@@ -171,6 +175,8 @@ func (gs *GameState) MakePromotion(m Move) UndoInfo {
 	if m.IsCapture() {
 		capturedPiece = board[to]
 	}
+	prevCastlingRights := gs.CastlingRights
+	gs.updateCastlingRights(from, to, capturedPiece)
 	board[from] = EmptyPiece()
 	board[to] = Piece{
 		PieceType: promoPieceType,
@@ -178,7 +184,7 @@ func (gs *GameState) MakePromotion(m Move) UndoInfo {
 	}
 	return UndoInfo{
 		CapturedPiece:      capturedPiece,
-		CastlingRights:     gs.CastlingRights,
+		CastlingRights:     prevCastlingRights,
 		EnPassantSquare:    gs.EnPassantSquare,
 		HalfMoveClock:      gs.HalfMoveClock,
 		FullMoveCounter:    gs.FullMoveCounter,
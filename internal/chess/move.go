@@ -1,5 +1,7 @@
 package chess
 
+import "strings"
+
 type MoveFlags uint8
 
 const (
@@ -87,7 +89,7 @@ func (m Move) String() string {
 	move := m.From.String()
 	move += m.To.String()
 	if m.Promotion != PieceNone {
-		move += m.Promotion.String()
+		move += strings.ToLower(m.Promotion.String())
 	}
 	return move
 }
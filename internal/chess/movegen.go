@@ -7,26 +7,39 @@ func abs(x int) int {
 	return x
 }
 
+var promotionPieces = [4]PieceType{Queen, Rook, Bishop, Knight}
+
+func appendPawnMove(moves *[]Move, from, to Square, flag MoveFlags, promotes bool) {
+	if !promotes {
+		*moves = append(*moves, Move{
+			From:  from,
+			To:    to,
+			Flags: flag,
+		})
+		return
+	}
+	for _, pt := range promotionPieces {
+		*moves = append(*moves, Move{
+			From:      from,
+			To:        to,
+			Flags:     flag | MoveFlagPromotion,
+			Promotion: pt,
+		})
+	}
+}
+
 func GeneratePawnMoves(state *GameState, from Square, moves *[]Move) {
-	board := state.Board
+	board := &state.Board
 	color := board[from].Color
 	dir := -1
 	if color == ColorWhite {
 		dir = 1
 	}
-	flag := MoveFlagNone
-
-	if (color == ColorWhite && from.Rank() == 6) || (color == ColorBlack && from.Rank() == 1) {
-		flag = MoveFlagPromotion
-	}
+	promotes := (color == ColorWhite && from.Rank() == 6) || (color == ColorBlack && from.Rank() == 1)
 
 	singlePushSquare := Square(int(from) + dir*8)
 	if singlePushSquare.isValid() && board[singlePushSquare].IsEmpty() {
-		*moves = append(*moves, Move{
-			From:  from,
-			To:    singlePushSquare,
-			Flags: flag,
-		})
+		appendPawnMove(moves, from, singlePushSquare, MoveFlagNone, promotes)
 	}
 	if (color == ColorWhite && from.Rank() == 1) || (color == ColorBlack && from.Rank() == 6) {
 		doublePushSquare := Square(int(from) + 2*dir*8)
@@ -46,22 +59,23 @@ func GeneratePawnMoves(state *GameState, from Square, moves *[]Move) {
 	}
 	for _, offset := range captureOffSet {
 		captureSquare := from.applyOffset(offset)
-		if captureSquare.isValid() && board[captureSquare].IsOpponent(board[from]) &&
-			abs(from.File()-captureSquare.File()) == 1 {
-			if captureSquare == state.EnPassantSquare {
-				flag = MoveFlagEnPassant
-			}
+		if !captureSquare.isValid() || abs(from.File()-captureSquare.File()) != 1 {
+			continue
+		}
+		if board[captureSquare].IsOpponent(board[from]) {
+			appendPawnMove(moves, from, captureSquare, MoveFlagCapture, promotes)
+		} else if captureSquare == state.EnPassantSquare && board[captureSquare].IsEmpty() {
 			*moves = append(*moves, Move{
 				From:  from,
 				To:    captureSquare,
-				Flags: flag | MoveFlagCapture,
+				Flags: MoveFlagEnPassant | MoveFlagCapture,
 			})
 		}
 	}
 }
 
 func GenerateKnightMoves(state *GameState, from Square, moves *[]Move) {
-	board := state.Board
+	board := &state.Board
 	for _, offset := range KnightOffsets {
 		to := from.applyOffset(offset)
 		if !to.isValid() {
@@ -90,15 +104,14 @@ func checkValidRankDiff(from, to Square) bool {
 }
 
 func GenerateSlidingMoves(state *GameState, from Square, moves *[]Move, offset int) {
-	board := state.Board
-	i := 1
-	stopMoves := false
-	for !stopMoves {
-		moveTo := from.applyOffset(offset)
+	board := &state.Board
+	current := from
+	for {
+		moveTo := current.applyOffset(offset)
 		if !moveTo.isValid() {
 			break
 		}
-		if !checkValidRankDiff(from, moveTo) {
+		if !checkValidRankDiff(current, moveTo) {
 			break
 		}
 		if board[moveTo].IsEmpty() {
@@ -114,10 +127,9 @@ func GenerateSlidingMoves(state *GameState, from Square, moves *[]Move, offset i
 					Flags: MoveFlagCapture,
 				})
 			}
-			stopMoves = true
+			break
 		}
-		from = moveTo
-		i++
+		current = moveTo
 	}
 }
 
@@ -144,7 +156,7 @@ func isSquareSafeForKing(state *GameState, sq Square, color Color) bool {
 }
 
 func GenerateKingMoves(state *GameState, from Square, moves *[]Move) {
-	board := state.Board
+	board := &state.Board
 	for _, offset := range KingOffsets {
 		to := from.applyOffset(offset)
 		if !to.isValid() {
@@ -187,7 +199,8 @@ func GenerateKingMoves(state *GameState, from Square, moves *[]Move) {
 		f := NewSquare(5, rank)
 		g := NewSquare(6, rank)
 
-		if board[f].IsEmpty() &&
+		if board[NewSquare(FILEH, rank)] == NewPiece(Rook, color) &&
+			board[f].IsEmpty() &&
 			board[g].IsEmpty() &&
 			isSquareSafeForKing(state, f, color) &&
 			isSquareSafeForKing(state, g, color) {
@@ -207,7 +220,8 @@ func GenerateKingMoves(state *GameState, from Square, moves *[]Move) {
 		c := NewSquare(2, rank)
 		b := NewSquare(1, rank)
 
-		if board[d].IsEmpty() &&
+		if board[NewSquare(FILEA, rank)] == NewPiece(Rook, color) &&
+			board[d].IsEmpty() &&
 			board[c].IsEmpty() &&
 			board[b].IsEmpty() &&
 			isSquareSafeForKing(state, d, color) &&
@@ -224,9 +238,9 @@ func GenerateKingMoves(state *GameState, from Square, moves *[]Move) {
 }
 
 func GeneratePseudoLegalMoves(state *GameState) []Move {
-	board := state.Board
+	board := &state.Board
 	moves := []Move{}
-	for i := range 63 {
+	for i := range 64 {
 		sq := Square(i)
 		piece := board[sq]
 		if piece.Color == state.SideToMove && !piece.IsEmpty() {
@@ -253,7 +267,7 @@ func GeneratePseudoLegalMoves(state *GameState) []Move {
 }
 
 func isSquareAttackedByPawn(state *GameState, square Square, byColor Color) bool {
-	board := state.Board
+	board := &state.Board
 	var captureOffsets []int
 	if byColor == ColorWhite {
 		captureOffsets = []int{-7, -9}
@@ -262,6 +276,9 @@ func isSquareAttackedByPawn(state *GameState, square Square, byColor Color) bool
 	}
 	for _, offset := range captureOffsets {
 		from := square.applyOffset(offset)
+		if !from.isValid() {
+			continue
+		}
 		if board[from].PieceType == Pawn && board[from].Color == byColor && checkValidRankDiff(from, square) {
 			return true
 		}
@@ -270,9 +287,12 @@ func isSquareAttackedByPawn(state *GameState, square Square, byColor Color) bool
 }
 
 func isSquareAttackedByKnight(state *GameState, square Square, byColor Color) bool {
-	board := state.Board
+	board := &state.Board
 	for _, offset := range KnightOffsets {
 		from := square.applyOffset(offset)
+		if !from.isValid() {
+			continue
+		}
 		if board[from].PieceType == Knight && board[from].Color == byColor && abs(square.File()-from.File()) <= 2 && abs(square.Rank()-from.Rank()) <= 2 {
 			return true
 		}
@@ -281,9 +301,12 @@ func isSquareAttackedByKnight(state *GameState, square Square, byColor Color) bo
 }
 
 func isSquareAttackedByKing(state *GameState, square Square, byColor Color) bool {
-	board := state.Board
+	board := &state.Board
 	for _, offset := range KingOffsets {
 		from := square.applyOffset(offset)
+		if !from.isValid() {
+			continue
+		}
 		if board[from].PieceType == King && board[from].Color == byColor && checkValidRankDiff(from, square) {
 			return true
 		}
@@ -292,7 +315,7 @@ func isSquareAttackedByKing(state *GameState, square Square, byColor Color) bool
 }
 
 func isSquareAttackedSliding(state *GameState, from Square, offset int, byColor Color, pieceType PieceType) bool {
-	board := state.Board
+	board := &state.Board
 	i := 1
 	stopMoves := false
 	for !stopMoves {
@@ -374,9 +397,10 @@ func GenerateLegalMoves(state *GameState) []Move {
 	legalMoves := make([]Move, 0, len(pseudoLegalMoves))
 
 	for _, move := range pseudoLegalMoves {
+		mover := state.SideToMove
 		undo := state.MakeMove(move)
 
-		if !state.IsKingInCheck() {
+		if !IsSquareAttacked(state, state.GetKingSquare(mover), mover.Opponent()) {
 			legalMoves = append(legalMoves, move)
 		}
 
@@ -56,3 +56,47 @@ func TestMoveGen(t *testing.T) {
 	a = isSquareAttackedByRook(&state, Square(28), ColorBlack)
 	fmt.Println(a)
 }
+
+func perft(state *GameState, depth int) int {
+	if depth == 0 {
+		return 1
+	}
+	nodes := 0
+	for _, m := range GenerateLegalMoves(state) {
+		undo := state.MakeMove(m)
+		nodes += perft(state, depth-1)
+		state.UnmakeMove(m, undo)
+	}
+	return nodes
+}
+
+func TestPerft(t *testing.T) {
+	tests := []struct {
+		fen   string
+		depth int
+		nodes int
+	}{
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", 3, 8902},
+		{"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1", 2, 2039},
+		{"8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1", 4, 43238},
+		{"r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq - 0 1", 3, 9467},
+		{"rnbq1k1r/pp1Pbppp/2p5/8/2B5/8/PPP1NnPP/RNBQK2R w KQ - 1 8", 3, 62379},
+	}
+	for _, tt := range tests {
+		state, err := ParseFEN(tt.fen)
+		if err != nil {
+			t.Fatalf("ParseFEN(%q): %v", tt.fen, err)
+		}
+		if got := perft(state, tt.depth); got != tt.nodes {
+			t.Errorf("perft(%q, %d) = %d, want %d", tt.fen, tt.depth, got, tt.nodes)
+		}
+		if got := state.ToFEN(); got != tt.fen {
+			t.Errorf("state not restored after perft: got %q, want %q", got, tt.fen)
+		}
+	}
+
+	initial := NewInitialGameState()
+	if got := perft(&initial, 3); got != 8902 {
+		t.Errorf("perft(initial, 3) = %d, want 8902", got)
+	}
+}
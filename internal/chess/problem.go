@@ -0,0 +1,349 @@
+package chess
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+type StipulationKind int
+
+const (
+	StipulationDirectMate StipulationKind = iota
+	StipulationHelpMate
+	StipulationSelfMate
+	StipulationStudyDraw
+)
+
+// defaultStudyHorizon is the horizon of a bare "=" stipulation.
+const defaultStudyHorizon = 3
+
+// Stipulation describes what a problem asks for. Moves counts full moves of
+// the side that has to fulfil the stipulation; for draw studies it is the
+// horizon within which White must force a draw by the rules: stalemate, a
+// dead position or a repetition, whatever Black plays. A bare "=" uses
+// defaultStudyHorizon.
+type Stipulation struct {
+	Kind  StipulationKind
+	Moves int
+}
+
+func ParseStipulation(s string) (Stipulation, error) {
+	s = strings.TrimSpace(s)
+	kind := StipulationDirectMate
+	var rest string
+	switch {
+	case strings.HasPrefix(s, "h#"):
+		kind = StipulationHelpMate
+		rest = s[2:]
+	case strings.HasPrefix(s, "s#"):
+		kind = StipulationSelfMate
+		rest = s[2:]
+	case strings.HasPrefix(s, "#"):
+		rest = s[1:]
+	case strings.HasPrefix(s, "="):
+		if s == "=" {
+			return Stipulation{Kind: StipulationStudyDraw, Moves: defaultStudyHorizon}, nil
+		}
+		kind = StipulationStudyDraw
+		rest = s[1:]
+	default:
+		return Stipulation{}, errors.New("unknown stipulation")
+	}
+
+	n, err := strconv.Atoi(rest)
+	if err != nil || n < 1 {
+		return Stipulation{}, errors.New("invalid stipulation move count")
+	}
+	return Stipulation{Kind: kind, Moves: n}, nil
+}
+
+func (s Stipulation) String() string {
+	n := strconv.Itoa(s.Moves)
+	switch s.Kind {
+	case StipulationHelpMate:
+		return "h#" + n
+	case StipulationSelfMate:
+		return "s#" + n
+	case StipulationStudyDraw:
+		return "=" + n
+	default:
+		return "#" + n
+	}
+}
+
+func (s Stipulation) firstMover() Color {
+	if s.Kind == StipulationHelpMate {
+		return ColorBlack
+	}
+	return ColorWhite
+}
+
+// Solution is a key move. For helpmates Line holds the complete cooperative
+// sequence starting with the key.
+type Solution struct {
+	Key  Move
+	Line []Move
+}
+
+// Try is a first move that fails to exactly one defence.
+type Try struct {
+	Move       Move
+	Refutation Move
+}
+
+// SetPlay pairs a defence in the diagram position, as if the defending side
+// were to move, with the moves that still fulfil the stipulation against it.
+type SetPlay struct {
+	Defence Move
+	Replies []Move
+}
+
+type ProblemReport struct {
+	Stipulation Stipulation
+	Solutions   []Solution
+	Tries       []Try
+	SetPlay     []SetPlay
+}
+
+func SolveProblem(fen string, stipulation string) (*ProblemReport, error) {
+	stip, err := ParseStipulation(stipulation)
+	if err != nil {
+		return nil, err
+	}
+	// Submitted compositions must be reachable positions, with one king a
+	// side to begin with.
+	state, err := ParseLegalFEN(fen)
+	if err != nil {
+		return nil, err
+	}
+	return Solve(state, stip)
+}
+
+func Solve(state *GameState, stip Stipulation) (*ProblemReport, error) {
+	gs := state.Copy()
+	if err := gs.setProblemSideToMove(stip.firstMover()); err != nil {
+		return nil, err
+	}
+
+	report := &ProblemReport{Stipulation: stip}
+	switch stip.Kind {
+	case StipulationDirectMate:
+		report.Solutions, report.Tries = solveDirectMate(&gs, stip.Moves)
+		report.SetPlay = directMateSetPlay(&gs, stip.Moves)
+	case StipulationHelpMate:
+		for _, line := range helpMateLines(&gs, 2*stip.Moves) {
+			report.Solutions = append(report.Solutions, Solution{Key: line[0], Line: line})
+		}
+	case StipulationSelfMate:
+		for _, m := range GenerateLegalMoves(&gs) {
+			undo := gs.MakeMove(m)
+			if selfMateAfterKey(&gs, stip.Moves) {
+				report.Solutions = append(report.Solutions, Solution{Key: m})
+			}
+			gs.UnmakeMove(m, undo)
+		}
+	case StipulationStudyDraw:
+		line := map[string]int{gs.PositionKey(): 1}
+		for _, m := range GenerateLegalMoves(&gs) {
+			undo := gs.MakeMove(m)
+			if holdsDraw(&gs, stip.Moves-1, line) {
+				report.Solutions = append(report.Solutions, Solution{Key: m})
+			}
+			gs.UnmakeMove(m, undo)
+		}
+	}
+	return report, nil
+}
+
+func (gs *GameState) setProblemSideToMove(c Color) error {
+	if gs.SideToMove != c {
+		gs.SideToMove = c
+		gs.EnPassantSquare = Square(-1)
+	}
+	if IsSquareAttacked(gs, gs.GetKingSquare(c.Opponent()), c) {
+		return errors.New("side not to move is in check")
+	}
+	return nil
+}
+
+func isCheckmate(gs *GameState) bool {
+	return gs.IsKingInCheck() && len(GenerateLegalMoves(gs)) == 0
+}
+
+// canForceMate reports whether the side to move can force mate in at most n
+// of its own moves.
+func canForceMate(gs *GameState, n int) bool {
+	if n <= 0 {
+		return false
+	}
+	for _, m := range GenerateLegalMoves(gs) {
+		undo := gs.MakeMove(m)
+		ok := len(defencesAgainstMate(gs, n-1)) == 0 && !isStalemateOrEmpty(gs)
+		gs.UnmakeMove(m, undo)
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+func isStalemateOrEmpty(gs *GameState) bool {
+	return !gs.IsKingInCheck() && len(GenerateLegalMoves(gs)) == 0
+}
+
+// defencesAgainstMate returns the defender's moves after which the attacker
+// no longer mates within n moves.
+func defencesAgainstMate(gs *GameState, n int) []Move {
+	var refutations []Move
+	for _, d := range GenerateLegalMoves(gs) {
+		undo := gs.MakeMove(d)
+		if !canForceMate(gs, n) {
+			refutations = append(refutations, d)
+		}
+		gs.UnmakeMove(d, undo)
+	}
+	return refutations
+}
+
+// drawnByRule reports whether the game is drawn in gs whatever follows.
+func drawnByRule(gs *GameState) bool {
+	return isStalemateOrEmpty(gs) || hasInsufficientMaterial(gs) || isBlockedPawnFortress(gs) || gs.HalfMoveClock >= 100
+}
+
+// holdsDraw reports whether, with Black to move, White draws against every
+// defence within n more moves of its own. line counts the positions of the
+// line so far: one coming round again is a repetition White can keep up.
+// Mating Black is more than a draw and counts too.
+func holdsDraw(gs *GameState, n int, line map[string]int) bool {
+	key := gs.PositionKey()
+	if isCheckmate(gs) || drawnByRule(gs) || line[key] > 0 {
+		return true
+	}
+	line[key]++
+	defer func() { line[key]-- }()
+	for _, d := range GenerateLegalMoves(gs) {
+		undo := gs.MakeMove(d)
+		ok := !isCheckmate(gs) && (drawnByRule(gs) || line[gs.PositionKey()] > 0 || canForceDraw(gs, n, line))
+		gs.UnmakeMove(d, undo)
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// canForceDraw reports whether White, to move, forces a draw within n
+// moves.
+func canForceDraw(gs *GameState, n int, line map[string]int) bool {
+	if n <= 0 {
+		return false
+	}
+	key := gs.PositionKey()
+	line[key]++
+	defer func() { line[key]-- }()
+	for _, m := range GenerateLegalMoves(gs) {
+		undo := gs.MakeMove(m)
+		ok := holdsDraw(gs, n-1, line)
+		gs.UnmakeMove(m, undo)
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+func solveDirectMate(gs *GameState, n int) ([]Solution, []Try) {
+	var solutions []Solution
+	var tries []Try
+	for _, m := range GenerateLegalMoves(gs) {
+		undo := gs.MakeMove(m)
+		if !isStalemateOrEmpty(gs) {
+			refutations := defencesAgainstMate(gs, n-1)
+			switch len(refutations) {
+			case 0:
+				solutions = append(solutions, Solution{Key: m})
+			case 1:
+				tries = append(tries, Try{Move: m, Refutation: refutations[0]})
+			}
+		}
+		gs.UnmakeMove(m, undo)
+	}
+	return solutions, tries
+}
+
+func directMateSetPlay(gs *GameState, n int) []SetPlay {
+	set := gs.Copy()
+	if err := set.setProblemSideToMove(gs.SideToMove.Opponent()); err != nil {
+		return nil
+	}
+
+	var play []SetPlay
+	for _, d := range GenerateLegalMoves(&set) {
+		undo := set.MakeMove(d)
+		var replies []Move
+		for _, m := range GenerateLegalMoves(&set) {
+			u := set.MakeMove(m)
+			if !isStalemateOrEmpty(&set) && len(defencesAgainstMate(&set, n-1)) == 0 {
+				replies = append(replies, m)
+			}
+			set.UnmakeMove(m, u)
+		}
+		set.UnmakeMove(d, undo)
+		if len(replies) > 0 {
+			play = append(play, SetPlay{Defence: d, Replies: replies})
+		}
+	}
+	return play
+}
+
+func helpMateLines(gs *GameState, plies int) [][]Move {
+	var lines [][]Move
+	for _, m := range GenerateLegalMoves(gs) {
+		undo := gs.MakeMove(m)
+		if plies == 1 {
+			if isCheckmate(gs) {
+				lines = append(lines, []Move{m})
+			}
+		} else if !isCheckmate(gs) && !isStalemateOrEmpty(gs) {
+			for _, tail := range helpMateLines(gs, plies-1) {
+				lines = append(lines, append([]Move{m}, tail...))
+			}
+		}
+		gs.UnmakeMove(m, undo)
+	}
+	return lines
+}
+
+// selfMateAfterKey reports whether, with the defender to move, every defence
+// either mates the attacker or still leaves a selfmate in n-1.
+func selfMateAfterKey(gs *GameState, n int) bool {
+	defences := GenerateLegalMoves(gs)
+	if len(defences) == 0 {
+		return false
+	}
+	for _, d := range defences {
+		undo := gs.MakeMove(d)
+		ok := isCheckmate(gs)
+		if !ok && n > 1 {
+			ok = canForceSelfMate(gs, n-1)
+		}
+		gs.UnmakeMove(d, undo)
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func canForceSelfMate(gs *GameState, n int) bool {
+	for _, m := range GenerateLegalMoves(gs) {
+		undo := gs.MakeMove(m)
+		ok := selfMateAfterKey(gs, n)
+		gs.UnmakeMove(m, undo)
+		if ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,130 @@
+package chess
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func solutionKeys(r *ProblemReport) []string {
+	var keys []string
+	for _, s := range r.Solutions {
+		keys = append(keys, s.Key.String())
+	}
+	return keys
+}
+
+func TestParseStipulation(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Stipulation
+	}{
+		{"#2", Stipulation{Kind: StipulationDirectMate, Moves: 2}},
+		{"h#3", Stipulation{Kind: StipulationHelpMate, Moves: 3}},
+		{"s#2", Stipulation{Kind: StipulationSelfMate, Moves: 2}},
+		{"=3", Stipulation{Kind: StipulationStudyDraw, Moves: 3}},
+		{"=", Stipulation{Kind: StipulationStudyDraw, Moves: defaultStudyHorizon}},
+	}
+	for _, tt := range tests {
+		got, err := ParseStipulation(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseStipulation(%q) = %v, %v; want %v", tt.in, got, err, tt.want)
+		}
+	}
+	for _, bad := range []string{"", "#0", "x#2", "h#", "=0"} {
+		if _, err := ParseStipulation(bad); err == nil {
+			t.Errorf("ParseStipulation(%q) succeeded, want error", bad)
+		}
+	}
+}
+
+func TestSolveDirectMate(t *testing.T) {
+	r, err := SolveProblem("6k1/5ppp/8/8/8/8/8/R5K1 w - - 0 1", "#1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys := solutionKeys(r); !slices.Equal(keys, []string{"a1a8"}) {
+		t.Errorf("solutions = %v, want [a1a8]", keys)
+	}
+	if len(r.SetPlay) != 1 || r.SetPlay[0].Defence.String() != "g8h8" {
+		t.Errorf("set play = %v, want g8h8 answered by Ra8#", r.SetPlay)
+	}
+}
+
+func TestSolveDirectMateTries(t *testing.T) {
+	r, err := SolveProblem("k7/8/1K6/8/8/8/8/7R w - - 0 1", "#2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Contains(solutionKeys(r), "h1h8") {
+		t.Errorf("solutions %v do not include the short mate h1h8", solutionKeys(r))
+	}
+	for _, try := range r.Tries {
+		if try.Move.String() == "h1a1" && try.Refutation.String() == "a8b8" {
+			return
+		}
+	}
+	t.Errorf("tries = %v, want h1a1 refuted by a8b8", r.Tries)
+}
+
+func TestSolveHelpMate(t *testing.T) {
+	r, err := SolveProblem("7k/5ppp/8/8/8/8/8/R6K b - - 0 1", "h#1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var lines []string
+	for _, s := range r.Solutions {
+		lines = append(lines, s.Line[0].String()+" "+s.Line[1].String())
+	}
+	slices.Sort(lines)
+	want := []string{"f7f5 a1a8", "f7f6 a1a8", "h8g8 a1a8"}
+	if !slices.Equal(lines, want) {
+		t.Errorf("lines = %v, want %v", lines, want)
+	}
+}
+
+func TestSolveSelfMate(t *testing.T) {
+	r, err := SolveProblem("8/8/8/3Q4/8/b7/1q5k/4BK2 w - - 0 1", "s#1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys := solutionKeys(r); !slices.Equal(keys, []string{"d5g2"}) {
+		t.Errorf("solutions = %v, want [d5g2]", keys)
+	}
+}
+
+func TestSolveRejectsCheckedSideNotToMove(t *testing.T) {
+	if _, err := SolveProblem("4k3/8/8/8/8/8/8/4RK2 b - - 0 1", "#2"); err == nil {
+		t.Error("expected error when Black is in check and White is to move")
+	}
+}
+
+func TestSolveRejectsIllegalPositions(t *testing.T) {
+	for _, fen := range []string{
+		"8/8/8/8/8/8/8/R5K1 w - - 0 1",
+		"k6k/8/8/8/8/8/8/R5K1 w - - 0 1",
+	} {
+		if _, err := SolveProblem(fen, "#1"); !errors.Is(err, ErrKingCount) {
+			t.Errorf("SolveProblem(%q) error = %v, want %v", fen, err, ErrKingCount)
+		}
+	}
+}
+
+func TestSolveStudyDraw(t *testing.T) {
+	// Black threatens Qb2#; only the rook sacrifice Rg8+ Kxg8 saves White,
+	// by stalemate.
+	for _, stip := range []string{"=1", "=3", "="} {
+		r, err := SolveProblem("7k/5p1p/5P2/8/8/p7/P1q5/K5R1 w - - 0 1", stip)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if keys := solutionKeys(r); !slices.Equal(keys, []string{"g1g8"}) {
+			t.Errorf("%s: solutions = %v, want [g1g8]", stip, keys)
+		}
+	}
+	// Nothing forces a draw in a quiet pawn ending, so nothing solves it.
+	r, err := SolveProblem("8/8/4k3/8/8/8/4P3/4K3 w - - 0 1", "=2")
+	if err != nil || len(r.Solutions) != 0 {
+		t.Errorf("quiet ending: solutions = %v, %v; want none", solutionKeys(r), err)
+	}
+}
@@ -0,0 +1,476 @@
+package chess
+
+import "errors"
+
+var (
+	ErrKingCount             = errors.New("each side must have exactly one king")
+	ErrPawnOnBackRank        = errors.New("pawn on first or last rank")
+	ErrTooManyPawns          = errors.New("more than eight pawns")
+	ErrTooManyPromotions     = errors.New("promoted pieces exceed missing pawns")
+	ErrTooManyPawnCaptures   = errors.New("pawn structure needs more captures than missing pieces")
+	ErrOpponentInCheck       = errors.New("side not to move is in check")
+	ErrTooManyCheckers       = errors.New("king attacked by more than two pieces")
+	ErrImpossibleCheck       = errors.New("check cannot result from any last move")
+	ErrInvalidEnPassant      = errors.New("en passant square inconsistent with last move")
+	ErrInvalidCastlingRights = errors.New("castling rights without king and rook on home squares")
+)
+
+// ValidatePosition reports the first reason the position could not arise from
+// a legal game, or nil if none of the retro-analysis checks fail.
+func ValidatePosition(state *GameState) error {
+	if errs := RetroAnalyze(state); len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+// RetroAnalyze runs every check and returns all violations found. The checks
+// are necessary conditions for reachability, not a full proof game search.
+func RetroAnalyze(state *GameState) []error {
+	gs := state.Copy()
+	gs.cacheKingSquares()
+
+	var errs []error
+	if countPieces(&gs.Board, NewPiece(King, ColorWhite)) != 1 ||
+		countPieces(&gs.Board, NewPiece(King, ColorBlack)) != 1 {
+		// Everything below assumes both kings are present.
+		return []error{ErrKingCount}
+	}
+
+	for _, c := range []Color{ColorWhite, ColorBlack} {
+		if err := checkMaterial(&gs, c); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := checkCastlingRights(&gs); err != nil {
+		errs = append(errs, err)
+	}
+	if IsSquareAttacked(&gs, gs.GetKingSquare(gs.SideToMove.Opponent()), gs.SideToMove) {
+		errs = append(errs, ErrOpponentInCheck)
+	}
+	if err := checkEnPassant(&gs); err != nil {
+		errs = append(errs, err)
+	}
+	if err := checkLastMove(&gs); err != nil {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+func countPieces(board *Board, p Piece) int {
+	n := 0
+	for sq := range board {
+		if board[sq] == p {
+			n++
+		}
+	}
+	return n
+}
+
+func isLightSquare(sq Square) bool {
+	return (sq.File()+sq.Rank())%2 == 1
+}
+
+func checkMaterial(gs *GameState, c Color) error {
+	board := &gs.Board
+	var pawns, knights, rooks, queens, lightBishops, darkBishops int
+	var pawnFiles []int
+	for sq := Square(0); sq < 64; sq++ {
+		p := board[sq]
+		if p.IsEmpty() || p.Color != c {
+			continue
+		}
+		switch p.PieceType {
+		case Pawn:
+			if sq.Rank() == 0 || sq.Rank() == 7 {
+				return ErrPawnOnBackRank
+			}
+			pawns++
+			pawnFiles = append(pawnFiles, sq.File())
+		case Knight:
+			knights++
+		case Bishop:
+			if isLightSquare(sq) {
+				lightBishops++
+			} else {
+				darkBishops++
+			}
+		case Rook:
+			rooks++
+		case Queen:
+			queens++
+		}
+	}
+	if pawns > 8 {
+		return ErrTooManyPawns
+	}
+
+	promoted := max(0, queens-1) + max(0, rooks-2) + max(0, knights-2) +
+		max(0, lightBishops-1) + max(0, darkBishops-1)
+	if promoted > 8-pawns {
+		return ErrTooManyPromotions
+	}
+
+	if minPawnCaptures(pawnFiles) > countMissing(board, c.Opponent()) {
+		return ErrTooManyPawnCaptures
+	}
+	return nil
+}
+
+func countMissing(board *Board, c Color) int {
+	missing := 16
+	for sq := range board {
+		if !board[sq].IsEmpty() && board[sq].Color == c {
+			missing--
+		}
+	}
+	return missing
+}
+
+// minPawnCaptures assigns each pawn to a distinct starting file so that the
+// total file displacement, and therefore the number of captures, is minimal.
+func minPawnCaptures(files []int) int {
+	const inf = 1 << 30
+	best := make([]int, 1<<8)
+	for i := range best {
+		best[i] = inf
+	}
+	best[0] = 0
+	for i, file := range files {
+		next := make([]int, 1<<8)
+		for j := range next {
+			next[j] = inf
+		}
+		for used, cost := range best {
+			if cost == inf || popcount(used) != i {
+				continue
+			}
+			for start := 0; start < 8; start++ {
+				if used&(1<<start) != 0 {
+					continue
+				}
+				mask := used | 1<<start
+				next[mask] = min(next[mask], cost+abs(file-start))
+			}
+		}
+		best = next
+	}
+	result := inf
+	for _, cost := range best {
+		result = min(result, cost)
+	}
+	return result
+}
+
+func popcount(x int) int {
+	n := 0
+	for ; x != 0; x &= x - 1 {
+		n++
+	}
+	return n
+}
+
+func checkCastlingRights(gs *GameState) error {
+	board := &gs.Board
+	cr := gs.CastlingRights
+	rights := []struct {
+		has  bool
+		c    Color
+		rook Square
+	}{
+		{cr.WhiteKingSide, ColorWhite, NewSquare(FILEH, 0)},
+		{cr.WhiteQueenSide, ColorWhite, NewSquare(FILEA, 0)},
+		{cr.BlackKingSide, ColorBlack, NewSquare(FILEH, 7)},
+		{cr.BlackQueenSide, ColorBlack, NewSquare(FILEA, 7)},
+	}
+	for _, r := range rights {
+		if !r.has {
+			continue
+		}
+		king := NewSquare(FILEE, r.rook.Rank())
+		if board[king] != NewPiece(King, r.c) || board[r.rook] != NewPiece(Rook, r.c) {
+			return ErrInvalidCastlingRights
+		}
+	}
+	return nil
+}
+
+func checkEnPassant(gs *GameState) error {
+	ep := gs.EnPassantSquare
+	if ep == -1 {
+		return nil
+	}
+	if !ep.isValid() {
+		return ErrInvalidEnPassant
+	}
+	mover := gs.SideToMove.Opponent()
+	dir := 8
+	wantRank := 2
+	if mover == ColorBlack {
+		dir = -8
+		wantRank = 5
+	}
+	pawn := ep.applyOffset(dir)
+	origin := ep.applyOffset(-dir)
+	if ep.Rank() != wantRank || !gs.Board[ep].IsEmpty() || !gs.Board[origin].IsEmpty() ||
+		gs.Board[pawn] != NewPiece(Pawn, mover) {
+		return ErrInvalidEnPassant
+	}
+
+	for _, checker := range checkers(gs) {
+		if checker == pawn {
+			continue
+		}
+		if !isBetween(gs, checker, gs.GetKingSquare(gs.SideToMove), origin) {
+			return ErrInvalidEnPassant
+		}
+	}
+	return nil
+}
+
+func checkers(gs *GameState) []Square {
+	king := gs.GetKingSquare(gs.SideToMove)
+	attacker := gs.SideToMove.Opponent()
+	var result []Square
+	for sq := Square(0); sq < 64; sq++ {
+		p := gs.Board[sq]
+		if p.IsEmpty() || p.Color != attacker {
+			continue
+		}
+		if pieceAttacks(&gs.Board, sq, king) {
+			result = append(result, sq)
+		}
+	}
+	return result
+}
+
+// pieceAttacks reports whether the piece on from attacks target by itself,
+// ignoring pins and every other piece except as a blocker.
+func pieceAttacks(board *Board, from, target Square) bool {
+	p := board[from]
+	df := target.File() - from.File()
+	dr := target.Rank() - from.Rank()
+	switch p.PieceType {
+	case Pawn:
+		forward := 1
+		if p.Color == ColorBlack {
+			forward = -1
+		}
+		return dr == forward && abs(df) == 1
+	case Knight:
+		return (abs(df) == 1 && abs(dr) == 2) || (abs(df) == 2 && abs(dr) == 1)
+	case King:
+		return from != target && abs(df) <= 1 && abs(dr) <= 1
+	case Bishop, Rook, Queen:
+		step := lineDirection(from, target)
+		if step == 0 || from == target {
+			return false
+		}
+		diagonal := df != 0 && dr != 0
+		if (p.PieceType == Bishop && !diagonal) || (p.PieceType == Rook && diagonal) {
+			return false
+		}
+		for cur := from.applyOffset(step); cur != target; cur = cur.applyOffset(step) {
+			if !board[cur].IsEmpty() {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func lineDirection(from, to Square) int {
+	df := to.File() - from.File()
+	dr := to.Rank() - from.Rank()
+	if df != 0 && dr != 0 && abs(df) != abs(dr) {
+		return 0
+	}
+	step := 0
+	if df > 0 {
+		step++
+	} else if df < 0 {
+		step--
+	}
+	if dr > 0 {
+		step += 8
+	} else if dr < 0 {
+		step -= 8
+	}
+	return step
+}
+
+// isBetween reports whether sq lies strictly between a slider on from and the
+// square to along the line joining them.
+func isBetween(gs *GameState, from, to, sq Square) bool {
+	p := gs.Board[from].PieceType
+	if p != Bishop && p != Rook && p != Queen {
+		return false
+	}
+	step := lineDirection(from, to)
+	if step == 0 {
+		return false
+	}
+	for cur := from.applyOffset(step); cur != to; cur = cur.applyOffset(step) {
+		if cur == sq {
+			return true
+		}
+	}
+	return false
+}
+
+// retractionOrigins lists squares from which the piece on to could have made
+// the last move, including pawn squares for a piece that just promoted.
+func retractionOrigins(gs *GameState, to Square) []Square {
+	board := &gs.Board
+	p := board[to]
+	var origins []Square
+	addIfEmpty := func(sq Square, ok bool) {
+		if ok && sq.isValid() && board[sq].IsEmpty() {
+			origins = append(origins, sq)
+		}
+	}
+
+	back := -8
+	homeRank, lastRank := 1, 7
+	if p.Color == ColorBlack {
+		back = 8
+		homeRank, lastRank = 6, 0
+	}
+
+	switch p.PieceType {
+	case Pawn:
+		one := to.applyOffset(back)
+		addIfEmpty(one, one.isValid() && one.Rank() != 0 && one.Rank() != 7)
+		two := to.applyOffset(2 * back)
+		if two.isValid() && two.Rank() == homeRank && board[one].IsEmpty() {
+			addIfEmpty(two, true)
+		}
+		for _, side := range []int{-1, 1} {
+			sq := to.applyOffset(back + side)
+			addIfEmpty(sq, sq.isValid() && abs(sq.File()-to.File()) == 1 && sq.Rank() != 0 && sq.Rank() != 7)
+		}
+	case Knight:
+		for _, offset := range KnightOffsets {
+			sq := to.applyOffset(offset)
+			addIfEmpty(sq, sq.isValid() && abs(sq.File()-to.File()) <= 2 && abs(sq.Rank()-to.Rank()) <= 2)
+		}
+	case King:
+		for _, offset := range KingOffsets {
+			sq := to.applyOffset(offset)
+			addIfEmpty(sq, sq.isValid() && checkValidRankDiff(sq, to))
+		}
+	case Bishop, Rook, Queen:
+		offsets := QueenOffsets[:]
+		if p.PieceType == Bishop {
+			offsets = BishopOffsets[:]
+		} else if p.PieceType == Rook {
+			offsets = RookOffsets[:]
+		}
+		for _, offset := range offsets {
+			cur := to
+			for {
+				next := cur.applyOffset(offset)
+				if !next.isValid() || !checkValidRankDiff(cur, next) || !board[next].IsEmpty() {
+					break
+				}
+				origins = append(origins, next)
+				cur = next
+			}
+		}
+	}
+
+	if p.PieceType != Pawn && p.PieceType != King && to.Rank() == lastRank {
+		for _, side := range []int{0, -1, 1} {
+			sq := to.applyOffset(back + side)
+			addIfEmpty(sq, sq.isValid() && abs(sq.File()-to.File()) == abs(side))
+		}
+	}
+	return origins
+}
+
+// givesCheckFrom reports whether the piece now on to already attacked the king
+// from origin. With captured set, to is assumed to have held a blocking piece.
+func givesCheckFrom(gs *GameState, origin, to, king Square, captured bool) bool {
+	board := gs.Board
+	board[origin] = board[to]
+	board[to] = EmptyPiece()
+	if captured {
+		board[to] = NewPiece(Knight, board[origin].Color.Opponent())
+	}
+	return pieceAttacks(&board, origin, king)
+}
+
+// checkLastMove verifies that any check on the side to move can be explained
+// by a single last move: either the checking piece arrived on its square, or
+// it was uncovered by another piece leaving the line, or both at once.
+func checkLastMove(gs *GameState) error {
+	found := checkers(gs)
+	king := gs.GetKingSquare(gs.SideToMove)
+	switch len(found) {
+	case 0:
+		return nil
+	case 1:
+		checker := found[0]
+		capturePossible := countMissing(&gs.Board, gs.SideToMove) > 0
+		for _, origin := range retractionOrigins(gs, checker) {
+			if !givesCheckFrom(gs, origin, checker, king, false) ||
+				(capturePossible && !givesCheckFrom(gs, origin, checker, king, true)) {
+				return nil
+			}
+		}
+		if canBeDiscovered(gs, checker, king) {
+			return nil
+		}
+		return ErrImpossibleCheck
+	case 2:
+		for _, pair := range [2][2]Square{{found[0], found[1]}, {found[1], found[0]}} {
+			moved, discovered := pair[0], pair[1]
+			for _, origin := range retractionOrigins(gs, moved) {
+				if isBetween(gs, discovered, king, origin) {
+					return nil
+				}
+			}
+			if gs.Board[moved].PieceType == Pawn && enPassantDiscovers(gs, moved, discovered, king) {
+				return nil
+			}
+		}
+		return ErrImpossibleCheck
+	default:
+		return ErrTooManyCheckers
+	}
+}
+
+func canBeDiscovered(gs *GameState, checker, king Square) bool {
+	mover := gs.Board[checker].Color
+	for sq := Square(0); sq < 64; sq++ {
+		p := gs.Board[sq]
+		if sq == checker || p.IsEmpty() || p.Color != mover {
+			continue
+		}
+		for _, origin := range retractionOrigins(gs, sq) {
+			if isBetween(gs, checker, king, origin) {
+				return true
+			}
+		}
+		if p.PieceType == Pawn && enPassantDiscovers(gs, sq, checker, king) {
+			return true
+		}
+	}
+	return false
+}
+
+// enPassantDiscovers reports whether an en passant capture landing on pawnSq
+// could have removed the captured pawn from the checking line.
+func enPassantDiscovers(gs *GameState, pawnSq, checker, king Square) bool {
+	color := gs.Board[pawnSq].Color
+	captureRank, back := 5, -8
+	if color == ColorBlack {
+		captureRank, back = 2, 8
+	}
+	if pawnSq.Rank() != captureRank {
+		return false
+	}
+	captured := pawnSq.applyOffset(back)
+	return gs.Board[captured].IsEmpty() && isBetween(gs, checker, king, captured)
+}
@@ -0,0 +1,93 @@
+package chess
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidatePositionRejectsUnreachable(t *testing.T) {
+	tests := []struct {
+		name string
+		fen  string
+		want error
+	}{
+		{"missing king", "8/8/8/8/8/8/8/4K3 w - - 0 1", ErrKingCount},
+		{"pawn on back rank", "P3k3/8/8/8/8/8/8/4K3 w - - 0 1", ErrPawnOnBackRank},
+		{"nine pawns", "4k3/8/8/8/8/P7/PPPPPPPP/4K3 w - - 0 1", ErrTooManyPawns},
+		{"promotions without missing pawns", "QQ2k3/8/8/8/8/8/PPPPPPPP/4K3 w - - 0 1", ErrTooManyPromotions},
+		{"two same-colored bishops with full pawns", "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKB1B w - - 0 1", ErrTooManyPromotions},
+		{"tripled pawns without captures", "rnbqkbnr/pppppppp/8/8/P7/P7/P1PPPPP1/RNBQKBNR w - - 0 1", ErrTooManyPawnCaptures},
+		{"side not to move in check", "4k3/8/8/8/8/8/8/4RK2 w - - 0 1", ErrOpponentInCheck},
+		{"double check by two knights", "4k3/8/3N1N2/8/8/8/8/4K3 b - - 0 1", ErrImpossibleCheck},
+		{"ep square without pushed pawn", "4k3/8/8/8/8/8/8/4K3 b - e3 0 1", ErrInvalidEnPassant},
+		{"castling rights without rook", "4k3/8/8/8/8/8/8/4K3 w K - 0 1", ErrInvalidCastlingRights},
+		{"rook check with a retractable last move", "k7/8/8/8/8/8/8/R3K3 b - - 0 1", nil},
+		{"pawn check from its starting square", "8/8/8/8/8/4k3/3P4/7K b - - 0 1", ErrImpossibleCheck},
+		{"knight check boxed in", "k7/2N5/1PP5/8/8/8/8/4K3 b - - 0 1", nil},
+	}
+	for _, tt := range tests {
+		state, err := ParseFEN(tt.fen)
+		if err != nil {
+			t.Fatalf("%s: ParseFEN: %v", tt.name, err)
+		}
+		if got := ValidatePosition(state); !errors.Is(got, tt.want) {
+			t.Errorf("%s: ValidatePosition = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestValidatePositionImpossibleRookCheck(t *testing.T) {
+	// Every square the a1 rook could have come from already checks the king,
+	// and no other white piece could have uncovered the a-file.
+	state, err := ParseFEN("k7/8/8/8/8/8/8/RR5K b - - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ValidatePosition(state); !errors.Is(err, ErrImpossibleCheck) {
+		t.Errorf("ValidatePosition = %v, want %v", err, ErrImpossibleCheck)
+	}
+}
+
+func validateTree(t *testing.T, state *GameState, depth int) {
+	if err := ValidatePosition(state); err != nil {
+		t.Fatalf("reachable position %q rejected: %v", state.ToFEN(), err)
+	}
+	if depth == 0 {
+		return
+	}
+	for _, m := range GenerateLegalMoves(state) {
+		undo := state.MakeMove(m)
+		validateTree(t, state, depth-1)
+		state.UnmakeMove(m, undo)
+	}
+}
+
+func TestValidatePositionAcceptsReachable(t *testing.T) {
+	for _, fen := range []string{
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1",
+		"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1",
+		"8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1",
+	} {
+		state, err := ParseLegalFEN(fen)
+		if err != nil {
+			t.Fatalf("ParseLegalFEN(%q): %v", fen, err)
+		}
+		validateTree(t, state, 2)
+	}
+}
+
+func TestParseFENRejectsMalformed(t *testing.T) {
+	for _, fen := range []string{
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBN w KQkq - 0 1",
+		"rnbqkbnr/pppppppp/9/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1",
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR x KQkq - 0 1",
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KXkq - 0 1",
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq z9 0 1",
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - x 1",
+		"rnbqkbnr/ppppxppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1",
+	} {
+		if _, err := ParseFEN(fen); err == nil {
+			t.Errorf("ParseFEN(%q) succeeded, want error", fen)
+		}
+	}
+}
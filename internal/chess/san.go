@@ -0,0 +1,113 @@
+package chess
+
+import (
+	"errors"
+	"strings"
+)
+
+var ErrInvalidNotation = errors.New("invalid move notation")
+
+func (gs *GameState) MoveToSAN(m Move) string {
+	var sb strings.Builder
+	piece := gs.Board[m.From]
+
+	if m.IsCastle() {
+		if m.To.File() == FILEG {
+			sb.WriteString("O-O")
+		} else {
+			sb.WriteString("O-O-O")
+		}
+	} else {
+		if piece.PieceType == Pawn {
+			if m.IsCapture() {
+				sb.WriteByte(byte('a' + m.From.File()))
+			}
+		} else {
+			sb.WriteString(piece.PieceType.String())
+			sb.WriteString(gs.sanDisambiguation(m))
+		}
+		if m.IsCapture() {
+			sb.WriteByte('x')
+		}
+		sb.WriteString(m.To.String())
+		if m.IsPromotion() {
+			sb.WriteByte('=')
+			sb.WriteString(m.Promotion.String())
+		}
+	}
+
+	undo := gs.MakeMove(m)
+	if gs.IsKingInCheck() {
+		if len(GenerateLegalMoves(gs)) == 0 {
+			sb.WriteByte('#')
+		} else {
+			sb.WriteByte('+')
+		}
+	}
+	gs.UnmakeMove(m, undo)
+	return sb.String()
+}
+
+func (gs *GameState) sanDisambiguation(m Move) string {
+	pieceType := gs.Board[m.From].PieceType
+	sameFile, sameRank, ambiguous := false, false, false
+	for _, other := range GenerateLegalMoves(gs) {
+		if other.To != m.To || other.From == m.From || gs.Board[other.From].PieceType != pieceType {
+			continue
+		}
+		ambiguous = true
+		if other.From.File() == m.From.File() {
+			sameFile = true
+		}
+		if other.From.Rank() == m.From.Rank() {
+			sameRank = true
+		}
+	}
+	switch {
+	case !ambiguous:
+		return ""
+	case !sameFile:
+		return string(rune('a' + m.From.File()))
+	case !sameRank:
+		return string(rune('1' + m.From.Rank()))
+	default:
+		return m.From.String()
+	}
+}
+
+func trimSANSuffix(s string) string {
+	s = strings.TrimRight(s, "+#!?")
+	return strings.ReplaceAll(s, "0", "O")
+}
+
+// ParseSAN resolves a move in standard algebraic notation against the legal
+// moves of the position.
+func (gs *GameState) ParseSAN(s string) (Move, error) {
+	want := trimSANSuffix(strings.TrimSpace(s))
+	if want == "" {
+		return Move{}, ErrInvalidNotation
+	}
+	for _, m := range GenerateLegalMoves(gs) {
+		san := trimSANSuffix(gs.MoveToSAN(m))
+		if san == want || strings.Replace(san, "=", "", 1) == want {
+			return m, nil
+		}
+	}
+	return Move{}, ErrIllegalMove
+}
+
+// ParseUCI resolves a move in long algebraic notation, such as e2e4 or e7e8q,
+// against the legal moves of the position.
+func (gs *GameState) ParseUCI(s string) (Move, error) {
+	s = strings.TrimSpace(s)
+	if (len(s) != 4 && len(s) != 5) || !isValidSquareString(s[0:2]) || !isValidSquareString(s[2:4]) {
+		return Move{}, ErrInvalidNotation
+	}
+	parsed, _ := ParseMove(s)
+	for _, m := range GenerateLegalMoves(gs) {
+		if m.From == parsed.From && m.To == parsed.To && m.Promotion == parsed.Promotion {
+			return m, nil
+		}
+	}
+	return Move{}, ErrIllegalMove
+}
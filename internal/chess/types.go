@@ -1,6 +1,7 @@
 package chess
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 )
@@ -39,6 +40,17 @@ func (c Color) String() string {
 	}
 }
 
+func ParseColor(s string) (Color, error) {
+	switch strings.ToLower(s) {
+	case "w", "white":
+		return ColorWhite, nil
+	case "b", "black":
+		return ColorBlack, nil
+	default:
+		return ColorWhite, errors.New("invalid color")
+	}
+}
+
 type PieceType uint8
 
 const (
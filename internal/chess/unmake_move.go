@@ -8,13 +8,13 @@ func (gs *GameState) restoreGameState(ui UndoInfo) {
 }
 
 func (gs *GameState) restoreCapturedPiece(from, to Square, capturedPiece Piece) {
-	board := gs.Board
+	board := &gs.Board
 	board[from] = board[to]
 	board[to] = capturedPiece
 }
 
 func (gs *GameState) UnmakeNormalMove(m Move, ui UndoInfo) {
-	board := gs.Board
+	board := &gs.Board
 	from := m.From
 	to := m.To
 	color := board[to].Color
@@ -33,7 +33,7 @@ func (gs *GameState) UnmakeDoublePush(m Move, ui UndoInfo) {
 }
 
 func (gs *GameState) UnmakeCastle(m Move, ui UndoInfo) {
-	board := gs.Board
+	board := &gs.Board
 	from := m.From
 	to := m.To
 	board[ui.CastledRookFrom], board[ui.CastledRookTo] = board[ui.CastledRookTo], board[ui.CastledRookFrom]
@@ -44,7 +44,7 @@ func (gs *GameState) UnmakeCastle(m Move, ui UndoInfo) {
 }
 
 func (gs *GameState) UnmakeEnPassantSquare(m Move, ui UndoInfo) {
-	board := gs.Board
+	board := &gs.Board
 	from := m.From
 	to := m.To
 	board[from], board[to] = board[to], board[from]
@@ -53,7 +53,7 @@ func (gs *GameState) UnmakeEnPassantSquare(m Move, ui UndoInfo) {
 }
 
 func (gs *GameState) UnmakePromotion(m Move, ui UndoInfo) {
-	board := gs.Board
+	board := &gs.Board
 	from := m.From
 	to := m.To
 	color := board[to].Color
@@ -0,0 +1,250 @@
+package clock
+
+import (
+	"errors"
+	"time"
+
+	"github.com/THECHAMP95821/chess-backend/internal/chess"
+)
+
+var (
+	ErrNotRunning     = errors.New("clock is not running")
+	ErrFlagged        = errors.New("flag has fallen")
+	ErrNoBerserk      = errors.New("time control does not allow berserk")
+	ErrAlreadyBerserk = errors.New("already berserk")
+	ErrBerserkTooLate = errors.New("berserk is only possible before your first move")
+	ErrOddsMismatch   = errors.New("time odds need two controls of the same kind")
+)
+
+// Clock is a server-authoritative chess clock. It never reads the wall clock
+// itself: every call takes the server's notion of now, so the caller decides
+// which timestamps count.
+type Clock struct {
+	// controls are usually the same for both sides; they differ with time
+	// odds, as in armageddon.
+	controls  [2]TimeControl
+	remaining [2]time.Duration
+	stage     [2]int
+	moves     [2]int
+	moveTimes [2][]time.Duration
+	berserk   [2]bool
+
+	running   bool
+	turn      chess.Color
+	turnStart time.Time
+	flagged   bool
+	loser     chess.Color
+	onFlag    []func(chess.Color)
+}
+
+func New(tc TimeControl) *Clock {
+	return newClock(tc, tc)
+}
+
+// NewOdds makes a clock giving each side its own time control. Both must be
+// of the same mode, and hourglass cannot give odds.
+func NewOdds(white, black TimeControl) (*Clock, error) {
+	if white.Mode != black.Mode || white.Mode == ModeHourglass || len(white.Periods) == 0 || len(black.Periods) == 0 {
+		return nil, ErrOddsMismatch
+	}
+	return newClock(white, black), nil
+}
+
+func newClock(white, black TimeControl) *Clock {
+	c := &Clock{controls: [2]TimeControl{chess.ColorWhite: white, chess.ColorBlack: black}}
+	for color, tc := range c.controls {
+		if len(tc.Periods) > 0 {
+			c.remaining[color] = tc.Periods[0].Time
+		}
+	}
+	return c
+}
+
+// Control is White's time control, which is everyone's unless the clock
+// gives time odds.
+func (c *Clock) Control() TimeControl {
+	return c.controls[chess.ColorWhite]
+}
+
+func (c *Clock) ControlOf(color chess.Color) TimeControl {
+	return c.controls[color]
+}
+
+// Odds reports whether the sides have different time controls.
+func (c *Clock) Odds() bool {
+	return c.controls[chess.ColorWhite].String() != c.controls[chess.ColorBlack].String()
+}
+
+func (c *Clock) mode() Mode {
+	return c.controls[chess.ColorWhite].Mode
+}
+
+// OnFlag registers a hook called once, with the flagged side, when a flag
+// fall is detected by Press or CheckFlag.
+func (c *Clock) OnFlag(fn func(chess.Color)) {
+	c.onFlag = append(c.onFlag, fn)
+}
+
+func (c *Clock) Start(now time.Time, turn chess.Color) {
+	if c.flagged {
+		return
+	}
+	c.running = true
+	c.turn = turn
+	c.turnStart = now
+}
+
+// Stop freezes the clock, charging the side to move for the time used so far.
+func (c *Clock) Stop(now time.Time) {
+	if !c.running {
+		return
+	}
+	c.remaining = c.liveRemaining(now)
+	c.running = false
+}
+
+func (c *Clock) Running() bool {
+	return c.running
+}
+
+func (c *Clock) Turn() chess.Color {
+	return c.turn
+}
+
+func (c *Clock) period(color chess.Color) Period {
+	return c.controls[color].Periods[c.stage[color]]
+}
+
+func (c *Clock) elapsedCharge(color chess.Color, elapsed time.Duration) time.Duration {
+	if c.mode() == ModeSimpleDelay && !c.berserk[color] {
+		return max(0, elapsed-c.period(color).Increment)
+	}
+	return elapsed
+}
+
+func (c *Clock) liveRemaining(now time.Time) [2]time.Duration {
+	r := c.remaining
+	if !c.running || len(c.controls[c.turn].Periods) == 0 {
+		return r
+	}
+	elapsed := max(0, now.Sub(c.turnStart))
+	r[c.turn] -= c.elapsedCharge(c.turn, elapsed)
+	if c.mode() == ModeHourglass {
+		r[c.turn.Opponent()] += elapsed
+	}
+	return r
+}
+
+func (c *Clock) Remaining(color chess.Color, now time.Time) time.Duration {
+	return max(0, c.liveRemaining(now)[color])
+}
+
+// TimeUntilFlag is how long the side to move can think before flagging, for
+// scheduling a server-side flag check.
+func (c *Clock) TimeUntilFlag(now time.Time) (time.Duration, bool) {
+	if !c.running {
+		return 0, false
+	}
+	left := c.liveRemaining(now)[c.turn]
+	if c.mode() == ModeSimpleDelay && !c.berserk[c.turn] {
+		elapsed := now.Sub(c.turnStart)
+		left += max(0, c.period(c.turn).Increment-elapsed)
+	}
+	return max(0, left), true
+}
+
+// Press ends the turn of the side to move, records the time spent on the move
+// and applies increment, delay and period rollover.
+func (c *Clock) Press(now time.Time) (time.Duration, error) {
+	if c.flagged {
+		return 0, ErrFlagged
+	}
+	if !c.running {
+		return 0, ErrNotRunning
+	}
+	if c.CheckFlag(now) {
+		return 0, ErrFlagged
+	}
+
+	mover := c.turn
+	elapsed := max(0, now.Sub(c.turnStart))
+	c.remaining = c.liveRemaining(now)
+	c.moveTimes[mover] = append(c.moveTimes[mover], elapsed)
+
+	p := c.period(mover)
+	switch {
+	case c.berserk[mover]:
+	case c.mode() == ModeFischer:
+		c.remaining[mover] += p.Increment
+	case c.mode() == ModeBronstein:
+		c.remaining[mover] += min(elapsed, p.Increment)
+	}
+
+	c.moves[mover]++
+	if p.Moves > 0 && c.moves[mover] == p.Moves && c.stage[mover]+1 < len(c.controls[mover].Periods) {
+		c.stage[mover]++
+		c.moves[mover] = 0
+		c.remaining[mover] += c.period(mover).Time
+	}
+
+	c.turn = mover.Opponent()
+	c.turnStart = now
+	return elapsed, nil
+}
+
+// CheckFlag reports whether the side to move has run out of time, firing the
+// flag hooks the first time it happens.
+func (c *Clock) CheckFlag(now time.Time) bool {
+	if c.flagged {
+		return true
+	}
+	if !c.running {
+		return false
+	}
+	left, _ := c.TimeUntilFlag(now)
+	if left > 0 {
+		return false
+	}
+
+	c.remaining = c.liveRemaining(now)
+	c.remaining[c.turn] = 0
+	c.running = false
+	c.flagged = true
+	c.loser = c.turn
+	for _, fn := range c.onFlag {
+		fn(c.loser)
+	}
+	return true
+}
+
+// Berserk halves color's starting time and takes away their increment or
+// delay for the rest of the game. The caller must make sure color has not
+// moved yet; the clock only knows about moves that pressed it.
+func (c *Clock) Berserk(color chess.Color) error {
+	if c.mode() == ModeHourglass || len(c.controls[color].Periods) == 0 {
+		return ErrNoBerserk
+	}
+	if c.berserk[color] {
+		return ErrAlreadyBerserk
+	}
+	if c.flagged || c.moves[color] > 0 || c.stage[color] > 0 {
+		return ErrBerserkTooLate
+	}
+	c.berserk[color] = true
+	c.remaining[color] -= c.controls[color].Periods[0].Time / 2
+	return nil
+}
+
+func (c *Clock) Berserked(color chess.Color) bool {
+	return c.berserk[color]
+}
+
+func (c *Clock) Flagged() (chess.Color, bool) {
+	return c.loser, c.flagged
+}
+
+func (c *Clock) MoveTimes(color chess.Color) []time.Duration {
+	times := make([]time.Duration, len(c.moveTimes[color]))
+	copy(times, c.moveTimes[color])
+	return times
+}
@@ -0,0 +1,215 @@
+package clock
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/THECHAMP95821/chess-backend/internal/chess"
+)
+
+var t0 = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+func at(d time.Duration) time.Time {
+	return t0.Add(d)
+}
+
+func mustParse(t *testing.T, s string) TimeControl {
+	t.Helper()
+	tc, err := ParseTimeControl(s)
+	if err != nil {
+		t.Fatalf("ParseTimeControl(%q): %v", s, err)
+	}
+	return tc
+}
+
+func TestParseTimeControl(t *testing.T) {
+	tc := mustParse(t, "40/5400+30:1800+30")
+	want := []Period{
+		{Moves: 40, Time: 5400 * time.Second, Increment: 30 * time.Second},
+		{Time: 1800 * time.Second, Increment: 30 * time.Second},
+	}
+	if tc.Mode != ModeFischer || len(tc.Periods) != 2 || tc.Periods[0] != want[0] || tc.Periods[1] != want[1] {
+		t.Errorf("got %+v, want fischer %+v", tc, want)
+	}
+	// Only stages with an increment decide the mode.
+	if tc := mustParse(t, "40/5400:1800d30"); tc.Mode != ModeSimpleDelay || tc.Periods[1].Increment != 30*time.Second {
+		t.Errorf("40/5400:1800d30 = %+v, want a delay in the second stage", tc)
+	}
+	for _, s := range []string{"300+3", "40/5400+30:1800+30", "*180", "300d5", "300b5", "60", "40/5400:1800d30"} {
+		if got := mustParse(t, s).String(); got != s {
+			t.Errorf("round trip %q = %q", s, got)
+		}
+	}
+	for _, bad := range []string{"", "abc", "0/300", "300:40/60", "300+x", "40/5400d30:1800+30"} {
+		if _, err := ParseTimeControl(bad); err == nil {
+			t.Errorf("ParseTimeControl(%q) succeeded, want error", bad)
+		}
+	}
+}
+
+func TestFischerIncrement(t *testing.T) {
+	c := New(mustParse(t, "60+2"))
+	c.Start(t0, chess.ColorWhite)
+	elapsed, err := c.Press(at(10 * time.Second))
+	if err != nil || elapsed != 10*time.Second {
+		t.Fatalf("Press = %v, %v", elapsed, err)
+	}
+	if got := c.Remaining(chess.ColorWhite, at(10*time.Second)); got != 52*time.Second {
+		t.Errorf("white remaining = %v, want 52s", got)
+	}
+	if got := c.Remaining(chess.ColorBlack, at(15*time.Second)); got != 55*time.Second {
+		t.Errorf("black remaining while thinking = %v, want 55s", got)
+	}
+}
+
+func TestDelays(t *testing.T) {
+	simple := New(mustParse(t, "60d5"))
+	simple.Start(t0, chess.ColorWhite)
+	simple.Press(at(3 * time.Second))
+	if got := simple.Remaining(chess.ColorWhite, at(3*time.Second)); got != 60*time.Second {
+		t.Errorf("simple delay under delay: %v, want 60s", got)
+	}
+	simple.Press(at(4 * time.Second))
+	simple.Press(at(12 * time.Second))
+	if got := simple.Remaining(chess.ColorWhite, at(12*time.Second)); got != 57*time.Second {
+		t.Errorf("simple delay over delay: %v, want 57s", got)
+	}
+
+	bronstein := New(mustParse(t, "60b5"))
+	bronstein.Start(t0, chess.ColorWhite)
+	bronstein.Press(at(8 * time.Second))
+	if got := bronstein.Remaining(chess.ColorWhite, at(8*time.Second)); got != 57*time.Second {
+		t.Errorf("bronstein: %v, want 57s", got)
+	}
+}
+
+func TestHourglass(t *testing.T) {
+	c := New(mustParse(t, "*60"))
+	c.Start(t0, chess.ColorWhite)
+	c.Press(at(10 * time.Second))
+	if w, b := c.Remaining(chess.ColorWhite, at(10*time.Second)), c.Remaining(chess.ColorBlack, at(10*time.Second)); w != 50*time.Second || b != 70*time.Second {
+		t.Errorf("hourglass = %v/%v, want 50s/70s", w, b)
+	}
+}
+
+func TestPeriodRollover(t *testing.T) {
+	c := New(mustParse(t, "2/60:30"))
+	c.Start(t0, chess.ColorWhite)
+	now := t0
+	for i := 0; i < 4; i++ {
+		now = now.Add(5 * time.Second)
+		if _, err := c.Press(now); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := c.Remaining(chess.ColorWhite, now); got != 80*time.Second {
+		t.Errorf("after two moves: %v, want 80s", got)
+	}
+	if got := c.MoveTimes(chess.ColorBlack); len(got) != 2 || got[1] != 5*time.Second {
+		t.Errorf("black move times = %v", got)
+	}
+}
+
+func TestFlagFeedsOutcome(t *testing.T) {
+	game := chess.NewGame()
+	c := New(mustParse(t, "10+0"))
+	c.OnFlag(func(flagged chess.Color) {
+		game.Timeout(flagged)
+	})
+	c.Start(t0, chess.ColorWhite)
+
+	if left, _ := c.TimeUntilFlag(at(4 * time.Second)); left != 6*time.Second {
+		t.Errorf("TimeUntilFlag = %v, want 6s", left)
+	}
+	if _, err := c.Press(at(11 * time.Second)); err != ErrFlagged {
+		t.Fatalf("Press after flag: err = %v, want ErrFlagged", err)
+	}
+	if loser, ok := c.Flagged(); !ok || loser != chess.ColorWhite {
+		t.Errorf("Flagged = %v, %v", loser, ok)
+	}
+	if game.Termination() != chess.TerminationTimeout || game.Outcome().Result != chess.GameBlackWins {
+		t.Errorf("game = %v %+v, want timeout won by Black", game.Termination(), game.Outcome())
+	}
+}
+
+func TestRestoreAndShift(t *testing.T) {
+	tc, _ := ParseTimeControl("40/5400+30:1800+30")
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := New(tc)
+	c.Start(start, chess.ColorWhite)
+	c.Press(start.Add(10 * time.Second))
+
+	restored, err := Restore(c.State())
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := start.Add(20 * time.Second)
+	for _, color := range []chess.Color{chess.ColorWhite, chess.ColorBlack} {
+		if got, want := restored.Remaining(color, now), c.Remaining(color, now); got != want {
+			t.Errorf("%v remaining = %v, want %v", color, got, want)
+		}
+	}
+	if got := restored.MoveTimes(chess.ColorWhite); len(got) != 1 || got[0] != 10*time.Second {
+		t.Errorf("move times = %v", got)
+	}
+
+	// Black thought for 10s before a 5 minute outage.
+	restored.Shift(5 * time.Minute)
+	if got, want := restored.Remaining(chess.ColorBlack, now.Add(5*time.Minute)), c.Remaining(chess.ColorBlack, now); got != want {
+		t.Errorf("after shift black has %v, want %v", got, want)
+	}
+}
+
+func TestBerserk(t *testing.T) {
+	c := New(mustParse(t, "60+2"))
+	if err := c.Berserk(chess.ColorBlack); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Berserk(chess.ColorBlack); !errors.Is(err, ErrAlreadyBerserk) {
+		t.Errorf("second berserk: %v", err)
+	}
+	c.Start(t0, chess.ColorBlack)
+	c.Press(at(5 * time.Second))
+	if got := c.Remaining(chess.ColorBlack, at(5*time.Second)); got != 25*time.Second {
+		t.Errorf("berserk black remaining = %v, want 25s without increment", got)
+	}
+	if err := c.Berserk(chess.ColorBlack); !errors.Is(err, ErrAlreadyBerserk) {
+		t.Errorf("berserk after moving: %v", err)
+	}
+	c.Press(at(6 * time.Second))
+	if err := c.Berserk(chess.ColorWhite); !errors.Is(err, ErrBerserkTooLate) {
+		t.Errorf("white berserk after moving: %v", err)
+	}
+	if got := c.Remaining(chess.ColorWhite, at(6*time.Second)); got != 61*time.Second {
+		t.Errorf("white remaining = %v, want 61s", got)
+	}
+
+	restored, err := Restore(c.State())
+	if err != nil || !restored.Berserked(chess.ColorBlack) || restored.Berserked(chess.ColorWhite) {
+		t.Errorf("restored berserk = %v, %v (%v)", restored.Berserked(chess.ColorWhite), restored.Berserked(chess.ColorBlack), err)
+	}
+
+	if err := New(mustParse(t, "*60")).Berserk(chess.ColorWhite); !errors.Is(err, ErrNoBerserk) {
+		t.Errorf("hourglass berserk: %v", err)
+	}
+}
+
+func TestTimeOdds(t *testing.T) {
+	c, err := NewOdds(mustParse(t, "300+2"), mustParse(t, "240+2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Start(t0, chess.ColorWhite)
+	c.Press(at(10 * time.Second))
+	if w, b := c.Remaining(chess.ColorWhite, at(10*time.Second)), c.Remaining(chess.ColorBlack, at(10*time.Second)); w != 292*time.Second || b != 240*time.Second {
+		t.Errorf("remaining = %v/%v, want 292s/240s", w, b)
+	}
+	restored, err := Restore(c.State())
+	if err != nil || restored.ControlOf(chess.ColorBlack).String() != "240+2" || !restored.Odds() {
+		t.Errorf("restored odds: %v, %v", restored.State().BlackControl, err)
+	}
+	if _, err := NewOdds(mustParse(t, "300+2"), mustParse(t, "240d2")); !errors.Is(err, ErrOddsMismatch) {
+		t.Errorf("mixed modes: %v", err)
+	}
+}
@@ -0,0 +1,86 @@
+package clock
+
+import (
+	"time"
+
+	"github.com/THECHAMP95821/chess-backend/internal/chess"
+)
+
+// State is a clock frozen into plain data, so it can be stored and rebuilt
+// by another process.
+type State struct {
+	Control string `json:"control"`
+	// BlackControl is set when Black plays with time odds.
+	BlackControl string             `json:"black_control,omitempty"`
+	Remaining    [2]time.Duration   `json:"remaining"`
+	Stage        [2]int             `json:"stage"`
+	Moves        [2]int             `json:"moves"`
+	MoveTimes    [2][]time.Duration `json:"move_times"`
+	Berserk      [2]bool            `json:"berserk"`
+	Running      bool               `json:"running"`
+	Turn         chess.Color        `json:"turn"`
+	TurnStart    time.Time          `json:"turn_start"`
+	Flagged      bool               `json:"flagged"`
+	Loser        chess.Color        `json:"loser"`
+}
+
+func (c *Clock) State() State {
+	s := State{
+		Control:   c.Control().String(),
+		Remaining: c.remaining,
+		Stage:     c.stage,
+		Moves:     c.moves,
+		Berserk:   c.berserk,
+		Running:   c.running,
+		Turn:      c.turn,
+		TurnStart: c.turnStart,
+		Flagged:   c.flagged,
+		Loser:     c.loser,
+	}
+	for color := range s.MoveTimes {
+		s.MoveTimes[color] = append([]time.Duration(nil), c.moveTimes[color]...)
+	}
+	if c.Odds() {
+		s.BlackControl = c.controls[chess.ColorBlack].String()
+	}
+	return s
+}
+
+// Restore rebuilds a clock from its state. Flag hooks are not part of the
+// state and must be registered again.
+func Restore(s State) (*Clock, error) {
+	tc, err := ParseTimeControl(s.Control)
+	if err != nil {
+		return nil, err
+	}
+	black := tc
+	if s.BlackControl != "" {
+		if black, err = ParseTimeControl(s.BlackControl); err != nil {
+			return nil, err
+		}
+	}
+	c := &Clock{
+		controls:  [2]TimeControl{chess.ColorWhite: tc, chess.ColorBlack: black},
+		remaining: s.Remaining,
+		stage:     s.Stage,
+		moves:     s.Moves,
+		berserk:   s.Berserk,
+		running:   s.Running,
+		turn:      s.Turn,
+		turnStart: s.TurnStart,
+		flagged:   s.Flagged,
+		loser:     s.Loser,
+	}
+	for color := range c.moveTimes {
+		c.moveTimes[color] = append([]time.Duration(nil), s.MoveTimes[color]...)
+	}
+	return c, nil
+}
+
+// Shift moves the start of the current turn d later, so the side to move is
+// not charged for a stretch of time, such as a server being down.
+func (c *Clock) Shift(d time.Duration) {
+	if c.running && d > 0 {
+		c.turnStart = c.turnStart.Add(d)
+	}
+}
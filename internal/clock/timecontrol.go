@@ -0,0 +1,151 @@
+package clock
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Mode int
+
+const (
+	ModeFischer Mode = iota
+	ModeBronstein
+	ModeSimpleDelay
+	ModeHourglass
+)
+
+func (m Mode) String() string {
+	switch m {
+	case ModeFischer:
+		return "fischer"
+	case ModeBronstein:
+		return "bronstein"
+	case ModeSimpleDelay:
+		return "delay"
+	case ModeHourglass:
+		return "hourglass"
+	default:
+		return "INVALID MODE"
+	}
+}
+
+// Period is one stage of a time control. Moves is the number of moves to be
+// made within the stage; zero means the stage lasts for the rest of the game.
+// Increment is the Fischer increment or the delay, depending on the mode.
+type Period struct {
+	Moves     int
+	Time      time.Duration
+	Increment time.Duration
+}
+
+type TimeControl struct {
+	Mode    Mode
+	Periods []Period
+}
+
+// ParseTimeControl accepts PGN TimeControl strings such as "300+3",
+// "40/5400+30:1800+30" and "*180" for hourglass. A delay is written with
+// "d" (simple delay) or "b" (Bronstein) in place of "+", e.g. "300d5".
+// All values are in seconds.
+func ParseTimeControl(s string) (TimeControl, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return TimeControl{}, errors.New("empty time control")
+	}
+
+	if strings.HasPrefix(s, "*") {
+		secs, err := parseSeconds(s[1:])
+		if err != nil {
+			return TimeControl{}, err
+		}
+		return TimeControl{Mode: ModeHourglass, Periods: []Period{{Time: secs}}}, nil
+	}
+
+	tc := TimeControl{Mode: ModeFischer}
+	// The first stage with an increment sets the mode; stages without one
+	// fit any mode.
+	modeSet := false
+	stages := strings.Split(s, ":")
+	for i, stage := range stages {
+		var p Period
+		if moves, rest, ok := strings.Cut(stage, "/"); ok {
+			n, err := strconv.Atoi(moves)
+			if err != nil || n <= 0 {
+				return TimeControl{}, errors.New("invalid move count in time control")
+			}
+			p.Moves = n
+			stage = rest
+		}
+
+		base, inc, sep := stage, "", ""
+		if j := strings.IndexAny(stage, "+db"); j >= 0 {
+			base, inc, sep = stage[:j], stage[j+1:], stage[j:j+1]
+		}
+		var err error
+		if p.Time, err = parseSeconds(base); err != nil {
+			return TimeControl{}, err
+		}
+		if sep != "" {
+			if p.Increment, err = parseSeconds(inc); err != nil {
+				return TimeControl{}, err
+			}
+			mode := ModeFischer
+			switch sep {
+			case "d":
+				mode = ModeSimpleDelay
+			case "b":
+				mode = ModeBronstein
+			}
+			if p.Increment > 0 {
+				if modeSet && mode != tc.Mode {
+					return TimeControl{}, errors.New("mixed increment modes in time control")
+				}
+				tc.Mode, modeSet = mode, true
+			}
+		}
+		if p.Moves == 0 && i != len(stages)-1 {
+			return TimeControl{}, errors.New("only the last period may be sudden death")
+		}
+		tc.Periods = append(tc.Periods, p)
+	}
+	return tc, nil
+}
+
+func parseSeconds(s string) (time.Duration, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return 0, errors.New("invalid seconds in time control")
+	}
+	return time.Duration(n) * time.Second, nil
+}
+
+func (tc TimeControl) String() string {
+	if tc.Mode == ModeHourglass && len(tc.Periods) > 0 {
+		return "*" + strconv.Itoa(int(tc.Periods[0].Time/time.Second))
+	}
+	sep := "+"
+	switch tc.Mode {
+	case ModeSimpleDelay:
+		sep = "d"
+	case ModeBronstein:
+		sep = "b"
+	}
+
+	stages := make([]string, 0, len(tc.Periods))
+	for _, p := range tc.Periods {
+		var sb strings.Builder
+		if p.Moves > 0 {
+			sb.WriteString(strconv.Itoa(p.Moves))
+			sb.WriteByte('/')
+		}
+		sb.WriteString(strconv.Itoa(int(p.Time / time.Second)))
+		if p.Increment > 0 {
+			sb.WriteString(sep)
+			sb.WriteString(strconv.Itoa(int(p.Increment / time.Second)))
+		}
+		stages = append(stages, sb.String())
+	}
+	return strings.Join(stages, ":")
+}
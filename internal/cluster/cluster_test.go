@@ -0,0 +1,186 @@
+package cluster
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/THECHAMP95821/chess-backend/internal/api"
+	"github.com/THECHAMP95821/chess-backend/internal/game"
+	"github.com/THECHAMP95821/chess-backend/internal/livecache"
+)
+
+const testTTL = 10 * time.Second
+
+type testNode struct {
+	*Node
+	games *game.Service
+	url   string
+}
+
+func startNode(t *testing.T, rdb *redis.Client, name string) *testNode {
+	t.Helper()
+	var h http.Handler
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+
+	games := game.NewService()
+	leases := NewLeases(rdb, name, testTTL)
+	cache := livecache.New(rdb, name)
+	games.SetCache(cache)
+	games.SetLeaser(leases)
+	n := NewNode(rdb, leases, srv.URL, games, cache)
+	h = n.Forward(api.NewServer(games))
+	n.Tick(context.Background())
+	return &testNode{Node: n, games: games, url: srv.URL}
+}
+
+func post(t *testing.T, url string, body any) map[string]any {
+	t.Helper()
+	data, _ := json.Marshal(body)
+	resp, err := http.Post(url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var out map[string]any
+	json.NewDecoder(resp.Body).Decode(&out)
+	if resp.StatusCode >= 300 {
+		t.Fatalf("POST %s: status %d, body %v", url, resp.StatusCode, out)
+	}
+	return out
+}
+
+func TestForwardAndFailover(t *testing.T) {
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer rdb.Close()
+	ctx := context.Background()
+
+	a := startNode(t, rdb, "node-a")
+	b := startNode(t, rdb, "node-b")
+
+	created := post(t, a.url+"/api/games", map[string]string{"time_control": "300+0"})
+	id := created["id"].(string)
+	if owner, _ := a.leases.Owner(ctx, id); owner != "node-a" {
+		t.Fatalf("lease owner = %q, want node-a", owner)
+	}
+
+	// Node B does not have the game but forwards to its owner.
+	moved := post(t, b.url+"/api/games/"+id+"/moves", map[string]string{"color": "white", "uci": "e2e4"})
+	if moved["ply"] != float64(1) || b.games.Has(id) {
+		t.Fatalf("forwarded move = %v; node-b has game: %v", moved, b.games.Has(id))
+	}
+
+	// Node A stops renewing; once its lease lapses node B takes over.
+	mr.FastForward(testTTL + time.Second)
+	b.Tick(ctx)
+	if !b.games.Has(id) {
+		t.Fatal("node-b did not take over the game")
+	}
+	got, err := b.games.Get(id)
+	if err != nil || got.Ply != 1 {
+		t.Errorf("taken over game = %+v, %v", got, err)
+	}
+
+	// When node A comes back it finds its lease gone and lets the game go.
+	a.Tick(ctx)
+	if a.games.Has(id) {
+		t.Error("node-a still runs a game leased to node-b")
+	}
+	moved = post(t, a.url+"/api/games/"+id+"/moves", map[string]string{"color": "black", "uci": "e7e5"})
+	if moved["ply"] != float64(2) {
+		t.Errorf("move through node-a = %v", moved)
+	}
+}
+
+func TestLeaseExclusive(t *testing.T) {
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer rdb.Close()
+	ctx := context.Background()
+
+	a := NewLeases(rdb, "a", testTTL)
+	b := NewLeases(rdb, "b", testTTL)
+	if err := a.Acquire(ctx, "g"); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Acquire(ctx, "g"); err == nil {
+		t.Error("b acquired a lease held by a")
+	}
+	b.Release(ctx, "g")
+	if owner, _ := a.Owner(ctx, "g"); owner != "a" {
+		t.Errorf("owner after foreign release = %q, want a", owner)
+	}
+	a.Release(ctx, "g")
+	if err := b.Acquire(ctx, "g"); err != nil {
+		t.Errorf("acquire after release: %v", err)
+	}
+}
+
+func TestLeaveHandsOff(t *testing.T) {
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer rdb.Close()
+	ctx := context.Background()
+
+	a := startNode(t, rdb, "node-a")
+	b := startNode(t, rdb, "node-b")
+	id := post(t, a.url+"/api/games", map[string]string{})["id"].(string)
+
+	a.Leave(ctx)
+	if owner, _ := a.leases.Owner(ctx, id); owner != "" {
+		t.Fatalf("lease still held by %q after leave", owner)
+	}
+	b.Tick(ctx)
+	if !b.games.Has(id) {
+		t.Error("node-b did not take over at once")
+	}
+	resp, _ := http.Post(a.url+"/api/games", "application/json", nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("create on a leaving node: status %d, want 503", resp.StatusCode)
+	}
+}
+
+func TestRenewFailureGivesUpGame(t *testing.T) {
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer rdb.Close()
+	ctx := context.Background()
+
+	a := startNode(t, rdb, "node-a")
+	now := time.Now()
+	a.leases.now = func() time.Time { return now }
+	id := post(t, a.url+"/api/games", map[string]string{})["id"].(string)
+
+	// Cut off from Redis, node A keeps the game while its lease is sure to
+	// last, and lets it go before the lease can lapse.
+	mr.SetError("connection refused")
+	now = now.Add(testTTL / 4)
+	a.Tick(ctx)
+	if !a.games.Has(id) {
+		t.Fatal("node-a gave up a game with most of its lease left")
+	}
+	now = now.Add(testTTL / 2)
+	a.Tick(ctx)
+	if a.games.Has(id) {
+		t.Fatal("node-a still runs a game whose lease is about to lapse")
+	}
+
+	// Once Redis is back the game is free to be taken over again.
+	mr.SetError("")
+	a.Tick(ctx)
+	if !a.games.Has(id) {
+		t.Error("node-a did not take its game back")
+	}
+}
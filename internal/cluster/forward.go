@@ -0,0 +1,88 @@
+package cluster
+
+import (
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// forwardedHeader marks a request that one node has already forwarded, so
+// that two nodes with different views of a lease cannot bounce it forever.
+const forwardedHeader = "X-Chess-Forwarded-By"
+
+type proxies struct {
+	mu sync.Mutex
+	m  map[string]*httputil.ReverseProxy
+}
+
+func (p *proxies) get(addr string) (*httputil.ReverseProxy, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if rp, ok := p.m[addr]; ok {
+		return rp, nil
+	}
+	target, err := url.Parse(addr)
+	if err != nil {
+		return nil, err
+	}
+	rp := &httputil.ReverseProxy{
+		Rewrite: func(r *httputil.ProxyRequest) {
+			r.SetURL(target)
+			r.SetXForwarded()
+		},
+	}
+	if p.m == nil {
+		p.m = make(map[string]*httputil.ReverseProxy)
+	}
+	p.m[addr] = rp
+	return rp, nil
+}
+
+// gameID extracts the game a request is about from /api/games/{id}/...,
+// or from the bot API's /api/bot/game/{id}/... and
+// /api/bot/game/stream/{id}.
+func gameID(path string) string {
+	rest, ok := strings.CutPrefix(path, "/api/games/")
+	if !ok {
+		if rest, ok = strings.CutPrefix(path, "/api/bot/game/"); !ok {
+			return ""
+		}
+		rest = strings.TrimPrefix(rest, "stream/")
+	}
+	id, _, _ := strings.Cut(rest, "/")
+	return id
+}
+
+// Forward wraps next so that requests about a game owned by another node
+// are proxied there, WebSocket upgrades included. Everything else, and
+// games nobody owns, is served locally.
+func (n *Node) Forward(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := gameID(r.URL.Path)
+		if id == "" || n.games.Has(id) || r.Header.Get(forwardedHeader) != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		owner, err := n.leases.Owner(r.Context(), id)
+		if err != nil || owner == "" || owner == n.leases.Node() {
+			next.ServeHTTP(w, r)
+			return
+		}
+		addr, err := n.address(r.Context(), owner)
+		if err != nil || addr == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		rp, err := n.proxy.get(addr)
+		if err != nil {
+			log.Printf("cluster: forward to %s: %v", owner, err)
+			next.ServeHTTP(w, r)
+			return
+		}
+		r.Header.Set(forwardedHeader, n.leases.Node())
+		rp.ServeHTTP(w, r)
+	})
+}
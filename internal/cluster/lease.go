@@ -0,0 +1,119 @@
+// Package cluster spreads live games over several server nodes. Every game
+// is owned by exactly one node, which holds an expiring lease on it in Redis
+// and is the only one keeping it in memory.
+package cluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var ErrNotOwner = errors.New("game is leased to another node")
+
+func leaseKey(gameID string) string {
+	return "chess:lease:" + gameID
+}
+
+// acquireScript takes the lease if it is free or already ours, and resets
+// its expiry either way.
+var acquireScript = redis.NewScript(`
+local owner = redis.call('GET', KEYS[1])
+if owner == false or owner == ARGV[1] then
+	redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+	return 1
+end
+return 0`)
+
+var releaseScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0`)
+
+// Leases hands out game leases to one node. A lease lasts TTL unless it is
+// renewed, so the games of a node that dies fail over once it lapses.
+type Leases struct {
+	rdb  *redis.Client
+	node string
+	ttl  time.Duration
+	now  func() time.Time
+
+	mu sync.Mutex
+	// expires is when each lease this node holds lapses, by the local
+	// clock, unless it is renewed first.
+	expires map[string]time.Time
+}
+
+func NewLeases(rdb *redis.Client, node string, ttl time.Duration) *Leases {
+	return &Leases{rdb: rdb, node: node, ttl: ttl, now: time.Now, expires: make(map[string]time.Time)}
+}
+
+func (l *Leases) Node() string {
+	return l.node
+}
+
+// Acquire takes or renews the lease on a game for this node.
+func (l *Leases) Acquire(ctx context.Context, gameID string) error {
+	// The lease runs from no earlier than now, so expiry by the local
+	// clock is never later than in Redis.
+	start := l.now()
+	ok, err := acquireScript.Run(ctx, l.rdb, []string{leaseKey(gameID)}, l.node, l.ttl.Milliseconds()).Int()
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if ok == 0 {
+		delete(l.expires, gameID)
+		return fmt.Errorf("game %s: %w", gameID, ErrNotOwner)
+	}
+	l.expires[gameID] = start.Add(l.ttl)
+	return nil
+}
+
+// Release gives up the lease on a game if this node holds it.
+func (l *Leases) Release(ctx context.Context, gameID string) error {
+	l.mu.Lock()
+	delete(l.expires, gameID)
+	l.mu.Unlock()
+	return releaseScript.Run(ctx, l.rdb, []string{leaseKey(gameID)}, l.node).Err()
+}
+
+// Remaining is how long this node's lease on a game is sure to last, or
+// zero if it holds none.
+func (l *Leases) Remaining(gameID string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	exp, ok := l.expires[gameID]
+	if !ok {
+		return 0
+	}
+	return max(exp.Sub(l.now()), 0)
+}
+
+// forgetLapsed drops the leases that have lapsed, such as those of games
+// that ended here.
+func (l *Leases) forgetLapsed() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	for id, exp := range l.expires {
+		if !exp.After(now) {
+			delete(l.expires, id)
+		}
+	}
+}
+
+// Owner returns the node holding the lease on a game, or "" if none does.
+func (l *Leases) Owner(ctx context.Context, gameID string) (string, error) {
+	owner, err := l.rdb.Get(ctx, leaseKey(gameID)).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", nil
+	}
+	return owner, err
+}
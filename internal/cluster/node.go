@@ -0,0 +1,158 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/THECHAMP95821/chess-backend/internal/game"
+	"github.com/THECHAMP95821/chess-backend/internal/livecache"
+)
+
+const addrsKey = "chess:node_addrs"
+
+// Node is this server's membership of the cluster: it keeps the leases on
+// its games, takes over games whose lease has lapsed and forwards requests
+// for games owned elsewhere.
+type Node struct {
+	leases *Leases
+	rdb    *redis.Client
+	addr   string
+	games  *game.Service
+	cache  *livecache.Cache
+	proxy  proxies
+}
+
+// NewNode joins games to the cluster. addr is the base URL other nodes use
+// to reach this one, such as "http://10.0.0.5:8080".
+func NewNode(rdb *redis.Client, leases *Leases, addr string, games *game.Service, cache *livecache.Cache) *Node {
+	return &Node{
+		leases: leases,
+		rdb:    rdb,
+		addr:   addr,
+		games:  games,
+		cache:  cache,
+	}
+}
+
+// Run advertises the node, renews its leases and fails over orphaned games
+// every interval until ctx is done. interval must be under half the lease
+// TTL, or a node cut off from Redis may keep a game after its lease lapses.
+func (n *Node) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		n.Tick(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Tick does one round of Run.
+func (n *Node) Tick(ctx context.Context) {
+	if err := n.rdb.HSet(ctx, addrsKey, n.leases.Node(), n.addr).Err(); err != nil {
+		log.Printf("cluster: advertise: %v", err)
+	}
+	n.renew(ctx)
+	if err := n.failover(ctx); err != nil {
+		log.Printf("cluster: failover: %v", err)
+	}
+}
+
+// renew extends the lease of every local game. A game whose lease has gone
+// to another node is evicted at once, so that two nodes never both play it.
+// So is one whose lease could not be renewed and has less than half its TTL
+// left: while Redis is out of reach this node cannot tell whether another
+// will take the game over, and must let it go before the lease lapses.
+func (n *Node) renew(ctx context.Context) {
+	for _, id := range n.games.IDs() {
+		err := n.leases.Acquire(ctx, id)
+		switch {
+		case errors.Is(err, ErrNotOwner):
+			log.Printf("cluster: lost lease on game %s", id)
+			n.games.Evict(id)
+		case err != nil && n.leases.Remaining(id) < n.leases.ttl/2:
+			log.Printf("cluster: giving up game %s, lease about to lapse: %v", id, err)
+			n.games.Evict(id)
+		case err != nil:
+			log.Printf("cluster: renew lease on game %s: %v", id, err)
+		}
+	}
+	n.leases.forgetLapsed()
+}
+
+// failover takes over cached games that no node holds a lease on.
+func (n *Node) failover(ctx context.Context) error {
+	local := make(map[string]bool)
+	for _, id := range n.games.IDs() {
+		local[id] = true
+	}
+	ids, err := n.cache.IDs(ctx)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if local[id] {
+			continue
+		}
+		owner, err := n.leases.Owner(ctx, id)
+		if err != nil {
+			return err
+		}
+		if owner != "" && owner != n.leases.Node() {
+			continue
+		}
+		if err := n.takeOver(ctx, id); err != nil {
+			log.Printf("cluster: take over game %s: %v", id, err)
+		}
+	}
+	return nil
+}
+
+func (n *Node) takeOver(ctx context.Context, id string) error {
+	if err := n.leases.Acquire(ctx, id); err != nil {
+		return err
+	}
+	st, prev, err := n.cache.Load(ctx, id)
+	if err != nil {
+		n.leases.Release(ctx, id)
+		return err
+	}
+	down, err := n.cache.DownSince(ctx, st, prev)
+	if err != nil {
+		n.leases.Release(ctx, id)
+		return err
+	}
+	if _, err := n.games.Restore(st, down); err != nil && !errors.Is(err, game.ErrGameExists) {
+		n.leases.Release(ctx, id)
+		return err
+	}
+	log.Printf("cluster: took over game %s from %s", id, prev)
+	return nil
+}
+
+// Leave hands every local game off and releases its lease, so that other
+// nodes can take it over at once instead of waiting for the lease to lapse.
+// Run must have stopped first, or this node could take its games back.
+func (n *Node) Leave(ctx context.Context) {
+	for _, id := range n.games.Handoff() {
+		if err := n.leases.Release(ctx, id); err != nil {
+			log.Printf("cluster: release game %s: %v", id, err)
+		}
+	}
+	n.rdb.HDel(ctx, addrsKey, n.leases.Node())
+}
+
+func (n *Node) address(ctx context.Context, node string) (string, error) {
+	addr, err := n.rdb.HGet(ctx, addrsKey, node).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", nil
+	}
+	return addr, err
+}
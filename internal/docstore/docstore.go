@@ -0,0 +1,144 @@
+// Package docstore keeps the state of things that outlive any one game,
+// such as tournaments, where every node of the cluster can reach it.
+// Documents are JSON, grouped in collections, and a lock per document lets
+// one node at a time change it.
+package docstore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// lockWait is how long Lock waits for a lock before giving up.
+const lockWait = 5 * time.Second
+
+var (
+	ErrNotFound = errors.New("document not found")
+	ErrLocked   = errors.New("document is locked")
+)
+
+// Store holds documents by collection and ID.
+type Store interface {
+	// Get returns a document, or ErrNotFound.
+	Get(ctx context.Context, coll, id string) ([]byte, error)
+	Put(ctx context.Context, coll, id string, data []byte) error
+	Delete(ctx context.Context, coll, id string) error
+	// IDs lists the documents of a collection in no particular order.
+	IDs(ctx context.Context, coll string) ([]string, error)
+	// Lock waits until it holds the lock on a document, which need not
+	// exist, and returns the function that releases it. It gives up with
+	// ErrLocked if the lock stays taken.
+	Lock(ctx context.Context, coll, id string) (unlock func(), err error)
+}
+
+// Load decodes a document into v.
+func Load(ctx context.Context, st Store, coll, id string, v any) error {
+	data, err := st.Get(ctx, coll, id)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// Save encodes v as a document.
+func Save(ctx context.Context, st Store, coll, id string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return st.Put(ctx, coll, id, data)
+}
+
+// Update decodes a document into v under its lock, runs fn and saves v
+// unless fn fails.
+func Update(ctx context.Context, st Store, coll, id string, v any, fn func() error) error {
+	unlock, err := st.Lock(ctx, coll, id)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err := Load(ctx, st, coll, id, v); err != nil {
+		return err
+	}
+	if err := fn(); err != nil {
+		return err
+	}
+	return Save(ctx, st, coll, id, v)
+}
+
+// Memory is a Store for a single process and for tests.
+type Memory struct {
+	mu    sync.Mutex
+	docs  map[string]map[string][]byte
+	locks map[string]chan struct{}
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		docs:  make(map[string]map[string][]byte),
+		locks: make(map[string]chan struct{}),
+	}
+}
+
+func (m *Memory) Get(ctx context.Context, coll, id string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	data, ok := m.docs[coll][id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return slices.Clone(data), nil
+}
+
+func (m *Memory) Put(ctx context.Context, coll, id string, data []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.docs[coll] == nil {
+		m.docs[coll] = make(map[string][]byte)
+	}
+	m.docs[coll][id] = slices.Clone(data)
+	return nil
+}
+
+func (m *Memory) Delete(ctx context.Context, coll, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.docs[coll], id)
+	return nil
+}
+
+func (m *Memory) IDs(ctx context.Context, coll string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ids := make([]string, 0, len(m.docs[coll]))
+	for id := range m.docs[coll] {
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func (m *Memory) Lock(ctx context.Context, coll, id string) (func(), error) {
+	key := coll + "/" + id
+	m.mu.Lock()
+	lock, ok := m.locks[key]
+	if !ok {
+		lock = make(chan struct{}, 1)
+		m.locks[key] = lock
+	}
+	m.mu.Unlock()
+	timer := time.NewTimer(lockWait)
+	defer timer.Stop()
+	select {
+	case lock <- struct{}{}:
+		return func() { <-lock }, nil
+	case <-timer.C:
+		return nil, fmt.Errorf("%s %s: %w", coll, id, ErrLocked)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
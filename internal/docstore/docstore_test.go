@@ -0,0 +1,98 @@
+package docstore
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+type counter struct {
+	N int `json:"n"`
+}
+
+// testStore checks the behaviour every Store must share.
+func testStore(t *testing.T, st Store) {
+	ctx := context.Background()
+	var c counter
+	if err := Load(ctx, st, "counters", "a", &c); !errors.Is(err, ErrNotFound) {
+		t.Errorf("missing document: err = %v, want ErrNotFound", err)
+	}
+	if err := Update(ctx, st, "counters", "a", &c, func() error { return nil }); !errors.Is(err, ErrNotFound) {
+		t.Errorf("updating a missing document: err = %v, want ErrNotFound", err)
+	}
+	if err := Save(ctx, st, "counters", "a", counter{}); err != nil {
+		t.Fatal(err)
+	}
+	Save(ctx, st, "counters", "b", counter{N: 7})
+
+	// Concurrent updates take turns.
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var c counter
+			if err := Update(ctx, st, "counters", "a", &c, func() error { c.N++; return nil }); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if err := Load(ctx, st, "counters", "a", &c); err != nil || c.N != 20 {
+		t.Errorf("after 20 updates: %+v, %v", c, err)
+	}
+
+	// A failed update changes nothing.
+	failed := errors.New("failed")
+	if err := Update(ctx, st, "counters", "a", &c, func() error { c.N = 0; return failed }); !errors.Is(err, failed) {
+		t.Errorf("failing update: err = %v", err)
+	}
+	if Load(ctx, st, "counters", "a", &c); c.N != 20 {
+		t.Errorf("failed update saved %+v", c)
+	}
+
+	ids, err := st.IDs(ctx, "counters")
+	slices.Sort(ids)
+	if err != nil || !slices.Equal(ids, []string{"a", "b"}) {
+		t.Errorf("IDs = %v, %v", ids, err)
+	}
+	if err := st.Delete(ctx, "counters", "b"); err != nil {
+		t.Fatal(err)
+	}
+	if ids, _ := st.IDs(ctx, "counters"); !slices.Equal(ids, []string{"a"}) {
+		t.Errorf("IDs after Delete = %v", ids)
+	}
+
+	// Another collection is apart.
+	if ids, err := st.IDs(ctx, "others"); err != nil || len(ids) != 0 {
+		t.Errorf("IDs of an empty collection = %v, %v", ids, err)
+	}
+}
+
+func TestMemory(t *testing.T) {
+	testStore(t, NewMemory())
+}
+
+func TestRedis(t *testing.T) {
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer rdb.Close()
+	testStore(t, NewRedis(rdb))
+
+	// A lock left behind by a node that died lapses.
+	ctx := context.Background()
+	if _, err := NewRedis(rdb).Lock(ctx, "counters", "c"); err != nil {
+		t.Fatal(err)
+	}
+	mr.FastForward(lockTTL)
+	unlock, err := NewRedis(rdb).Lock(ctx, "counters", "c")
+	if err != nil {
+		t.Fatalf("lock after the holder's lapsed: %v", err)
+	}
+	unlock()
+}
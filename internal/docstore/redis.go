@@ -0,0 +1,103 @@
+package docstore
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// lockTTL bounds how long a node that died holding a lock keeps others
+	// out. No change may take longer.
+	lockTTL    = 10 * time.Second
+	lockRetry  = 10 * time.Millisecond
+	keyPrefix  = "chess:doc:"
+	lockSuffix = ":lock"
+)
+
+func collKey(coll string) string {
+	return keyPrefix + coll
+}
+
+func docKey(coll, id string) string {
+	return keyPrefix + coll + ":" + id
+}
+
+var unlockScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0`)
+
+// Redis is the Store shared by all nodes. Every document is a key of its
+// own, and a set per collection indexes them.
+type Redis struct {
+	rdb *redis.Client
+}
+
+func NewRedis(rdb *redis.Client) *Redis {
+	return &Redis{rdb: rdb}
+}
+
+func (r *Redis) Get(ctx context.Context, coll, id string) ([]byte, error) {
+	data, err := r.rdb.Get(ctx, docKey(coll, id)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
+
+func (r *Redis) Put(ctx context.Context, coll, id string, data []byte) error {
+	_, err := r.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, docKey(coll, id), data, 0)
+		pipe.SAdd(ctx, collKey(coll), id)
+		return nil
+	})
+	return err
+}
+
+func (r *Redis) Delete(ctx context.Context, coll, id string) error {
+	_, err := r.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, docKey(coll, id))
+		pipe.SRem(ctx, collKey(coll), id)
+		return nil
+	})
+	return err
+}
+
+func (r *Redis) IDs(ctx context.Context, coll string) ([]string, error) {
+	return r.rdb.SMembers(ctx, collKey(coll)).Result()
+}
+
+func (r *Redis) Lock(ctx context.Context, coll, id string) (func(), error) {
+	b := make([]byte, 8)
+	rand.Read(b)
+	token := hex.EncodeToString(b)
+	key := docKey(coll, id) + lockSuffix
+
+	deadline := time.Now().Add(lockWait)
+	for {
+		ok, err := r.rdb.SetNX(ctx, key, token, lockTTL).Result()
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return func() {
+				unlockScript.Run(context.Background(), r.rdb, []string{key}, token)
+			}, nil
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("%s %s: %w", coll, id, ErrLocked)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(lockRetry):
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package fanout
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/THECHAMP95821/chess-backend/internal/game"
+)
+
+const (
+	endingsKey   = "chess:endings"
+	endingsGroup = "scorers"
+	// endingsLength is roughly how many endings the stream keeps.
+	endingsLength = 10000
+	// claimIdle is how long an ending may go unacknowledged before another
+	// node handles it instead.
+	claimIdle   = 30 * time.Second
+	endingBatch = 16
+)
+
+// Endings carries the final view of every finished game through a Redis
+// stream that all nodes read as one consumer group, so that whatever scores
+// games, such as tournaments, learns of every game whichever node it ended
+// on. Each ending goes to one node; if that node fails to handle it, it
+// goes to another after claimIdle.
+type Endings struct {
+	rdb   *redis.Client
+	node  string
+	queue chan game.View
+	// closing stops retries once Close is called.
+	closing chan struct{}
+	wg      sync.WaitGroup
+}
+
+func NewEndings(rdb *redis.Client, node string) *Endings {
+	e := &Endings{
+		rdb:     rdb,
+		node:    node,
+		queue:   make(chan game.View, publishBuffer),
+		closing: make(chan struct{}),
+	}
+	e.wg.Add(1)
+	go e.run()
+	return e
+}
+
+// Send adds a finished game to the stream. It is meant to be registered
+// with game.Service.OnGameEnd, and never blocks the game: while Redis is
+// out of reach endings wait in a buffer.
+func (e *Endings) Send(v game.View) {
+	select {
+	case e.queue <- v:
+	default:
+		log.Printf("fanout: dropped the ending of game %s", v.ID)
+	}
+}
+
+func (e *Endings) run() {
+	defer e.wg.Done()
+	ctx := context.Background()
+	for v := range e.queue {
+		data, err := json.Marshal(v)
+		if err != nil {
+			log.Printf("fanout: encode ending: %v", err)
+			continue
+		}
+		e.send(ctx, v.ID, data)
+	}
+}
+
+// send retries until the ending is in the stream or Close is called.
+func (e *Endings) send(ctx context.Context, id string, data []byte) {
+	for retry := time.Second; ; retry = min(2*retry, time.Minute) {
+		err := e.rdb.XAdd(ctx, &redis.XAddArgs{
+			Stream: endingsKey,
+			MaxLen: endingsLength,
+			Approx: true,
+			Values: map[string]any{"game": data},
+		}).Err()
+		if err == nil {
+			return
+		}
+		log.Printf("fanout: send the ending of game %s: %v", id, err)
+		select {
+		case <-e.closing:
+			log.Printf("fanout: dropped the ending of game %s", id)
+			return
+		case <-time.After(retry):
+		}
+	}
+}
+
+// Close sends whatever is still queued, trying each once more, and stops
+// sending. Send must not be called afterwards.
+func (e *Endings) Close() {
+	close(e.closing)
+	close(e.queue)
+	e.wg.Wait()
+}
+
+// Run hands endings to handle as they come, until ctx is done. An ending
+// handle fails on is left for another try after claimIdle.
+func (e *Endings) Run(ctx context.Context, handle func(context.Context, game.View) error) {
+	for ctx.Err() == nil {
+		if err := e.consume(ctx, time.Second, handle); err != nil && ctx.Err() == nil {
+			log.Printf("fanout: read endings: %v", err)
+			select {
+			case <-ctx.Done():
+			case <-time.After(time.Second):
+			}
+		}
+	}
+}
+
+// Tick hands the endings waiting now to handle, without blocking.
+func (e *Endings) Tick(ctx context.Context, handle func(context.Context, game.View) error) error {
+	return e.consume(ctx, -1, handle)
+}
+
+// consume handles the endings any node left unacknowledged for claimIdle,
+// then new ones, waiting up to block for them unless it is negative.
+func (e *Endings) consume(ctx context.Context, block time.Duration, handle func(context.Context, game.View) error) error {
+	err := e.rdb.XGroupCreateMkStream(ctx, endingsKey, endingsGroup, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return err
+	}
+
+	stale, _, err := e.rdb.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+		Stream:   endingsKey,
+		Group:    endingsGroup,
+		Consumer: e.node,
+		MinIdle:  claimIdle,
+		Start:    "0",
+		Count:    endingBatch,
+	}).Result()
+	if err != nil {
+		return err
+	}
+	e.handle(ctx, stale, handle)
+
+	streams, err := e.rdb.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    endingsGroup,
+		Consumer: e.node,
+		Streams:  []string{endingsKey, ">"},
+		Count:    endingBatch,
+		Block:    block,
+	}).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, st := range streams {
+		e.handle(ctx, st.Messages, handle)
+	}
+	return nil
+}
+
+func (e *Endings) handle(ctx context.Context, msgs []redis.XMessage, handle func(context.Context, game.View) error) {
+	for _, msg := range msgs {
+		var v game.View
+		data, _ := msg.Values["game"].(string)
+		if err := json.Unmarshal([]byte(data), &v); err != nil {
+			// It will never decode; drop it.
+			log.Printf("fanout: decode ending %s: %v", msg.ID, err)
+		} else if err := handle(ctx, v); err != nil {
+			log.Printf("fanout: handle the ending of game %s: %v", v.ID, err)
+			continue
+		}
+		if err := e.rdb.XAck(ctx, endingsKey, endingsGroup, msg.ID).Err(); err != nil {
+			log.Printf("fanout: acknowledge ending %s: %v", msg.ID, err)
+		}
+	}
+}
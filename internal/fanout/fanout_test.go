@@ -0,0 +1,165 @@
+package fanout
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/THECHAMP95821/chess-backend/internal/chess"
+	"github.com/THECHAMP95821/chess-backend/internal/game"
+)
+
+func next(t *testing.T, c <-chan game.Event) (game.Event, bool) {
+	t.Helper()
+	select {
+	case e, ok := <-c:
+		return e, ok
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for event")
+		return game.Event{}, false
+	}
+}
+
+// waitFor polls until cond holds, for state that settles asynchronously.
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestFanoutAcrossNodes(t *testing.T) {
+	mr := miniredis.RunT(t)
+	ctx := context.Background()
+
+	// Node A hosts the game and publishes its events.
+	rdbA := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer rdbA.Close()
+	pub := NewPublisher(rdbA)
+	defer pub.Close()
+	games := game.NewService()
+	games.OnEvent(pub.Send)
+
+	// Node B only has spectators.
+	rdbB := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer rdbB.Close()
+	hub := NewHub(rdbB)
+	defer hub.Close()
+
+	v, _ := games.Create(game.CreateOptions{})
+	if _, _, err := hub.Watch(ctx, v.ID); err != ErrNoEvents {
+		t.Fatalf("watch before any event: err = %v, want ErrNoEvents", err)
+	}
+
+	games.Move(v.ID, game.MoveRequest{Color: chess.ColorWhite, UCI: "e2e4"})
+	waitFor(t, func() bool { return mr.Exists(lastEventKey(v.ID)) })
+
+	last, w, err := hub.Watch(ctx, v.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	if last.Seq != 1 || last.Move == nil || last.Move.SAN != "e4" {
+		t.Errorf("last event = %+v, want e4 at seq 1", last)
+	}
+	waitFor(t, func() bool { return rdbA.PubSubNumSub(ctx, channelName(v.ID)).Val()[channelName(v.ID)] == 1 })
+
+	games.Move(v.ID, game.MoveRequest{Color: chess.ColorBlack, UCI: "e7e5"})
+	if e, _ := next(t, w.C); e.Seq != 2 || e.Move.SAN != "e5" || e.FEN == "" {
+		t.Errorf("event = %+v, want e5 at seq 2", e)
+	}
+}
+
+func TestWatcherCoalescesClockTicks(t *testing.T) {
+	// No pump: inspect what push leaves in the queue.
+	w := &Watcher{seq: 1, notify: make(chan struct{}, 1)}
+	w.push(game.Event{Seq: 1, Type: game.EventClock, Clock: &game.ClockView{WhiteMs: 3000}})
+	w.push(game.Event{Seq: 1, Type: game.EventClock, Clock: &game.ClockView{WhiteMs: 2000}})
+	w.push(game.Event{Seq: 1, Type: game.EventMove})
+	w.push(game.Event{Seq: 2, Type: game.EventMove})
+	w.push(game.Event{Seq: 1, Type: game.EventClock, Clock: &game.ClockView{WhiteMs: 1500}})
+	w.push(game.Event{Seq: 2, Type: game.EventClock, Clock: &game.ClockView{WhiteMs: 1000}})
+
+	if len(w.queue) != 3 {
+		t.Fatalf("queue = %+v, want tick, move, tick", w.queue)
+	}
+	if q := w.queue[0]; q.Type != game.EventClock || q.Clock.WhiteMs != 2000 {
+		t.Errorf("first = %+v, want the newer of two ticks", q)
+	}
+	if q := w.queue[1]; q.Type != game.EventMove || q.Seq != 2 {
+		t.Errorf("second = %+v, want move at seq 2 without the duplicate", q)
+	}
+	if q := w.queue[2]; q.Type != game.EventClock || q.Clock.WhiteMs != 1000 {
+		t.Errorf("third = %+v, want the tick after the move", q)
+	}
+}
+
+func TestWatcherDropsSlowConsumer(t *testing.T) {
+	w := newWatcher(0)
+	defer w.Close()
+	for i := range watcherBuffer + 2 {
+		w.push(game.Event{Seq: uint64(i + 1), Type: game.EventMove})
+	}
+	for {
+		if _, ok := next(t, w.C); !ok {
+			break
+		}
+	}
+}
+
+func TestEndingsReachOneNode(t *testing.T) {
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer rdb.Close()
+	ctx := context.Background()
+
+	// The game ends on node A; nodes B and C score games.
+	sender := NewEndings(rdb, "node-a")
+	defer sender.Close()
+	games := game.NewService()
+	games.OnGameEnd(sender.Send)
+	v, _ := games.Create(game.CreateOptions{WhiteID: "alice", BlackID: "bob"})
+	games.Resign(v.ID, chess.ColorBlack)
+	waitFor(t, func() bool { n, _ := rdb.XLen(ctx, endingsKey).Result(); return n == 1 })
+
+	b, c := NewEndings(rdb, "node-b"), NewEndings(rdb, "node-c")
+	defer b.Close()
+	defer c.Close()
+	var handled []game.View
+	failing := func(ctx context.Context, v game.View) error { return errors.New("store down") }
+	scoring := func(ctx context.Context, v game.View) error {
+		handled = append(handled, v)
+		return nil
+	}
+
+	// Node B takes the ending but fails to score it; node C gets nothing
+	// until the ending has waited claimIdle.
+	if err := b.Tick(ctx, failing); err != nil {
+		t.Fatal(err)
+	}
+	c.Tick(ctx, scoring)
+	if len(handled) != 0 {
+		t.Fatalf("node-c handled %d endings node-b still holds", len(handled))
+	}
+	mr.SetTime(time.Now().Add(claimIdle))
+	c.Tick(ctx, scoring)
+	if len(handled) != 1 || handled[0].ID != v.ID || handled[0].Outcome.Result != "1-0" {
+		t.Fatalf("handled = %+v, want the resigned game", handled)
+	}
+
+	// Once scored, the ending is not handed out again.
+	mr.SetTime(time.Now().Add(2 * claimIdle))
+	b.Tick(ctx, scoring)
+	c.Tick(ctx, scoring)
+	if len(handled) != 1 {
+		t.Errorf("ending handled %d times", len(handled))
+	}
+}
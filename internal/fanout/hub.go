@@ -0,0 +1,121 @@
+package fanout
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/THECHAMP95821/chess-backend/internal/game"
+)
+
+var ErrNoEvents = errors.New("no events published for game")
+
+// watcherBuffer is how many events a watcher may fall behind before it is
+// dropped. Clock events never count against it: a newer one replaces the
+// last one still queued.
+const watcherBuffer = 256
+
+// Hub holds one Redis subscription per watched game on this node and fans
+// each event out to the local watchers of that game.
+type Hub struct {
+	rdb      *redis.Client
+	ps       *redis.PubSub
+	mu       sync.Mutex
+	watchers map[string]map[*Watcher]struct{}
+	done     chan struct{}
+}
+
+func NewHub(rdb *redis.Client) *Hub {
+	h := &Hub{
+		rdb:      rdb,
+		ps:       rdb.Subscribe(context.Background()),
+		watchers: make(map[string]map[*Watcher]struct{}),
+		done:     make(chan struct{}),
+	}
+	go h.run()
+	return h
+}
+
+func (h *Hub) run() {
+	defer close(h.done)
+	for msg := range h.ps.Channel() {
+		id := strings.TrimSuffix(strings.TrimPrefix(msg.Channel, "chess:game:"), ":events")
+		var e game.Event
+		if err := json.Unmarshal([]byte(msg.Payload), &e); err != nil {
+			log.Printf("fanout: decode event of game %s: %v", id, err)
+			continue
+		}
+		h.mu.Lock()
+		for w := range h.watchers[id] {
+			w.push(e)
+		}
+		h.mu.Unlock()
+	}
+}
+
+// Watch follows a game hosted on any node. It returns the latest event
+// published for the game, which carries enough state to render it, and a
+// watcher for every event after that.
+func (h *Hub) Watch(ctx context.Context, gameID string) (game.Event, *Watcher, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	ws, ok := h.watchers[gameID]
+	if !ok {
+		if err := h.ps.Subscribe(ctx, channelName(gameID)); err != nil {
+			return game.Event{}, nil, err
+		}
+		ws = make(map[*Watcher]struct{})
+		h.watchers[gameID] = ws
+	}
+
+	var last game.Event
+	data, err := h.rdb.Get(ctx, lastEventKey(gameID)).Bytes()
+	if err == nil {
+		err = json.Unmarshal(data, &last)
+	} else if errors.Is(err, redis.Nil) {
+		err = ErrNoEvents
+	}
+	if err != nil {
+		if len(ws) == 0 {
+			delete(h.watchers, gameID)
+			h.ps.Unsubscribe(ctx, channelName(gameID))
+		}
+		return game.Event{}, nil, err
+	}
+
+	w := newWatcher(last.Seq)
+	w.cancel = func() { h.unwatch(gameID, w) }
+	ws[w] = struct{}{}
+	return last, w, nil
+}
+
+func (h *Hub) unwatch(gameID string, w *Watcher) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	ws := h.watchers[gameID]
+	delete(ws, w)
+	if len(ws) == 0 {
+		delete(h.watchers, gameID)
+		h.ps.Unsubscribe(context.Background(), channelName(gameID))
+	}
+}
+
+// Close ends the Redis subscription. Watchers still open are closed too.
+func (h *Hub) Close() error {
+	err := h.ps.Close()
+	<-h.done
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, ws := range h.watchers {
+		for w := range ws {
+			w.stop()
+		}
+	}
+	return err
+}
@@ -0,0 +1,84 @@
+// Package fanout spreads game events across server instances through Redis
+// pub/sub, so a spectator can follow a game from any node.
+package fanout
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/THECHAMP95821/chess-backend/internal/game"
+)
+
+const (
+	publishBuffer = 1024
+	lastEventTTL  = 24 * time.Hour
+)
+
+func channelName(gameID string) string {
+	return "chess:game:" + gameID + ":events"
+}
+
+func lastEventKey(gameID string) string {
+	return "chess:game:" + gameID + ":last"
+}
+
+// Publisher forwards the events of the games hosted on this node to Redis.
+// Send never blocks the game: events wait in a buffer, and when Redis falls
+// behind clock events are the first to be dropped.
+type Publisher struct {
+	rdb   *redis.Client
+	queue chan game.Event
+	wg    sync.WaitGroup
+}
+
+func NewPublisher(rdb *redis.Client) *Publisher {
+	p := &Publisher{
+		rdb:   rdb,
+		queue: make(chan game.Event, publishBuffer),
+	}
+	p.wg.Add(1)
+	go p.run()
+	return p
+}
+
+func (p *Publisher) Send(e game.Event) {
+	select {
+	case p.queue <- e:
+	default:
+		if e.Type != game.EventClock {
+			log.Printf("fanout: dropped event %d of game %s", e.Seq, e.GameID)
+		}
+	}
+}
+
+func (p *Publisher) run() {
+	defer p.wg.Done()
+	ctx := context.Background()
+	for e := range p.queue {
+		data, err := json.Marshal(e)
+		if err != nil {
+			log.Printf("fanout: encode event: %v", err)
+			continue
+		}
+		_, err = p.rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Publish(ctx, channelName(e.GameID), data)
+			pipe.Set(ctx, lastEventKey(e.GameID), data, lastEventTTL)
+			return nil
+		})
+		if err != nil {
+			log.Printf("fanout: publish event %d of game %s: %v", e.Seq, e.GameID, err)
+		}
+	}
+}
+
+// Close publishes whatever is still queued and stops the publisher. Send
+// must not be called afterwards.
+func (p *Publisher) Close() {
+	close(p.queue)
+	p.wg.Wait()
+}
@@ -0,0 +1,121 @@
+package fanout
+
+import (
+	"sync"
+
+	"github.com/THECHAMP95821/chess-backend/internal/game"
+)
+
+// Watcher delivers the events of one game to one consumer. Like a
+// game.Subscription, C is closed if the consumer falls too far behind.
+type Watcher struct {
+	C      <-chan game.Event
+	out    chan game.Event
+	mu     sync.Mutex
+	queue  []game.Event
+	seq    uint64
+	closed bool
+	notify chan struct{}
+	done   chan struct{}
+	once   sync.Once
+	cancel func()
+}
+
+func newWatcher(seq uint64) *Watcher {
+	out := make(chan game.Event)
+	w := &Watcher{
+		C:      out,
+		out:    out,
+		seq:    seq,
+		notify: make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+	go w.pump()
+	return w
+}
+
+func (w *Watcher) push(e game.Event) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return
+	}
+
+	if e.Type == game.EventClock {
+		if e.Seq < w.seq {
+			return
+		}
+		if n := len(w.queue); n > 0 && w.queue[n-1].Type == game.EventClock {
+			w.queue[n-1] = e
+			return
+		}
+		if len(w.queue) >= watcherBuffer {
+			return
+		}
+	} else {
+		if e.Seq <= w.seq {
+			return
+		}
+		w.seq = e.Seq
+		if len(w.queue) >= watcherBuffer {
+			w.queue = nil
+			w.closed = true
+			w.signal()
+			return
+		}
+	}
+	w.queue = append(w.queue, e)
+	w.signal()
+}
+
+func (w *Watcher) signal() {
+	select {
+	case w.notify <- struct{}{}:
+	default:
+	}
+}
+
+// pump moves queued events to C one at a time, so a slow reader only ever
+// holds up its own queue.
+func (w *Watcher) pump() {
+	defer close(w.out)
+	for {
+		select {
+		case <-w.notify:
+		case <-w.done:
+			return
+		}
+		for {
+			w.mu.Lock()
+			if len(w.queue) == 0 {
+				closed := w.closed
+				w.mu.Unlock()
+				if closed {
+					return
+				}
+				break
+			}
+			e := w.queue[0]
+			w.queue = w.queue[1:]
+			w.mu.Unlock()
+
+			select {
+			case w.out <- e:
+			case <-w.done:
+				return
+			}
+		}
+	}
+}
+
+func (w *Watcher) stop() {
+	w.once.Do(func() { close(w.done) })
+}
+
+// Close stops the watcher and releases its share of the game subscription.
+func (w *Watcher) Close() {
+	w.stop()
+	if w.cancel != nil {
+		w.cancel()
+	}
+}
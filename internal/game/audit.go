@@ -0,0 +1,66 @@
+package game
+
+import (
+	"context"
+	"errors"
+
+	"github.com/THECHAMP95821/chess-backend/internal/gamelog"
+	"github.com/THECHAMP95821/chess-backend/internal/store"
+)
+
+var ErrNoJournal = errors.New("games are not journaled on this server")
+
+// Audit is a game's event log together with the position replaying it
+// gives.
+type Audit struct {
+	Events  []gamelog.Event `json:"events"`
+	Version int             `json:"version"`
+	FEN     string          `json:"fen"`
+	Ply     int             `json:"ply"`
+	// Checked is set when the game runs on this node and the replay was
+	// compared with it; Diverged when the two differ, as they do while
+	// events wait to be logged.
+	Checked  bool `json:"checked"`
+	Diverged bool `json:"diverged"`
+	// Unlogged counts the game's events still waiting to be logged.
+	Unlogged int `json:"unlogged"`
+}
+
+// Audit replays a game's event log. If the game runs here, events that
+// could not be logged before are retried first and the replay is checked
+// against the game.
+func (s *Service) Audit(ctx context.Context, id string) (Audit, error) {
+	j := s.journal
+	lg, err := s.lookup(id)
+	if err == nil {
+		lg.mu.Lock()
+		defer lg.mu.Unlock()
+		if lg.journal != nil {
+			j = lg.journal
+			// A failure is reported through Unlogged.
+			lg.flushLog(ctx)
+		}
+	}
+	if j == nil {
+		return Audit{}, ErrNoJournal
+	}
+	events, err := j.Events(ctx, id)
+	if err != nil {
+		return Audit{}, err
+	}
+	r, err := j.Rebuild(ctx, id)
+	if errors.Is(err, store.ErrNotFound) {
+		return Audit{}, ErrGameNotFound
+	}
+	if err != nil {
+		return Audit{}, err
+	}
+	a := Audit{Events: events, Version: r.Version, FEN: r.Position.ToFEN(), Ply: r.Ply}
+	if lg != nil && lg.journal != nil {
+		pos := lg.game.Position()
+		a.Checked = true
+		a.Diverged = a.Ply != lg.game.Ply() || a.FEN != pos.ToFEN() || a.Version != lg.logVersion
+		a.Unlogged = len(lg.unlogged)
+	}
+	return a, nil
+}
@@ -0,0 +1,78 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+var (
+	ErrInvalidChat = errors.New("invalid chat message")
+	ErrChatTooFast = errors.New("chatting too fast")
+)
+
+const (
+	// maxChat is the longest chat message, in characters.
+	maxChat = 140
+	// A user may post chatBurst messages to a game per chatWindow.
+	chatBurst  = 5
+	chatWindow = 10 * time.Second
+)
+
+// Chat rooms: the players' room is for the two players, the spectators'
+// room for everyone else.
+const (
+	RoomPlayer    = "player"
+	RoomSpectator = "spectator"
+)
+
+// ChatView is one chat message. User is whoever wrote it, if anyone.
+type ChatView struct {
+	Room string `json:"room"`
+	User string `json:"user,omitempty"`
+	Text string `json:"text"`
+}
+
+// Chat posts text to one of a game's rooms. It is published like any other
+// event, also after the game has ended, but not journaled: chat is no part
+// of the game. The players' room only reaches the players' subscriptions.
+func (s *Service) Chat(id string, msg ChatView) error {
+	msg.Text = strings.TrimSpace(msg.Text)
+	switch n := utf8.RuneCountInString(msg.Text); {
+	case n == 0:
+		return fmt.Errorf("%w: empty", ErrInvalidChat)
+	case n > maxChat:
+		return fmt.Errorf("%w: longer than %d characters", ErrInvalidChat, maxChat)
+	}
+	if msg.Room != RoomPlayer && msg.Room != RoomSpectator {
+		return fmt.Errorf("%w: unknown room %q", ErrInvalidChat, msg.Room)
+	}
+	_, err := s.update(id, func(lg *liveGame, now time.Time) error {
+		if !lg.allowChat(msg.User, now) {
+			return fmt.Errorf("%w: at most %d messages per %s", ErrChatTooFast, chatBurst, chatWindow)
+		}
+		lg.publish(Event{Type: EventChat, Time: now, Chat: &msg})
+		return nil
+	})
+	return err
+}
+
+// allowChat records a message by user at now, unless they have posted
+// chatBurst messages within the last chatWindow.
+func (lg *liveGame) allowChat(user string, now time.Time) bool {
+	if lg.chatSent == nil {
+		lg.chatSent = make(map[string][]time.Time)
+	}
+	sent := lg.chatSent[user]
+	for len(sent) > 0 && now.Sub(sent[0]) >= chatWindow {
+		sent = sent[1:]
+	}
+	if len(sent) >= chatBurst {
+		lg.chatSent[user] = sent
+		return false
+	}
+	lg.chatSent[user] = append(sent, now)
+	return true
+}
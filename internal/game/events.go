@@ -0,0 +1,137 @@
+package game
+
+import "time"
+
+type EventType string
+
+const (
+	EventMove             EventType = "move"
+	EventClock            EventType = "clock"
+	EventDrawOffer        EventType = "draw_offer"
+	EventDrawDeclined     EventType = "draw_declined"
+	EventTakebackOffer    EventType = "takeback_offer"
+	EventTakebackDeclined EventType = "takeback_declined"
+	EventTakeback         EventType = "takeback"
+	EventGameEnd          EventType = "game_end"
+	EventPlayerGone       EventType = "player_gone"
+	EventPlayerBack       EventType = "player_back"
+	EventBerserk          EventType = "berserk"
+	EventChat             EventType = "chat"
+)
+
+// Event is one change to a live game. Seq increases by one for every event
+// of a game, so a client that sees a jump knows it missed something; Ply is
+// the number of moves on the board after the event. Clock events are only
+// resynchronisation hints: they repeat the seq of the latest event and may
+// be dropped or coalesced on the way to a client. Chat in the players'
+// room repeats the latest seq too: it only reaches the players'
+// subscriptions on the game's node, and is not kept for Resume.
+type Event struct {
+	Seq     uint64       `json:"seq"`
+	GameID  string       `json:"game_id"`
+	Ply     int          `json:"ply"`
+	Type    EventType    `json:"type"`
+	Time    time.Time    `json:"time"`
+	By      string       `json:"by,omitempty"`
+	Move    *MoveView    `json:"move,omitempty"`
+	FEN     string       `json:"fen,omitempty"`
+	Clock   *ClockView   `json:"clock,omitempty"`
+	Outcome *OutcomeView `json:"outcome,omitempty"`
+	Chat    *ChatView    `json:"chat,omitempty"`
+}
+
+const (
+	subscriberBuffer = 64
+	historySize      = 256
+)
+
+// Subscription delivers a game's events in order. If the subscriber falls
+// more than subscriberBuffer events behind, C is closed and the subscriber
+// must resynchronise from a fresh snapshot. Clock events that do not fit
+// are skipped instead.
+type Subscription struct {
+	C      <-chan Event
+	ch     chan Event
+	player bool
+	cancel func()
+}
+
+func (sub *Subscription) Close() {
+	sub.cancel()
+}
+
+// private reports whether e is for the players alone.
+func (e Event) private() bool {
+	return e.Type == EventChat && e.Chat.Room == RoomPlayer
+}
+
+func (lg *liveGame) publish(e Event) {
+	private := e.private()
+	if e.Type != EventClock && !private {
+		lg.seq++
+	}
+	e.Seq = lg.seq
+	e.GameID = lg.id
+	e.Ply = lg.game.Ply()
+	if e.FEN == "" {
+		pos := lg.game.Position()
+		e.FEN = pos.ToFEN()
+	}
+	if lg.clock != nil && e.Clock == nil {
+		e.Clock = lg.clockView(e.Time)
+	}
+	if e.Type != EventClock && !private {
+		lg.history = append(lg.history, e)
+		if len(lg.history) > historySize {
+			lg.history = lg.history[len(lg.history)-historySize:]
+		}
+	}
+
+	for sub := range lg.subs {
+		if private && !sub.player {
+			continue
+		}
+		select {
+		case sub.ch <- e:
+		default:
+			if e.Type != EventClock {
+				delete(lg.subs, sub)
+				close(sub.ch)
+			}
+		}
+	}
+	if lg.onEvent != nil && !private {
+		lg.onEvent(e)
+	}
+}
+
+func (lg *liveGame) subscribe(player bool) *Subscription {
+	ch := make(chan Event, subscriberBuffer)
+	sub := &Subscription{C: ch, ch: ch, player: player}
+	lg.subs[sub] = struct{}{}
+	sub.cancel = func() {
+		lg.mu.Lock()
+		defer lg.mu.Unlock()
+		if _, ok := lg.subs[sub]; ok {
+			delete(lg.subs, sub)
+			close(sub.ch)
+		}
+	}
+	return sub
+}
+
+// eventsSince returns the events after seq, or false if some of them are no
+// longer kept.
+func (lg *liveGame) eventsSince(seq uint64) ([]Event, bool) {
+	if seq > lg.seq {
+		return nil, false
+	}
+	if seq == lg.seq {
+		return []Event{}, true
+	}
+	if len(lg.history) == 0 || lg.history[0].Seq > seq+1 {
+		return nil, false
+	}
+	missed := lg.history[seq+1-lg.history[0].Seq:]
+	return append([]Event(nil), missed...), true
+}
@@ -0,0 +1,270 @@
+package game
+
+import (
+	"context"
+	"errors"
+	"log"
+	"maps"
+	"time"
+
+	"github.com/THECHAMP95821/chess-backend/internal/chess"
+	"github.com/THECHAMP95821/chess-backend/internal/clock"
+)
+
+var (
+	ErrGameExists   = errors.New("game already live on this node")
+	ErrShuttingDown = errors.New("server is shutting down")
+)
+
+// LiveState is everything needed to resume a running game in another
+// process: the moves from the initial position, the clock and what is
+// pending.
+type LiveState struct {
+	ID            string         `json:"id"`
+	WhiteID       string         `json:"white_id,omitempty"`
+	BlackID       string         `json:"black_id,omitempty"`
+	Rated         bool           `json:"rated,omitempty"`
+	Berserk       bool           `json:"berserk,omitempty"`
+	ResultRule    string         `json:"result_rule,omitempty"`
+	InitialFEN    string         `json:"initial_fen"`
+	Moves         []MoveView     `json:"moves"`
+	MoveIDs       map[string]int `json:"move_ids,omitempty"`
+	Clock         *clock.State   `json:"clock,omitempty"`
+	DrawOffer     string         `json:"draw_offer,omitempty"`
+	TakebackOffer string         `json:"takeback_offer,omitempty"`
+	Seq           uint64         `json:"seq"`
+	LogVersion    int            `json:"log_version"`
+	CreatedAt     time.Time      `json:"created_at"`
+	SavedAt       time.Time      `json:"saved_at"`
+}
+
+// LiveCache keeps the state of running games where it outlives the process.
+type LiveCache interface {
+	Save(ctx context.Context, s LiveState) error
+	Delete(ctx context.Context, id string) error
+}
+
+// Leaser grants this node exclusive ownership of a game among all nodes.
+type Leaser interface {
+	Acquire(ctx context.Context, id string) error
+}
+
+// SetLeaser makes the service take a lease on every game it creates.
+func (s *Service) SetLeaser(l Leaser) {
+	s.leaser = l
+}
+
+// SetCache makes the service keep the state of every running game, created
+// or restored after the call, in c.
+func (s *Service) SetCache(c LiveCache) {
+	s.cache = c
+}
+
+func (lg *liveGame) liveState(now time.Time) LiveState {
+	start := lg.game.StartPosition()
+	st := LiveState{
+		ID:         lg.id,
+		WhiteID:    lg.whiteID,
+		BlackID:    lg.blackID,
+		Rated:      lg.rated,
+		Berserk:    lg.berserk,
+		InitialFEN: start.ToFEN(),
+		Moves:      []MoveView{},
+		Seq:        lg.seq,
+		LogVersion: lg.logVersion,
+		CreatedAt:  lg.createdAt,
+		SavedAt:    now,
+	}
+	for _, pm := range lg.game.Moves() {
+		st.Moves = append(st.Moves, MoveView{UCI: pm.Move.String(), SAN: pm.SAN, PlayedAt: pm.PlayedAt})
+	}
+	if lg.rule != chess.StandardRule {
+		st.ResultRule = lg.rule.String()
+	}
+	if len(lg.moveIDs) > 0 {
+		st.MoveIDs = maps.Clone(lg.moveIDs)
+	}
+	if lg.clock != nil {
+		cs := lg.clock.State()
+		st.Clock = &cs
+	}
+	if lg.drawOffer != nil {
+		st.DrawOffer = colorName(*lg.drawOffer)
+	}
+	if lg.takebackOffer != nil {
+		st.TakebackOffer = colorName(*lg.takebackOffer)
+	}
+	return st
+}
+
+// cacheState saves the game's live state, or drops it once the game is over.
+func (lg *liveGame) cacheState(now time.Time) {
+	if lg.cache == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+	defer cancel()
+	var err error
+	if lg.game.IsOver() {
+		err = lg.cache.Delete(ctx, lg.id)
+	} else {
+		err = lg.cache.Save(ctx, lg.liveState(now))
+	}
+	if err != nil {
+		log.Printf("game %s: cache: %v", lg.id, err)
+	}
+}
+
+// Restore resumes a game from its live state. down is when the process that
+// ran it stopped: the side to move is not charged for the time since then.
+func (s *Service) Restore(st LiveState, down time.Time) (View, error) {
+	start, err := chess.ParseFEN(st.InitialFEN)
+	if err != nil {
+		return View{}, err
+	}
+	rule, err := chess.ParseResultRule(st.ResultRule)
+	if err != nil {
+		return View{}, err
+	}
+	g := chess.NewGameFromState(*start)
+	for _, m := range st.Moves {
+		if _, err := g.PlayUCI(m.UCI, m.PlayedAt); err != nil {
+			return View{}, err
+		}
+	}
+
+	lg := &liveGame{
+		id:         st.ID,
+		whiteID:    st.WhiteID,
+		blackID:    st.BlackID,
+		rated:      st.Rated,
+		berserk:    st.Berserk,
+		rule:       rule,
+		game:       g,
+		createdAt:  st.CreatedAt,
+		seq:        st.Seq,
+		logVersion: st.LogVersion,
+		subs:       make(map[*Subscription]struct{}),
+		moveIDs:    make(map[string]int),
+		onEvent:    s.onEvent,
+		store:      s.store,
+		journal:    s.journal,
+		cache:      s.cache,
+		rater:      s.rater,
+		onEnd:      s.onEnd,
+		// The last owner may have left its writes unfinished.
+		movesStale: true,
+		logStale:   true,
+	}
+	maps.Copy(lg.moveIDs, st.MoveIDs)
+	if st.DrawOffer != "" {
+		c, _ := chess.ParseColor(st.DrawOffer)
+		lg.drawOffer = &c
+	}
+	if st.TakebackOffer != "" {
+		c, _ := chess.ParseColor(st.TakebackOffer)
+		lg.takebackOffer = &c
+	}
+	now := s.now()
+	if st.Clock != nil {
+		if lg.clock, err = clock.Restore(*st.Clock); err != nil {
+			return View{}, err
+		}
+		lg.clock.OnFlag(func(flagged chess.Color) {
+			lg.game.Timeout(flagged)
+		})
+		lg.clock.Shift(now.Sub(down))
+	}
+
+	s.mu.Lock()
+	if _, ok := s.games[st.ID]; ok {
+		s.mu.Unlock()
+		return View{}, ErrGameExists
+	}
+	s.games[st.ID] = lg
+	s.mu.Unlock()
+
+	lg.mu.Lock()
+	defer lg.mu.Unlock()
+	lg.checkFlag(now)
+	s.scheduleFlag(lg, now)
+	lg.cacheState(now)
+	return lg.view(now), nil
+}
+
+// Has reports whether a game is live on this node.
+func (s *Service) Has(id string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, ok := s.games[id]
+	return ok
+}
+
+// IDs lists the games live on this node.
+func (s *Service) IDs() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	ids := make([]string, 0, len(s.games))
+	for id := range s.games {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// Evict stops running a game here, for when another node has taken it
+// over. Its cached state is left for the new owner, and subscribers are cut
+// off so that they reconnect there.
+func (s *Service) Evict(id string) {
+	s.mu.Lock()
+	lg, ok := s.games[id]
+	delete(s.games, id)
+	s.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	lg.mu.Lock()
+	defer lg.mu.Unlock()
+	if lg.flagTimer != nil {
+		lg.flagTimer.Stop()
+		lg.flagTimer = nil
+	}
+	for i := range lg.presence {
+		lg.presence[i].stopTimer()
+	}
+	for sub := range lg.subs {
+		delete(lg.subs, sub)
+		close(sub.ch)
+	}
+	// Later writes from stray timers must not clobber the new owner's state.
+	lg.cache, lg.store, lg.journal, lg.onEvent = nil, nil, nil, nil
+}
+
+// Drain stops the service accepting new games. Running games carry on.
+func (s *Service) Drain() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.draining = true
+}
+
+// Handoff ends this node's part in every running game so that another
+// process can resume it: each game's state is saved as of now, which is
+// where the next owner's clock picks up, and then it is evicted, which tells
+// connected clients to reconnect. It returns the IDs of the games let go.
+func (s *Service) Handoff() []string {
+	s.Drain()
+	ids := s.IDs()
+	for _, id := range ids {
+		lg, err := s.lookup(id)
+		if err != nil {
+			continue
+		}
+		lg.mu.Lock()
+		now := s.now()
+		lg.checkFlag(now)
+		lg.cacheState(now)
+		lg.mu.Unlock()
+		s.Evict(id)
+	}
+	return ids
+}
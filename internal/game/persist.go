@@ -0,0 +1,213 @@
+package game
+
+import (
+	"context"
+	"errors"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/THECHAMP95821/chess-backend/internal/chess"
+	"github.com/THECHAMP95821/chess-backend/internal/gamelog"
+	"github.com/THECHAMP95821/chess-backend/internal/store"
+)
+
+const storeTimeout = 5 * time.Second
+
+// SetStore makes the service record every game created after the call,
+// with its moves and outcome, in repo.
+func (s *Service) SetStore(repo store.Repository) {
+	s.store = repo
+}
+
+// SetJournal makes the service log the events of every game created after
+// the call to j.
+func (s *Service) SetJournal(j *gamelog.Journal) {
+	s.journal = j
+}
+
+// Rater updates the players' ratings once a rated game has ended.
+type Rater interface {
+	RateGame(ctx context.Context, g store.Game) error
+}
+
+// SetRater makes the service pass every rated game created or restored
+// after the call to r when it ends.
+func (s *Service) SetRater(r Rater) {
+	s.rater = r
+}
+
+// save writes to the game's store, if it has one. The live game stays
+// authoritative, so a failed write is logged instead of undoing the change.
+func (lg *liveGame) save(fn func(ctx context.Context, repo store.Repository) error) {
+	if lg.store == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+	defer cancel()
+	if err := fn(ctx, lg.store); err != nil {
+		log.Printf("game %s: store: %v", lg.id, err)
+	}
+}
+
+// saveMoves writes the moves played since the last successful write, rec
+// last of them.
+func (lg *liveGame) saveMoves(rec store.Move) {
+	if lg.store == nil {
+		return
+	}
+	lg.unsaved = append(lg.unsaved, rec)
+	lg.save(lg.writeMoves)
+}
+
+// takeBackMoves removes the moves taken back from the store.
+func (lg *liveGame) takeBackMoves() {
+	if lg.store == nil {
+		return
+	}
+	ply := lg.game.Ply()
+	lg.unsaved = slices.DeleteFunc(lg.unsaved, func(m store.Move) bool { return m.Ply > ply })
+	lg.save(func(ctx context.Context, repo store.Repository) error {
+		if lg.movesStale {
+			return lg.writeMoves(ctx, repo)
+		}
+		err := repo.TruncateMoves(ctx, lg.id, ply)
+		if err != nil {
+			lg.movesStale = true
+		}
+		return err
+	})
+}
+
+// writeMoves brings the stored moves up to date with the game. After a
+// failed write it first reads them back, drops any that are not in the
+// game and queues the ones missing; those lose their clock times unless
+// this node still has them.
+func (lg *liveGame) writeMoves(ctx context.Context, repo store.Repository) error {
+	if lg.movesStale {
+		stored, err := repo.Moves(ctx, lg.id)
+		if errors.Is(err, store.ErrNotFound) {
+			err = repo.SaveGame(ctx, lg.record())
+		}
+		if err != nil {
+			return err
+		}
+		played := lg.game.Moves()
+		n := 0
+		for n < len(stored) && n < len(played) && stored[n].UCI == played[n].Move.String() {
+			n++
+		}
+		if n < len(stored) {
+			if err := repo.TruncateMoves(ctx, lg.id, n); err != nil {
+				return err
+			}
+		}
+		queued := make(map[int]store.Move, len(lg.unsaved))
+		for _, m := range lg.unsaved {
+			queued[m.Ply] = m
+		}
+		lg.unsaved = lg.unsaved[:0]
+		for i, pm := range played[n:] {
+			ply := n + i + 1
+			m, ok := queued[ply]
+			if !ok {
+				m = store.Move{Ply: ply, UCI: pm.Move.String(), SAN: pm.SAN, PlayedAt: pm.PlayedAt}
+			}
+			lg.unsaved = append(lg.unsaved, m)
+		}
+		lg.movesStale = false
+	}
+	for len(lg.unsaved) > 0 {
+		if err := repo.AppendMove(ctx, lg.id, lg.unsaved[0]); err != nil {
+			lg.movesStale = true
+			return err
+		}
+		lg.unsaved = lg.unsaved[1:]
+	}
+	return nil
+}
+
+// logEntry is an event waiting to be logged, with the game as it left it.
+type logEntry struct {
+	event gamelog.Event
+	pos   chess.GameState
+	ply   int
+}
+
+// journalize appends e to the game's event log, if it has one. Events that
+// cannot be logged yet are kept, in order, for the next try.
+func (lg *liveGame) journalize(e gamelog.Event) {
+	if lg.journal == nil {
+		return
+	}
+	lg.unlogged = append(lg.unlogged, logEntry{event: e, pos: lg.game.Position(), ply: lg.game.Ply()})
+	ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+	defer cancel()
+	if err := lg.flushLog(ctx); err != nil {
+		log.Printf("game %s: journal %s: %v", lg.id, e.Kind, err)
+	}
+}
+
+// flushLog appends the events not logged yet. After a failed append it
+// first finds out how many went through, so that the log neither skips
+// nor repeats an event.
+func (lg *liveGame) flushLog(ctx context.Context) error {
+	if lg.logStale {
+		n, err := lg.journal.Since(ctx, lg.id, lg.logVersion)
+		if err != nil {
+			return err
+		}
+		// Any more were logged by the game's last owner.
+		lg.logVersion += n
+		lg.unlogged = lg.unlogged[min(n, len(lg.unlogged)):]
+		lg.logStale = false
+	}
+	for len(lg.unlogged) > 0 {
+		next := lg.unlogged[0]
+		version, err := lg.journal.Append(ctx, lg.id, lg.logVersion, next.event, &next.pos, next.ply)
+		if version == lg.logVersion {
+			lg.logStale = true
+			return err
+		}
+		lg.logVersion = version
+		lg.unlogged = lg.unlogged[1:]
+		if err != nil {
+			// The event is in; only its snapshot is missing.
+			log.Printf("game %s: journal snapshot: %v", lg.id, err)
+		}
+	}
+	return nil
+}
+
+// rate hands a finished game to the rater, if it is rated and there is one.
+func (lg *liveGame) rate(rec store.Game) {
+	if lg.rater == nil || !lg.rated {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+	defer cancel()
+	if err := lg.rater.RateGame(ctx, rec); err != nil {
+		log.Printf("game %s: rating: %v", lg.id, err)
+	}
+}
+
+func (lg *liveGame) record() store.Game {
+	start := lg.game.StartPosition()
+	rec := store.Game{
+		ID:         lg.id,
+		WhiteID:    lg.whiteID,
+		BlackID:    lg.blackID,
+		InitialFEN: start.ToFEN(),
+		Rated:      lg.rated,
+		Result:     lg.game.Outcome().Result.String(),
+		CreatedAt:  lg.createdAt,
+	}
+	if lg.game.IsOver() {
+		ov := lg.outcomeView()
+		rec.Termination, rec.DrawReason = ov.Termination, ov.DrawReason
+	}
+	if lg.clock != nil {
+		rec.TimeControl = lg.clock.Control().String()
+	}
+	return rec
+}
@@ -0,0 +1,79 @@
+package game
+
+import (
+	"sync"
+	"time"
+
+	"github.com/THECHAMP95821/chess-backend/internal/chess"
+)
+
+// DefaultDisconnectGrace is how long a player may be away before the game is
+// told they are gone. Nothing is paused: the clock keeps running throughout.
+const DefaultDisconnectGrace = 20 * time.Second
+
+// presence tracks a player's open connections. goneSince is set when the
+// last one closes and announced once the grace period has run out.
+type presence struct {
+	conns     int
+	goneSince time.Time
+	announced bool
+	timer     *time.Timer
+}
+
+func (p *presence) stopTimer() {
+	if p.timer != nil {
+		p.timer.Stop()
+		p.timer = nil
+	}
+}
+
+// Connect records that c has a connection open to the game. The returned
+// function must be called when that connection closes.
+func (s *Service) Connect(id string, c chess.Color) (func(), error) {
+	lg, err := s.lookup(id)
+	if err != nil {
+		return nil, err
+	}
+	lg.mu.Lock()
+	defer lg.mu.Unlock()
+
+	p := &lg.presence[c]
+	p.conns++
+	if p.conns == 1 {
+		p.stopTimer()
+		p.goneSince = time.Time{}
+		if p.announced {
+			p.announced = false
+			lg.publish(Event{Type: EventPlayerBack, Time: s.now(), By: colorName(c)})
+		}
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { s.disconnect(lg, c) })
+	}, nil
+}
+
+func (s *Service) disconnect(lg *liveGame, c chess.Color) {
+	lg.mu.Lock()
+	defer lg.mu.Unlock()
+
+	p := &lg.presence[c]
+	p.conns--
+	if p.conns > 0 {
+		return
+	}
+	p.goneSince = s.now()
+	if lg.game.IsOver() {
+		return
+	}
+	p.timer = time.AfterFunc(s.grace, func() {
+		lg.mu.Lock()
+		defer lg.mu.Unlock()
+		if p.conns > 0 || p.announced || lg.game.IsOver() {
+			return
+		}
+		p.announced = true
+		lg.publish(Event{Type: EventPlayerGone, Time: s.now(), By: colorName(c)})
+	})
+}
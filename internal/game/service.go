@@ -0,0 +1,722 @@
+package game
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"maps"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/THECHAMP95821/chess-backend/internal/chess"
+	"github.com/THECHAMP95821/chess-backend/internal/clock"
+	"github.com/THECHAMP95821/chess-backend/internal/gamelog"
+	"github.com/THECHAMP95821/chess-backend/internal/store"
+)
+
+var (
+	ErrGameNotFound       = errors.New("game not found")
+	ErrNotYourTurn        = errors.New("not your turn")
+	ErrNoDrawOffer        = errors.New("no draw offer to accept")
+	ErrOwnDrawOffer       = errors.New("cannot accept your own draw offer")
+	ErrNoTakeback         = errors.New("no takeback offer to accept")
+	ErrOwnTakeback        = errors.New("cannot accept your own takeback offer")
+	ErrNothingToUndo      = errors.New("no move of yours to take back")
+	ErrInvalidFEN         = errors.New("invalid FEN")
+	ErrInvalidTime        = errors.New("invalid time control")
+	ErrStalePosition      = errors.New("move made against a stale position")
+	ErrUnsupportedVariant = errors.New("unsupported variant")
+	ErrBerserkNotAllowed  = errors.New("berserk is not allowed in this game")
+)
+
+// CreateOptions describes a new game. WhiteID and BlackID name the users
+// playing each side, if they are registered; only games between two of them
+// can be rated. Variant is empty or "standard", the only one supported.
+// Berserk lets either player halve their own clock before their first move.
+// BlackTimeControl gives Black a different time control from White's, and
+// Rule decides what result counts; armageddon games need both.
+type CreateOptions struct {
+	FEN              string
+	TimeControl      string
+	BlackTimeControl string
+	Variant          string
+	WhiteID          string
+	BlackID          string
+	Rated            bool
+	Berserk          bool
+	Rule             chess.ResultRule
+}
+
+// MoveRequest is a move submitted by a player. ExpectedPly, when set, is the
+// ply the client believes the game is at; ID is a client-chosen key that
+// makes resubmitting the same move harmless.
+type MoveRequest struct {
+	Color       chess.Color
+	UCI         string
+	SAN         string
+	ExpectedPly *int
+	ID          string
+}
+
+// liveGame is a game in progress together with everything that is not part
+// of the rules: its clock, pending offers and event subscribers.
+type liveGame struct {
+	mu            sync.Mutex
+	id            string
+	whiteID       string
+	blackID       string
+	rated         bool
+	berserk       bool
+	rule          chess.ResultRule
+	game          *chess.Game
+	clock         *clock.Clock
+	flagTimer     *time.Timer
+	drawOffer     *chess.Color
+	takebackOffer *chess.Color
+	createdAt     time.Time
+	seq           uint64
+	history       []Event
+	subs          map[*Subscription]struct{}
+	presence      [2]presence
+	// moveIDs maps the moveKey of each move played under a client ID to
+	// the ply it made.
+	moveIDs map[string]int
+	onEvent func(Event)
+	store   store.Repository
+	// unsaved are the moves still to be written to the store, in order.
+	// Once a write has failed the stored moves may be anything, and
+	// movesStale has them checked against the game before the next write.
+	unsaved    []store.Move
+	movesStale bool
+	journal    *gamelog.Journal
+	logVersion int
+	// unlogged are the events still to be appended to the journal. After
+	// a failed append logStale has the journal's version looked up again.
+	unlogged []logEntry
+	logStale bool
+	cache    LiveCache
+	rater    Rater
+	onEnd    func(View)
+	// chatSent holds when each user chatted within the last chatWindow.
+	chatSent map[string][]time.Time
+}
+
+type Service struct {
+	mu       sync.RWMutex
+	games    map[string]*liveGame
+	now      func() time.Time
+	grace    time.Duration
+	onEvent  func(Event)
+	store    store.Repository
+	journal  *gamelog.Journal
+	cache    LiveCache
+	rater    Rater
+	onEnd    func(View)
+	onStart  func(View)
+	leaser   Leaser
+	draining bool
+}
+
+func NewService() *Service {
+	return &Service{
+		games: make(map[string]*liveGame),
+		now:   time.Now,
+		grace: DefaultDisconnectGrace,
+	}
+}
+
+// OnEvent registers fn to receive every event of every game created after
+// the call. fn runs with the game locked and must not block.
+func (s *Service) OnEvent(fn func(Event)) {
+	s.onEvent = fn
+}
+
+// OnGameEnd registers fn to receive the final view of every game created or
+// restored after the call, once it ends. fn runs with the game locked.
+func (s *Service) OnGameEnd(fn func(View)) {
+	s.onEnd = fn
+}
+
+// OnGameStart registers fn to receive the first view of every game created
+// after the call. fn runs once the game is live, with no lock held.
+func (s *Service) OnGameStart(fn func(View)) {
+	s.onStart = fn
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func (s *Service) Create(opts CreateOptions) (View, error) {
+	s.mu.RLock()
+	draining := s.draining
+	s.mu.RUnlock()
+	if draining {
+		return View{}, ErrShuttingDown
+	}
+
+	if opts.Variant != "" && opts.Variant != "standard" {
+		return View{}, fmt.Errorf("%w: %s", ErrUnsupportedVariant, opts.Variant)
+	}
+	g := chess.NewGame()
+	if opts.FEN != "" {
+		state, err := chess.ParseLegalFEN(opts.FEN)
+		if err != nil {
+			return View{}, fmt.Errorf("%w: %v", ErrInvalidFEN, err)
+		}
+		g = chess.NewGameFromState(*state)
+	}
+
+	lg := &liveGame{
+		id:        newID(),
+		whiteID:   opts.WhiteID,
+		blackID:   opts.BlackID,
+		rated:     opts.Rated && opts.WhiteID != "" && opts.BlackID != "",
+		berserk:   opts.Berserk,
+		rule:      opts.Rule,
+		game:      g,
+		createdAt: s.now(),
+		subs:      make(map[*Subscription]struct{}),
+		moveIDs:   make(map[string]int),
+		onEvent:   s.onEvent,
+		store:     s.store,
+		journal:   s.journal,
+		cache:     s.cache,
+		rater:     s.rater,
+		onEnd:     s.onEnd,
+	}
+	if opts.TimeControl != "" {
+		tc, err := clock.ParseTimeControl(opts.TimeControl)
+		if err != nil {
+			return View{}, fmt.Errorf("%w: %v", ErrInvalidTime, err)
+		}
+		lg.clock = clock.New(tc)
+		if opts.BlackTimeControl != "" {
+			black, err := clock.ParseTimeControl(opts.BlackTimeControl)
+			if err != nil {
+				return View{}, fmt.Errorf("%w: %v", ErrInvalidTime, err)
+			}
+			if lg.clock, err = clock.NewOdds(tc, black); err != nil {
+				return View{}, fmt.Errorf("%w: %v", ErrInvalidTime, err)
+			}
+		}
+		lg.clock.OnFlag(func(flagged chess.Color) {
+			lg.game.Timeout(flagged)
+		})
+	}
+
+	if s.leaser != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+		err := s.leaser.Acquire(ctx, lg.id)
+		cancel()
+		if err != nil {
+			return View{}, err
+		}
+	}
+
+	lg.save(func(ctx context.Context, repo store.Repository) error {
+		return repo.SaveGame(ctx, lg.record())
+	})
+	start := g.StartPosition()
+	lg.journalize(gamelog.Event{
+		Kind:        gamelog.GameCreated,
+		At:          lg.createdAt,
+		FEN:         start.ToFEN(),
+		TimeControl: opts.TimeControl,
+		WhiteID:     opts.WhiteID,
+		BlackID:     opts.BlackID,
+	})
+
+	lg.cacheState(lg.createdAt)
+
+	s.mu.Lock()
+	s.games[lg.id] = lg
+	s.mu.Unlock()
+	v := lg.view(s.now())
+	if s.onStart != nil {
+		s.onStart(v)
+	}
+	return v, nil
+}
+
+func (s *Service) lookup(id string) (*liveGame, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	lg, ok := s.games[id]
+	if !ok {
+		return nil, ErrGameNotFound
+	}
+	return lg, nil
+}
+
+// Ongoing lists the games live on this node that userID is playing and
+// that have not ended.
+func (s *Service) Ongoing(userID string) []View {
+	s.mu.RLock()
+	games := make([]*liveGame, 0, len(s.games))
+	for _, lg := range s.games {
+		games = append(games, lg)
+	}
+	s.mu.RUnlock()
+
+	views := []View{}
+	for _, lg := range games {
+		lg.mu.Lock()
+		if userID != "" && (lg.whiteID == userID || lg.blackID == userID) && !lg.game.IsOver() {
+			views = append(views, lg.view(s.now()))
+		}
+		lg.mu.Unlock()
+	}
+	sort.Slice(views, func(i, j int) bool { return views[i].CreatedAt.Before(views[j].CreatedAt) })
+	return views
+}
+
+func (s *Service) Get(id string) (View, error) {
+	return s.update(id, func(lg *liveGame, now time.Time) error {
+		return nil
+	})
+}
+
+// Subscribe returns the current state of a game together with a subscription
+// to every event after it, so the two line up without gaps. Only a player's
+// subscription gets the chat of the players' room.
+func (s *Service) Subscribe(id string, player bool) (View, *Subscription, error) {
+	lg, err := s.lookup(id)
+	if err != nil {
+		return View{}, nil, err
+	}
+	lg.mu.Lock()
+	defer lg.mu.Unlock()
+	now := s.now()
+	lg.checkFlag(now)
+	return lg.view(now), lg.subscribe(player), nil
+}
+
+// Catchup is what a reconnecting client needs to get back in sync: the
+// events it missed, or a full snapshot when those are no longer available.
+type Catchup struct {
+	Snapshot *View
+	Missed   []Event
+}
+
+// Resume is Subscribe for a client that has already seen every event up to
+// and including since.
+func (s *Service) Resume(id string, since uint64, player bool) (Catchup, *Subscription, error) {
+	lg, err := s.lookup(id)
+	if err != nil {
+		return Catchup{}, nil, err
+	}
+	lg.mu.Lock()
+	defer lg.mu.Unlock()
+	now := s.now()
+	lg.checkFlag(now)
+	if missed, ok := lg.eventsSince(since); ok {
+		if lg.clock != nil && lg.clock.Running() {
+			missed = append(missed, Event{
+				Seq:    lg.seq,
+				GameID: lg.id,
+				Ply:    lg.game.Ply(),
+				Type:   EventClock,
+				Time:   now,
+				Clock:  lg.clockView(now),
+			})
+		}
+		return Catchup{Missed: missed}, lg.subscribe(player), nil
+	}
+	v := lg.view(now)
+	return Catchup{Snapshot: &v}, lg.subscribe(player), nil
+}
+
+func (lg *liveGame) checkFlag(now time.Time) {
+	if lg.clock == nil || lg.game.IsOver() {
+		return
+	}
+	if lg.clock.CheckFlag(now) {
+		lg.finish(now)
+	}
+}
+
+// finish clears everything pending once the game has ended and announces
+// the result.
+func (lg *liveGame) finish(now time.Time) {
+	lg.drawOffer = nil
+	lg.takebackOffer = nil
+	if lg.clock != nil {
+		lg.clock.Stop(now)
+	}
+	if lg.flagTimer != nil {
+		lg.flagTimer.Stop()
+		lg.flagTimer = nil
+	}
+	for i := range lg.presence {
+		lg.presence[i].stopTimer()
+	}
+	outcome := lg.outcomeView()
+	lg.publish(Event{Type: EventGameEnd, Time: now, Outcome: &outcome})
+	if lg.clock != nil {
+		if flagged, ok := lg.clock.Flagged(); ok {
+			lg.journalize(gamelog.Event{Kind: gamelog.ClockFlagged, At: now, Color: colorName(flagged)})
+		}
+	}
+	lg.journalize(gamelog.Event{
+		Kind:        gamelog.GameEnded,
+		At:          now,
+		Result:      outcome.Result,
+		Termination: outcome.Termination,
+		DrawReason:  outcome.DrawReason,
+	})
+	lg.cacheState(now)
+	rec := lg.record()
+	rec.EndedAt = now
+	lg.save(func(ctx context.Context, repo store.Repository) error {
+		return repo.SaveGame(ctx, rec)
+	})
+	lg.rate(rec)
+	if lg.onEnd != nil {
+		lg.onEnd(lg.view(now))
+	}
+}
+
+// scheduleFlag arms a timer for the moment the side to move would run out of
+// time, so flag falls are detected even if nobody touches the game.
+func (s *Service) scheduleFlag(lg *liveGame, now time.Time) {
+	if lg.flagTimer != nil {
+		lg.flagTimer.Stop()
+		lg.flagTimer = nil
+	}
+	if lg.clock == nil || lg.game.IsOver() {
+		return
+	}
+	left, running := lg.clock.TimeUntilFlag(now)
+	if !running {
+		return
+	}
+	lg.flagTimer = time.AfterFunc(left, func() {
+		lg.mu.Lock()
+		defer lg.mu.Unlock()
+		now := s.now()
+		lg.checkFlag(now)
+		if !lg.game.IsOver() {
+			s.scheduleFlag(lg, now)
+		}
+	})
+}
+
+// moveKey scopes a client's move ID to the side that sent it.
+func moveKey(c chess.Color, id string) string {
+	return colorName(c) + ":" + id
+}
+
+func (s *Service) Move(id string, req MoveRequest) (View, error) {
+	return s.update(id, func(lg *liveGame, now time.Time) error {
+		if req.ID != "" {
+			if _, ok := lg.moveIDs[moveKey(req.Color, req.ID)]; ok {
+				// Already applied: a retry or a second tab.
+				return nil
+			}
+		}
+		if lg.game.IsOver() {
+			return chess.ErrGameOver
+		}
+		if req.ExpectedPly != nil && *req.ExpectedPly != lg.game.Ply() {
+			return fmt.Errorf("%w: expected ply %d, game is at %d", ErrStalePosition, *req.ExpectedPly, lg.game.Ply())
+		}
+		pos := lg.game.Position()
+		if pos.SideToMove != req.Color {
+			return ErrNotYourTurn
+		}
+
+		var m chess.Move
+		var err error
+		switch {
+		case req.UCI != "":
+			m, err = pos.ParseUCI(req.UCI)
+		case req.SAN != "":
+			m, err = pos.ParseSAN(req.SAN)
+		default:
+			err = chess.ErrInvalidNotation
+		}
+		if err != nil {
+			return err
+		}
+
+		played, err := lg.game.PlayMove(m, now)
+		if err != nil {
+			return err
+		}
+		if req.ID != "" {
+			lg.moveIDs[moveKey(req.Color, req.ID)] = lg.game.Ply()
+		}
+		spent := lg.pressClock(now)
+		rec := store.Move{
+			Ply:      lg.game.Ply(),
+			UCI:      played.Move.String(),
+			SAN:      played.SAN,
+			PlayedAt: played.PlayedAt,
+			Spent:    spent,
+		}
+		if lg.clock != nil {
+			rec.Clock = lg.clock.Remaining(req.Color, now)
+		}
+		lg.saveMoves(rec)
+		logged := gamelog.Event{Kind: gamelog.MoveMade, At: now, UCI: played.Move.String()}
+		if lg.clock != nil {
+			logged.ClockMs = lg.clock.Remaining(req.Color, now).Milliseconds()
+		}
+		lg.journalize(logged)
+		lg.publish(Event{
+			Type: EventMove,
+			Time: now,
+			By:   colorName(req.Color),
+			Move: &MoveView{ID: req.ID, UCI: played.Move.String(), SAN: played.SAN, PlayedAt: played.PlayedAt},
+		})
+		if lg.drawOffer != nil && *lg.drawOffer != req.Color {
+			// Moving instead of accepting declines the opponent's offer.
+			lg.drawOffer = nil
+			lg.publish(Event{Type: EventDrawDeclined, Time: now, By: colorName(req.Color)})
+		}
+		if lg.takebackOffer != nil && *lg.takebackOffer != req.Color {
+			lg.takebackOffer = nil
+			lg.publish(Event{Type: EventTakebackDeclined, Time: now, By: colorName(req.Color)})
+		}
+		return nil
+	})
+}
+
+// pressClock hands the clock to the side to move and returns how long the
+// move just played took.
+func (lg *liveGame) pressClock(now time.Time) time.Duration {
+	if lg.clock == nil || lg.game.IsOver() {
+		return 0
+	}
+	if !lg.clock.Running() {
+		lg.clock.Start(now, lg.game.Position().SideToMove)
+		return 0
+	}
+	spent, _ := lg.clock.Press(now)
+	return spent
+}
+
+func (s *Service) Resign(id string, c chess.Color) (View, error) {
+	return s.update(id, func(lg *liveGame, now time.Time) error {
+		return lg.game.Resign(c)
+	})
+}
+
+func (s *Service) OfferDraw(id string, c chess.Color) (View, error) {
+	return s.update(id, func(lg *liveGame, now time.Time) error {
+		if lg.game.IsOver() {
+			return chess.ErrGameOver
+		}
+		if lg.drawOffer != nil && *lg.drawOffer != c {
+			return lg.game.AgreeDraw()
+		}
+		lg.drawOffer = &c
+		lg.journalize(gamelog.Event{Kind: gamelog.DrawOffered, At: now, Color: colorName(c)})
+		lg.publish(Event{Type: EventDrawOffer, Time: now, By: colorName(c)})
+		return nil
+	})
+}
+
+func (s *Service) AcceptDraw(id string, c chess.Color) (View, error) {
+	return s.update(id, func(lg *liveGame, now time.Time) error {
+		if lg.drawOffer == nil {
+			return ErrNoDrawOffer
+		}
+		if *lg.drawOffer == c {
+			return ErrOwnDrawOffer
+		}
+		return lg.game.AgreeDraw()
+	})
+}
+
+func (s *Service) DeclineDraw(id string, c chess.Color) (View, error) {
+	return s.update(id, func(lg *liveGame, now time.Time) error {
+		if lg.drawOffer == nil || *lg.drawOffer == c {
+			return ErrNoDrawOffer
+		}
+		lg.drawOffer = nil
+		lg.publish(Event{Type: EventDrawDeclined, Time: now, By: colorName(c)})
+		return nil
+	})
+}
+
+// takebackPlies is how many plies must be undone to take back c's last move.
+func (lg *liveGame) takebackPlies(c chess.Color) int {
+	plies := 1
+	pos := lg.game.Position()
+	if pos.SideToMove == c {
+		plies = 2
+	}
+	if plies > lg.game.Ply() {
+		return 0
+	}
+	return plies
+}
+
+func (s *Service) OfferTakeback(id string, c chess.Color) (View, error) {
+	return s.update(id, func(lg *liveGame, now time.Time) error {
+		if lg.game.IsOver() {
+			return chess.ErrGameOver
+		}
+		if lg.takebackPlies(c) == 0 {
+			return ErrNothingToUndo
+		}
+		lg.takebackOffer = &c
+		lg.publish(Event{Type: EventTakebackOffer, Time: now, By: colorName(c)})
+		return nil
+	})
+}
+
+func (s *Service) AcceptTakeback(id string, c chess.Color) (View, error) {
+	return s.update(id, func(lg *liveGame, now time.Time) error {
+		if lg.takebackOffer == nil {
+			return ErrNoTakeback
+		}
+		if *lg.takebackOffer == c {
+			return ErrOwnTakeback
+		}
+		plies := lg.takebackPlies(*lg.takebackOffer)
+		if plies == 0 {
+			return ErrNothingToUndo
+		}
+		for range plies {
+			if err := lg.game.Takeback(); err != nil {
+				return err
+			}
+		}
+		lg.takebackOffer = nil
+		// IDs of the moves taken back are free for the moves replacing them.
+		maps.DeleteFunc(lg.moveIDs, func(_ string, ply int) bool { return ply > lg.game.Ply() })
+		lg.takeBackMoves()
+		pos := lg.game.Position()
+		lg.journalize(gamelog.Event{Kind: gamelog.MoveTakenBack, At: now, FEN: pos.ToFEN(), Ply: lg.game.Ply()})
+		if lg.clock != nil && lg.clock.Running() {
+			lg.clock.Stop(now)
+			if lg.game.Ply() > 0 {
+				lg.clock.Start(now, lg.game.Position().SideToMove)
+			}
+		}
+		lg.publish(Event{Type: EventTakeback, Time: now, By: colorName(c)})
+		return nil
+	})
+}
+
+func (s *Service) DeclineTakeback(id string, c chess.Color) (View, error) {
+	return s.update(id, func(lg *liveGame, now time.Time) error {
+		if lg.takebackOffer == nil || *lg.takebackOffer == c {
+			return ErrNoTakeback
+		}
+		lg.takebackOffer = nil
+		lg.publish(Event{Type: EventTakebackDeclined, Time: now, By: colorName(c)})
+		return nil
+	})
+}
+
+// Berserk halves c's clock in exchange for a bonus in arena scoring. It must
+// come before c's first move.
+func (s *Service) Berserk(id string, c chess.Color) (View, error) {
+	return s.update(id, func(lg *liveGame, now time.Time) error {
+		if lg.game.IsOver() {
+			return chess.ErrGameOver
+		}
+		if !lg.berserk || lg.clock == nil {
+			return ErrBerserkNotAllowed
+		}
+		if lg.hasMoved(c) {
+			return clock.ErrBerserkTooLate
+		}
+		if err := lg.clock.Berserk(c); err != nil {
+			return err
+		}
+		lg.publish(Event{Type: EventBerserk, Time: now, By: colorName(c), Clock: lg.clockView(now)})
+		return nil
+	})
+}
+
+// hasMoved reports whether c has made a move. The first move does not
+// press the clock, so the clock cannot tell.
+func (lg *liveGame) hasMoved(c chess.Color) bool {
+	ply := lg.game.Ply()
+	if lg.game.StartPosition().SideToMove == c {
+		return ply >= 1
+	}
+	return ply >= 2
+}
+
+// update runs fn with the game locked, then settles what every mutation
+// shares: flag detection, the game end announcement and the flag timer.
+func (s *Service) update(id string, fn func(lg *liveGame, now time.Time) error) (View, error) {
+	lg, err := s.lookup(id)
+	if err != nil {
+		return View{}, err
+	}
+	lg.mu.Lock()
+	defer lg.mu.Unlock()
+
+	now := s.now()
+	lg.checkFlag(now)
+	wasOver, seq := lg.game.IsOver(), lg.seq
+	if err := fn(lg, now); err != nil {
+		return View{}, err
+	}
+	if !wasOver && lg.game.IsOver() {
+		lg.finish(now)
+	} else if lg.seq != seq {
+		lg.cacheState(now)
+	}
+	s.scheduleFlag(lg, now)
+	return lg.view(now), nil
+}
+
+// LegalMovesFrom lists the legal moves of the piece on square, in UCI.
+func (s *Service) LegalMovesFrom(id string, square string) ([]string, error) {
+	lg, err := s.lookup(id)
+	if err != nil {
+		return nil, err
+	}
+	lg.mu.Lock()
+	defer lg.mu.Unlock()
+
+	moves := []string{}
+	for _, m := range lg.game.LegalMoves() {
+		if m.From.String() == square {
+			moves = append(moves, m.String())
+		}
+	}
+	return moves, nil
+}
+
+// RunClockSync publishes a clock event for every game with a running clock
+// each interval until ctx is done, so clients can correct drift.
+func (s *Service) RunClockSync(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		s.mu.RLock()
+		games := make([]*liveGame, 0, len(s.games))
+		for _, lg := range s.games {
+			games = append(games, lg)
+		}
+		s.mu.RUnlock()
+
+		for _, lg := range games {
+			lg.mu.Lock()
+			if lg.clock != nil && lg.clock.Running() && (len(lg.subs) > 0 || lg.onEvent != nil) {
+				lg.publish(Event{Type: EventClock, Time: s.now()})
+			}
+			lg.mu.Unlock()
+		}
+	}
+}
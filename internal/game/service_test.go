@@ -0,0 +1,546 @@
+package game
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/THECHAMP95821/chess-backend/internal/chess"
+	"github.com/THECHAMP95821/chess-backend/internal/clock"
+	"github.com/THECHAMP95821/chess-backend/internal/gamelog"
+	"github.com/THECHAMP95821/chess-backend/internal/store"
+)
+
+type fakeClock struct {
+	t time.Time
+}
+
+func (f *fakeClock) now() time.Time {
+	return f.t
+}
+
+func (f *fakeClock) advance(d time.Duration) {
+	f.t = f.t.Add(d)
+}
+
+func newTestService() (*Service, *fakeClock) {
+	fc := &fakeClock{t: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+	s := NewService()
+	s.now = fc.now
+	return s, fc
+}
+
+func TestServiceFlagsOnAccess(t *testing.T) {
+	s, fc := newTestService()
+	v, err := s.Create(CreateOptions{TimeControl: "60+0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Move(v.ID, MoveRequest{Color: chess.ColorWhite, UCI: "e2e4"}); err != nil {
+		t.Fatal(err)
+	}
+	fc.advance(61 * time.Second)
+
+	got, err := s.Get(v.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Outcome.Termination != "timeout" || got.Outcome.Result != "1-0" {
+		t.Errorf("outcome = %+v, want Black flagged", got.Outcome)
+	}
+	if _, err := s.Move(v.ID, MoveRequest{Color: chess.ColorBlack, UCI: "e7e5"}); err != chess.ErrGameOver {
+		t.Errorf("move after flag: err = %v, want ErrGameOver", err)
+	}
+}
+
+func TestServiceMoveDeclinesDrawOffer(t *testing.T) {
+	s, _ := newTestService()
+	v, _ := s.Create(CreateOptions{})
+	s.OfferDraw(v.ID, chess.ColorWhite)
+	got, err := s.Move(v.ID, MoveRequest{Color: chess.ColorWhite, SAN: "e4"})
+	if err != nil || got.DrawOffer != "white" {
+		t.Fatalf("offer should survive the offerer's move: %+v, %v", got.DrawOffer, err)
+	}
+	got, _ = s.Move(v.ID, MoveRequest{Color: chess.ColorBlack, SAN: "e5"})
+	if got.DrawOffer != "" {
+		t.Errorf("draw offer = %q after opponent moved, want none", got.DrawOffer)
+	}
+}
+
+func TestServiceResume(t *testing.T) {
+	s, _ := newTestService()
+	v, _ := s.Create(CreateOptions{})
+	for _, uci := range []string{"e2e4", "e7e5", "g1f3"} {
+		c := chess.ColorWhite
+		if v.SideToMove == "black" {
+			c = chess.ColorBlack
+		}
+		var err error
+		if v, err = s.Move(v.ID, MoveRequest{Color: c, UCI: uci}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	catchup, sub, err := s.Resume(v.ID, 1, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub.Close()
+	if catchup.Snapshot != nil || len(catchup.Missed) != 2 || catchup.Missed[0].Seq != 2 || catchup.Missed[1].Move.UCI != "g1f3" {
+		t.Errorf("resume from 1 = %+v, want moves 2 and 3", catchup)
+	}
+
+	catchup, sub, _ = s.Resume(v.ID, 3, false)
+	sub.Close()
+	if catchup.Snapshot != nil || len(catchup.Missed) != 0 {
+		t.Errorf("resume when up to date = %+v, want nothing missed", catchup)
+	}
+
+	catchup, sub, _ = s.Resume(v.ID, 9, false)
+	sub.Close()
+	if catchup.Snapshot == nil || catchup.Snapshot.Seq != 3 {
+		t.Errorf("resume from the future = %+v, want snapshot", catchup)
+	}
+
+	lg, _ := s.lookup(v.ID)
+	lg.history = lg.history[2:]
+	catchup, sub, _ = s.Resume(v.ID, 1, false)
+	sub.Close()
+	if catchup.Snapshot == nil {
+		t.Errorf("resume past history = %+v, want snapshot", catchup)
+	}
+}
+
+func TestServicePresence(t *testing.T) {
+	s, fc := newTestService()
+	s.grace = 10 * time.Millisecond
+	v, _ := s.Create(CreateOptions{})
+	_, sub, _ := s.Subscribe(v.ID, false)
+	defer sub.Close()
+
+	release, err := s.Connect(v.ID, chess.ColorWhite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := s.Get(v.ID)
+	if !got.Presence["white"].Connected || got.Presence["black"].Connected {
+		t.Errorf("presence = %+v, want only white connected", got.Presence)
+	}
+
+	release()
+	fc.advance(5 * time.Second)
+	got, _ = s.Get(v.ID)
+	if p := got.Presence["white"]; p.Connected || p.GoneMs != 5000 {
+		t.Errorf("white presence = %+v, want gone for 5s", p)
+	}
+
+	select {
+	case e := <-sub.C:
+		if e.Type != EventPlayerGone || e.By != "white" {
+			t.Errorf("event = %+v, want white gone", e)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no event after grace period")
+	}
+
+	release, _ = s.Connect(v.ID, chess.ColorWhite)
+	defer release()
+	if e := <-sub.C; e.Type != EventPlayerBack {
+		t.Errorf("event = %+v, want white back", e)
+	}
+}
+
+func TestServiceMoveConcurrency(t *testing.T) {
+	s, _ := newTestService()
+	v, _ := s.Create(CreateOptions{})
+	_, sub, _ := s.Subscribe(v.ID, false)
+	defer sub.Close()
+
+	ply := 0
+	req := MoveRequest{Color: chess.ColorWhite, UCI: "e2e4", ExpectedPly: &ply, ID: "m1"}
+	for range 2 {
+		got, err := s.Move(v.ID, req)
+		if err != nil || got.Ply != 1 {
+			t.Fatalf("move m1: ply %d, err %v", got.Ply, err)
+		}
+	}
+	if e := <-sub.C; e.Move == nil || e.Move.ID != "m1" {
+		t.Errorf("event = %+v, want move m1", e)
+	}
+	select {
+	case e := <-sub.C:
+		t.Errorf("duplicate submission published %+v", e)
+	default:
+	}
+
+	stale := MoveRequest{Color: chess.ColorWhite, UCI: "d2d4", ExpectedPly: &ply, ID: "m2"}
+	if _, err := s.Move(v.ID, stale); !errors.Is(err, ErrStalePosition) {
+		t.Errorf("stale move: err = %v, want ErrStalePosition", err)
+	}
+}
+
+func TestServiceMoveIDsPerSideAndPly(t *testing.T) {
+	s, _ := newTestService()
+	v, _ := s.Create(CreateOptions{})
+
+	s.Move(v.ID, MoveRequest{Color: chess.ColorWhite, UCI: "e2e4", ID: "1"})
+	got, err := s.Move(v.ID, MoveRequest{Color: chess.ColorBlack, UCI: "e7e5", ID: "1"})
+	if err != nil || got.Ply != 2 {
+		t.Fatalf("black move under white's ID: ply %d, err %v", got.Ply, err)
+	}
+
+	s.OfferTakeback(v.ID, chess.ColorWhite)
+	s.AcceptTakeback(v.ID, chess.ColorBlack)
+	got, err = s.Move(v.ID, MoveRequest{Color: chess.ColorWhite, UCI: "d2d4", ID: "1"})
+	if err != nil || got.Ply != 1 || got.Moves[0].UCI != "d2d4" {
+		t.Errorf("move under an ID taken back: %+v, err %v", got.Moves, err)
+	}
+}
+
+func TestServiceRecordsGames(t *testing.T) {
+	s, fc := newTestService()
+	repo := store.NewMemory()
+	s.SetStore(repo)
+	ctx := context.Background()
+
+	v, _ := s.Create(CreateOptions{TimeControl: "60+1"})
+	s.Move(v.ID, MoveRequest{Color: chess.ColorWhite, UCI: "e2e4"})
+	fc.advance(3 * time.Second)
+	s.Move(v.ID, MoveRequest{Color: chess.ColorBlack, UCI: "e7e5"})
+	fc.advance(2 * time.Second)
+	s.Move(v.ID, MoveRequest{Color: chess.ColorWhite, UCI: "f1c4"})
+	s.OfferTakeback(v.ID, chess.ColorWhite)
+	s.AcceptTakeback(v.ID, chess.ColorBlack)
+
+	moves, err := repo.Moves(ctx, v.ID)
+	if err != nil || len(moves) != 2 {
+		t.Fatalf("stored moves = %+v, %v; want two after takeback", moves, err)
+	}
+	if m := moves[1]; m.SAN != "e5" || m.Spent != 3*time.Second || m.Clock != 58*time.Second {
+		t.Errorf("e5 = %+v, want 3s spent and 58s left", m)
+	}
+
+	s.Resign(v.ID, chess.ColorWhite)
+	g, err := repo.Game(ctx, v.ID)
+	if err != nil || g.Result != "0-1" || g.Termination != "resignation" || g.TimeControl != "60+1" || g.EndedAt.IsZero() {
+		t.Errorf("stored game = %+v, %v", g, err)
+	}
+}
+
+// flakyRepo fails the next failAppends move writes, first committing them
+// if committed is set, as a write that times out might.
+type flakyRepo struct {
+	*store.Memory
+	failAppends int
+	committed   bool
+}
+
+func (r *flakyRepo) AppendMove(ctx context.Context, gameID string, m store.Move) error {
+	if r.failAppends == 0 {
+		return r.Memory.AppendMove(ctx, gameID, m)
+	}
+	r.failAppends--
+	if r.committed {
+		r.Memory.AppendMove(ctx, gameID, m)
+	}
+	return context.DeadlineExceeded
+}
+
+func TestServiceCatchesUpAfterFailedWrites(t *testing.T) {
+	s, fc := newTestService()
+	repo := &flakyRepo{Memory: store.NewMemory()}
+	s.SetStore(repo)
+	ctx := context.Background()
+	v, _ := s.Create(CreateOptions{TimeControl: "60+0"})
+	play := func(c chess.Color, uci string) {
+		t.Helper()
+		fc.advance(time.Second)
+		if _, err := s.Move(v.ID, MoveRequest{Color: c, UCI: uci}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	play(chess.ColorWhite, "e2e4")
+	repo.failAppends = 1
+	play(chess.ColorBlack, "e7e5")
+	play(chess.ColorWhite, "g1f3")
+	moves, _ := repo.Moves(ctx, v.ID)
+	if len(moves) != 3 || moves[1].UCI != "e7e5" || moves[1].Spent != time.Second {
+		t.Fatalf("moves after a failed write = %+v", moves)
+	}
+
+	repo.failAppends, repo.committed = 1, true
+	play(chess.ColorBlack, "b8c6")
+	play(chess.ColorWhite, "f1c4")
+	s.OfferTakeback(v.ID, chess.ColorWhite)
+	s.AcceptTakeback(v.ID, chess.ColorBlack)
+	play(chess.ColorWhite, "f1b5")
+	moves, _ = repo.Moves(ctx, v.ID)
+	if len(moves) != 5 || moves[3].UCI != "b8c6" || moves[4].UCI != "f1b5" {
+		t.Errorf("moves after a write that failed but went through = %+v", moves)
+	}
+}
+
+type raterFunc func(store.Game) error
+
+func (f raterFunc) RateGame(ctx context.Context, g store.Game) error { return f(g) }
+
+func TestServiceRatesGames(t *testing.T) {
+	s, _ := newTestService()
+	var rated []store.Game
+	s.SetRater(raterFunc(func(g store.Game) error {
+		rated = append(rated, g)
+		return nil
+	}))
+
+	casual, _ := s.Create(CreateOptions{WhiteID: "alice", BlackID: "bob"})
+	anonymous, _ := s.Create(CreateOptions{WhiteID: "alice", Rated: true})
+	v, _ := s.Create(CreateOptions{TimeControl: "180+2", WhiteID: "alice", BlackID: "bob", Rated: true})
+	if !v.Rated || anonymous.Rated {
+		t.Errorf("rated = %v, %v; want only games between two users rated", v.Rated, anonymous.Rated)
+	}
+	for _, id := range []string{casual.ID, anonymous.ID, v.ID} {
+		s.Resign(id, chess.ColorBlack)
+	}
+	if len(rated) != 1 || rated[0].ID != v.ID || rated[0].Result != "1-0" || rated[0].EndedAt.IsZero() {
+		t.Errorf("rated games = %+v, want just the finished rated one", rated)
+	}
+}
+
+func TestServiceJournalReplays(t *testing.T) {
+	s, _ := newTestService()
+	j := gamelog.NewJournal(store.NewMemory())
+	j.SnapshotEvery = 2
+	s.SetJournal(j)
+
+	v, _ := s.Create(CreateOptions{TimeControl: "60+0"})
+	for i, uci := range []string{"f2f3", "e7e5", "g2g4", "d8h4"} {
+		c := chess.ColorWhite
+		if i%2 == 1 {
+			c = chess.ColorBlack
+		}
+		if _, err := s.Move(v.ID, MoveRequest{Color: c, UCI: uci}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r, err := j.Rebuild(context.Background(), v.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := s.Get(v.ID)
+	if r.Position.ToFEN() != got.FEN || r.Ended == nil || r.Ended.Termination != "checkmate" {
+		t.Errorf("rebuilt %s ended %+v, want %s ended by mate", r.Position.ToFEN(), r.Ended, got.FEN)
+	}
+}
+
+// flakyLog fails the next failAppends appends, first committing them if
+// committed is set.
+type flakyLog struct {
+	*store.Memory
+	failAppends int
+	committed   bool
+}
+
+func (l *flakyLog) Append(ctx context.Context, gameID string, expected int, entries ...store.LogEntry) error {
+	if l.failAppends == 0 {
+		return l.Memory.Append(ctx, gameID, expected, entries...)
+	}
+	l.failAppends--
+	if l.committed {
+		l.Memory.Append(ctx, gameID, expected, entries...)
+	}
+	return context.DeadlineExceeded
+}
+
+func TestServiceJournalCatchesUp(t *testing.T) {
+	s, _ := newTestService()
+	el := &flakyLog{Memory: store.NewMemory()}
+	s.SetJournal(gamelog.NewJournal(el))
+	ctx := context.Background()
+	if _, err := s.Audit(ctx, "missing"); !errors.Is(err, ErrGameNotFound) {
+		t.Errorf("audit of a missing game: err = %v", err)
+	}
+
+	v, _ := s.Create(CreateOptions{})
+	el.failAppends = 1
+	s.Move(v.ID, MoveRequest{Color: chess.ColorWhite, UCI: "e2e4"})
+	a, err := s.Audit(ctx, v.ID)
+	if err != nil || !a.Checked || a.Diverged || a.Ply != 1 || len(a.Events) != 2 {
+		t.Fatalf("audit after a failed append = %+v, %v", a, err)
+	}
+
+	el.failAppends, el.committed = 1, true
+	s.Move(v.ID, MoveRequest{Color: chess.ColorBlack, UCI: "e7e5"})
+	s.Move(v.ID, MoveRequest{Color: chess.ColorWhite, UCI: "g1f3"})
+	a, err = s.Audit(ctx, v.ID)
+	if err != nil || a.Diverged || a.Ply != 3 || len(a.Events) != 4 || a.Unlogged != 0 {
+		t.Errorf("audit after an append that failed but went through = %+v, %v", a, err)
+	}
+}
+
+type memCache map[string]LiveState
+
+func (c memCache) Save(ctx context.Context, s LiveState) error {
+	c[s.ID] = s
+	return nil
+}
+
+func (c memCache) Delete(ctx context.Context, id string) error {
+	delete(c, id)
+	return nil
+}
+
+func TestServiceHandoff(t *testing.T) {
+	s, fc := newTestService()
+	cache := memCache{}
+	s.SetCache(cache)
+	v, _ := s.Create(CreateOptions{TimeControl: "60+0"})
+	s.Move(v.ID, MoveRequest{Color: chess.ColorWhite, UCI: "e2e4"})
+	_, sub, _ := s.Subscribe(v.ID, false)
+
+	fc.advance(7 * time.Second)
+	if ids := s.Handoff(); len(ids) != 1 || ids[0] != v.ID {
+		t.Fatalf("handed off %v, want [%s]", ids, v.ID)
+	}
+	if _, ok := <-sub.C; ok {
+		t.Error("subscriber not disconnected")
+	}
+	if st := cache[v.ID]; !st.SavedAt.Equal(fc.now()) || st.Clock == nil || !st.Clock.Running {
+		t.Errorf("cached state = %+v, want running clock saved at handoff", st)
+	}
+	if s.Has(v.ID) {
+		t.Error("game still live after handoff")
+	}
+	if _, err := s.Create(CreateOptions{}); !errors.Is(err, ErrShuttingDown) {
+		t.Errorf("create while draining: err = %v, want ErrShuttingDown", err)
+	}
+}
+
+func TestServiceBerserk(t *testing.T) {
+	s, _ := newTestService()
+	plain, _ := s.Create(CreateOptions{TimeControl: "180+2"})
+	if _, err := s.Berserk(plain.ID, chess.ColorWhite); !errors.Is(err, ErrBerserkNotAllowed) {
+		t.Errorf("berserk in a plain game: %v", err)
+	}
+
+	v, _ := s.Create(CreateOptions{TimeControl: "180+2", Berserk: true})
+	if _, err := s.Move(v.ID, MoveRequest{Color: chess.ColorWhite, UCI: "e2e4"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Berserk(v.ID, chess.ColorWhite); !errors.Is(err, clock.ErrBerserkTooLate) {
+		t.Errorf("berserk after the first move: %v", err)
+	}
+	got, err := s.Berserk(v.ID, chess.ColorBlack)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Clock.BlackBerserk || got.Clock.WhiteBerserk || got.Clock.BlackMs != 90000 {
+		t.Errorf("clock after berserk = %+v", got.Clock)
+	}
+}
+
+func TestServiceArmageddon(t *testing.T) {
+	s, _ := newTestService()
+	v, err := s.Create(CreateOptions{TimeControl: "300+0", BlackTimeControl: "240+0", Rule: chess.ArmageddonRule})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Clock.WhiteMs != 300000 || v.Clock.BlackMs != 240000 || v.Clock.BlackControl != "240" || v.ResultRule != "armageddon" {
+		t.Errorf("armageddon game = %+v, clock %+v", v, v.Clock)
+	}
+	s.OfferDraw(v.ID, chess.ColorWhite)
+	v, err = s.AcceptDraw(v.ID, chess.ColorBlack)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Outcome.Result != "1/2-1/2" || v.Outcome.Scored != "0-1" {
+		t.Errorf("drawn armageddon outcome = %+v, want a draw scored for Black", v.Outcome)
+	}
+	if _, err := s.Create(CreateOptions{TimeControl: "300+0", BlackTimeControl: "*240"}); !errors.Is(err, ErrInvalidTime) {
+		t.Errorf("odds across modes: err = %v", err)
+	}
+}
+
+func TestServiceChatAndStarts(t *testing.T) {
+	s, _ := newTestService()
+	var started []string
+	s.OnGameStart(func(v View) { started = append(started, v.ID) })
+	v, _ := s.Create(CreateOptions{WhiteID: "alice", BlackID: "bot"})
+	other, _ := s.Create(CreateOptions{WhiteID: "bob", BlackID: "carol"})
+	if len(started) != 2 || started[0] != v.ID {
+		t.Errorf("started = %v", started)
+	}
+	if got := s.Ongoing("bot"); len(got) != 1 || got[0].ID != v.ID {
+		t.Errorf("ongoing for bot = %+v", got)
+	}
+	s.Resign(other.ID, chess.ColorWhite)
+	if got := s.Ongoing("bob"); len(got) != 0 {
+		t.Errorf("finished game still ongoing: %+v", got)
+	}
+
+	_, sub, _ := s.Subscribe(v.ID, true)
+	defer sub.Close()
+	if err := s.Chat(v.ID, ChatView{Room: RoomPlayer, User: "bot", Text: "  good luck  "}); err != nil {
+		t.Fatal(err)
+	}
+	if e := <-sub.C; e.Type != EventChat || e.Chat.Text != "good luck" || e.Chat.User != "bot" {
+		t.Errorf("chat event = %+v", e)
+	}
+	if err := s.Chat(v.ID, ChatView{Room: RoomPlayer, Text: strings.Repeat("x", maxChat+1)}); !errors.Is(err, ErrInvalidChat) {
+		t.Errorf("long message: err = %v", err)
+	}
+	if err := s.Chat(v.ID, ChatView{Room: "lobby", Text: "hi"}); !errors.Is(err, ErrInvalidChat) {
+		t.Errorf("unknown room: err = %v", err)
+	}
+}
+
+func TestServiceKeepsPlayerChatFromSpectators(t *testing.T) {
+	s, fc := newTestService()
+	var published []Event
+	s.OnEvent(func(e Event) { published = append(published, e) })
+	v, _ := s.Create(CreateOptions{WhiteID: "alice", BlackID: "bob"})
+	_, player, _ := s.Subscribe(v.ID, true)
+	defer player.Close()
+	_, spectator, _ := s.Subscribe(v.ID, false)
+	defer spectator.Close()
+
+	s.Chat(v.ID, ChatView{Room: RoomPlayer, User: "alice", Text: "good luck"})
+	s.Chat(v.ID, ChatView{Room: RoomSpectator, User: "carol", Text: "go bob"})
+	if e := <-player.C; e.Chat.Room != RoomPlayer || e.Seq != 0 {
+		t.Errorf("player got %+v first, want the players' chat at seq 0", e)
+	}
+	if e := <-player.C; e.Chat.Room != RoomSpectator || e.Seq != 1 {
+		t.Errorf("player got %+v second", e)
+	}
+	if e := <-spectator.C; e.Chat.Room != RoomSpectator || e.Seq != 1 {
+		t.Errorf("spectator got %+v, want only the spectators' chat", e)
+	}
+	if len(published) != 1 || published[0].Chat.Room != RoomSpectator {
+		t.Errorf("published %+v, want only the spectators' chat", published)
+	}
+	if catchup, sub, _ := s.Resume(v.ID, 0, true); len(catchup.Missed) != 1 {
+		t.Errorf("missed %+v, want the spectators' chat alone", catchup.Missed)
+	} else {
+		sub.Close()
+	}
+
+	// Each user has their own allowance.
+	for range chatBurst - 1 {
+		if err := s.Chat(v.ID, ChatView{Room: RoomPlayer, User: "alice", Text: "hi"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Chat(v.ID, ChatView{Room: RoomSpectator, User: "alice", Text: "hi"}); !errors.Is(err, ErrChatTooFast) {
+		t.Errorf("message %d in a window: err = %v", chatBurst+1, err)
+	}
+	if err := s.Chat(v.ID, ChatView{Room: RoomPlayer, User: "bob", Text: "hi"}); err != nil {
+		t.Errorf("another user: err = %v", err)
+	}
+	fc.t = fc.t.Add(chatWindow)
+	if err := s.Chat(v.ID, ChatView{Room: RoomPlayer, User: "alice", Text: "hi"}); err != nil {
+		t.Errorf("after the window: err = %v", err)
+	}
+}
@@ -0,0 +1,154 @@
+package game
+
+import (
+	"strings"
+	"time"
+
+	"github.com/THECHAMP95821/chess-backend/internal/chess"
+)
+
+type MoveView struct {
+	ID       string    `json:"id,omitempty"`
+	UCI      string    `json:"uci"`
+	SAN      string    `json:"san"`
+	PlayedAt time.Time `json:"played_at"`
+}
+
+type ClockView struct {
+	Control string `json:"control"`
+	WhiteMs int64  `json:"white_ms"`
+	BlackMs int64  `json:"black_ms"`
+	Running bool   `json:"running"`
+	Ticking string `json:"ticking,omitempty"`
+	// BlackControl is Black's time control when it differs from Control.
+	BlackControl string `json:"black_control,omitempty"`
+	// WhiteBerserk and BlackBerserk are set once that side has gone berserk.
+	WhiteBerserk bool `json:"white_berserk,omitempty"`
+	BlackBerserk bool `json:"black_berserk,omitempty"`
+}
+
+type OutcomeView struct {
+	Result      string `json:"result"`
+	Termination string `json:"termination"`
+	DrawReason  string `json:"draw_reason,omitempty"`
+	// Scored is the result that counts, when the game's result rule makes
+	// it differ from the result on the board.
+	Scored string `json:"scored,omitempty"`
+}
+
+type PresenceView struct {
+	Connected bool  `json:"connected"`
+	GoneMs    int64 `json:"gone_ms,omitempty"`
+}
+
+// View is the externally visible state of a game, built from the position,
+// its legal moves and the evaluated outcome.
+type View struct {
+	ID         string                  `json:"id"`
+	WhiteID    string                  `json:"white_id,omitempty"`
+	BlackID    string                  `json:"black_id,omitempty"`
+	Rated      bool                    `json:"rated"`
+	ResultRule string                  `json:"result_rule,omitempty"`
+	Seq        uint64                  `json:"seq"`
+	FEN        string                  `json:"fen"`
+	InitialFEN string                  `json:"initial_fen"`
+	SideToMove string                  `json:"side_to_move"`
+	Ply        int                     `json:"ply"`
+	Moves      []MoveView              `json:"moves"`
+	LegalMoves []string                `json:"legal_moves"`
+	InCheck    bool                    `json:"in_check"`
+	Outcome    OutcomeView             `json:"outcome"`
+	DrawOffer  string                  `json:"draw_offer,omitempty"`
+	Takeback   string                  `json:"takeback_offer,omitempty"`
+	Clock      *ClockView              `json:"clock,omitempty"`
+	Presence   map[string]PresenceView `json:"presence"`
+	CreatedAt  time.Time               `json:"created_at"`
+}
+
+func colorName(c chess.Color) string {
+	return strings.ToLower(c.String())
+}
+
+func (lg *liveGame) outcomeView() OutcomeView {
+	ov := OutcomeView{
+		Result:      lg.game.Outcome().Result.String(),
+		Termination: lg.game.Termination().String(),
+	}
+	if reason := lg.game.Outcome().DrawReason; reason != chess.DrawNone {
+		ov.DrawReason = reason.String()
+	}
+	if scored := lg.rule.Score(lg.game.Outcome()); scored != lg.game.Outcome().Result {
+		ov.Scored = scored.String()
+	}
+	return ov
+}
+
+func (lg *liveGame) clockView(now time.Time) *ClockView {
+	if lg.clock == nil {
+		return nil
+	}
+	cv := &ClockView{
+		Control: lg.clock.Control().String(),
+		WhiteMs: lg.clock.Remaining(chess.ColorWhite, now).Milliseconds(),
+		BlackMs: lg.clock.Remaining(chess.ColorBlack, now).Milliseconds(),
+		Running: lg.clock.Running(),
+
+		WhiteBerserk: lg.clock.Berserked(chess.ColorWhite),
+		BlackBerserk: lg.clock.Berserked(chess.ColorBlack),
+	}
+	if cv.Running {
+		cv.Ticking = colorName(lg.clock.Turn())
+	}
+	if lg.clock.Odds() {
+		cv.BlackControl = lg.clock.ControlOf(chess.ColorBlack).String()
+	}
+	return cv
+}
+
+func (lg *liveGame) view(now time.Time) View {
+	pos := lg.game.Position()
+	start := lg.game.StartPosition()
+
+	v := View{
+		ID:         lg.id,
+		WhiteID:    lg.whiteID,
+		BlackID:    lg.blackID,
+		Rated:      lg.rated,
+		Seq:        lg.seq,
+		FEN:        pos.ToFEN(),
+		InitialFEN: start.ToFEN(),
+		SideToMove: colorName(pos.SideToMove),
+		Ply:        lg.game.Ply(),
+		Moves:      []MoveView{},
+		LegalMoves: []string{},
+		InCheck:    pos.IsKingInCheck(),
+		Outcome:    lg.outcomeView(),
+		Clock:      lg.clockView(now),
+		Presence:   make(map[string]PresenceView, 2),
+		CreatedAt:  lg.createdAt,
+	}
+	if lg.rule != chess.StandardRule {
+		v.ResultRule = lg.rule.String()
+	}
+	for _, pm := range lg.game.Moves() {
+		v.Moves = append(v.Moves, MoveView{UCI: pm.Move.String(), SAN: pm.SAN, PlayedAt: pm.PlayedAt})
+	}
+	for _, m := range lg.game.LegalMoves() {
+		v.LegalMoves = append(v.LegalMoves, m.String())
+	}
+	if lg.drawOffer != nil {
+		v.DrawOffer = colorName(*lg.drawOffer)
+	}
+	if lg.takebackOffer != nil {
+		v.Takeback = colorName(*lg.takebackOffer)
+	}
+	for _, c := range []chess.Color{chess.ColorWhite, chess.ColorBlack} {
+		p := lg.presence[c]
+		pv := PresenceView{Connected: p.conns > 0}
+		if !pv.Connected && !p.goneSince.IsZero() {
+			pv.GoneMs = now.Sub(p.goneSince).Milliseconds()
+		}
+		v.Presence[colorName(c)] = pv
+	}
+	return v
+}
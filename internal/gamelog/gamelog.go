@@ -0,0 +1,190 @@
+// Package gamelog records every game as an append-only stream of domain
+// events and rebuilds positions by replaying it.
+package gamelog
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/THECHAMP95821/chess-backend/internal/chess"
+	"github.com/THECHAMP95821/chess-backend/internal/store"
+)
+
+var ErrCorruptLog = errors.New("game log cannot be replayed")
+
+type Kind string
+
+const (
+	GameCreated   Kind = "game_created"
+	MoveMade      Kind = "move_made"
+	MoveTakenBack Kind = "move_taken_back"
+	DrawOffered   Kind = "draw_offered"
+	ClockFlagged  Kind = "clock_flagged"
+	GameEnded     Kind = "game_ended"
+)
+
+// Event is one domain event of a game. Which fields are set depends on
+// Kind: GameCreated carries the setup, MoveMade the move and the mover's
+// remaining time, MoveTakenBack the position it returned to, DrawOffered
+// and ClockFlagged the color involved and GameEnded the outcome.
+type Event struct {
+	Kind        Kind      `json:"kind"`
+	At          time.Time `json:"at"`
+	FEN         string    `json:"fen,omitempty"`
+	TimeControl string    `json:"time_control,omitempty"`
+	WhiteID     string    `json:"white_id,omitempty"`
+	BlackID     string    `json:"black_id,omitempty"`
+	UCI         string    `json:"uci,omitempty"`
+	ClockMs     int64     `json:"clock_ms,omitempty"`
+	Ply         int       `json:"ply,omitempty"`
+	Color       string    `json:"color,omitempty"`
+	Result      string    `json:"result,omitempty"`
+	Termination string    `json:"termination,omitempty"`
+	DrawReason  string    `json:"draw_reason,omitempty"`
+}
+
+// DefaultSnapshotEvery is how many moves apart snapshots are taken.
+const DefaultSnapshotEvery = 20
+
+// Journal appends the events of games to an event log, snapshotting the
+// position every SnapshotEvery moves.
+type Journal struct {
+	log           store.EventLog
+	SnapshotEvery int
+}
+
+func NewJournal(log store.EventLog) *Journal {
+	return &Journal{log: log, SnapshotEvery: DefaultSnapshotEvery}
+}
+
+// Append writes e as the event after version and returns the new version.
+// pos is the position once e has happened and ply its move count; they are
+// only used to take snapshots.
+func (j *Journal) Append(ctx context.Context, gameID string, version int, e Event, pos *chess.GameState, ply int) (int, error) {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return version, err
+	}
+	entry := store.LogEntry{Kind: string(e.Kind), At: e.At, Data: data}
+	if err := j.log.Append(ctx, gameID, version, entry); err != nil {
+		return version, err
+	}
+	version++
+
+	if e.Kind == MoveMade && j.SnapshotEvery > 0 && ply%j.SnapshotEvery == 0 {
+		err = j.log.SaveSnapshot(ctx, store.Snapshot{
+			GameID:  gameID,
+			Version: version,
+			Ply:     ply,
+			FEN:     pos.ToFEN(),
+			At:      e.At,
+		})
+	}
+	return version, err
+}
+
+// Since counts the events logged for a game after version, which tells a
+// writer whether appends that failed went through after all.
+func (j *Journal) Since(ctx context.Context, gameID string, version int) (int, error) {
+	entries, err := j.log.Entries(ctx, gameID, version)
+	return len(entries), err
+}
+
+// Events returns the whole stream of a game, for audits and for building
+// derived views.
+func (j *Journal) Events(ctx context.Context, gameID string) ([]Event, error) {
+	entries, err := j.log.Entries(ctx, gameID, 0)
+	if err != nil {
+		return nil, err
+	}
+	return decode(entries)
+}
+
+func decode(entries []store.LogEntry) ([]Event, error) {
+	events := make([]Event, len(entries))
+	for i, entry := range entries {
+		if err := json.Unmarshal(entry.Data, &events[i]); err != nil {
+			return nil, fmt.Errorf("%w: version %d: %v", ErrCorruptLog, entry.Version, err)
+		}
+	}
+	return events, nil
+}
+
+// Replayed is a game rebuilt from its log.
+type Replayed struct {
+	Position chess.GameState
+	Ply      int
+	Version  int
+	Ended    *Event
+}
+
+// Rebuild replays a game's log from its latest snapshot, or from the start
+// if it has none, pushing every move through MakeMove.
+func (j *Journal) Rebuild(ctx context.Context, gameID string) (Replayed, error) {
+	var r Replayed
+	snap, err := j.log.LatestSnapshot(ctx, gameID)
+	switch {
+	case err == nil:
+		pos, err := chess.ParseFEN(snap.FEN)
+		if err != nil {
+			return r, fmt.Errorf("%w: snapshot at version %d: %v", ErrCorruptLog, snap.Version, err)
+		}
+		r.Position, r.Ply, r.Version = *pos, snap.Ply, snap.Version
+	case errors.Is(err, store.ErrNotFound):
+	default:
+		return r, err
+	}
+
+	entries, err := j.log.Entries(ctx, gameID, r.Version)
+	if err != nil {
+		return r, err
+	}
+	if r.Version == 0 && len(entries) == 0 {
+		return r, fmt.Errorf("game %s: %w", gameID, store.ErrNotFound)
+	}
+	events, err := decode(entries)
+	if err != nil {
+		return r, err
+	}
+	for i, e := range events {
+		if err := r.apply(e); err != nil {
+			return r, fmt.Errorf("%w: version %d: %v", ErrCorruptLog, entries[i].Version, err)
+		}
+		r.Version = entries[i].Version
+	}
+	return r, nil
+}
+
+func (r *Replayed) apply(e Event) error {
+	if r.Version == 0 && e.Kind != GameCreated {
+		return fmt.Errorf("stream starts with %s", e.Kind)
+	}
+	switch e.Kind {
+	case GameCreated:
+		pos, err := chess.ParseFEN(e.FEN)
+		if err != nil {
+			return err
+		}
+		r.Position = *pos
+	case MoveMade:
+		m, err := r.Position.ParseUCI(e.UCI)
+		if err != nil {
+			return err
+		}
+		r.Position.MakeMove(m)
+		r.Ply++
+	case MoveTakenBack:
+		pos, err := chess.ParseFEN(e.FEN)
+		if err != nil {
+			return err
+		}
+		r.Position, r.Ply = *pos, e.Ply
+	case GameEnded:
+		ended := e
+		r.Ended = &ended
+	}
+	return nil
+}
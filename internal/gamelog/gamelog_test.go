@@ -0,0 +1,91 @@
+package gamelog
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/THECHAMP95821/chess-backend/internal/chess"
+	"github.com/THECHAMP95821/chess-backend/internal/store"
+)
+
+// play appends a game's moves to j the way the game service does.
+func play(t *testing.T, j *Journal, id string, moves ...string) (int, chess.GameState) {
+	t.Helper()
+	ctx := context.Background()
+	at := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+	pos := chess.NewInitialGameState()
+	version, err := j.Append(ctx, id, 0, Event{Kind: GameCreated, At: at, FEN: pos.ToFEN()}, &pos, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, uci := range moves {
+		m, err := pos.ParseUCI(uci)
+		if err != nil {
+			t.Fatalf("%s: %v", uci, err)
+		}
+		pos.MakeMove(m)
+		if version, err = j.Append(ctx, id, version, Event{Kind: MoveMade, At: at, UCI: uci}, &pos, i+1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return version, pos
+}
+
+func TestRebuildFromSnapshot(t *testing.T) {
+	log := store.NewMemory()
+	j := NewJournal(log)
+	j.SnapshotEvery = 2
+	version, want := play(t, j, "g1", "e2e4", "e7e5", "g1f3", "b8c6", "f1b5")
+
+	snap, err := log.LatestSnapshot(context.Background(), "g1")
+	if err != nil || snap.Ply != 4 {
+		t.Fatalf("latest snapshot = %+v, %v; want one at ply 4", snap, err)
+	}
+
+	got, err := j.Rebuild(context.Background(), "g1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Position.ToFEN() != want.ToFEN() || got.Ply != 5 || got.Version != version || got.Ended != nil {
+		t.Errorf("rebuilt %s at ply %d version %d, want %s at ply 5 version %d",
+			got.Position.ToFEN(), got.Ply, got.Version, want.ToFEN(), version)
+	}
+}
+
+func TestRebuildTakebackAndEnd(t *testing.T) {
+	ctx := context.Background()
+	j := NewJournal(store.NewMemory())
+	version, _ := play(t, j, "g2", "e2e4", "e7e5", "d1h5")
+
+	back, _ := chess.ParseFEN("rnbqkbnr/pppp1ppp/8/4p3/4P3/8/PPPP1PPP/RNBQKBNR w KQkq - 0 2")
+	version, _ = j.Append(ctx, "g2", version, Event{Kind: MoveTakenBack, FEN: back.ToFEN(), Ply: 2}, back, 2)
+	version, _ = j.Append(ctx, "g2", version, Event{Kind: GameEnded, Result: "0-1", Termination: "resignation"}, back, 2)
+
+	got, err := j.Rebuild(ctx, "g2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Position.ToFEN() != back.ToFEN() || got.Ply != 2 || got.Ended == nil || got.Ended.Result != "0-1" {
+		t.Errorf("rebuilt = %+v", got)
+	}
+
+	events, _ := j.Events(ctx, "g2")
+	if len(events) != version || events[0].Kind != GameCreated || events[len(events)-1].Kind != GameEnded {
+		t.Errorf("events = %+v", events)
+	}
+}
+
+func TestRebuildRejectsIllegalMove(t *testing.T) {
+	j := NewJournal(store.NewMemory())
+	version, pos := play(t, j, "g3", "e2e4")
+	j.Append(context.Background(), "g3", version, Event{Kind: MoveMade, UCI: "e2e4"}, &pos, 2)
+
+	if _, err := j.Rebuild(context.Background(), "g3"); !errors.Is(err, ErrCorruptLog) {
+		t.Errorf("err = %v, want ErrCorruptLog", err)
+	}
+	if _, err := j.Rebuild(context.Background(), "missing"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("missing game: err = %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,139 @@
+// Package livecache keeps the state of running games in Redis, so that
+// another process can resume them if the one running them goes away.
+package livecache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/THECHAMP95821/chess-backend/internal/game"
+)
+
+const (
+	indexKey = "chess:live"
+	nodesKey = "chess:nodes"
+)
+
+func stateKey(id string) string {
+	return "chess:live:" + id
+}
+
+// entry is what is stored per game: its state and the node running it.
+type entry struct {
+	Node  string         `json:"node"`
+	State game.LiveState `json:"state"`
+}
+
+// Cache is the Redis-backed game.LiveCache of one node. The node proves it
+// is alive with heartbeats, which date an outage when the cluster package
+// takes one of its games over.
+type Cache struct {
+	rdb  *redis.Client
+	node string
+	now  func() time.Time
+}
+
+func New(rdb *redis.Client, node string) *Cache {
+	return &Cache{rdb: rdb, node: node, now: time.Now}
+}
+
+func (c *Cache) Save(ctx context.Context, s game.LiveState) error {
+	data, err := json.Marshal(entry{Node: c.node, State: s})
+	if err != nil {
+		return err
+	}
+	_, err = c.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, stateKey(s.ID), data, 0)
+		pipe.SAdd(ctx, indexKey, s.ID)
+		return nil
+	})
+	return err
+}
+
+func (c *Cache) Delete(ctx context.Context, id string) error {
+	_, err := c.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, stateKey(id))
+		pipe.SRem(ctx, indexKey, id)
+		return nil
+	})
+	return err
+}
+
+// Heartbeat records that this node is alive now.
+func (c *Cache) Heartbeat(ctx context.Context) error {
+	return c.rdb.HSet(ctx, nodesKey, c.node, c.now().UnixMilli()).Err()
+}
+
+// RunHeartbeat beats every interval until ctx is done.
+func (c *Cache) RunHeartbeat(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := c.Heartbeat(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("livecache: heartbeat: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// lastBeat is when node last beat, or the zero time if it never did.
+func (c *Cache) lastBeat(ctx context.Context, node string) (time.Time, error) {
+	v, err := c.rdb.HGet(ctx, nodesKey, node).Result()
+	if errors.Is(err, redis.Nil) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	ms, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("heartbeat of %s: %v", node, err)
+	}
+	return time.UnixMilli(ms), nil
+}
+
+// IDs lists the games with a cached state.
+func (c *Cache) IDs(ctx context.Context) ([]string, error) {
+	return c.rdb.SMembers(ctx, indexKey).Result()
+}
+
+// Load returns the cached state of a game and the node that saved it.
+func (c *Cache) Load(ctx context.Context, id string) (game.LiveState, string, error) {
+	data, err := c.rdb.Get(ctx, stateKey(id)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		c.rdb.SRem(ctx, indexKey, id)
+		return game.LiveState{}, "", game.ErrGameNotFound
+	}
+	if err != nil {
+		return game.LiveState{}, "", err
+	}
+	var e entry
+	if err := json.Unmarshal(data, &e); err != nil {
+		return game.LiveState{}, "", err
+	}
+	return e.State, e.Node, nil
+}
+
+// DownSince estimates when the node that saved s stopped running it: its
+// last heartbeat or the save itself, whichever came later.
+func (c *Cache) DownSince(ctx context.Context, s game.LiveState, node string) (time.Time, error) {
+	beat, err := c.lastBeat(ctx, node)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if beat.After(s.SavedAt) {
+		return beat, nil
+	}
+	return s.SavedAt, nil
+}
@@ -0,0 +1,61 @@
+package livecache
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/THECHAMP95821/chess-backend/internal/chess"
+	"github.com/THECHAMP95821/chess-backend/internal/game"
+)
+
+func TestCacheSavesLiveGames(t *testing.T) {
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer rdb.Close()
+	ctx := context.Background()
+
+	cache := New(rdb, "node-a")
+	games := game.NewService()
+	games.SetCache(cache)
+	v, _ := games.Create(game.CreateOptions{TimeControl: "60+0"})
+	games.Move(v.ID, game.MoveRequest{Color: chess.ColorWhite, UCI: "e2e4"})
+	games.OfferDraw(v.ID, chess.ColorWhite)
+	finished, _ := games.Create(game.CreateOptions{})
+	games.Resign(finished.ID, chess.ColorWhite)
+
+	if ids, err := cache.IDs(ctx); err != nil || !slices.Equal(ids, []string{v.ID}) {
+		t.Fatalf("IDs = %v, %v; want only the live game", ids, err)
+	}
+	st, node, err := cache.Load(ctx, v.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if node != "node-a" || st.ID != v.ID || st.DrawOffer != "white" {
+		t.Errorf("loaded %+v from %q", st, node)
+	}
+
+	// The node is taken to be down from its last sign of life: the save,
+	// or a heartbeat after it.
+	if down, _ := cache.DownSince(ctx, st, node); !down.Equal(st.SavedAt) {
+		t.Errorf("DownSince without heartbeats = %v, want the save at %v", down, st.SavedAt)
+	}
+	beat := st.SavedAt.Add(time.Minute).Truncate(time.Millisecond)
+	cache.now = func() time.Time { return beat }
+	cache.Heartbeat(ctx)
+	if down, _ := cache.DownSince(ctx, st, node); !down.Equal(beat) {
+		t.Errorf("DownSince = %v, want the heartbeat at %v", down, beat)
+	}
+
+	if err := cache.Delete(ctx, v.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := cache.Load(ctx, v.ID); !errors.Is(err, game.ErrGameNotFound) {
+		t.Errorf("Load after Delete = %v, want %v", err, game.ErrGameNotFound)
+	}
+}
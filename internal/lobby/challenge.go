@@ -0,0 +1,235 @@
+package lobby
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/THECHAMP95821/chess-backend/internal/docstore"
+	"github.com/THECHAMP95821/chess-backend/internal/game"
+)
+
+var (
+	ErrChallengeNotFound = errors.New("challenge not found")
+	ErrNotParticipant    = errors.New("challenge is between other users")
+	ErrSelfChallenge     = errors.New("cannot challenge yourself")
+	ErrChallengeClosed   = errors.New("challenge already answered")
+	ErrInvalidReason     = errors.New("invalid decline reason")
+)
+
+// answeredTTL is how long an answered challenge can still be looked up, so
+// that its sender learns the answer even without a stream.
+const answeredTTL = 10 * time.Minute
+
+type ChallengeStatus string
+
+const (
+	ChallengePending   ChallengeStatus = "pending"
+	ChallengeAccepted  ChallengeStatus = "accepted"
+	ChallengeDeclined  ChallengeStatus = "declined"
+	ChallengeCancelled ChallengeStatus = "cancelled"
+)
+
+// DeclineReasons are the reasons a challenge may be declined with.
+var DeclineReasons = []string{
+	"generic", "later", "too_fast", "too_slow", "time_control",
+	"rated", "casual", "variant", "position",
+}
+
+// Challenge is an invitation from one user to another. Color is the side
+// the sender wants.
+type Challenge struct {
+	ID   string `json:"id"`
+	From string `json:"from"`
+	To   string `json:"to"`
+	Terms
+	Status        ChallengeStatus `json:"status"`
+	DeclineReason string          `json:"decline_reason,omitempty"`
+	GameID        string          `json:"game_id,omitempty"`
+	CreatedAt     time.Time       `json:"created_at"`
+}
+
+// storedChallenge is a challenge as the store keeps it.
+type storedChallenge struct {
+	Challenge
+	AnsweredAt time.Time `json:"answered_at"`
+}
+
+func (c *Challenge) involves(userID string) bool {
+	return c.From == userID || c.To == userID
+}
+
+// Challenge sends c to its recipient.
+func (l *Lobby) Challenge(ctx context.Context, c Challenge) (Challenge, error) {
+	if c.From == c.To {
+		return Challenge{}, ErrSelfChallenge
+	}
+	if err := c.Terms.normalize(); err != nil {
+		return Challenge{}, err
+	}
+	c.ID = newID()
+	c.Status = ChallengePending
+	c.CreatedAt = l.now()
+
+	l.prune(ctx)
+	if err := docstore.Save(ctx, l.docs, challengesColl, c.ID, storedChallenge{Challenge: c}); err != nil {
+		return Challenge{}, err
+	}
+	l.publish(ctx, Event{Type: EventChallenge, Time: c.CreatedAt, Challenge: &c})
+	return c, nil
+}
+
+// GetChallenge returns a challenge its sender or recipient asks for.
+func (l *Lobby) GetChallenge(ctx context.Context, id, userID string) (Challenge, error) {
+	var sc storedChallenge
+	err := docstore.Load(ctx, l.docs, challengesColl, id, &sc)
+	if errors.Is(err, docstore.ErrNotFound) {
+		return Challenge{}, ErrChallengeNotFound
+	}
+	if err != nil {
+		return Challenge{}, err
+	}
+	if !sc.involves(userID) {
+		return Challenge{}, ErrNotParticipant
+	}
+	return sc.Challenge, nil
+}
+
+// checkPending fails unless c still awaits an answer and userID is its
+// sender or, if sender is false, its recipient.
+func (c *Challenge) checkPending(userID string, sender bool) error {
+	if (sender && c.From != userID) || (!sender && c.To != userID) {
+		return ErrNotParticipant
+	}
+	if c.Status != ChallengePending {
+		return fmt.Errorf("%w: %s", ErrChallengeClosed, c.Status)
+	}
+	return nil
+}
+
+// AcceptChallenge starts the game of a challenge sent to userID.
+func (l *Lobby) AcceptChallenge(ctx context.Context, id, userID string) (game.View, error) {
+	var v game.View
+	_, err := l.answer(ctx, id, ChallengeAccepted, EventChallengeAccepted, func(c *Challenge) error {
+		if err := c.checkPending(userID, false); err != nil {
+			return err
+		}
+		var err error
+		if v, err = c.Terms.start(l.games, c.From, c.To); err != nil {
+			return err
+		}
+		c.GameID = v.ID
+		return nil
+	})
+	if err != nil {
+		return game.View{}, err
+	}
+	return v, nil
+}
+
+// DeclineChallenge turns down a challenge sent to userID, with one of
+// DeclineReasons; an empty reason is "generic".
+func (l *Lobby) DeclineChallenge(ctx context.Context, id, userID, reason string) (Challenge, error) {
+	if reason == "" {
+		reason = "generic"
+	}
+	if !slices.Contains(DeclineReasons, reason) {
+		return Challenge{}, fmt.Errorf("%w: %q", ErrInvalidReason, reason)
+	}
+	return l.answer(ctx, id, ChallengeDeclined, EventChallengeDeclined, func(c *Challenge) error {
+		if err := c.checkPending(userID, false); err != nil {
+			return err
+		}
+		c.DeclineReason = reason
+		return nil
+	})
+}
+
+// CancelChallenge withdraws a challenge userID sent.
+func (l *Lobby) CancelChallenge(ctx context.Context, id, userID string) (Challenge, error) {
+	return l.answer(ctx, id, ChallengeCancelled, EventChallengeCancelled, func(c *Challenge) error {
+		return c.checkPending(userID, true)
+	})
+}
+
+// answer gives challenge id status, unless fn fails, and publishes it.
+func (l *Lobby) answer(ctx context.Context, id string, status ChallengeStatus, t EventType, fn func(c *Challenge) error) (Challenge, error) {
+	var sc storedChallenge
+	err := docstore.Update(ctx, l.docs, challengesColl, id, &sc, func() error {
+		if err := fn(&sc.Challenge); err != nil {
+			return err
+		}
+		sc.Status = status
+		sc.AnsweredAt = l.now()
+		return nil
+	})
+	if errors.Is(err, docstore.ErrNotFound) {
+		return Challenge{}, ErrChallengeNotFound
+	}
+	if err != nil {
+		return Challenge{}, err
+	}
+	c := sc.Challenge
+	l.publish(ctx, Event{Type: t, Time: sc.AnsweredAt, Challenge: &c, GameID: c.GameID})
+	return c, nil
+}
+
+// challenges loads every challenge in the store.
+func (l *Lobby) challenges(ctx context.Context) ([]storedChallenge, error) {
+	ids, err := l.docs.IDs(ctx, challengesColl)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]storedChallenge, 0, len(ids))
+	for _, id := range ids {
+		var sc storedChallenge
+		err := docstore.Load(ctx, l.docs, challengesColl, id, &sc)
+		if errors.Is(err, docstore.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, sc)
+	}
+	return list, nil
+}
+
+// prune forgets challenges answered more than answeredTTL ago.
+func (l *Lobby) prune(ctx context.Context) {
+	list, err := l.challenges(ctx)
+	if err != nil {
+		log.Printf("lobby: prune challenges: %v", err)
+		return
+	}
+	now := l.now()
+	for _, sc := range list {
+		if sc.Status != ChallengePending && now.Sub(sc.AnsweredAt) > answeredTTL {
+			if err := l.docs.Delete(ctx, challengesColl, sc.ID); err != nil {
+				log.Printf("lobby: prune challenge %s: %v", sc.ID, err)
+			}
+		}
+	}
+}
+
+// pendingFor lists the unanswered challenges from or to userID, oldest
+// first.
+func (l *Lobby) pendingFor(ctx context.Context, userID string) ([]Challenge, error) {
+	all, err := l.challenges(ctx)
+	if err != nil {
+		return nil, err
+	}
+	list := []Challenge{}
+	for _, sc := range all {
+		if sc.Status == ChallengePending && sc.involves(userID) {
+			list = append(list, sc.Challenge)
+		}
+	}
+	slices.SortFunc(list, func(a, b Challenge) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+	return list, nil
+}
@@ -0,0 +1,112 @@
+package lobby
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+type EventType string
+
+const (
+	EventSeekAdded          EventType = "seek_added"
+	EventSeekRemoved        EventType = "seek_removed"
+	EventChallenge          EventType = "challenge"
+	EventChallengeAccepted  EventType = "challenge_accepted"
+	EventChallengeDeclined  EventType = "challenge_declined"
+	EventChallengeCancelled EventType = "challenge_cancelled"
+)
+
+// Event is one change to the lobby. GameID is set when a seek or challenge
+// was accepted and names the game that started.
+type Event struct {
+	Type      EventType  `json:"type"`
+	Time      time.Time  `json:"time"`
+	Seek      *Seek      `json:"seek,omitempty"`
+	Challenge *Challenge `json:"challenge,omitempty"`
+	GameID    string     `json:"game_id,omitempty"`
+}
+
+// Snapshot is the lobby as one user sees it: every open seek and their own
+// pending challenges.
+type Snapshot struct {
+	Seeks      []Seek      `json:"seeks"`
+	Challenges []Challenge `json:"challenges"`
+}
+
+const subscriberBuffer = 64
+
+// Subscription delivers the lobby events one user may see: all seek events
+// and those of their own challenges. If the subscriber falls more than
+// subscriberBuffer events behind, C is closed and it must start over from a
+// fresh snapshot.
+type Subscription struct {
+	C      <-chan Event
+	ch     chan Event
+	userID string
+	cancel func()
+}
+
+func (sub *Subscription) Close() {
+	sub.cancel()
+}
+
+// Subscribe returns what userID sees of the lobby now and a subscription to
+// every later change. The subscription starts first, so it may repeat a
+// change the snapshot already shows.
+func (l *Lobby) Subscribe(ctx context.Context, userID string) (Snapshot, *Subscription, error) {
+	ch := make(chan Event, subscriberBuffer)
+	sub := &Subscription{C: ch, ch: ch, userID: userID}
+	sub.cancel = func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if _, ok := l.subs[sub]; ok {
+			delete(l.subs, sub)
+			close(sub.ch)
+		}
+	}
+	l.mu.Lock()
+	l.subs[sub] = struct{}{}
+	l.mu.Unlock()
+
+	seeks, err := l.Seeks(ctx)
+	if err != nil {
+		sub.Close()
+		return Snapshot{}, nil, err
+	}
+	challenges, err := l.pendingFor(ctx, userID)
+	if err != nil {
+		sub.Close()
+		return Snapshot{}, nil, err
+	}
+	return Snapshot{Seeks: seeks, Challenges: challenges}, sub, nil
+}
+
+// publish sends e to the subscribers of every node that shares the relay,
+// or of this one.
+func (l *Lobby) publish(ctx context.Context, e Event) {
+	if l.relay == nil {
+		l.Deliver(e)
+		return
+	}
+	if err := l.relay.Publish(ctx, e); err != nil {
+		log.Printf("lobby: publish %s: %v", e.Type, err)
+	}
+}
+
+// Deliver hands an event from the relay to this node's subscribers.
+func (l *Lobby) Deliver(e Event) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for sub := range l.subs {
+		if e.Challenge != nil && !e.Challenge.involves(sub.userID) {
+			continue
+		}
+		select {
+		case sub.ch <- e:
+		default:
+			delete(l.subs, sub)
+			close(sub.ch)
+		}
+	}
+}
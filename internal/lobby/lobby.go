@@ -0,0 +1,333 @@
+// Package lobby is where players find games on their own terms: open seeks
+// anyone may accept, and challenges sent to one user.
+package lobby
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	mathrand "math/rand/v2"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/THECHAMP95821/chess-backend/internal/chess"
+	"github.com/THECHAMP95821/chess-backend/internal/clock"
+	"github.com/THECHAMP95821/chess-backend/internal/docstore"
+	"github.com/THECHAMP95821/chess-backend/internal/game"
+	"github.com/THECHAMP95821/chess-backend/internal/rating"
+)
+
+var (
+	ErrSeekNotFound  = errors.New("seek not found")
+	ErrNotYourSeek   = errors.New("seek was posted by someone else")
+	ErrOwnSeek       = errors.New("cannot accept your own seek")
+	ErrRatingRange   = errors.New("rating outside the seek's range")
+	ErrTooManySeeks  = errors.New("too many open seeks")
+	ErrInvalidColor  = errors.New("invalid color preference")
+	ErrRatedPosition = errors.New("games from a custom position cannot be rated")
+)
+
+// maxSeeks is how many seeks one user may have open at a time.
+const maxSeeks = 5
+
+// Games starts the games agreed on in the lobby.
+type Games interface {
+	Create(opts game.CreateOptions) (game.View, error)
+}
+
+// Ratings looks up a player's current rating in a rating pool.
+type Ratings interface {
+	Current(ctx context.Context, userID string, pool rating.Pool) (rating.Rating, int, error)
+}
+
+// Terms are the game a seek or challenge proposes. Color is the side its
+// author wants: white, black or random.
+type Terms struct {
+	TimeControl string `json:"time_control,omitempty"`
+	Variant     string `json:"variant"`
+	Rated       bool   `json:"rated"`
+	Color       string `json:"color"`
+	FEN         string `json:"fen,omitempty"`
+}
+
+// normalize validates t and puts it in canonical form.
+func (t *Terms) normalize() error {
+	if t.Variant == "" {
+		t.Variant = "standard"
+	}
+	if t.Variant != "standard" {
+		return fmt.Errorf("%w: %s", game.ErrUnsupportedVariant, t.Variant)
+	}
+	if t.TimeControl != "" {
+		tc, err := clock.ParseTimeControl(t.TimeControl)
+		if err != nil {
+			return fmt.Errorf("%w: %v", game.ErrInvalidTime, err)
+		}
+		t.TimeControl = tc.String()
+	}
+	switch t.Color {
+	case "":
+		t.Color = "random"
+	case "white", "black", "random":
+	default:
+		return fmt.Errorf("%w: %q", ErrInvalidColor, t.Color)
+	}
+	if t.FEN != "" {
+		state, err := chess.ParseLegalFEN(t.FEN)
+		if err != nil {
+			return fmt.Errorf("%w: %v", game.ErrInvalidFEN, err)
+		}
+		if t.Rated {
+			return ErrRatedPosition
+		}
+		t.FEN = state.ToFEN()
+	}
+	return nil
+}
+
+func (t Terms) pool() rating.Pool {
+	pool, _ := rating.PoolFor(t.TimeControl, t.Variant)
+	return pool
+}
+
+// start creates the game between author, who asked for t.Color, and
+// opponent.
+func (t Terms) start(games Games, author, opponent string) (game.View, error) {
+	white, black := author, opponent
+	if t.Color == "black" || (t.Color == "random" && mathrand.IntN(2) == 0) {
+		white, black = opponent, author
+	}
+	return games.Create(game.CreateOptions{
+		FEN:         t.FEN,
+		TimeControl: t.TimeControl,
+		Variant:     t.Variant,
+		WhiteID:     white,
+		BlackID:     black,
+		Rated:       t.Rated,
+	})
+}
+
+// Seek is an open invitation to play. MinRating and MaxRating, when set,
+// bound the rating of whoever may accept it.
+type Seek struct {
+	ID     string `json:"id"`
+	UserID string `json:"user_id"`
+	Terms
+	Rating    float64   `json:"rating"`
+	MinRating float64   `json:"min_rating,omitempty"`
+	MaxRating float64   `json:"max_rating,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+const (
+	seeksColl      = "lobby_seeks"
+	challengesColl = "lobby_challenges"
+	// seekersColl is only locked, to count a user's seeks.
+	seekersColl = "lobby_seekers"
+)
+
+// Relay carries lobby events to every node, this one included, which hands
+// them to Deliver.
+type Relay interface {
+	Publish(ctx context.Context, e Event) error
+}
+
+// Lobby keeps the open seeks and challenges in its store and streams
+// changes to them to subscribers. Nodes sharing a store and a relay share
+// the lobby.
+type Lobby struct {
+	mu      sync.Mutex
+	docs    docstore.Store
+	relay   Relay
+	games   Games
+	ratings Ratings
+	subs    map[*Subscription]struct{}
+	now     func() time.Time
+}
+
+func New(games Games) *Lobby {
+	return &Lobby{
+		docs:  docstore.NewMemory(),
+		games: games,
+		subs:  make(map[*Subscription]struct{}),
+		now:   time.Now,
+	}
+}
+
+// SetStore keeps seeks and challenges in st. Without it they only live as
+// long as this process.
+func (l *Lobby) SetStore(st docstore.Store) {
+	l.docs = st
+}
+
+// SetRelay sends events through r, for the subscribers of every node.
+// Without it they only reach this node's.
+func (l *Lobby) SetRelay(r Relay) {
+	l.relay = r
+}
+
+// SetRatings makes the lobby check rating ranges against ratings from r.
+// Without it everyone has the default rating.
+func (l *Lobby) SetRatings(r Ratings) {
+	l.ratings = r
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func (l *Lobby) rating(ctx context.Context, userID string, pool rating.Pool) (float64, error) {
+	if l.ratings == nil {
+		return rating.DefaultRating, nil
+	}
+	r, _, err := l.ratings.Current(ctx, userID, pool)
+	return r.Rating, err
+}
+
+// Post opens a seek.
+func (l *Lobby) Post(ctx context.Context, s Seek) (Seek, error) {
+	if err := s.Terms.normalize(); err != nil {
+		return Seek{}, err
+	}
+	r, err := l.rating(ctx, s.UserID, s.pool())
+	if err != nil {
+		return Seek{}, err
+	}
+	s.ID = newID()
+	s.Rating = r
+	s.CreatedAt = l.now()
+
+	unlock, err := l.docs.Lock(ctx, seekersColl, s.UserID)
+	if err != nil {
+		return Seek{}, err
+	}
+	defer unlock()
+	seeks, err := l.Seeks(ctx)
+	if err != nil {
+		return Seek{}, err
+	}
+	open := 0
+	for _, other := range seeks {
+		if other.UserID == s.UserID {
+			open++
+		}
+	}
+	if open >= maxSeeks {
+		return Seek{}, ErrTooManySeeks
+	}
+	if err := docstore.Save(ctx, l.docs, seeksColl, s.ID, s); err != nil {
+		return Seek{}, err
+	}
+	l.publish(ctx, Event{Type: EventSeekAdded, Time: s.CreatedAt, Seek: &s})
+	return s, nil
+}
+
+// Seeks lists the open seeks, oldest first.
+func (l *Lobby) Seeks(ctx context.Context) ([]Seek, error) {
+	ids, err := l.docs.IDs(ctx, seeksColl)
+	if err != nil {
+		return nil, err
+	}
+	seeks := make([]Seek, 0, len(ids))
+	for _, id := range ids {
+		var s Seek
+		err := docstore.Load(ctx, l.docs, seeksColl, id, &s)
+		if errors.Is(err, docstore.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		seeks = append(seeks, s)
+	}
+	sort.Slice(seeks, func(i, j int) bool {
+		return seeks[i].CreatedAt.Before(seeks[j].CreatedAt)
+	})
+	return seeks, nil
+}
+
+// lockSeek locks and loads seek id.
+func (l *Lobby) lockSeek(ctx context.Context, id string) (Seek, func(), error) {
+	unlock, err := l.docs.Lock(ctx, seeksColl, id)
+	if err != nil {
+		return Seek{}, nil, err
+	}
+	var s Seek
+	if err := docstore.Load(ctx, l.docs, seeksColl, id, &s); err != nil {
+		unlock()
+		if errors.Is(err, docstore.ErrNotFound) {
+			err = ErrSeekNotFound
+		}
+		return Seek{}, nil, err
+	}
+	return s, unlock, nil
+}
+
+// Withdraw closes one of userID's seeks.
+func (l *Lobby) Withdraw(ctx context.Context, id, userID string) error {
+	s, unlock, err := l.lockSeek(ctx, id)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if s.UserID != userID {
+		return ErrNotYourSeek
+	}
+	if err := l.docs.Delete(ctx, seeksColl, id); err != nil {
+		return err
+	}
+	l.publish(ctx, Event{Type: EventSeekRemoved, Time: l.now(), Seek: &s})
+	return nil
+}
+
+// AcceptSeek starts the game a seek asks for between its author and
+// userID.
+func (l *Lobby) AcceptSeek(ctx context.Context, id, userID string) (game.View, error) {
+	var s Seek
+	err := docstore.Load(ctx, l.docs, seeksColl, id, &s)
+	if errors.Is(err, docstore.ErrNotFound) {
+		return game.View{}, ErrSeekNotFound
+	}
+	if err != nil {
+		return game.View{}, err
+	}
+	if s.UserID == userID {
+		return game.View{}, ErrOwnSeek
+	}
+	if s.MinRating > 0 || s.MaxRating > 0 {
+		r, err := l.rating(ctx, userID, s.pool())
+		if err != nil {
+			return game.View{}, err
+		}
+		if (s.MinRating > 0 && r < s.MinRating) || (s.MaxRating > 0 && r > s.MaxRating) {
+			return game.View{}, fmt.Errorf("%w: %.0f", ErrRatingRange, r)
+		}
+	}
+
+	// Someone else may have taken it while the rating was looked up.
+	s, unlock, err := l.lockSeek(ctx, id)
+	if err != nil {
+		return game.View{}, err
+	}
+	defer unlock()
+	// Gone before the game starts, so that it cannot be accepted again if
+	// removing it fails.
+	if err := l.docs.Delete(ctx, seeksColl, id); err != nil {
+		return game.View{}, err
+	}
+	v, err := s.Terms.start(l.games, s.UserID, userID)
+	if err != nil {
+		if err := docstore.Save(ctx, l.docs, seeksColl, id, s); err != nil {
+			log.Printf("lobby: restore seek %s: %v", id, err)
+		}
+		return game.View{}, err
+	}
+	l.publish(ctx, Event{Type: EventSeekRemoved, Time: l.now(), Seek: &s, GameID: v.ID})
+	return v, nil
+}
@@ -0,0 +1,213 @@
+package lobby
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/THECHAMP95821/chess-backend/internal/docstore"
+	"github.com/THECHAMP95821/chess-backend/internal/game"
+	"github.com/THECHAMP95821/chess-backend/internal/rating"
+)
+
+type fixedRatings map[string]float64
+
+func (f fixedRatings) Current(ctx context.Context, userID string, pool rating.Pool) (rating.Rating, int, error) {
+	r := rating.Default()
+	r.Rating = f[userID]
+	return r, 1, nil
+}
+
+func TestSeeks(t *testing.T) {
+	ctx := context.Background()
+	games := game.NewService()
+	l := New(games)
+	l.SetRatings(fixedRatings{"alice": 1600, "bob": 1500, "carol": 1900})
+	_, sub, _ := l.Subscribe(ctx, "dave")
+	defer sub.Close()
+
+	for _, tc := range []struct {
+		terms Terms
+		want  error
+	}{
+		{Terms{TimeControl: "soon"}, game.ErrInvalidTime},
+		{Terms{Variant: "crazyhouse"}, game.ErrUnsupportedVariant},
+		{Terms{Color: "green"}, ErrInvalidColor},
+		{Terms{FEN: "8/8/8/8/8/8/8/8 w - - 0 1"}, game.ErrInvalidFEN},
+		{Terms{FEN: "4k3/8/8/8/8/8/8/4K2R w K - 0 1", Rated: true}, ErrRatedPosition},
+	} {
+		if _, err := l.Post(ctx, Seek{UserID: "alice", Terms: tc.terms}); !errors.Is(err, tc.want) {
+			t.Errorf("post %+v: err = %v, want %v", tc.terms, err, tc.want)
+		}
+	}
+
+	s, err := l.Post(ctx, Seek{
+		UserID:    "alice",
+		Terms:     Terms{TimeControl: "300+3", Color: "black", FEN: "4k3/8/8/8/8/8/8/4K2R w K - 0 1"},
+		MinRating: 1400,
+		MaxRating: 1800,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Rating != 1600 || s.Variant != "standard" {
+		t.Errorf("seek = %+v", s)
+	}
+	if e := <-sub.C; e.Type != EventSeekAdded || e.Seek.ID != s.ID {
+		t.Errorf("event = %+v, want seek_added", e)
+	}
+
+	if _, err := l.AcceptSeek(ctx, s.ID, "alice"); !errors.Is(err, ErrOwnSeek) {
+		t.Errorf("own seek: err = %v", err)
+	}
+	if _, err := l.AcceptSeek(ctx, s.ID, "carol"); !errors.Is(err, ErrRatingRange) {
+		t.Errorf("out of range: err = %v", err)
+	}
+	v, err := l.AcceptSeek(ctx, s.ID, "bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.WhiteID != "bob" || v.BlackID != "alice" || v.InitialFEN != s.FEN || v.Clock.Control != "300+3" {
+		t.Errorf("game = %+v", v)
+	}
+	if e := <-sub.C; e.Type != EventSeekRemoved || e.GameID != v.ID {
+		t.Errorf("event = %+v, want seek_removed with the game", e)
+	}
+	if _, err := l.AcceptSeek(ctx, s.ID, "carol"); !errors.Is(err, ErrSeekNotFound) {
+		t.Errorf("accepted twice: err = %v", err)
+	}
+
+	s, _ = l.Post(ctx, Seek{UserID: "alice"})
+	if err := l.Withdraw(ctx, s.ID, "bob"); !errors.Is(err, ErrNotYourSeek) {
+		t.Errorf("withdraw someone else's seek: err = %v", err)
+	}
+	if err := l.Withdraw(ctx, s.ID, "alice"); err != nil {
+		t.Errorf("withdraw: err = %v", err)
+	}
+	if seeks, err := l.Seeks(ctx); err != nil || len(seeks) != 0 {
+		t.Errorf("seeks after withdrawing = %v, %v", seeks, err)
+	}
+}
+
+func TestChallenges(t *testing.T) {
+	ctx := context.Background()
+	games := game.NewService()
+	l := New(games)
+	snap, bob, _ := l.Subscribe(ctx, "bob")
+	defer bob.Close()
+	_, carol, _ := l.Subscribe(ctx, "carol")
+	defer carol.Close()
+	if len(snap.Seeks) != 0 || len(snap.Challenges) != 0 {
+		t.Errorf("empty snapshot = %+v", snap)
+	}
+
+	if _, err := l.Challenge(ctx, Challenge{From: "alice", To: "alice"}); !errors.Is(err, ErrSelfChallenge) {
+		t.Errorf("self challenge: err = %v", err)
+	}
+	c, err := l.Challenge(ctx, Challenge{From: "alice", To: "bob", Terms: Terms{TimeControl: "60+0", Color: "white"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := <-bob.C; e.Type != EventChallenge || e.Challenge.ID != c.ID {
+		t.Errorf("bob's event = %+v", e)
+	}
+	if snap, sub, _ := l.Subscribe(ctx, "bob"); len(snap.Challenges) != 1 {
+		t.Errorf("bob's snapshot = %+v, want the challenge", snap)
+	} else {
+		sub.Close()
+	}
+
+	if _, err := l.AcceptChallenge(ctx, c.ID, "alice"); !errors.Is(err, ErrNotParticipant) {
+		t.Errorf("sender accepting: err = %v", err)
+	}
+	if _, err := l.DeclineChallenge(ctx, c.ID, "bob", "rude"); !errors.Is(err, ErrInvalidReason) {
+		t.Errorf("bad reason: err = %v", err)
+	}
+	declined, err := l.DeclineChallenge(ctx, c.ID, "bob", "too_fast")
+	if err != nil || declined.Status != ChallengeDeclined || declined.DeclineReason != "too_fast" {
+		t.Errorf("decline = %+v, %v", declined, err)
+	}
+	if _, err := l.CancelChallenge(ctx, c.ID, "alice"); !errors.Is(err, ErrChallengeClosed) {
+		t.Errorf("cancel after decline: err = %v", err)
+	}
+
+	c, _ = l.Challenge(ctx, Challenge{From: "alice", To: "bob", Terms: Terms{TimeControl: "180+2", Color: "white"}})
+	v, err := l.AcceptChallenge(ctx, c.ID, "bob")
+	if err != nil || v.WhiteID != "alice" || v.BlackID != "bob" {
+		t.Fatalf("accept = %+v, %v", v, err)
+	}
+	if got, err := l.GetChallenge(ctx, c.ID, "alice"); err != nil || got.Status != ChallengeAccepted || got.GameID != v.ID {
+		t.Errorf("accepted challenge = %+v, %v", got, err)
+	}
+	if _, err := l.GetChallenge(ctx, c.ID, "carol"); !errors.Is(err, ErrNotParticipant) {
+		t.Errorf("outsider looking: err = %v", err)
+	}
+
+	c, _ = l.Challenge(ctx, Challenge{From: "bob", To: "alice"})
+	if _, err := l.CancelChallenge(ctx, c.ID, "bob"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case e := <-carol.C:
+		t.Errorf("carol saw someone else's challenge: %+v", e)
+	default:
+	}
+}
+
+func TestLobbySharedByNodes(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer rdb.Close()
+	docs := docstore.NewRedis(rdb)
+	relay := NewRedis(rdb)
+	node := func() *Lobby {
+		l := New(game.NewService())
+		l.SetStore(docs)
+		l.SetRelay(relay)
+		go relay.Run(ctx, l.Deliver)
+		return l
+	}
+	a, b := node(), node()
+	for mr.PubSubNumSub(eventsChannel)[eventsChannel] < 2 {
+		time.Sleep(time.Millisecond)
+	}
+
+	_, sub, err := b.Subscribe(ctx, "bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Close()
+	s, err := a.Post(ctx, Seek{UserID: "alice", Terms: Terms{TimeControl: "300+3"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := <-sub.C; e.Type != EventSeekAdded || e.Seek.ID != s.ID {
+		t.Errorf("event on the other node = %+v, want seek_added", e)
+	}
+	if seeks, _ := b.Seeks(ctx); len(seeks) != 1 {
+		t.Errorf("seeks on the other node = %+v", seeks)
+	}
+	if _, err := b.AcceptSeek(ctx, s.ID, "bob"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.AcceptSeek(ctx, s.ID, "carol"); !errors.Is(err, ErrSeekNotFound) {
+		t.Errorf("accepted on both nodes: err = %v", err)
+	}
+
+	c, err := a.Challenge(ctx, Challenge{From: "alice", To: "bob"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.DeclineChallenge(ctx, c.ID, "bob", ""); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := a.GetChallenge(ctx, c.ID, "alice"); got.Status != ChallengeDeclined {
+		t.Errorf("challenge on the sender's node = %+v", got)
+	}
+}
@@ -0,0 +1,51 @@
+package lobby
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const eventsChannel = "chess:lobby:events"
+
+// Redis is the Relay between the nodes sharing a Redis, over pub/sub.
+type Redis struct {
+	rdb *redis.Client
+}
+
+func NewRedis(rdb *redis.Client) *Redis {
+	return &Redis{rdb: rdb}
+}
+
+func (r *Redis) Publish(ctx context.Context, e Event) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return r.rdb.Publish(ctx, eventsChannel, data).Err()
+}
+
+// Run hands deliver every event published by any node until ctx is done.
+func (r *Redis) Run(ctx context.Context, deliver func(Event)) {
+	ps := r.rdb.Subscribe(ctx, eventsChannel)
+	defer ps.Close()
+	ch := ps.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-ch:
+			if !ok {
+				return
+			}
+			var e Event
+			if err := json.Unmarshal([]byte(msg.Payload), &e); err != nil {
+				log.Printf("lobby: decode event: %v", err)
+				continue
+			}
+			deliver(e)
+		}
+	}
+}
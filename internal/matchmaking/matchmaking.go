@@ -0,0 +1,225 @@
+// Package matchmaking runs quick pairing: players join the pool for a time
+// control and variant and are paired with someone of a similar rating,
+// accepting a wider gap the longer they wait.
+package matchmaking
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/THECHAMP95821/chess-backend/internal/clock"
+	"github.com/THECHAMP95821/chess-backend/internal/game"
+	"github.com/THECHAMP95821/chess-backend/internal/rating"
+)
+
+// Games starts the games of paired players.
+type Games interface {
+	Create(opts game.CreateOptions) (game.View, error)
+}
+
+// Ratings looks up a player's current rating in a rating pool.
+type Ratings interface {
+	Current(ctx context.Context, userID string, pool rating.Pool) (rating.Rating, int, error)
+}
+
+// Request is a player asking for a game.
+type Request struct {
+	UserID      string
+	TimeControl string
+	Variant     string
+	Rated       bool
+}
+
+// Status is where a player stands: still waiting, or paired into a game.
+type Status struct {
+	Waiting *Ticket `json:"waiting,omitempty"`
+	Match   *Match  `json:"match,omitempty"`
+}
+
+type Service struct {
+	queue   Queue
+	games   Games
+	ratings Ratings
+	policy  Policy
+	now     func() time.Time
+}
+
+func NewService(q Queue, games Games) *Service {
+	return &Service{queue: q, games: games, policy: DefaultPolicy, now: time.Now}
+}
+
+// SetRatings makes players wait with their rating from r. Without it
+// everyone has the default rating.
+func (s *Service) SetRatings(r Ratings) {
+	s.ratings = r
+}
+
+// poolName keys the queue: players are only paired with others who asked
+// for exactly the same game.
+func poolName(timeControl, variant string, rated bool) string {
+	mode := "casual"
+	if rated {
+		mode = "rated"
+	}
+	if timeControl == "" {
+		timeControl = "unlimited"
+	}
+	return variant + "/" + timeControl + "/" + mode
+}
+
+// Join puts a player in the queue for the game they asked for.
+func (s *Service) Join(ctx context.Context, req Request) (Ticket, error) {
+	variant := req.Variant
+	if variant == "" {
+		variant = "standard"
+	}
+	if variant != "standard" {
+		return Ticket{}, fmt.Errorf("%w: %s", game.ErrUnsupportedVariant, variant)
+	}
+	tc := ""
+	if req.TimeControl != "" {
+		parsed, err := clock.ParseTimeControl(req.TimeControl)
+		if err != nil {
+			return Ticket{}, fmt.Errorf("%w: %v", game.ErrInvalidTime, err)
+		}
+		tc = parsed.String()
+	}
+
+	r := rating.Default()
+	if s.ratings != nil {
+		pool, err := rating.PoolFor(tc, variant)
+		if err != nil {
+			return Ticket{}, err
+		}
+		if r, _, err = s.ratings.Current(ctx, req.UserID, pool); err != nil {
+			return Ticket{}, err
+		}
+	}
+	history, err := s.queue.History(ctx, req.UserID)
+	if err != nil {
+		return Ticket{}, err
+	}
+
+	t := Ticket{
+		UserID:      req.UserID,
+		Pool:        poolName(tc, variant, req.Rated),
+		TimeControl: tc,
+		Variant:     variant,
+		Rated:       req.Rated,
+		Rating:      r.Rating,
+		JoinedAt:    s.now(),
+	}
+	summarise(&t, history)
+	if err := s.queue.Add(ctx, t); err != nil {
+		return Ticket{}, err
+	}
+	return t, nil
+}
+
+// Leave takes a waiting player out of the queue.
+func (s *Service) Leave(ctx context.Context, userID string) error {
+	_, err := s.queue.Remove(ctx, userID)
+	return err
+}
+
+// Status reports whether a player is waiting or has been paired. A player
+// who is neither gets ErrNotQueued.
+func (s *Service) Status(ctx context.Context, userID string) (Status, error) {
+	t, err := s.queue.Ticket(ctx, userID)
+	if err == nil {
+		return Status{Waiting: &t}, nil
+	}
+	if !errors.Is(err, ErrNotQueued) {
+		return Status{}, err
+	}
+	m, err := s.queue.Match(ctx, userID)
+	if err != nil {
+		return Status{}, err
+	}
+	return Status{Match: &m}, nil
+}
+
+// Tick pairs whoever can be paired in every pool and starts their games.
+// Several nodes may tick at once; Claim makes sure each player only ends
+// up in one game.
+func (s *Service) Tick(ctx context.Context) error {
+	pools, err := s.queue.Pools(ctx)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, pool := range pools {
+		tickets, err := s.queue.Tickets(ctx, pool)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for _, pair := range s.policy.pair(tickets, s.now()) {
+			if err := s.start(ctx, pair[0], pair[1]); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s *Service) start(ctx context.Context, a, b Ticket) error {
+	ok, err := s.queue.Claim(ctx, a, b)
+	if err != nil || !ok {
+		return err
+	}
+	white, black := a, b
+	if !whiteFirst(a, b) {
+		white, black = b, a
+	}
+	v, err := s.games.Create(game.CreateOptions{
+		TimeControl: white.TimeControl,
+		Variant:     white.Variant,
+		WhiteID:     white.UserID,
+		BlackID:     black.UserID,
+		Rated:       white.Rated,
+	})
+	if err != nil {
+		// Put both back where they were, keeping their place in the queue.
+		s.queue.Add(ctx, a)
+		s.queue.Add(ctx, b)
+		return fmt.Errorf("pair %s and %s: %w", a.UserID, b.UserID, err)
+	}
+
+	now := s.now()
+	for _, side := range []struct {
+		t        Ticket
+		color    string
+		opponent string
+	}{
+		{white, "white", black.UserID},
+		{black, "black", white.UserID},
+	} {
+		if err := s.queue.SetMatch(ctx, side.t.UserID, Match{GameID: v.ID, Color: side.color, Opponent: side.opponent, At: now}); err != nil {
+			return err
+		}
+		if err := s.queue.Record(ctx, side.t.UserID, Pairing{Opponent: side.opponent, Color: side.color}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Run ticks every interval until ctx is done.
+func (s *Service) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := s.Tick(ctx); err != nil {
+			log.Printf("matchmaking: %v", err)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package matchmaking
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/THECHAMP95821/chess-backend/internal/game"
+	"github.com/THECHAMP95821/chess-backend/internal/rating"
+)
+
+func TestPolicyWindow(t *testing.T) {
+	p := Policy{Initial: 50, Step: 10, Every: time.Second, Max: 100}
+	for _, tc := range []struct {
+		waited time.Duration
+		want   float64
+	}{
+		{0, 50},
+		{1500 * time.Millisecond, 60},
+		{3 * time.Second, 80},
+		{time.Minute, 100},
+	} {
+		if got := p.Window(tc.waited); got != tc.want {
+			t.Errorf("Window(%v) = %v, want %v", tc.waited, got, tc.want)
+		}
+	}
+}
+
+func TestPair(t *testing.T) {
+	p := Policy{Initial: 50, Step: 50, Every: 10 * time.Second, Max: 500}
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	ticket := func(user string, r float64, waited time.Duration) Ticket {
+		return Ticket{UserID: user, Rating: r, JoinedAt: now.Add(-waited)}
+	}
+
+	// Close enough straight away, and the closest is preferred.
+	pairs := p.pair([]Ticket{ticket("a", 1500, 0), ticket("b", 1540, 0), ticket("c", 1520, 0)}, now)
+	if len(pairs) != 1 || pairs[0][0].UserID != "a" || pairs[0][1].UserID != "c" {
+		t.Errorf("pairs = %v, want a with c", pairs)
+	}
+
+	// 150 apart: only once both have waited long enough.
+	far := []Ticket{ticket("a", 1500, 30*time.Second), ticket("b", 1650, 0)}
+	if pairs := p.pair(far, now); len(pairs) != 0 {
+		t.Errorf("paired a newcomer outside its window: %v", pairs)
+	}
+	far[1].JoinedAt = now.Add(-20 * time.Second)
+	if pairs := p.pair(far, now); len(pairs) != 1 {
+		t.Errorf("pairs = %v, want a with b after both waited", pairs)
+	}
+
+	// No immediate rematch, even against the closest player.
+	rematch := []Ticket{ticket("a", 1500, 0), ticket("b", 1500, 0), ticket("c", 1530, 0)}
+	rematch[0].LastOpponent = "b"
+	pairs = p.pair(rematch, now)
+	if len(pairs) != 1 || pairs[0][1].UserID != "c" {
+		t.Errorf("pairs = %v, want a with c rather than a rematch", pairs)
+	}
+
+	// In a pool of two, a rematch is better than waiting for ever.
+	p.RematchAfter = 15 * time.Second
+	pool := []Ticket{ticket("a", 1500, 10*time.Second), ticket("b", 1500, 0)}
+	pool[0].LastOpponent, pool[1].LastOpponent = "b", "a"
+	if pairs := p.pair(pool, now); len(pairs) != 0 {
+		t.Errorf("rematch before either waited long: %v", pairs)
+	}
+	pool[0].JoinedAt = now.Add(-15 * time.Second)
+	if pairs := p.pair(pool, now); len(pairs) != 1 {
+		t.Errorf("pairs = %v, want the rematch once a has waited", pairs)
+	}
+}
+
+func TestWhiteFirst(t *testing.T) {
+	var a, b Ticket
+	summarise(&a, []Pairing{{"x", "white"}, {"y", "white"}})
+	summarise(&b, []Pairing{{"z", "black"}})
+	if whiteFirst(a, b) || !whiteFirst(b, a) {
+		t.Error("white went to the player who had it more often")
+	}
+	a, b = Ticket{}, Ticket{}
+	summarise(&a, []Pairing{{"x", "black"}, {"y", "white"}})
+	summarise(&b, []Pairing{{"z", "white"}, {"w", "black"}})
+	if !whiteFirst(a, b) {
+		t.Error("white did not go to the player who had black last")
+	}
+}
+
+func testQueue(t *testing.T, q Queue) {
+	ctx := context.Background()
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	alice := Ticket{UserID: "alice", Pool: "standard/180+2/rated", Rating: 1600, JoinedAt: now}
+	bob := Ticket{UserID: "bob", Pool: alice.Pool, Rating: 1500, JoinedAt: now}
+	carol := Ticket{UserID: "carol", Pool: "standard/60+0/casual", Rating: 1400, JoinedAt: now}
+
+	for _, tk := range []Ticket{alice, bob, carol} {
+		if err := q.Add(ctx, tk); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := q.Add(ctx, alice); !errors.Is(err, ErrAlreadyQueued) {
+		t.Errorf("second ticket: err = %v, want ErrAlreadyQueued", err)
+	}
+	if pools, err := q.Pools(ctx); err != nil || len(pools) != 2 {
+		t.Errorf("pools = %v, %v", pools, err)
+	}
+	tickets, err := q.Tickets(ctx, alice.Pool)
+	if err != nil || len(tickets) != 2 || tickets[0].UserID != "bob" || tickets[1].Rating != 1600 {
+		t.Errorf("tickets = %+v, %v; want bob then alice", tickets, err)
+	}
+
+	if ok, err := q.Claim(ctx, alice, bob); !ok || err != nil {
+		t.Fatalf("claim = %v, %v", ok, err)
+	}
+	if ok, _ := q.Claim(ctx, alice, bob); ok {
+		t.Error("claimed the same pair twice")
+	}
+	if _, err := q.Ticket(ctx, "alice"); !errors.Is(err, ErrNotQueued) {
+		t.Errorf("claimed ticket: err = %v, want ErrNotQueued", err)
+	}
+	if tickets, _ := q.Tickets(ctx, alice.Pool); len(tickets) != 0 {
+		t.Errorf("tickets left after claim = %+v", tickets)
+	}
+
+	if _, err := q.Remove(ctx, "carol"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.Remove(ctx, "carol"); !errors.Is(err, ErrNotQueued) {
+		t.Errorf("second remove: err = %v, want ErrNotQueued", err)
+	}
+
+	q.SetMatch(ctx, "alice", Match{GameID: "g1", Color: "white", Opponent: "bob"})
+	if m, err := q.Match(ctx, "alice"); err != nil || m.GameID != "g1" {
+		t.Errorf("match = %+v, %v", m, err)
+	}
+	q.Add(ctx, alice)
+	if _, err := q.Match(ctx, "alice"); !errors.Is(err, ErrNotQueued) {
+		t.Errorf("match after joining again: err = %v, want ErrNotQueued", err)
+	}
+
+	for i := range historyLength + 2 {
+		q.Record(ctx, "bob", Pairing{Opponent: string(rune('a' + i)), Color: "black"})
+	}
+	if h, err := q.History(ctx, "bob"); err != nil || len(h) != historyLength || h[0].Opponent != "l" {
+		t.Errorf("history = %+v, %v", h, err)
+	}
+}
+
+func TestMemory(t *testing.T) {
+	testQueue(t, NewMemory())
+}
+
+func TestRedis(t *testing.T) {
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer rdb.Close()
+	testQueue(t, NewRedis(rdb))
+}
+
+type fixedRatings map[string]float64
+
+func (f fixedRatings) Current(ctx context.Context, userID string, pool rating.Pool) (rating.Rating, int, error) {
+	r := rating.Default()
+	r.Rating = f[userID]
+	return r, 1, nil
+}
+
+func TestServicePairs(t *testing.T) {
+	ctx := context.Background()
+	games := game.NewService()
+	q := NewMemory()
+	s := NewService(q, games)
+	s.SetRatings(fixedRatings{"alice": 1500, "bob": 1520, "carol": 2200})
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+	q.Record(ctx, "bob", Pairing{Opponent: "dave", Color: "white"})
+
+	if _, err := s.Join(ctx, Request{UserID: "alice", TimeControl: "soon", Rated: true}); !errors.Is(err, game.ErrInvalidTime) {
+		t.Errorf("bad time control: err = %v", err)
+	}
+	if _, err := s.Join(ctx, Request{UserID: "alice", TimeControl: "180+2", Variant: "atomic"}); !errors.Is(err, game.ErrUnsupportedVariant) {
+		t.Errorf("variant: err = %v", err)
+	}
+	for _, user := range []string{"alice", "bob", "carol"} {
+		if _, err := s.Join(ctx, Request{UserID: user, TimeControl: "180+2", Rated: true}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Tick(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	st, err := s.Status(ctx, "alice")
+	if err != nil || st.Match == nil || st.Match.Opponent != "bob" || st.Match.Color != "white" {
+		t.Fatalf("alice = %+v, %v; want white against bob", st, err)
+	}
+	v, err := games.Get(st.Match.GameID)
+	if err != nil || v.WhiteID != "alice" || v.BlackID != "bob" || !v.Rated || v.Clock == nil || v.Clock.Control != "180+2" {
+		t.Errorf("game = %+v, %v", v, err)
+	}
+	if st, _ := s.Status(ctx, "carol"); st.Waiting == nil {
+		t.Errorf("carol = %+v, want still waiting", st)
+	}
+	if err := s.Leave(ctx, "carol"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Status(ctx, "carol"); !errors.Is(err, ErrNotQueued) {
+		t.Errorf("carol after leaving: err = %v, want ErrNotQueued", err)
+	}
+}
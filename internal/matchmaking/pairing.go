@@ -0,0 +1,120 @@
+package matchmaking
+
+import (
+	"math"
+	"math/rand/v2"
+	"slices"
+	"time"
+)
+
+// Policy is how far apart in rating two players may be paired. Everyone
+// starts with the Initial window, which widens by Step every Every they
+// wait, up to Max.
+type Policy struct {
+	Initial float64
+	Step    float64
+	Every   time.Duration
+	Max     float64
+	// RematchAfter is how long either of two players who just played each
+	// other must wait before they may be paired again. With zero they never
+	// are.
+	RematchAfter time.Duration
+}
+
+var DefaultPolicy = Policy{
+	Initial:      75,
+	Step:         25,
+	Every:        3 * time.Second,
+	Max:          600,
+	RematchAfter: 20 * time.Second,
+}
+
+// rematchAllowed reports whether a and b may play each other.
+func (p Policy) rematchAllowed(a, b Ticket, now time.Time) bool {
+	if a.LastOpponent != b.UserID && b.LastOpponent != a.UserID {
+		return true
+	}
+	if p.RematchAfter <= 0 {
+		return false
+	}
+	return now.Sub(a.JoinedAt) >= p.RematchAfter || now.Sub(b.JoinedAt) >= p.RematchAfter
+}
+
+// Window is the largest rating difference a player who has waited for
+// waited accepts.
+func (p Policy) Window(waited time.Duration) float64 {
+	if waited <= 0 || p.Every <= 0 {
+		return math.Min(p.Initial, p.Max)
+	}
+	steps := float64(waited / p.Every)
+	return math.Min(p.Initial+steps*p.Step, p.Max)
+}
+
+// pair picks the games to start from the tickets of one pool. Players are
+// served in the order they joined: each is paired with the closest rated
+// player whose window also accepts them, unless the two just played each
+// other and neither has waited RematchAfter.
+func (p Policy) pair(tickets []Ticket, now time.Time) [][2]Ticket {
+	waiting := slices.Clone(tickets)
+	slices.SortStableFunc(waiting, func(a, b Ticket) int {
+		return a.JoinedAt.Compare(b.JoinedAt)
+	})
+
+	paired := make(map[string]bool)
+	var pairs [][2]Ticket
+	for i, a := range waiting {
+		if paired[a.UserID] {
+			continue
+		}
+		best, bestDiff := -1, math.Inf(1)
+		for j, b := range waiting {
+			if j == i || paired[b.UserID] || !p.rematchAllowed(a, b, now) {
+				continue
+			}
+			diff := math.Abs(a.Rating - b.Rating)
+			if diff > p.Window(now.Sub(a.JoinedAt)) || diff > p.Window(now.Sub(b.JoinedAt)) {
+				continue
+			}
+			if diff < bestDiff {
+				best, bestDiff = j, diff
+			}
+		}
+		if best < 0 {
+			continue
+		}
+		paired[a.UserID], paired[waiting[best].UserID] = true, true
+		pairs = append(pairs, [2]Ticket{a, waiting[best]})
+	}
+	return pairs
+}
+
+// whiteFirst reports whether a should have white against b. White goes to
+// whoever has had it less often lately, then to whoever had black in their
+// last game, and otherwise to either at random.
+func whiteFirst(a, b Ticket) bool {
+	if a.ColorBalance != b.ColorBalance {
+		return a.ColorBalance < b.ColorBalance
+	}
+	if a.LastColor != b.LastColor {
+		return a.LastColor == "black" || b.LastColor == "white"
+	}
+	return rand.IntN(2) == 0
+}
+
+// summarise condenses a player's recent pairings, newest first, into what
+// their ticket needs.
+func summarise(t *Ticket, history []Pairing) {
+	if len(history) == 0 {
+		return
+	}
+	t.LastOpponent = history[0].Opponent
+	t.LastColor = history[0].Color
+	for _, p := range history {
+		switch p.Color {
+		case "white":
+			t.ColorBalance++
+		case "black":
+			t.ColorBalance--
+		}
+	}
+}
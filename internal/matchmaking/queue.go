@@ -0,0 +1,195 @@
+package matchmaking
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+var (
+	ErrAlreadyQueued = errors.New("already waiting for a game")
+	ErrNotQueued     = errors.New("not waiting for a game")
+)
+
+// historyLength is how many of a player's recent pairings are kept for
+// choosing colors.
+const historyLength = 10
+
+// Ticket is a player waiting in a pool. LastOpponent, ColorBalance and
+// LastColor summarise their recent games when they joined, which is all
+// pairing needs to know about their history.
+type Ticket struct {
+	UserID       string    `json:"user_id"`
+	Pool         string    `json:"pool"`
+	TimeControl  string    `json:"time_control"`
+	Variant      string    `json:"variant"`
+	Rated        bool      `json:"rated"`
+	Rating       float64   `json:"rating"`
+	JoinedAt     time.Time `json:"joined_at"`
+	LastOpponent string    `json:"last_opponent,omitempty"`
+	ColorBalance int       `json:"color_balance"`
+	LastColor    string    `json:"last_color,omitempty"`
+}
+
+// Match is the game a waiting player was paired into.
+type Match struct {
+	GameID   string    `json:"game_id"`
+	Color    string    `json:"color"`
+	Opponent string    `json:"opponent"`
+	At       time.Time `json:"at"`
+}
+
+// Pairing is one game in a player's recent history.
+type Pairing struct {
+	Opponent string `json:"opponent"`
+	Color    string `json:"color"`
+}
+
+// Queue holds the players waiting in every pool, the matches they were
+// given and their recent pairings. It is shared by all nodes, so removing
+// a pair of players through Claim must be atomic.
+type Queue interface {
+	// Add queues t, unless its player is already waiting in any pool. It
+	// forgets the player's previous match.
+	Add(ctx context.Context, t Ticket) error
+	// Remove takes a player out of the queue, returning ErrNotQueued if
+	// they are not in it.
+	Remove(ctx context.Context, userID string) (Ticket, error)
+	Ticket(ctx context.Context, userID string) (Ticket, error)
+	Pools(ctx context.Context) ([]string, error)
+	// Tickets lists the players waiting in pool, lowest rating first.
+	Tickets(ctx context.Context, pool string) ([]Ticket, error)
+	// Claim removes both players from the queue if both are still in it,
+	// reporting whether it did.
+	Claim(ctx context.Context, a, b Ticket) (bool, error)
+	SetMatch(ctx context.Context, userID string, m Match) error
+	// Match returns a player's latest match, or ErrNotQueued.
+	Match(ctx context.Context, userID string) (Match, error)
+	Record(ctx context.Context, userID string, p Pairing) error
+	// History lists a player's recent pairings, newest first.
+	History(ctx context.Context, userID string) ([]Pairing, error)
+}
+
+// Memory is a Queue for a single process and for tests.
+type Memory struct {
+	mu      sync.Mutex
+	tickets map[string]Ticket
+	matches map[string]Match
+	history map[string][]Pairing
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		tickets: make(map[string]Ticket),
+		matches: make(map[string]Match),
+		history: make(map[string][]Pairing),
+	}
+}
+
+func (m *Memory) Add(ctx context.Context, t Ticket) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.tickets[t.UserID]; ok {
+		return fmt.Errorf("user %s: %w", t.UserID, ErrAlreadyQueued)
+	}
+	m.tickets[t.UserID] = t
+	delete(m.matches, t.UserID)
+	return nil
+}
+
+func (m *Memory) Remove(ctx context.Context, userID string) (Ticket, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t, ok := m.tickets[userID]
+	if !ok {
+		return Ticket{}, fmt.Errorf("user %s: %w", userID, ErrNotQueued)
+	}
+	delete(m.tickets, userID)
+	return t, nil
+}
+
+func (m *Memory) Ticket(ctx context.Context, userID string) (Ticket, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t, ok := m.tickets[userID]
+	if !ok {
+		return Ticket{}, fmt.Errorf("user %s: %w", userID, ErrNotQueued)
+	}
+	return t, nil
+}
+
+func (m *Memory) Pools(ctx context.Context) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var pools []string
+	for _, t := range m.tickets {
+		if !slices.Contains(pools, t.Pool) {
+			pools = append(pools, t.Pool)
+		}
+	}
+	slices.Sort(pools)
+	return pools, nil
+}
+
+func (m *Memory) Tickets(ctx context.Context, pool string) ([]Ticket, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	tickets := []Ticket{}
+	for _, t := range m.tickets {
+		if t.Pool == pool {
+			tickets = append(tickets, t)
+		}
+	}
+	slices.SortFunc(tickets, func(a, b Ticket) int {
+		return cmp.Or(cmp.Compare(a.Rating, b.Rating), cmp.Compare(a.UserID, b.UserID))
+	})
+	return tickets, nil
+}
+
+func (m *Memory) Claim(ctx context.Context, a, b Ticket) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, okA := m.tickets[a.UserID]
+	_, okB := m.tickets[b.UserID]
+	if !okA || !okB {
+		return false, nil
+	}
+	delete(m.tickets, a.UserID)
+	delete(m.tickets, b.UserID)
+	return true, nil
+}
+
+func (m *Memory) SetMatch(ctx context.Context, userID string, match Match) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.matches[userID] = match
+	return nil
+}
+
+func (m *Memory) Match(ctx context.Context, userID string) (Match, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	match, ok := m.matches[userID]
+	if !ok {
+		return Match{}, fmt.Errorf("user %s: %w", userID, ErrNotQueued)
+	}
+	return match, nil
+}
+
+func (m *Memory) Record(ctx context.Context, userID string, p Pairing) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	h := append([]Pairing{p}, m.history[userID]...)
+	m.history[userID] = h[:min(len(h), historyLength)]
+	return nil
+}
+
+func (m *Memory) History(ctx context.Context, userID string) ([]Pairing, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Pairing{}, m.history[userID]...), nil
+}
@@ -0,0 +1,198 @@
+package matchmaking
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	ticketsKey = "chess:mm:tickets"
+	poolsKey   = "chess:mm:pools"
+	matchTTL   = time.Hour
+	historyTTL = 30 * 24 * time.Hour
+)
+
+func poolKey(pool string) string {
+	return "chess:mm:pool:" + pool
+}
+
+func matchKey(userID string) string {
+	return "chess:mm:match:" + userID
+}
+
+func historyKey(userID string) string {
+	return "chess:mm:history:" + userID
+}
+
+// addScript queues a ticket unless the player already has one.
+var addScript = redis.NewScript(`
+if redis.call('HSETNX', KEYS[1], ARGV[1], ARGV[2]) == 0 then
+	return 0
+end
+redis.call('ZADD', KEYS[2], ARGV[3], ARGV[1])
+redis.call('SADD', KEYS[3], ARGV[4])
+redis.call('DEL', KEYS[4])
+return 1`)
+
+// claimScript removes two players only if both are still waiting.
+var claimScript = redis.NewScript(`
+if redis.call('HEXISTS', KEYS[1], ARGV[1]) == 0 or redis.call('HEXISTS', KEYS[1], ARGV[2]) == 0 then
+	return 0
+end
+redis.call('HDEL', KEYS[1], ARGV[1], ARGV[2])
+redis.call('ZREM', KEYS[2], ARGV[1], ARGV[2])
+return 1`)
+
+// Redis is the Queue shared by all nodes. Tickets live in one hash, and
+// every pool is a sorted set of its players by rating.
+type Redis struct {
+	rdb *redis.Client
+}
+
+func NewRedis(rdb *redis.Client) *Redis {
+	return &Redis{rdb: rdb}
+}
+
+func (q *Redis) Add(ctx context.Context, t Ticket) error {
+	data, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+	ok, err := addScript.Run(ctx, q.rdb,
+		[]string{ticketsKey, poolKey(t.Pool), poolsKey, matchKey(t.UserID)},
+		t.UserID, data, t.Rating, t.Pool).Int()
+	if err != nil {
+		return err
+	}
+	if ok == 0 {
+		return fmt.Errorf("user %s: %w", t.UserID, ErrAlreadyQueued)
+	}
+	return nil
+}
+
+func (q *Redis) Remove(ctx context.Context, userID string) (Ticket, error) {
+	t, err := q.Ticket(ctx, userID)
+	if err != nil {
+		return Ticket{}, err
+	}
+	var removed *redis.IntCmd
+	_, err = q.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		removed = pipe.HDel(ctx, ticketsKey, userID)
+		pipe.ZRem(ctx, poolKey(t.Pool), userID)
+		return nil
+	})
+	if err != nil {
+		return Ticket{}, err
+	}
+	if removed.Val() == 0 {
+		// Paired in the meantime.
+		return Ticket{}, fmt.Errorf("user %s: %w", userID, ErrNotQueued)
+	}
+	return t, nil
+}
+
+func (q *Redis) Ticket(ctx context.Context, userID string) (Ticket, error) {
+	data, err := q.rdb.HGet(ctx, ticketsKey, userID).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return Ticket{}, fmt.Errorf("user %s: %w", userID, ErrNotQueued)
+	}
+	if err != nil {
+		return Ticket{}, err
+	}
+	var t Ticket
+	err = json.Unmarshal(data, &t)
+	return t, err
+}
+
+func (q *Redis) Pools(ctx context.Context) ([]string, error) {
+	return q.rdb.SMembers(ctx, poolsKey).Result()
+}
+
+func (q *Redis) Tickets(ctx context.Context, pool string) ([]Ticket, error) {
+	users, err := q.rdb.ZRange(ctx, poolKey(pool), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	tickets := []Ticket{}
+	if len(users) == 0 {
+		return tickets, nil
+	}
+	values, err := q.rdb.HMGet(ctx, ticketsKey, users...).Result()
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range values {
+		data, ok := v.(string)
+		if !ok {
+			// Claimed between the two reads.
+			continue
+		}
+		var t Ticket
+		if err := json.Unmarshal([]byte(data), &t); err != nil {
+			return nil, err
+		}
+		tickets = append(tickets, t)
+	}
+	return tickets, nil
+}
+
+func (q *Redis) Claim(ctx context.Context, a, b Ticket) (bool, error) {
+	ok, err := claimScript.Run(ctx, q.rdb, []string{ticketsKey, poolKey(a.Pool)}, a.UserID, b.UserID).Int()
+	return ok == 1, err
+}
+
+func (q *Redis) SetMatch(ctx context.Context, userID string, m Match) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return q.rdb.Set(ctx, matchKey(userID), data, matchTTL).Err()
+}
+
+func (q *Redis) Match(ctx context.Context, userID string) (Match, error) {
+	data, err := q.rdb.Get(ctx, matchKey(userID)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return Match{}, fmt.Errorf("user %s: %w", userID, ErrNotQueued)
+	}
+	if err != nil {
+		return Match{}, err
+	}
+	var m Match
+	err = json.Unmarshal(data, &m)
+	return m, err
+}
+
+func (q *Redis) Record(ctx context.Context, userID string, p Pairing) error {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	_, err = q.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.LPush(ctx, historyKey(userID), data)
+		pipe.LTrim(ctx, historyKey(userID), 0, historyLength-1)
+		pipe.Expire(ctx, historyKey(userID), historyTTL)
+		return nil
+	})
+	return err
+}
+
+func (q *Redis) History(ctx context.Context, userID string) ([]Pairing, error) {
+	values, err := q.rdb.LRange(ctx, historyKey(userID), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	history := make([]Pairing, 0, len(values))
+	for _, v := range values {
+		var p Pairing
+		if err := json.Unmarshal([]byte(v), &p); err != nil {
+			return nil, err
+		}
+		history = append(history, p)
+	}
+	return history, nil
+}
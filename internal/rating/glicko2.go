@@ -0,0 +1,149 @@
+// Package rating implements the Glicko-2 rating system, with a separate
+// pool of ratings per time control category and variant.
+package rating
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	DefaultRating     = 1500.0
+	DefaultDeviation  = 350.0
+	DefaultVolatility = 0.06
+
+	// ProvisionalDeviation is the deviation above which a rating is too
+	// uncertain to be shown without a question mark.
+	ProvisionalDeviation = 110.0
+	// MinDeviation keeps very active players' ratings from freezing.
+	MinDeviation = 45.0
+
+	// tau constrains how fast volatility may change.
+	tau     = 0.5
+	scale   = 173.7178
+	epsilon = 1e-6
+)
+
+// PeriodLength is how long a rating period lasts for deviation decay: each
+// period without games makes a rating that much less certain.
+var PeriodLength = 24 * time.Hour
+
+// Rating is a Glicko-2 rating on the familiar Glicko scale.
+type Rating struct {
+	Rating     float64
+	Deviation  float64
+	Volatility float64
+}
+
+func Default() Rating {
+	return Rating{Rating: DefaultRating, Deviation: DefaultDeviation, Volatility: DefaultVolatility}
+}
+
+func (r Rating) Provisional() bool {
+	return r.Deviation > ProvisionalDeviation
+}
+
+// Result is one game against an opponent: Score is 1 for a win, 0.5 for a
+// draw and 0 for a loss.
+type Result struct {
+	Opponent Rating
+	Score    float64
+}
+
+// Decay widens the deviation of a rating for periods rating periods without
+// games, never beyond the deviation of a new player.
+func (r Rating) Decay(periods float64) Rating {
+	if periods <= 0 {
+		return r
+	}
+	phi := r.Deviation / scale
+	phi = math.Sqrt(phi*phi + r.Volatility*r.Volatility*periods)
+	r.Deviation = math.Min(phi*scale, DefaultDeviation)
+	return r
+}
+
+// DecaySince decays r for the time between last and now.
+func (r Rating) DecaySince(last, now time.Time) Rating {
+	if last.IsZero() || !now.After(last) {
+		return r
+	}
+	return r.Decay(float64(now.Sub(last)) / float64(PeriodLength))
+}
+
+func g(phi float64) float64 {
+	return 1 / math.Sqrt(1+3*phi*phi/(math.Pi*math.Pi))
+}
+
+func expected(mu, muJ, phiJ float64) float64 {
+	return 1 / (1 + math.Exp(-g(phiJ)*(mu-muJ)))
+}
+
+// Rate applies one rating period in which r played results. With no
+// results the deviation simply grows by one period.
+func (r Rating) Rate(results []Result) Rating {
+	if len(results) == 0 {
+		return r.Decay(1)
+	}
+	mu := (r.Rating - DefaultRating) / scale
+	phi := r.Deviation / scale
+	sigma := r.Volatility
+
+	var vInv, sum float64
+	for _, res := range results {
+		muJ := (res.Opponent.Rating - DefaultRating) / scale
+		phiJ := res.Opponent.Deviation / scale
+		gJ := g(phiJ)
+		e := expected(mu, muJ, phiJ)
+		vInv += gJ * gJ * e * (1 - e)
+		sum += gJ * (res.Score - e)
+	}
+	v := 1 / vInv
+	delta := v * sum
+
+	sigma = newVolatility(phi, sigma, v, delta)
+	phiStar := math.Sqrt(phi*phi + sigma*sigma)
+	phi = 1 / math.Sqrt(1/(phiStar*phiStar)+1/v)
+	mu += phi * phi * sum
+
+	return Rating{
+		Rating:     mu*scale + DefaultRating,
+		Deviation:  math.Max(math.Min(phi*scale, DefaultDeviation), MinDeviation),
+		Volatility: sigma,
+	}
+}
+
+// newVolatility solves for the new volatility with the Illinois algorithm,
+// as in step 5 of Glickman's description of Glicko-2.
+func newVolatility(phi, sigma, v, delta float64) float64 {
+	a := math.Log(sigma * sigma)
+	f := func(x float64) float64 {
+		ex := math.Exp(x)
+		d := phi*phi + v + ex
+		return ex*(delta*delta-d)/(2*d*d) - (x-a)/(tau*tau)
+	}
+
+	A := a
+	var B float64
+	if delta*delta > phi*phi+v {
+		B = math.Log(delta*delta - phi*phi - v)
+	} else {
+		k := 1.0
+		for f(a-k*tau) < 0 {
+			k++
+		}
+		B = a - k*tau
+	}
+
+	fA, fB := f(A), f(B)
+	for math.Abs(B-A) > epsilon {
+		C := A + (A-B)*fA/(fB-fA)
+		fC := f(C)
+		if fC*fB <= 0 {
+			A, fA = B, fB
+		} else {
+			fA /= 2
+		}
+		B, fB = C, fC
+	}
+	return math.Exp(A / 2)
+}
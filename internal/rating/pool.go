@@ -0,0 +1,51 @@
+package rating
+
+import (
+	"time"
+
+	"github.com/THECHAMP95821/chess-backend/internal/clock"
+)
+
+// Pool names a separate set of ratings. Games only affect the ratings of
+// the pool they were played in.
+type Pool string
+
+const (
+	Bullet         Pool = "bullet"
+	Blitz          Pool = "blitz"
+	Rapid          Pool = "rapid"
+	Classical      Pool = "classical"
+	Correspondence Pool = "correspondence"
+	// Bot is where every game with a bot in it is rated, whatever its
+	// clock, so that games against engines leave human ratings alone.
+	Bot Pool = "bot"
+)
+
+// PoolFor places a game in its pool. Variants other than standard chess
+// each have a pool of their own, whatever the clock; otherwise the pool
+// follows the estimated duration of the game, base time plus forty moves'
+// worth of increment. Untimed games count as correspondence.
+func PoolFor(timeControl, variant string) (Pool, error) {
+	if variant != "" && variant != "standard" {
+		return Pool(variant), nil
+	}
+	if timeControl == "" {
+		return Correspondence, nil
+	}
+	tc, err := clock.ParseTimeControl(timeControl)
+	if err != nil {
+		return "", err
+	}
+	first := tc.Periods[0]
+	estimate := first.Time + 40*first.Increment
+	switch {
+	case estimate < 3*time.Minute:
+		return Bullet, nil
+	case estimate < 8*time.Minute:
+		return Blitz, nil
+	case estimate < 25*time.Minute:
+		return Rapid, nil
+	default:
+		return Classical, nil
+	}
+}
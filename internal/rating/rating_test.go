@@ -0,0 +1,131 @@
+package rating
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/THECHAMP95821/chess-backend/internal/store"
+)
+
+func near(got, want, tol float64) bool {
+	return math.Abs(got-want) <= tol
+}
+
+// TestRateGlickmanExample checks the worked example from Glickman's
+// description of Glicko-2.
+func TestRateGlickmanExample(t *testing.T) {
+	player := Rating{Rating: 1500, Deviation: 200, Volatility: 0.06}
+	got := player.Rate([]Result{
+		{Opponent: Rating{Rating: 1400, Deviation: 30, Volatility: 0.06}, Score: 1},
+		{Opponent: Rating{Rating: 1550, Deviation: 100, Volatility: 0.06}, Score: 0},
+		{Opponent: Rating{Rating: 1700, Deviation: 300, Volatility: 0.06}, Score: 0},
+	})
+	if !near(got.Rating, 1464.06, 0.01) || !near(got.Deviation, 151.52, 0.01) || !near(got.Volatility, 0.05999, 0.00001) {
+		t.Errorf("rating after example period = %+v, want 1464.06/151.52/0.05999", got)
+	}
+}
+
+func TestDecay(t *testing.T) {
+	r := Rating{Rating: 1800, Deviation: 60, Volatility: 0.06}
+	if r.Decay(0) != r {
+		t.Error("decay over no time changed the rating")
+	}
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	month := r.DecaySince(now.Add(-30*PeriodLength), now)
+	if month.Rating != r.Rating || month.Deviation <= r.Deviation {
+		t.Errorf("after a month away = %+v, want a wider deviation", month)
+	}
+	if years := r.Decay(1e6); years.Deviation != DefaultDeviation || !years.Provisional() {
+		t.Errorf("after years away = %+v, want the deviation of a new player", years)
+	}
+	if r.Provisional() || !Default().Provisional() {
+		t.Error("provisional status wrong")
+	}
+}
+
+func TestPoolFor(t *testing.T) {
+	for _, tc := range []struct {
+		control, variant string
+		want             Pool
+	}{
+		{"60+0", "", Bullet},
+		{"120+1", "", Bullet},
+		{"180+2", "", Blitz},
+		{"300+3", "", Blitz},
+		{"600+0", "", Rapid},
+		{"900+10", "", Rapid},
+		{"1800+0", "", Classical},
+		{"", "", Correspondence},
+		{"60+0", "chess960", "chess960"},
+	} {
+		got, err := PoolFor(tc.control, tc.variant)
+		if err != nil || got != tc.want {
+			t.Errorf("PoolFor(%q, %q) = %q, %v, want %q", tc.control, tc.variant, got, err, tc.want)
+		}
+	}
+	if _, err := PoolFor("soon", ""); err == nil {
+		t.Error("PoolFor accepted a bad time control")
+	}
+}
+
+func TestServiceRateGame(t *testing.T) {
+	ctx := context.Background()
+	repo := store.NewMemory()
+	s := NewService(repo)
+	end := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return end }
+
+	g := store.Game{ID: "g1", WhiteID: "alice", BlackID: "bob", TimeControl: "180+2", Rated: true, Result: "1-0", EndedAt: end}
+	if err := s.RateGame(ctx, g); err != nil {
+		t.Fatal(err)
+	}
+	alice, games, _ := s.Current(ctx, "alice", Blitz)
+	bob, _, _ := s.Current(ctx, "bob", Blitz)
+	if games != 1 || alice.Rating <= DefaultRating || bob.Rating >= DefaultRating {
+		t.Errorf("after alice beat bob: alice %+v (%d games), bob %+v", alice, games, bob)
+	}
+	if !near(alice.Rating-DefaultRating, DefaultRating-bob.Rating, 1e-9) {
+		t.Errorf("equal players moved unevenly: alice %v, bob %v", alice.Rating, bob.Rating)
+	}
+	if err := s.RateGame(ctx, g); !errors.Is(err, store.ErrDuplicate) {
+		t.Errorf("rating a game twice: err = %v, want ErrDuplicate", err)
+	}
+
+	g2 := g
+	g2.ID, g2.Result, g2.EndedAt = "g2", "1/2-1/2", end.Add(time.Hour)
+	if err := s.RateGame(ctx, g2); err != nil {
+		t.Fatal(err)
+	}
+	h, err := s.History(ctx, "bob", Blitz)
+	if err != nil || len(h) != 2 || h[0].GameID != "g1" || h[1].Rating <= h[0].Rating {
+		t.Errorf("bob's history = %+v, %v", h, err)
+	}
+
+	unrated := g
+	unrated.ID, unrated.Rated = "g3", false
+	if err := s.RateGame(ctx, unrated); !errors.Is(err, ErrUnrated) {
+		t.Errorf("unrated game: err = %v, want ErrUnrated", err)
+	}
+}
+
+func TestServiceRatesBotGamesApart(t *testing.T) {
+	ctx := context.Background()
+	repo := store.NewMemory()
+	s := NewService(repo)
+	repo.CreateUser(ctx, store.User{ID: "alice", Name: "alice"})
+	repo.CreateUser(ctx, store.User{ID: "engine", Name: "engine", Bot: true})
+
+	g := store.Game{ID: "g1", WhiteID: "alice", BlackID: "engine", TimeControl: "180+2", Rated: true, Result: "0-1"}
+	if err := s.RateGame(ctx, g); err != nil {
+		t.Fatal(err)
+	}
+	if _, games, _ := s.Current(ctx, "alice", Blitz); games != 0 {
+		t.Errorf("bot game counted in blitz: %d games", games)
+	}
+	if r, games, _ := s.Current(ctx, "alice", Bot); games != 1 || r.Rating >= DefaultRating {
+		t.Errorf("alice's bot rating = %+v (%d games)", r, games)
+	}
+}
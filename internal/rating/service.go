@@ -0,0 +1,122 @@
+package rating
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/THECHAMP95821/chess-backend/internal/store"
+)
+
+var ErrUnrated = errors.New("game is not rated")
+
+// Service keeps players' ratings in a repository up to date as rated games
+// end, and records every change in their rating history.
+type Service struct {
+	repo store.Repository
+	now  func() time.Time
+}
+
+func NewService(repo store.Repository) *Service {
+	return &Service{repo: repo, now: time.Now}
+}
+
+// Current returns a player's rating in pool as of now, decayed for the time
+// since their last game. Players who never played in the pool get the
+// default rating.
+func (s *Service) Current(ctx context.Context, userID string, pool Pool) (Rating, int, error) {
+	rec, err := s.repo.Rating(ctx, userID, string(pool))
+	if errors.Is(err, store.ErrNotFound) {
+		return Default(), 0, nil
+	}
+	if err != nil {
+		return Rating{}, 0, err
+	}
+	r := Rating{Rating: rec.Rating, Deviation: rec.Deviation, Volatility: rec.Volatility}
+	return r.DecaySince(rec.UpdatedAt, s.now()), rec.Games, nil
+}
+
+// RateGame updates both players' ratings for a finished rated game. Each
+// is rated against the other's rating from before the game.
+func (s *Service) RateGame(ctx context.Context, g store.Game) error {
+	if !g.Rated || g.WhiteID == "" || g.BlackID == "" {
+		return ErrUnrated
+	}
+	var white float64
+	switch g.Result {
+	case "1-0":
+		white = 1
+	case "0-1":
+		white = 0
+	case "1/2-1/2":
+		white = 0.5
+	default:
+		return fmt.Errorf("rate game %s: no result", g.ID)
+	}
+	pool, err := s.poolOf(ctx, g)
+	if err != nil {
+		return fmt.Errorf("rate game %s: %w", g.ID, err)
+	}
+
+	w, wGames, err := s.Current(ctx, g.WhiteID, pool)
+	if err != nil {
+		return err
+	}
+	b, bGames, err := s.Current(ctx, g.BlackID, pool)
+	if err != nil {
+		return err
+	}
+	at := g.EndedAt
+	if at.IsZero() {
+		at = s.now()
+	}
+	if err := s.update(ctx, g.ID, g.WhiteID, pool, w.Rate([]Result{{Opponent: b, Score: white}}), wGames+1, at); err != nil {
+		return err
+	}
+	return s.update(ctx, g.ID, g.BlackID, pool, b.Rate([]Result{{Opponent: w, Score: 1 - white}}), bGames+1, at)
+}
+
+func (s *Service) poolOf(ctx context.Context, g store.Game) (Pool, error) {
+	for _, id := range []string{g.WhiteID, g.BlackID} {
+		u, err := s.repo.User(ctx, id)
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			return "", err
+		}
+		if u.Bot {
+			return Bot, nil
+		}
+	}
+	return PoolFor(g.TimeControl, "")
+}
+
+// update records the change first, so that a game that was already rated
+// fails with store.ErrDuplicate before touching the rating.
+func (s *Service) update(ctx context.Context, gameID, userID string, pool Pool, r Rating, games int, at time.Time) error {
+	err := s.repo.AppendRatingHistory(ctx, store.RatingChange{
+		UserID:     userID,
+		Pool:       string(pool),
+		GameID:     gameID,
+		Rating:     r.Rating,
+		Deviation:  r.Deviation,
+		Volatility: r.Volatility,
+		At:         at,
+	})
+	if err != nil {
+		return err
+	}
+	return s.repo.SaveRating(ctx, store.Rating{
+		UserID:     userID,
+		Pool:       string(pool),
+		Rating:     r.Rating,
+		Deviation:  r.Deviation,
+		Volatility: r.Volatility,
+		Games:      games,
+		UpdatedAt:  at,
+	})
+}
+
+// History lists a player's ratings in pool after each of their rated games.
+func (s *Service) History(ctx context.Context, userID string, pool Pool) ([]store.RatingChange, error) {
+	return s.repo.RatingHistory(ctx, userID, string(pool))
+}
@@ -0,0 +1,88 @@
+package store
+
+import (
+	"context"
+	"time"
+)
+
+// Credentials are what a user logs in with. Email is optional but unique
+// among users that give one; PasswordHash is in the encoding of the hasher
+// that made it.
+type Credentials struct {
+	UserID       string
+	Email        string
+	PasswordHash string
+	UpdatedAt    time.Time
+}
+
+// Session is one logged-in device. Only a hash of the session token is
+// stored; ID names the session to its owner, for listing and revoking.
+type Session struct {
+	ID        string
+	TokenHash string
+	UserID    string
+	Device    string
+	CreatedAt time.Time
+	LastSeen  time.Time
+	ExpiresAt time.Time
+	RevokedAt time.Time
+}
+
+// PasswordReset is an outstanding password reset token, again stored only
+// as a hash. UsedAt is zero until it has been redeemed.
+type PasswordReset struct {
+	TokenHash string
+	UserID    string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	UsedAt    time.Time
+}
+
+// APIToken is a long-lived token a user made for a script or bot, limited
+// to Scopes. Like sessions, only its hash is stored.
+type APIToken struct {
+	ID          string
+	TokenHash   string
+	UserID      string
+	Description string
+	Scopes      []string
+	CreatedAt   time.Time
+	LastUsed    time.Time
+	RevokedAt   time.Time
+}
+
+type Accounts interface {
+	// CreateAccount creates a user together with their credentials, or
+	// neither. It fails with ErrDuplicate if the name or email is taken.
+	CreateAccount(ctx context.Context, u User, c Credentials) error
+	// SaveCredentials inserts or replaces the credentials of a user. It
+	// fails with ErrDuplicate if another user has the same email.
+	SaveCredentials(ctx context.Context, c Credentials) error
+	Credentials(ctx context.Context, userID string) (Credentials, error)
+	CredentialsByEmail(ctx context.Context, email string) (Credentials, error)
+
+	CreateSession(ctx context.Context, s Session) error
+	SessionByToken(ctx context.Context, tokenHash string) (Session, error)
+	// Sessions returns the sessions of a user that are neither revoked nor
+	// expired at now, most recently created first.
+	Sessions(ctx context.Context, userID string, now time.Time) ([]Session, error)
+	TouchSession(ctx context.Context, id string, at time.Time) error
+	// RevokeSession fails with ErrNotFound unless the user has a session
+	// with that id that is not yet revoked.
+	RevokeSession(ctx context.Context, userID, id string, at time.Time) error
+	RevokeSessions(ctx context.Context, userID string, at time.Time) error
+
+	CreatePasswordReset(ctx context.Context, r PasswordReset) error
+	// UsePasswordReset marks a reset as used and returns it. It fails with
+	// ErrNotFound if there is no such reset or it was already used.
+	UsePasswordReset(ctx context.Context, tokenHash string, at time.Time) (PasswordReset, error)
+
+	CreateAPIToken(ctx context.Context, t APIToken) error
+	APITokenByHash(ctx context.Context, tokenHash string) (APIToken, error)
+	// APITokens returns the unrevoked tokens of a user, newest first.
+	APITokens(ctx context.Context, userID string) ([]APIToken, error)
+	TouchAPIToken(ctx context.Context, id string, at time.Time) error
+	// RevokeAPIToken fails with ErrNotFound unless the user has an
+	// unrevoked token with that id.
+	RevokeAPIToken(ctx context.Context, userID, id string, at time.Time) error
+}
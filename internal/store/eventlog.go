@@ -0,0 +1,40 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var ErrVersionConflict = errors.New("event stream moved on")
+
+// LogEntry is one event in a game's append-only stream. Version numbers the
+// entries of a game from 1; Data is the JSON encoding of the event.
+type LogEntry struct {
+	GameID  string
+	Version int
+	Kind    string
+	At      time.Time
+	Data    []byte
+}
+
+// Snapshot is the position of a game after the entry numbered Version, so
+// that rebuilding it only needs the entries after that.
+type Snapshot struct {
+	GameID  string
+	Version int
+	Ply     int
+	FEN     string
+	At      time.Time
+}
+
+type EventLog interface {
+	// Append adds entries to the stream of a game, which must currently end
+	// at version expected; otherwise it fails with ErrVersionConflict.
+	Append(ctx context.Context, gameID string, expected int, entries ...LogEntry) error
+	// Entries returns the entries after version, in order.
+	Entries(ctx context.Context, gameID string, after int) ([]LogEntry, error)
+	SaveSnapshot(ctx context.Context, s Snapshot) error
+	// LatestSnapshot fails with ErrNotFound if the game has none.
+	LatestSnapshot(ctx context.Context, gameID string) (Snapshot, error)
+}
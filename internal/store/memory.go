@@ -0,0 +1,455 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Memory is a Repository that keeps everything in maps, for tests and for
+// running without a database.
+type Memory struct {
+	mu      sync.RWMutex
+	users   map[string]User
+	games   map[string]Game
+	moves   map[string][]Move
+	ratings map[[2]string]Rating
+	entries map[string][]LogEntry
+	snaps   map[string]Snapshot
+	history map[[2]string][]RatingChange
+	creds   map[string]Credentials
+	session map[string]Session
+	resets  map[string]PasswordReset
+	tokens  map[string]APIToken
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		users:   make(map[string]User),
+		games:   make(map[string]Game),
+		moves:   make(map[string][]Move),
+		ratings: make(map[[2]string]Rating),
+		entries: make(map[string][]LogEntry),
+		snaps:   make(map[string]Snapshot),
+		history: make(map[[2]string][]RatingChange),
+		creds:   make(map[string]Credentials),
+		session: make(map[string]Session),
+		resets:  make(map[string]PasswordReset),
+		tokens:  make(map[string]APIToken),
+	}
+}
+
+func (m *Memory) CreateUser(ctx context.Context, u User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.createUser(u)
+}
+
+func (m *Memory) createUser(u User) error {
+	if _, ok := m.users[u.ID]; ok {
+		return fmt.Errorf("user %s: %w", u.ID, ErrDuplicate)
+	}
+	for _, other := range m.users {
+		if other.Name == u.Name {
+			return fmt.Errorf("user name %s: %w", u.Name, ErrDuplicate)
+		}
+	}
+	m.users[u.ID] = u
+	return nil
+}
+
+func (m *Memory) User(ctx context.Context, id string) (User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	u, ok := m.users[id]
+	if !ok {
+		return User{}, fmt.Errorf("user %s: %w", id, ErrNotFound)
+	}
+	return u, nil
+}
+
+func (m *Memory) UserByName(ctx context.Context, name string) (User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, u := range m.users {
+		if u.Name == name {
+			return u, nil
+		}
+	}
+	return User{}, fmt.Errorf("user name %s: %w", name, ErrNotFound)
+}
+
+func (m *Memory) MarkBot(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u, ok := m.users[id]
+	if !ok {
+		return fmt.Errorf("user %s: %w", id, ErrNotFound)
+	}
+	u.Bot = true
+	m.users[id] = u
+	return nil
+}
+
+func (m *Memory) SaveGame(ctx context.Context, g Game) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.games[g.ID] = g
+	return nil
+}
+
+func (m *Memory) Game(ctx context.Context, id string) (Game, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	g, ok := m.games[id]
+	if !ok {
+		return Game{}, fmt.Errorf("game %s: %w", id, ErrNotFound)
+	}
+	return g, nil
+}
+
+func (m *Memory) AppendMove(ctx context.Context, gameID string, mv Move) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.games[gameID]; !ok {
+		return fmt.Errorf("game %s: %w", gameID, ErrNotFound)
+	}
+	moves := m.moves[gameID]
+	if mv.Ply <= len(moves) {
+		return fmt.Errorf("game %s ply %d: %w", gameID, mv.Ply, ErrDuplicate)
+	}
+	if mv.Ply != len(moves)+1 {
+		return fmt.Errorf("game %s: ply %d does not follow %d", gameID, mv.Ply, len(moves))
+	}
+	m.moves[gameID] = append(moves, mv)
+	return nil
+}
+
+func (m *Memory) TruncateMoves(ctx context.Context, gameID string, ply int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if moves := m.moves[gameID]; ply < len(moves) {
+		m.moves[gameID] = moves[:max(ply, 0)]
+	}
+	return nil
+}
+
+func (m *Memory) Moves(ctx context.Context, gameID string) ([]Move, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if _, ok := m.games[gameID]; !ok {
+		return nil, fmt.Errorf("game %s: %w", gameID, ErrNotFound)
+	}
+	return append([]Move{}, m.moves[gameID]...), nil
+}
+
+func (m *Memory) SaveRating(ctx context.Context, r Rating) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.ratings[[2]string{r.UserID, r.Pool}] = r
+	return nil
+}
+
+func (m *Memory) Rating(ctx context.Context, userID, pool string) (Rating, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	r, ok := m.ratings[[2]string{userID, pool}]
+	if !ok {
+		return Rating{}, fmt.Errorf("rating %s/%s: %w", userID, pool, ErrNotFound)
+	}
+	return r, nil
+}
+
+func (m *Memory) AppendRatingHistory(ctx context.Context, c RatingChange) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := [2]string{c.UserID, c.Pool}
+	for _, prev := range m.history[key] {
+		if prev.GameID == c.GameID {
+			return fmt.Errorf("rating change %s/%s for game %s: %w", c.UserID, c.Pool, c.GameID, ErrDuplicate)
+		}
+	}
+	m.history[key] = append(m.history[key], c)
+	return nil
+}
+
+func (m *Memory) RatingHistory(ctx context.Context, userID, pool string) ([]RatingChange, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]RatingChange{}, m.history[[2]string{userID, pool}]...), nil
+}
+
+func (m *Memory) Append(ctx context.Context, gameID string, expected int, entries ...LogEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stream := m.entries[gameID]
+	if len(stream) != expected {
+		return fmt.Errorf("game %s at version %d, not %d: %w", gameID, len(stream), expected, ErrVersionConflict)
+	}
+	for i, e := range entries {
+		e.GameID = gameID
+		e.Version = expected + i + 1
+		stream = append(stream, e)
+	}
+	m.entries[gameID] = stream
+	return nil
+}
+
+func (m *Memory) Entries(ctx context.Context, gameID string, after int) ([]LogEntry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	stream := m.entries[gameID]
+	if after >= len(stream) {
+		return []LogEntry{}, nil
+	}
+	return append([]LogEntry{}, stream[max(after, 0):]...), nil
+}
+
+func (m *Memory) SaveSnapshot(ctx context.Context, s Snapshot) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if cur, ok := m.snaps[s.GameID]; !ok || s.Version > cur.Version {
+		m.snaps[s.GameID] = s
+	}
+	return nil
+}
+
+func (m *Memory) LatestSnapshot(ctx context.Context, gameID string) (Snapshot, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	s, ok := m.snaps[gameID]
+	if !ok {
+		return Snapshot{}, fmt.Errorf("snapshot of game %s: %w", gameID, ErrNotFound)
+	}
+	return s, nil
+}
+
+func (m *Memory) CreateAccount(ctx context.Context, u User, c Credentials) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.emailFree(c); err != nil {
+		return err
+	}
+	if err := m.createUser(u); err != nil {
+		return err
+	}
+	m.creds[c.UserID] = c
+	return nil
+}
+
+func (m *Memory) SaveCredentials(ctx context.Context, c Credentials) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.users[c.UserID]; !ok {
+		return fmt.Errorf("credentials of %s: %w", c.UserID, ErrNotFound)
+	}
+	if err := m.emailFree(c); err != nil {
+		return err
+	}
+	m.creds[c.UserID] = c
+	return nil
+}
+
+// emailFree fails with ErrDuplicate if another user has c's email.
+func (m *Memory) emailFree(c Credentials) error {
+	if c.Email == "" {
+		return nil
+	}
+	for _, other := range m.creds {
+		if other.UserID != c.UserID && other.Email == c.Email {
+			return fmt.Errorf("email %s: %w", c.Email, ErrDuplicate)
+		}
+	}
+	return nil
+}
+
+func (m *Memory) Credentials(ctx context.Context, userID string) (Credentials, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	c, ok := m.creds[userID]
+	if !ok {
+		return Credentials{}, fmt.Errorf("credentials of %s: %w", userID, ErrNotFound)
+	}
+	return c, nil
+}
+
+func (m *Memory) CredentialsByEmail(ctx context.Context, email string) (Credentials, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if email != "" {
+		for _, c := range m.creds {
+			if c.Email == email {
+				return c, nil
+			}
+		}
+	}
+	return Credentials{}, fmt.Errorf("email %s: %w", email, ErrNotFound)
+}
+
+func (m *Memory) CreateSession(ctx context.Context, s Session) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.users[s.UserID]; !ok {
+		return fmt.Errorf("session of %s: %w", s.UserID, ErrNotFound)
+	}
+	for _, other := range m.session {
+		if other.ID == s.ID || other.TokenHash == s.TokenHash {
+			return fmt.Errorf("session %s: %w", s.ID, ErrDuplicate)
+		}
+	}
+	m.session[s.ID] = s
+	return nil
+}
+
+func (m *Memory) SessionByToken(ctx context.Context, tokenHash string) (Session, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, s := range m.session {
+		if s.TokenHash == tokenHash {
+			return s, nil
+		}
+	}
+	return Session{}, fmt.Errorf("session: %w", ErrNotFound)
+}
+
+func (m *Memory) Sessions(ctx context.Context, userID string, now time.Time) ([]Session, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	out := []Session{}
+	for _, s := range m.session {
+		if s.UserID == userID && s.RevokedAt.IsZero() && s.ExpiresAt.After(now) {
+			out = append(out, s)
+		}
+	}
+	slices.SortFunc(out, func(a, b Session) int { return b.CreatedAt.Compare(a.CreatedAt) })
+	return out, nil
+}
+
+func (m *Memory) TouchSession(ctx context.Context, id string, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.session[id]
+	if !ok {
+		return fmt.Errorf("session %s: %w", id, ErrNotFound)
+	}
+	s.LastSeen = at
+	m.session[id] = s
+	return nil
+}
+
+func (m *Memory) RevokeSession(ctx context.Context, userID, id string, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.session[id]
+	if !ok || s.UserID != userID || !s.RevokedAt.IsZero() {
+		return fmt.Errorf("session %s: %w", id, ErrNotFound)
+	}
+	s.RevokedAt = at
+	m.session[id] = s
+	return nil
+}
+
+func (m *Memory) RevokeSessions(ctx context.Context, userID string, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id, s := range m.session {
+		if s.UserID == userID && s.RevokedAt.IsZero() {
+			s.RevokedAt = at
+			m.session[id] = s
+		}
+	}
+	return nil
+}
+
+func (m *Memory) CreatePasswordReset(ctx context.Context, r PasswordReset) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.users[r.UserID]; !ok {
+		return fmt.Errorf("password reset of %s: %w", r.UserID, ErrNotFound)
+	}
+	if _, ok := m.resets[r.TokenHash]; ok {
+		return fmt.Errorf("password reset: %w", ErrDuplicate)
+	}
+	m.resets[r.TokenHash] = r
+	return nil
+}
+
+func (m *Memory) UsePasswordReset(ctx context.Context, tokenHash string, at time.Time) (PasswordReset, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r, ok := m.resets[tokenHash]
+	if !ok || !r.UsedAt.IsZero() {
+		return PasswordReset{}, fmt.Errorf("password reset: %w", ErrNotFound)
+	}
+	r.UsedAt = at
+	m.resets[tokenHash] = r
+	return r, nil
+}
+
+func (m *Memory) CreateAPIToken(ctx context.Context, t APIToken) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.users[t.UserID]; !ok {
+		return fmt.Errorf("api token of %s: %w", t.UserID, ErrNotFound)
+	}
+	for _, other := range m.tokens {
+		if other.ID == t.ID || other.TokenHash == t.TokenHash {
+			return fmt.Errorf("api token %s: %w", t.ID, ErrDuplicate)
+		}
+	}
+	t.Scopes = slices.Clone(t.Scopes)
+	m.tokens[t.ID] = t
+	return nil
+}
+
+func (m *Memory) APITokenByHash(ctx context.Context, tokenHash string) (APIToken, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, t := range m.tokens {
+		if t.TokenHash == tokenHash {
+			t.Scopes = slices.Clone(t.Scopes)
+			return t, nil
+		}
+	}
+	return APIToken{}, fmt.Errorf("api token: %w", ErrNotFound)
+}
+
+func (m *Memory) APITokens(ctx context.Context, userID string) ([]APIToken, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	out := []APIToken{}
+	for _, t := range m.tokens {
+		if t.UserID == userID && t.RevokedAt.IsZero() {
+			t.Scopes = slices.Clone(t.Scopes)
+			out = append(out, t)
+		}
+	}
+	slices.SortFunc(out, func(a, b APIToken) int { return b.CreatedAt.Compare(a.CreatedAt) })
+	return out, nil
+}
+
+func (m *Memory) TouchAPIToken(ctx context.Context, id string, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t, ok := m.tokens[id]
+	if !ok {
+		return fmt.Errorf("api token %s: %w", id, ErrNotFound)
+	}
+	t.LastUsed = at
+	m.tokens[id] = t
+	return nil
+}
+
+func (m *Memory) RevokeAPIToken(ctx context.Context, userID, id string, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t, ok := m.tokens[id]
+	if !ok || t.UserID != userID || !t.RevokedAt.IsZero() {
+		return fmt.Errorf("api token %s: %w", id, ErrNotFound)
+	}
+	t.RevokedAt = at
+	m.tokens[id] = t
+	return nil
+}
@@ -0,0 +1,43 @@
+CREATE TABLE users (
+    id         TEXT PRIMARY KEY,
+    name       TEXT NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE games (
+    id           TEXT PRIMARY KEY,
+    white_id     TEXT REFERENCES users (id),
+    black_id     TEXT REFERENCES users (id),
+    initial_fen  TEXT NOT NULL,
+    time_control TEXT NOT NULL DEFAULT '',
+    result       TEXT NOT NULL DEFAULT '*',
+    termination  TEXT NOT NULL DEFAULT '',
+    draw_reason  TEXT NOT NULL DEFAULT '',
+    created_at   TIMESTAMPTZ NOT NULL,
+    ended_at     TIMESTAMPTZ
+);
+
+CREATE INDEX games_white_id_idx ON games (white_id, created_at DESC);
+CREATE INDEX games_black_id_idx ON games (black_id, created_at DESC);
+
+CREATE TABLE moves (
+    game_id   TEXT NOT NULL REFERENCES games (id) ON DELETE CASCADE,
+    ply       INTEGER NOT NULL CHECK (ply > 0),
+    uci       TEXT NOT NULL,
+    san       TEXT NOT NULL,
+    played_at TIMESTAMPTZ NOT NULL,
+    clock_ms  BIGINT NOT NULL DEFAULT 0,
+    spent_ms  BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (game_id, ply)
+);
+
+CREATE TABLE ratings (
+    user_id    TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    pool       TEXT NOT NULL,
+    rating     DOUBLE PRECISION NOT NULL,
+    deviation  DOUBLE PRECISION NOT NULL,
+    volatility DOUBLE PRECISION NOT NULL,
+    games      INTEGER NOT NULL DEFAULT 0,
+    updated_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (user_id, pool)
+);
@@ -0,0 +1,17 @@
+CREATE TABLE game_events (
+    game_id TEXT NOT NULL,
+    version INTEGER NOT NULL CHECK (version > 0),
+    kind    TEXT NOT NULL,
+    at      TIMESTAMPTZ NOT NULL,
+    data    JSONB NOT NULL,
+    PRIMARY KEY (game_id, version)
+);
+
+CREATE TABLE game_snapshots (
+    game_id TEXT NOT NULL,
+    version INTEGER NOT NULL,
+    ply     INTEGER NOT NULL,
+    fen     TEXT NOT NULL,
+    at      TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (game_id, version)
+);
@@ -0,0 +1,14 @@
+ALTER TABLE games ADD COLUMN rated BOOLEAN NOT NULL DEFAULT false;
+
+CREATE TABLE rating_history (
+    user_id    TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    pool       TEXT NOT NULL,
+    game_id    TEXT NOT NULL,
+    rating     DOUBLE PRECISION NOT NULL,
+    deviation  DOUBLE PRECISION NOT NULL,
+    volatility DOUBLE PRECISION NOT NULL,
+    at         TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (user_id, pool, game_id)
+);
+
+CREATE INDEX rating_history_at_idx ON rating_history (user_id, pool, at);
@@ -0,0 +1,13 @@
+CREATE TABLE api_tokens (
+    id          TEXT PRIMARY KEY,
+    token_hash  TEXT NOT NULL UNIQUE,
+    user_id     TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    description TEXT NOT NULL DEFAULT '',
+    -- Space-separated, as in OAuth.
+    scopes      TEXT NOT NULL,
+    created_at  TIMESTAMPTZ NOT NULL,
+    last_used   TIMESTAMPTZ,
+    revoked_at  TIMESTAMPTZ
+);
+
+CREATE INDEX api_tokens_user_id_idx ON api_tokens (user_id, created_at DESC);
@@ -0,0 +1 @@
+ALTER TABLE users ADD COLUMN bot BOOLEAN NOT NULL DEFAULT false;
@@ -0,0 +1,6 @@
+-- Games and ratings name players by ID whether or not they have an account:
+-- tournament imports and servers without accounts have none.
+ALTER TABLE games DROP CONSTRAINT IF EXISTS games_white_id_fkey;
+ALTER TABLE games DROP CONSTRAINT IF EXISTS games_black_id_fkey;
+ALTER TABLE ratings DROP CONSTRAINT IF EXISTS ratings_user_id_fkey;
+ALTER TABLE rating_history DROP CONSTRAINT IF EXISTS rating_history_user_id_fkey;
@@ -0,0 +1,536 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+// Postgres is the Repository backed by PostgreSQL.
+type Postgres struct {
+	db *sql.DB
+}
+
+// OpenPostgres connects to the database at dsn and brings its schema up to
+// date.
+func OpenPostgres(ctx context.Context, dsn string) (*Postgres, error) {
+	db, err := sql.Open("pgx", dsn)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if err := Migrate(ctx, db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &Postgres{db: db}, nil
+}
+
+func (p *Postgres) DB() *sql.DB {
+	return p.db
+}
+
+func (p *Postgres) Close() error {
+	return p.db.Close()
+}
+
+// translate maps driver errors onto the package's sentinel errors.
+func translate(err error, what string) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%s: %w", what, ErrNotFound)
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case "23505": // unique_violation
+			return fmt.Errorf("%s: %w", what, ErrDuplicate)
+		case "23503": // foreign_key_violation
+			return fmt.Errorf("%s: %w", what, ErrNotFound)
+		}
+	}
+	return fmt.Errorf("%s: %w", what, err)
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
+
+func (p *Postgres) CreateUser(ctx context.Context, u User) error {
+	_, err := p.db.ExecContext(ctx,
+		`INSERT INTO users (id, name, bot, created_at) VALUES ($1, $2, $3, $4)`,
+		u.ID, u.Name, u.Bot, u.CreatedAt)
+	return translate(err, "user "+u.ID)
+}
+
+func (p *Postgres) User(ctx context.Context, id string) (User, error) {
+	var u User
+	err := p.db.QueryRowContext(ctx,
+		`SELECT id, name, bot, created_at FROM users WHERE id = $1`, id,
+	).Scan(&u.ID, &u.Name, &u.Bot, &u.CreatedAt)
+	return u, translate(err, "user "+id)
+}
+
+func (p *Postgres) UserByName(ctx context.Context, name string) (User, error) {
+	var u User
+	err := p.db.QueryRowContext(ctx,
+		`SELECT id, name, bot, created_at FROM users WHERE name = $1`, name,
+	).Scan(&u.ID, &u.Name, &u.Bot, &u.CreatedAt)
+	return u, translate(err, "user name "+name)
+}
+
+func (p *Postgres) MarkBot(ctx context.Context, id string) error {
+	res, err := p.db.ExecContext(ctx, `UPDATE users SET bot = true WHERE id = $1`, id)
+	if err != nil {
+		return translate(err, "user "+id)
+	}
+	return affected(res, "user "+id)
+}
+
+func (p *Postgres) SaveGame(ctx context.Context, g Game) error {
+	_, err := p.db.ExecContext(ctx, `
+		INSERT INTO games (id, white_id, black_id, initial_fen, time_control,
+			result, termination, draw_reason, created_at, ended_at, rated)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		ON CONFLICT (id) DO UPDATE SET
+			white_id = EXCLUDED.white_id,
+			black_id = EXCLUDED.black_id,
+			result = EXCLUDED.result,
+			termination = EXCLUDED.termination,
+			draw_reason = EXCLUDED.draw_reason,
+			ended_at = EXCLUDED.ended_at,
+			rated = EXCLUDED.rated`,
+		g.ID, nullString(g.WhiteID), nullString(g.BlackID), g.InitialFEN, g.TimeControl,
+		g.Result, g.Termination, g.DrawReason, g.CreatedAt, nullTime(g.EndedAt), g.Rated)
+	return translate(err, "game "+g.ID)
+}
+
+func (p *Postgres) Game(ctx context.Context, id string) (Game, error) {
+	var g Game
+	var white, black sql.NullString
+	var ended sql.NullTime
+	err := p.db.QueryRowContext(ctx, `
+		SELECT id, white_id, black_id, initial_fen, time_control,
+			result, termination, draw_reason, created_at, ended_at, rated
+		FROM games WHERE id = $1`, id,
+	).Scan(&g.ID, &white, &black, &g.InitialFEN, &g.TimeControl,
+		&g.Result, &g.Termination, &g.DrawReason, &g.CreatedAt, &ended, &g.Rated)
+	g.WhiteID, g.BlackID, g.EndedAt = white.String, black.String, ended.Time
+	return g, translate(err, "game "+id)
+}
+
+func (p *Postgres) AppendMove(ctx context.Context, gameID string, m Move) error {
+	// The insert only succeeds for the ply right after the last stored one.
+	res, err := p.db.ExecContext(ctx, `
+		INSERT INTO moves (game_id, ply, uci, san, played_at, clock_ms, spent_ms)
+		SELECT $1, $2, $3, $4, $5, $6, $7
+		WHERE $2 = 1 + (SELECT count(*) FROM moves WHERE game_id = $1)`,
+		gameID, m.Ply, m.UCI, m.SAN, m.PlayedAt, m.Clock.Milliseconds(), m.Spent.Milliseconds())
+	if err != nil {
+		return translate(err, fmt.Sprintf("game %s ply %d", gameID, m.Ply))
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		return nil
+	}
+	var stored int
+	if err := p.db.QueryRowContext(ctx, `SELECT count(*) FROM moves WHERE game_id = $1`, gameID).Scan(&stored); err != nil {
+		return translate(err, "game "+gameID)
+	}
+	if m.Ply <= stored {
+		return fmt.Errorf("game %s ply %d: %w", gameID, m.Ply, ErrDuplicate)
+	}
+	return fmt.Errorf("game %s: ply %d does not follow %d", gameID, m.Ply, stored)
+}
+
+func (p *Postgres) TruncateMoves(ctx context.Context, gameID string, ply int) error {
+	_, err := p.db.ExecContext(ctx, `DELETE FROM moves WHERE game_id = $1 AND ply > $2`, gameID, ply)
+	return translate(err, "game "+gameID)
+}
+
+func (p *Postgres) Moves(ctx context.Context, gameID string) ([]Move, error) {
+	if _, err := p.Game(ctx, gameID); err != nil {
+		return nil, err
+	}
+	rows, err := p.db.QueryContext(ctx, `
+		SELECT ply, uci, san, played_at, clock_ms, spent_ms
+		FROM moves WHERE game_id = $1 ORDER BY ply`, gameID)
+	if err != nil {
+		return nil, translate(err, "game "+gameID)
+	}
+	defer rows.Close()
+
+	moves := []Move{}
+	for rows.Next() {
+		var m Move
+		var clockMs, spentMs int64
+		if err := rows.Scan(&m.Ply, &m.UCI, &m.SAN, &m.PlayedAt, &clockMs, &spentMs); err != nil {
+			return nil, err
+		}
+		m.Clock = time.Duration(clockMs) * time.Millisecond
+		m.Spent = time.Duration(spentMs) * time.Millisecond
+		moves = append(moves, m)
+	}
+	return moves, rows.Err()
+}
+
+func (p *Postgres) SaveRating(ctx context.Context, r Rating) error {
+	_, err := p.db.ExecContext(ctx, `
+		INSERT INTO ratings (user_id, pool, rating, deviation, volatility, games, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (user_id, pool) DO UPDATE SET
+			rating = EXCLUDED.rating,
+			deviation = EXCLUDED.deviation,
+			volatility = EXCLUDED.volatility,
+			games = EXCLUDED.games,
+			updated_at = EXCLUDED.updated_at`,
+		r.UserID, r.Pool, r.Rating, r.Deviation, r.Volatility, r.Games, r.UpdatedAt)
+	return translate(err, "rating "+r.UserID+"/"+r.Pool)
+}
+
+func (p *Postgres) Rating(ctx context.Context, userID, pool string) (Rating, error) {
+	var r Rating
+	err := p.db.QueryRowContext(ctx, `
+		SELECT user_id, pool, rating, deviation, volatility, games, updated_at
+		FROM ratings WHERE user_id = $1 AND pool = $2`, userID, pool,
+	).Scan(&r.UserID, &r.Pool, &r.Rating, &r.Deviation, &r.Volatility, &r.Games, &r.UpdatedAt)
+	return r, translate(err, "rating "+userID+"/"+pool)
+}
+
+func (p *Postgres) AppendRatingHistory(ctx context.Context, c RatingChange) error {
+	_, err := p.db.ExecContext(ctx, `
+		INSERT INTO rating_history (user_id, pool, game_id, rating, deviation, volatility, at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		c.UserID, c.Pool, c.GameID, c.Rating, c.Deviation, c.Volatility, c.At)
+	return translate(err, fmt.Sprintf("rating change %s/%s for game %s", c.UserID, c.Pool, c.GameID))
+}
+
+func (p *Postgres) RatingHistory(ctx context.Context, userID, pool string) ([]RatingChange, error) {
+	rows, err := p.db.QueryContext(ctx, `
+		SELECT game_id, rating, deviation, volatility, at FROM rating_history
+		WHERE user_id = $1 AND pool = $2 ORDER BY at, game_id`, userID, pool)
+	if err != nil {
+		return nil, translate(err, "rating history "+userID+"/"+pool)
+	}
+	defer rows.Close()
+
+	changes := []RatingChange{}
+	for rows.Next() {
+		c := RatingChange{UserID: userID, Pool: pool}
+		if err := rows.Scan(&c.GameID, &c.Rating, &c.Deviation, &c.Volatility, &c.At); err != nil {
+			return nil, err
+		}
+		changes = append(changes, c)
+	}
+	return changes, rows.Err()
+}
+
+func (p *Postgres) Append(ctx context.Context, gameID string, expected int, entries ...LogEntry) error {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var head int
+	if err := tx.QueryRowContext(ctx,
+		`SELECT coalesce(max(version), 0) FROM game_events WHERE game_id = $1`, gameID,
+	).Scan(&head); err != nil {
+		return translate(err, "game "+gameID)
+	}
+	if head != expected {
+		return fmt.Errorf("game %s at version %d, not %d: %w", gameID, head, expected, ErrVersionConflict)
+	}
+	for i, e := range entries {
+		_, err := tx.ExecContext(ctx,
+			`INSERT INTO game_events (game_id, version, kind, at, data) VALUES ($1, $2, $3, $4, $5)`,
+			gameID, expected+i+1, e.Kind, e.At, e.Data)
+		if err != nil {
+			// A concurrent writer took the version between our read and insert.
+			if errors.Is(translate(err, ""), ErrDuplicate) {
+				return fmt.Errorf("game %s version %d: %w", gameID, expected+i+1, ErrVersionConflict)
+			}
+			return translate(err, "game "+gameID)
+		}
+	}
+	return tx.Commit()
+}
+
+func (p *Postgres) Entries(ctx context.Context, gameID string, after int) ([]LogEntry, error) {
+	rows, err := p.db.QueryContext(ctx, `
+		SELECT version, kind, at, data FROM game_events
+		WHERE game_id = $1 AND version > $2 ORDER BY version`, gameID, after)
+	if err != nil {
+		return nil, translate(err, "game "+gameID)
+	}
+	defer rows.Close()
+
+	entries := []LogEntry{}
+	for rows.Next() {
+		e := LogEntry{GameID: gameID}
+		if err := rows.Scan(&e.Version, &e.Kind, &e.At, &e.Data); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+func (p *Postgres) SaveSnapshot(ctx context.Context, s Snapshot) error {
+	_, err := p.db.ExecContext(ctx, `
+		INSERT INTO game_snapshots (game_id, version, ply, fen, at) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (game_id, version) DO NOTHING`,
+		s.GameID, s.Version, s.Ply, s.FEN, s.At)
+	return translate(err, "snapshot of game "+s.GameID)
+}
+
+func (p *Postgres) LatestSnapshot(ctx context.Context, gameID string) (Snapshot, error) {
+	s := Snapshot{GameID: gameID}
+	err := p.db.QueryRowContext(ctx, `
+		SELECT version, ply, fen, at FROM game_snapshots
+		WHERE game_id = $1 ORDER BY version DESC LIMIT 1`, gameID,
+	).Scan(&s.Version, &s.Ply, &s.FEN, &s.At)
+	return s, translate(err, "snapshot of game "+gameID)
+}
+
+func (p *Postgres) CreateAccount(ctx context.Context, u User, c Credentials) error {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO users (id, name, bot, created_at) VALUES ($1, $2, $3, $4)`,
+		u.ID, u.Name, u.Bot, u.CreatedAt); err != nil {
+		return translate(err, "user "+u.ID)
+	}
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO credentials (user_id, email, password_hash, updated_at)
+		VALUES ($1, $2, $3, $4)`,
+		c.UserID, nullString(c.Email), c.PasswordHash, c.UpdatedAt); err != nil {
+		return translate(err, "credentials of "+c.UserID)
+	}
+	return tx.Commit()
+}
+
+func (p *Postgres) SaveCredentials(ctx context.Context, c Credentials) error {
+	_, err := p.db.ExecContext(ctx, `
+		INSERT INTO credentials (user_id, email, password_hash, updated_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id) DO UPDATE SET
+			email = EXCLUDED.email,
+			password_hash = EXCLUDED.password_hash,
+			updated_at = EXCLUDED.updated_at`,
+		c.UserID, nullString(c.Email), c.PasswordHash, c.UpdatedAt)
+	return translate(err, "credentials of "+c.UserID)
+}
+
+func scanCredentials(row *sql.Row, what string) (Credentials, error) {
+	var (
+		c     Credentials
+		email sql.NullString
+	)
+	err := row.Scan(&c.UserID, &email, &c.PasswordHash, &c.UpdatedAt)
+	c.Email = email.String
+	return c, translate(err, what)
+}
+
+func (p *Postgres) Credentials(ctx context.Context, userID string) (Credentials, error) {
+	return scanCredentials(p.db.QueryRowContext(ctx, `
+		SELECT user_id, email, password_hash, updated_at FROM credentials
+		WHERE user_id = $1`, userID), "credentials of "+userID)
+}
+
+func (p *Postgres) CredentialsByEmail(ctx context.Context, email string) (Credentials, error) {
+	return scanCredentials(p.db.QueryRowContext(ctx, `
+		SELECT user_id, email, password_hash, updated_at FROM credentials
+		WHERE email = $1`, email), "email "+email)
+}
+
+func (p *Postgres) CreateSession(ctx context.Context, s Session) error {
+	_, err := p.db.ExecContext(ctx, `
+		INSERT INTO sessions (id, token_hash, user_id, device, created_at, last_seen, expires_at, revoked_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		s.ID, s.TokenHash, s.UserID, s.Device, s.CreatedAt, s.LastSeen, s.ExpiresAt, nullTime(s.RevokedAt))
+	return translate(err, "session "+s.ID)
+}
+
+const sessionColumns = `id, token_hash, user_id, device, created_at, last_seen, expires_at, revoked_at`
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanSession(row scanner) (Session, error) {
+	var (
+		s       Session
+		revoked sql.NullTime
+	)
+	err := row.Scan(&s.ID, &s.TokenHash, &s.UserID, &s.Device, &s.CreatedAt, &s.LastSeen, &s.ExpiresAt, &revoked)
+	s.RevokedAt = revoked.Time
+	return s, err
+}
+
+func (p *Postgres) SessionByToken(ctx context.Context, tokenHash string) (Session, error) {
+	s, err := scanSession(p.db.QueryRowContext(ctx,
+		`SELECT `+sessionColumns+` FROM sessions WHERE token_hash = $1`, tokenHash))
+	return s, translate(err, "session")
+}
+
+func (p *Postgres) Sessions(ctx context.Context, userID string, now time.Time) ([]Session, error) {
+	rows, err := p.db.QueryContext(ctx, `
+		SELECT `+sessionColumns+` FROM sessions
+		WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > $2
+		ORDER BY created_at DESC`, userID, now)
+	if err != nil {
+		return nil, translate(err, "sessions of "+userID)
+	}
+	defer rows.Close()
+
+	sessions := []Session{}
+	for rows.Next() {
+		s, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+	return sessions, rows.Err()
+}
+
+func (p *Postgres) TouchSession(ctx context.Context, id string, at time.Time) error {
+	res, err := p.db.ExecContext(ctx, `UPDATE sessions SET last_seen = $2 WHERE id = $1`, id, at)
+	if err != nil {
+		return translate(err, "session "+id)
+	}
+	return affected(res, "session "+id)
+}
+
+func (p *Postgres) RevokeSession(ctx context.Context, userID, id string, at time.Time) error {
+	res, err := p.db.ExecContext(ctx, `
+		UPDATE sessions SET revoked_at = $3
+		WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL`, id, userID, at)
+	if err != nil {
+		return translate(err, "session "+id)
+	}
+	return affected(res, "session "+id)
+}
+
+func (p *Postgres) RevokeSessions(ctx context.Context, userID string, at time.Time) error {
+	_, err := p.db.ExecContext(ctx, `
+		UPDATE sessions SET revoked_at = $2
+		WHERE user_id = $1 AND revoked_at IS NULL`, userID, at)
+	return translate(err, "sessions of "+userID)
+}
+
+func (p *Postgres) CreatePasswordReset(ctx context.Context, r PasswordReset) error {
+	_, err := p.db.ExecContext(ctx, `
+		INSERT INTO password_resets (token_hash, user_id, created_at, expires_at)
+		VALUES ($1, $2, $3, $4)`,
+		r.TokenHash, r.UserID, r.CreatedAt, r.ExpiresAt)
+	return translate(err, "password reset of "+r.UserID)
+}
+
+func (p *Postgres) UsePasswordReset(ctx context.Context, tokenHash string, at time.Time) (PasswordReset, error) {
+	r := PasswordReset{TokenHash: tokenHash, UsedAt: at}
+	err := p.db.QueryRowContext(ctx, `
+		UPDATE password_resets SET used_at = $2
+		WHERE token_hash = $1 AND used_at IS NULL
+		RETURNING user_id, created_at, expires_at`, tokenHash, at,
+	).Scan(&r.UserID, &r.CreatedAt, &r.ExpiresAt)
+	return r, translate(err, "password reset")
+}
+
+func (p *Postgres) CreateAPIToken(ctx context.Context, t APIToken) error {
+	_, err := p.db.ExecContext(ctx, `
+		INSERT INTO api_tokens (id, token_hash, user_id, description, scopes, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)`,
+		t.ID, t.TokenHash, t.UserID, t.Description, strings.Join(t.Scopes, " "), t.CreatedAt)
+	return translate(err, "api token "+t.ID)
+}
+
+const apiTokenColumns = `id, token_hash, user_id, description, scopes, created_at, last_used, revoked_at`
+
+func scanAPIToken(row scanner) (APIToken, error) {
+	var (
+		t                 APIToken
+		scopes            string
+		lastUsed, revoked sql.NullTime
+	)
+	err := row.Scan(&t.ID, &t.TokenHash, &t.UserID, &t.Description, &scopes, &t.CreatedAt, &lastUsed, &revoked)
+	t.Scopes = strings.Fields(scopes)
+	t.LastUsed, t.RevokedAt = lastUsed.Time, revoked.Time
+	return t, err
+}
+
+func (p *Postgres) APITokenByHash(ctx context.Context, tokenHash string) (APIToken, error) {
+	t, err := scanAPIToken(p.db.QueryRowContext(ctx,
+		`SELECT `+apiTokenColumns+` FROM api_tokens WHERE token_hash = $1`, tokenHash))
+	return t, translate(err, "api token")
+}
+
+func (p *Postgres) APITokens(ctx context.Context, userID string) ([]APIToken, error) {
+	rows, err := p.db.QueryContext(ctx, `
+		SELECT `+apiTokenColumns+` FROM api_tokens
+		WHERE user_id = $1 AND revoked_at IS NULL
+		ORDER BY created_at DESC`, userID)
+	if err != nil {
+		return nil, translate(err, "api tokens of "+userID)
+	}
+	defer rows.Close()
+
+	tokens := []APIToken{}
+	for rows.Next() {
+		t, err := scanAPIToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, t)
+	}
+	return tokens, rows.Err()
+}
+
+func (p *Postgres) TouchAPIToken(ctx context.Context, id string, at time.Time) error {
+	res, err := p.db.ExecContext(ctx, `UPDATE api_tokens SET last_used = $2 WHERE id = $1`, id, at)
+	if err != nil {
+		return translate(err, "api token "+id)
+	}
+	return affected(res, "api token "+id)
+}
+
+func (p *Postgres) RevokeAPIToken(ctx context.Context, userID, id string, at time.Time) error {
+	res, err := p.db.ExecContext(ctx, `
+		UPDATE api_tokens SET revoked_at = $3
+		WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL`, id, userID, at)
+	if err != nil {
+		return translate(err, "api token "+id)
+	}
+	return affected(res, "api token "+id)
+}
+
+// affected reports ErrNotFound when an update matched no rows.
+func affected(res sql.Result, what string) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("%s: %w", what, ErrNotFound)
+	}
+	return nil
+}
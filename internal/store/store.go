@@ -0,0 +1,97 @@
+// Package store persists users, games, moves and ratings.
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	ErrNotFound  = errors.New("not found")
+	ErrDuplicate = errors.New("already exists")
+)
+
+// User is a player. Bot marks accounts played by an engine through the bot
+// API; once set it is never cleared.
+type User struct {
+	ID        string
+	Name      string
+	Bot       bool
+	CreatedAt time.Time
+}
+
+// Game is the stored header of a game. WhiteID and BlackID are empty for
+// sides not played by a registered user; EndedAt is zero while it is going.
+type Game struct {
+	ID          string
+	WhiteID     string
+	BlackID     string
+	InitialFEN  string
+	TimeControl string
+	Rated       bool
+	Result      string
+	Termination string
+	DrawReason  string
+	CreatedAt   time.Time
+	EndedAt     time.Time
+}
+
+// Move is one ply of a stored game. Clock is what the mover had left after
+// the move, increment included, and Spent how long they took; both are zero
+// in untimed games.
+type Move struct {
+	Ply      int
+	UCI      string
+	SAN      string
+	PlayedAt time.Time
+	Clock    time.Duration
+	Spent    time.Duration
+}
+
+// Rating is a player's Glicko-2 rating in one pool, such as "blitz".
+type Rating struct {
+	UserID     string
+	Pool       string
+	Rating     float64
+	Deviation  float64
+	Volatility float64
+	Games      int
+	UpdatedAt  time.Time
+}
+
+// RatingChange is a player's rating in a pool right after a rated game.
+type RatingChange struct {
+	UserID     string
+	Pool       string
+	GameID     string
+	Rating     float64
+	Deviation  float64
+	Volatility float64
+	At         time.Time
+}
+
+type Repository interface {
+	CreateUser(ctx context.Context, u User) error
+	User(ctx context.Context, id string) (User, error)
+	UserByName(ctx context.Context, name string) (User, error)
+	// MarkBot flags a user as a bot.
+	MarkBot(ctx context.Context, id string) error
+
+	// SaveGame inserts the game or updates its outcome.
+	SaveGame(ctx context.Context, g Game) error
+	Game(ctx context.Context, id string) (Game, error)
+	// AppendMove stores m, which must be the next ply of the game.
+	AppendMove(ctx context.Context, gameID string, m Move) error
+	// TruncateMoves deletes every move after ply, for takebacks.
+	TruncateMoves(ctx context.Context, gameID string, ply int) error
+	Moves(ctx context.Context, gameID string) ([]Move, error)
+
+	SaveRating(ctx context.Context, r Rating) error
+	Rating(ctx context.Context, userID, pool string) (Rating, error)
+	// AppendRatingHistory records c; a second change for the same game is
+	// ErrDuplicate, so a game is never rated twice.
+	AppendRatingHistory(ctx context.Context, c RatingChange) error
+	// RatingHistory lists a player's changes in a pool, oldest first.
+	RatingHistory(ctx context.Context, userID, pool string) ([]RatingChange, error)
+}
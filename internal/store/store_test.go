@@ -0,0 +1,314 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+// testRepository checks the behaviour every Repository must share.
+func testRepository(t *testing.T, repo Repository) {
+	ctx := context.Background()
+	now := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	suffix := now.Format("150405.000000000") + t.Name()
+
+	alice := User{ID: "u-alice-" + suffix, Name: "alice-" + suffix, CreatedAt: now}
+	if err := repo.CreateUser(ctx, alice); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.CreateUser(ctx, User{ID: "u-other-" + suffix, Name: alice.Name, CreatedAt: now}); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("duplicate name: err = %v, want ErrDuplicate", err)
+	}
+	if got, err := repo.UserByName(ctx, alice.Name); err != nil || got.ID != alice.ID {
+		t.Errorf("UserByName = %+v, %v", got, err)
+	}
+	if _, err := repo.User(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("missing user: err = %v, want ErrNotFound", err)
+	}
+	if err := repo.MarkBot(ctx, alice.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := repo.User(ctx, alice.ID); err != nil || !got.Bot {
+		t.Errorf("User after MarkBot = %+v, %v", got, err)
+	}
+	if err := repo.MarkBot(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("MarkBot of missing user: err = %v, want ErrNotFound", err)
+	}
+
+	g := Game{
+		ID:          "g-" + suffix,
+		WhiteID:     alice.ID,
+		InitialFEN:  "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1",
+		TimeControl: "180+2",
+		Result:      "*",
+		CreatedAt:   now,
+	}
+	if err := repo.SaveGame(ctx, g); err != nil {
+		t.Fatal(err)
+	}
+	for i, uci := range []string{"e2e4", "e7e5", "g1f3"} {
+		m := Move{Ply: i + 1, UCI: uci, SAN: uci, PlayedAt: now, Clock: 181 * time.Second, Spent: time.Second}
+		if err := repo.AppendMove(ctx, g.ID, m); err != nil {
+			t.Fatalf("append %s: %v", uci, err)
+		}
+	}
+	if err := repo.AppendMove(ctx, g.ID, Move{Ply: 2, UCI: "d7d5", PlayedAt: now}); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("replayed ply: err = %v, want ErrDuplicate", err)
+	}
+	if err := repo.TruncateMoves(ctx, g.ID, 2); err != nil {
+		t.Fatal(err)
+	}
+	moves, err := repo.Moves(ctx, g.ID)
+	if err != nil || len(moves) != 2 || moves[1].UCI != "e7e5" || moves[0].Clock != 181*time.Second {
+		t.Errorf("moves after takeback = %+v, %v", moves, err)
+	}
+
+	g.Result, g.Termination, g.EndedAt = "1-0", "resignation", now.Add(time.Minute)
+	if err := repo.SaveGame(ctx, g); err != nil {
+		t.Fatal(err)
+	}
+	got, err := repo.Game(ctx, g.ID)
+	if err != nil || got.Result != "1-0" || got.BlackID != "" || !got.EndedAt.Equal(g.EndedAt) {
+		t.Errorf("game = %+v, %v", got, err)
+	}
+	// Players need not have accounts, as in imported tournaments.
+	guests := Game{ID: "g-guests-" + suffix, WhiteID: "guest-w-" + suffix, BlackID: "guest-b-" + suffix, InitialFEN: g.InitialFEN, Result: "*", CreatedAt: now}
+	if err := repo.SaveGame(ctx, guests); err != nil {
+		t.Errorf("game between players without accounts: %v", err)
+	}
+
+	r := Rating{UserID: alice.ID, Pool: "blitz", Rating: 1500, Deviation: 350, Volatility: 0.06, UpdatedAt: now}
+	if err := repo.SaveRating(ctx, r); err != nil {
+		t.Fatal(err)
+	}
+	r.Rating, r.Games = 1520, 1
+	repo.SaveRating(ctx, r)
+	if got, err := repo.Rating(ctx, alice.ID, "blitz"); err != nil || got.Rating != 1520 || got.Games != 1 {
+		t.Errorf("rating = %+v, %v", got, err)
+	}
+	if _, err := repo.Rating(ctx, alice.ID, "bullet"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("missing rating: err = %v, want ErrNotFound", err)
+	}
+
+	change := RatingChange{UserID: alice.ID, Pool: "blitz", GameID: g.ID, Rating: 1520, Deviation: 300, Volatility: 0.06, At: now}
+	if err := repo.AppendRatingHistory(ctx, change); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.AppendRatingHistory(ctx, change); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("rating a game twice: err = %v, want ErrDuplicate", err)
+	}
+	if h, err := repo.RatingHistory(ctx, alice.ID, "blitz"); err != nil || len(h) != 1 || h[0].Rating != 1520 {
+		t.Errorf("rating history = %+v, %v", h, err)
+	}
+}
+
+// testEventLog checks the behaviour every EventLog must share.
+func testEventLog(t *testing.T, log EventLog) {
+	ctx := context.Background()
+	now := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	id := "g-log-" + time.Now().Format("150405.000000000")
+
+	first := []LogEntry{
+		{Kind: "game_created", At: now, Data: []byte(`{"kind":"game_created"}`)},
+		{Kind: "move_made", At: now, Data: []byte(`{"kind":"move_made","uci":"e2e4"}`)},
+	}
+	if err := log.Append(ctx, id, 0, first...); err != nil {
+		t.Fatal(err)
+	}
+	if err := log.Append(ctx, id, 1, LogEntry{Kind: "move_made", At: now, Data: []byte(`{}`)}); !errors.Is(err, ErrVersionConflict) {
+		t.Errorf("stale append: err = %v, want ErrVersionConflict", err)
+	}
+	if err := log.Append(ctx, id, 2, LogEntry{Kind: "move_made", At: now, Data: []byte(`{"kind":"move_made","uci":"e7e5"}`)}); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := log.Entries(ctx, id, 1)
+	if err != nil || len(entries) != 2 || entries[0].Version != 2 || entries[1].Version != 3 || entries[0].Kind != "move_made" {
+		t.Errorf("entries after 1 = %+v, %v", entries, err)
+	}
+
+	if _, err := log.LatestSnapshot(ctx, id); !errors.Is(err, ErrNotFound) {
+		t.Errorf("no snapshot: err = %v, want ErrNotFound", err)
+	}
+	log.SaveSnapshot(ctx, Snapshot{GameID: id, Version: 2, Ply: 1, FEN: "a", At: now})
+	log.SaveSnapshot(ctx, Snapshot{GameID: id, Version: 3, Ply: 2, FEN: "b", At: now})
+	if s, err := log.LatestSnapshot(ctx, id); err != nil || s.Version != 3 || s.FEN != "b" {
+		t.Errorf("latest snapshot = %+v, %v", s, err)
+	}
+}
+
+// testAccounts checks the behaviour every Accounts must share.
+func testAccounts(t *testing.T, repo Repository, acc Accounts) {
+	ctx := context.Background()
+	now := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	suffix := time.Now().Format("150405.000000000") + t.Name()
+
+	alice := User{ID: "u-acc-alice-" + suffix, Name: "acc-alice-" + suffix, CreatedAt: now}
+	bob := User{ID: "u-acc-bob-" + suffix, Name: "acc-bob-" + suffix, CreatedAt: now}
+	for _, u := range []User{alice, bob} {
+		if err := repo.CreateUser(ctx, u); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	email := "alice-" + suffix + "@example.com"
+	if err := acc.SaveCredentials(ctx, Credentials{UserID: alice.ID, Email: email, PasswordHash: "h1", UpdatedAt: now}); err != nil {
+		t.Fatal(err)
+	}
+	if err := acc.SaveCredentials(ctx, Credentials{UserID: bob.ID, Email: email, PasswordHash: "h2", UpdatedAt: now}); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("taken email: err = %v, want ErrDuplicate", err)
+	}
+	if err := acc.SaveCredentials(ctx, Credentials{UserID: bob.ID, PasswordHash: "h2", UpdatedAt: now}); err != nil {
+		t.Errorf("no email: %v", err)
+	}
+	if err := acc.SaveCredentials(ctx, Credentials{UserID: alice.ID, Email: email, PasswordHash: "h3", UpdatedAt: now}); err != nil {
+		t.Fatal(err)
+	}
+	if c, err := acc.CredentialsByEmail(ctx, email); err != nil || c.UserID != alice.ID || c.PasswordHash != "h3" {
+		t.Errorf("CredentialsByEmail = %+v, %v", c, err)
+	}
+	if c, err := acc.Credentials(ctx, bob.ID); err != nil || c.Email != "" {
+		t.Errorf("Credentials = %+v, %v", c, err)
+	}
+
+	// An account is created whole or not at all.
+	carol := User{ID: "u-acc-carol-" + suffix, Name: "acc-carol-" + suffix, CreatedAt: now}
+	carolEmail := "carol-" + suffix + "@example.com"
+	if err := acc.CreateAccount(ctx, carol, Credentials{UserID: carol.ID, Email: email, PasswordHash: "h4", UpdatedAt: now}); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("account with a taken email: err = %v, want ErrDuplicate", err)
+	}
+	if _, err := repo.User(ctx, carol.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("user left behind by a failed account: err = %v", err)
+	}
+	taken := User{ID: carol.ID, Name: alice.Name, CreatedAt: now}
+	if err := acc.CreateAccount(ctx, taken, Credentials{UserID: carol.ID, Email: carolEmail, PasswordHash: "h4", UpdatedAt: now}); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("account with a taken name: err = %v, want ErrDuplicate", err)
+	}
+	if _, err := acc.CredentialsByEmail(ctx, carolEmail); !errors.Is(err, ErrNotFound) {
+		t.Errorf("credentials left behind by a failed account: err = %v", err)
+	}
+	if err := acc.CreateAccount(ctx, carol, Credentials{UserID: carol.ID, Email: carolEmail, PasswordHash: "h4", UpdatedAt: now}); err != nil {
+		t.Fatal(err)
+	}
+	if c, err := acc.CredentialsByEmail(ctx, carolEmail); err != nil || c.UserID != carol.ID {
+		t.Errorf("CredentialsByEmail after CreateAccount = %+v, %v", c, err)
+	}
+
+	day := 24 * time.Hour
+	phone := Session{ID: "s-phone-" + suffix, TokenHash: "t-phone-" + suffix, UserID: alice.ID, Device: "phone",
+		CreatedAt: now, LastSeen: now, ExpiresAt: now.Add(day)}
+	laptop := Session{ID: "s-laptop-" + suffix, TokenHash: "t-laptop-" + suffix, UserID: alice.ID, Device: "laptop",
+		CreatedAt: now.Add(time.Hour), LastSeen: now.Add(time.Hour), ExpiresAt: now.Add(2 * day)}
+	for _, s := range []Session{phone, laptop} {
+		if err := acc.CreateSession(ctx, s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := acc.TouchSession(ctx, phone.ID, now.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if s, err := acc.SessionByToken(ctx, phone.TokenHash); err != nil || s.ID != phone.ID || !s.LastSeen.Equal(now.Add(time.Minute)) || !s.RevokedAt.IsZero() {
+		t.Errorf("SessionByToken = %+v, %v", s, err)
+	}
+	if ss, err := acc.Sessions(ctx, alice.ID, now); err != nil || len(ss) != 2 || ss[0].ID != laptop.ID {
+		t.Errorf("sessions = %+v, %v", ss, err)
+	}
+	if ss, err := acc.Sessions(ctx, alice.ID, now.Add(day)); err != nil || len(ss) != 1 {
+		t.Errorf("sessions after phone expired = %+v, %v", ss, err)
+	}
+	if err := acc.RevokeSession(ctx, bob.ID, laptop.ID, now); !errors.Is(err, ErrNotFound) {
+		t.Errorf("revoking another user's session: err = %v, want ErrNotFound", err)
+	}
+	if err := acc.RevokeSession(ctx, alice.ID, laptop.ID, now); err != nil {
+		t.Fatal(err)
+	}
+	if err := acc.RevokeSession(ctx, alice.ID, laptop.ID, now); !errors.Is(err, ErrNotFound) {
+		t.Errorf("revoking twice: err = %v, want ErrNotFound", err)
+	}
+	if ss, _ := acc.Sessions(ctx, alice.ID, now); len(ss) != 1 || ss[0].ID != phone.ID {
+		t.Errorf("sessions after revoke = %+v", ss)
+	}
+	if err := acc.RevokeSessions(ctx, alice.ID, now); err != nil {
+		t.Fatal(err)
+	}
+	if s, _ := acc.SessionByToken(ctx, phone.TokenHash); s.RevokedAt.IsZero() {
+		t.Errorf("phone session not revoked: %+v", s)
+	}
+
+	reset := PasswordReset{TokenHash: "r-" + suffix, UserID: alice.ID, CreatedAt: now, ExpiresAt: now.Add(time.Hour)}
+	if err := acc.CreatePasswordReset(ctx, reset); err != nil {
+		t.Fatal(err)
+	}
+	if r, err := acc.UsePasswordReset(ctx, reset.TokenHash, now); err != nil || r.UserID != alice.ID || !r.ExpiresAt.Equal(reset.ExpiresAt) {
+		t.Errorf("UsePasswordReset = %+v, %v", r, err)
+	}
+	if _, err := acc.UsePasswordReset(ctx, reset.TokenHash, now); !errors.Is(err, ErrNotFound) {
+		t.Errorf("reusing reset: err = %v, want ErrNotFound", err)
+	}
+
+	bot := APIToken{ID: "k-bot-" + suffix, TokenHash: "kh-bot-" + suffix, UserID: alice.ID, Description: "bot",
+		Scopes: []string{"games:read", "games:play"}, CreatedAt: now}
+	script := APIToken{ID: "k-script-" + suffix, TokenHash: "kh-script-" + suffix, UserID: alice.ID,
+		Scopes: []string{"games:read"}, CreatedAt: now.Add(time.Hour)}
+	for _, k := range []APIToken{bot, script} {
+		if err := acc.CreateAPIToken(ctx, k); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := acc.TouchAPIToken(ctx, bot.ID, now.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if k, err := acc.APITokenByHash(ctx, bot.TokenHash); err != nil || k.ID != bot.ID || len(k.Scopes) != 2 || k.Scopes[1] != "games:play" || !k.LastUsed.Equal(now.Add(time.Minute)) {
+		t.Errorf("APITokenByHash = %+v, %v", k, err)
+	}
+	if err := acc.RevokeAPIToken(ctx, bob.ID, script.ID, now); !errors.Is(err, ErrNotFound) {
+		t.Errorf("revoking another user's token: err = %v, want ErrNotFound", err)
+	}
+	if err := acc.RevokeAPIToken(ctx, alice.ID, script.ID, now); err != nil {
+		t.Fatal(err)
+	}
+	if ks, err := acc.APITokens(ctx, alice.ID); err != nil || len(ks) != 1 || ks[0].ID != bot.ID {
+		t.Errorf("tokens after revoke = %+v, %v", ks, err)
+	}
+}
+
+func TestMemory(t *testing.T) {
+	testRepository(t, NewMemory())
+	testEventLog(t, NewMemory())
+	m := NewMemory()
+	testAccounts(t, m, m)
+}
+
+// TestPostgres runs against the database in CHESS_TEST_DATABASE_URL, for
+// example the one from docker-compose.yml.
+func TestPostgres(t *testing.T) {
+	dsn := os.Getenv("CHESS_TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("CHESS_TEST_DATABASE_URL not set")
+	}
+	pg, err := OpenPostgres(context.Background(), dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pg.Close()
+	testRepository(t, pg)
+	testEventLog(t, pg)
+	testAccounts(t, pg, pg)
+}
+
+func TestMigrationsOrdered(t *testing.T) {
+	ms, err := migrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ms) == 0 || ms[0].version != 1 {
+		t.Fatalf("migrations = %+v, want to start at version 1", ms)
+	}
+	for i := 1; i < len(ms); i++ {
+		if ms[i].version <= ms[i-1].version {
+			t.Errorf("%s after %s", ms[i].name, ms[i-1].name)
+		}
+	}
+}
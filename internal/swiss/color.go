@@ -0,0 +1,110 @@
+package swiss
+
+import "github.com/THECHAMP95821/chess-backend/internal/chess"
+
+// Strengths of a color preference, weakest first.
+const (
+	noPreference = iota
+	mild
+	strong
+	absolute
+)
+
+type preference struct {
+	color    chess.Color
+	strength int
+}
+
+// difference is the number of games played with White minus those played
+// with Black.
+func (r *record) difference() int {
+	d := 0
+	for _, c := range r.colors {
+		if c == chess.ColorWhite {
+			d++
+		} else {
+			d--
+		}
+	}
+	return d
+}
+
+// preference follows article A.6 of the Dutch system: absolute when the
+// color difference is beyond one or the last two games had the same color,
+// strong when it is one, and otherwise mild towards alternating.
+func (r *record) preference() preference {
+	n := len(r.colors)
+	if n == 0 {
+		return preference{}
+	}
+	d := r.difference()
+	last := r.colors[n-1]
+	switch {
+	case d > 1 || (n >= 2 && last == chess.ColorWhite && r.colors[n-2] == chess.ColorWhite):
+		return preference{chess.ColorBlack, absolute}
+	case d < -1 || (n >= 2 && last == chess.ColorBlack && r.colors[n-2] == chess.ColorBlack):
+		return preference{chess.ColorWhite, absolute}
+	case d == 1:
+		return preference{chess.ColorBlack, strong}
+	case d == -1:
+		return preference{chess.ColorWhite, strong}
+	}
+	return preference{last.Opponent(), mild}
+}
+
+// colorClash reports whether two players want the same color, and so one
+// of them will be disappointed.
+func colorClash(a, b *record) bool {
+	pa, pb := a.pref, b.pref
+	return pa.strength != noPreference && pb.strength != noPreference && pa.color == pb.color
+}
+
+// allocate gives colors to a and b, with a the higher ranked, following
+// article E of the Dutch system. first is the color a gets when neither
+// has any history, as on the first round.
+func allocate(a, b *record, first chess.Color) (white, black *record) {
+	pa, pb := a.pref, b.pref
+	give := func(c chess.Color) (*record, *record) {
+		if c == chess.ColorWhite {
+			return a, b
+		}
+		return b, a
+	}
+	switch {
+	case pa.strength == noPreference && pb.strength == noPreference:
+		return give(first)
+	case pb.strength == noPreference:
+		return give(pa.color)
+	case pa.strength == noPreference:
+		return give(pb.color.Opponent())
+	case pa.color != pb.color:
+		return give(pa.color)
+	case pa.strength != pb.strength:
+		if pa.strength > pb.strength {
+			return give(pa.color)
+		}
+		return give(pb.color.Opponent())
+	case pa.strength == absolute:
+		da, db := abs(a.difference()), abs(b.difference())
+		if da != db {
+			if da > db {
+				return give(pa.color)
+			}
+			return give(pb.color.Opponent())
+		}
+	}
+	// Alternate from the latest round in which they had different colors.
+	for i, j := len(a.colors)-1, len(b.colors)-1; i >= 0 && j >= 0; i, j = i-1, j-1 {
+		if a.colors[i] != b.colors[j] {
+			return give(a.colors[i].Opponent())
+		}
+	}
+	return give(pa.color)
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,252 @@
+package swiss
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+)
+
+var (
+	ErrNoPairing     = errors.New("no valid pairing for the round")
+	ErrUnknownPlayer = errors.New("unknown player")
+)
+
+// searchBudget bounds the search for a pairing. If the best pairing is
+// not found within it, the search starts over ignoring color preferences
+// and repeated floats, which is much faster.
+const searchBudget = 200_000
+
+type pairer struct {
+	final   bool
+	played  int
+	quality bool
+	steps   int
+}
+
+func (pr *pairer) exhausted() bool {
+	return pr.steps > searchBudget
+}
+
+func (pr *pairer) topscorer(r *record) bool {
+	return r.score > float64(pr.played)/2
+}
+
+// compatible applies the absolute criteria: no two players meet twice, and
+// two players who must both have the same color do not meet, except for
+// topscorers in the final round.
+func (pr *pairer) compatible(a, b *record) bool {
+	if a.opponents[b.id] {
+		return false
+	}
+	if a.pref.strength == absolute && b.pref.strength == absolute && a.pref.color == b.pref.color {
+		return pr.final && (pr.topscorer(a) || pr.topscorer(b))
+	}
+	return true
+}
+
+// Pair pairs the next round among the active players. The bye, if any,
+// goes to the lowest ranked player who has not had one yet and whose
+// removal leaves the others pairable.
+func Pair(h History, active []string) (Round, error) {
+	recs := h.records()
+	players := make([]*record, 0, len(active))
+	for _, id := range active {
+		r, ok := recs[id]
+		if !ok {
+			return Round{}, fmt.Errorf("%w: %s", ErrUnknownPlayer, id)
+		}
+		r.pref = r.preference()
+		players = append(players, r)
+	}
+	ranked(players)
+
+	for _, quality := range []bool{true, false} {
+		pr := &pairer{
+			final:   h.TotalRounds > 0 && len(h.Rounds)+1 >= h.TotalRounds,
+			played:  len(h.Rounds),
+			quality: quality,
+		}
+		pairs, bye, ok := pr.run(players)
+		if ok {
+			return h.round(players, pairs, bye), nil
+		}
+		if !pr.exhausted() {
+			break
+		}
+	}
+	return Round{}, ErrNoPairing
+}
+
+func (pr *pairer) run(players []*record) ([][2]*record, *record, bool) {
+	if len(players)%2 == 0 {
+		pairs, ok := pr.brackets(players)
+		return pairs, nil, ok
+	}
+	for i := len(players) - 1; i >= 0 && !pr.exhausted(); i-- {
+		if players[i].hadBye {
+			continue
+		}
+		rest := slices.Delete(slices.Clone(players), i, i+1)
+		if pairs, ok := pr.brackets(rest); ok {
+			return pairs, players[i], true
+		}
+	}
+	return nil, nil, false
+}
+
+// brackets splits ranked players into score groups and pairs them from the
+// top down.
+func (pr *pairer) brackets(players []*record) ([][2]*record, bool) {
+	var groups [][]*record
+	for _, r := range players {
+		if n := len(groups); n > 0 && groups[n-1][0].score == r.score {
+			groups[n-1] = append(groups[n-1], r)
+		} else {
+			groups = append(groups, []*record{r})
+		}
+	}
+	return pr.solve(groups, 0, nil)
+}
+
+// solve pairs bracket i, made of score group i and the players floating
+// down into it, and then everything below. A bracket is paired with as
+// many pairs as possible, and among those with as few color clashes and
+// repeated floats as possible; when the rest cannot be paired after it,
+// the next best pairing of the bracket is tried.
+func (pr *pairer) solve(groups [][]*record, i int, carried []*record) ([][2]*record, bool) {
+	if i == len(groups) {
+		return nil, len(carried) == 0
+	}
+	bracket := append(slices.Clone(carried), groups[i]...)
+	ranked(bracket)
+	last := i == len(groups)-1
+
+	var result [][2]*record
+	accept := func(pairs [][2]*record, floaters []*record) bool {
+		rest, ok := pr.solve(groups, i+1, floaters)
+		if ok {
+			result = append(slices.Clone(pairs), rest...)
+		}
+		return ok
+	}
+	for p := len(bracket) / 2; p >= 0; p-- {
+		floats := len(bracket) - 2*p
+		if last && floats > 0 {
+			break
+		}
+		if !pr.quality {
+			if pr.search(bracket, p, floats, math.MaxInt, accept) {
+				return result, true
+			}
+			continue
+		}
+		for maxCost := 0; maxCost <= p+floats && !pr.exhausted(); maxCost++ {
+			if pr.search(bracket, p, floats, maxCost, accept) {
+				return result, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// search tries the pairings of a bracket with p pairs in the order of the
+// Dutch system: the top half S1 against the bottom half S2 in order, then
+// transpositions of S2, then exchanges between the halves. A pairing may
+// cost at most maxCost in color clashes and repeated downfloats; each one
+// within it is offered to accept until it takes one.
+func (pr *pairer) search(bracket []*record, p, floats, maxCost int, accept func([][2]*record, []*record) bool) bool {
+	used := make([]bool, len(bracket))
+	var pairs [][2]*record
+	var floaters []*record
+
+	var dfs func(cost int) bool
+	dfs = func(cost int) bool {
+		pr.steps++
+		if pr.exhausted() {
+			return false
+		}
+		i := slices.Index(used, false)
+		if i < 0 {
+			return accept(pairs, floaters)
+		}
+		used[i] = true
+		a := bracket[i]
+		for _, inS2 := range []bool{true, false} {
+			for j := i + 1; j < len(bracket); j++ {
+				if used[j] || (j >= p) != inS2 {
+					continue
+				}
+				b := bracket[j]
+				if !pr.compatible(a, b) {
+					continue
+				}
+				c := cost
+				if pr.quality && colorClash(a, b) {
+					c++
+				}
+				if c > maxCost {
+					continue
+				}
+				used[j] = true
+				pairs = append(pairs, [2]*record{a, b})
+				if dfs(c) {
+					return true
+				}
+				used[j] = false
+				pairs = pairs[:len(pairs)-1]
+			}
+		}
+		if len(floaters) < floats {
+			c := cost
+			if pr.quality && a.floated < 0 {
+				c++
+			}
+			if c <= maxCost {
+				floaters = append(floaters, a)
+				if dfs(c) {
+					return true
+				}
+				floaters = floaters[:len(floaters)-1]
+			}
+		}
+		used[i] = false
+		return false
+	}
+	return dfs(0)
+}
+
+// round turns pairs into boards, ordered by the higher score on each and
+// then the rank of the higher ranked player, and allocates colors.
+func (h History) round(players []*record, pairs [][2]*record, bye *record) Round {
+	rank := make(map[*record]int, len(players))
+	for i, r := range players {
+		rank[r] = i
+	}
+	for i, pair := range pairs {
+		if rank[pair[1]] < rank[pair[0]] {
+			pairs[i] = [2]*record{pair[1], pair[0]}
+		}
+	}
+	slices.SortFunc(pairs, func(x, y [2]*record) int {
+		return cmp.Or(
+			cmp.Compare(max(y[0].score, y[1].score), max(x[0].score, x[1].score)),
+			cmp.Compare(rank[x[0]], rank[y[0]]),
+		)
+	})
+
+	round := Round{Pairings: make([]Pairing, 0, len(pairs)+1)}
+	for board, pair := range pairs {
+		first := h.InitialColor
+		if board%2 == 1 {
+			first = first.Opponent()
+		}
+		white, black := allocate(pair[0], pair[1], first)
+		round.Pairings = append(round.Pairings, Pairing{White: white.id, Black: black.id})
+	}
+	if bye != nil {
+		round.Pairings = append(round.Pairings, Pairing{White: bye.id, Result: ByeResult})
+	}
+	return round
+}
@@ -0,0 +1,169 @@
+// Package swiss pairs Swiss tournaments with the FIDE Dutch system and
+// ranks their players with the usual tiebreaks. It works on the history of
+// a tournament alone and keeps no state of its own.
+package swiss
+
+import (
+	"cmp"
+	"slices"
+
+	"github.com/THECHAMP95821/chess-backend/internal/chess"
+)
+
+// Result is the outcome of one board, from White's side.
+type Result string
+
+const (
+	Pending         Result = ""
+	WhiteWins       Result = "1-0"
+	BlackWins       Result = "0-1"
+	Draw            Result = "1/2-1/2"
+	WhiteForfeitWin Result = "+/-"
+	BlackForfeitWin Result = "-/+"
+	DoubleForfeit   Result = "-/-"
+	// ByeResult is the result of a pairing-allocated bye.
+	ByeResult Result = "bye"
+)
+
+// Played reports whether the game was actually played. Forfeits and byes
+// do not count towards colors or opponents met.
+func (r Result) Played() bool {
+	return r == Pending || r == WhiteWins || r == BlackWins || r == Draw
+}
+
+// Player is a participant. Players are listed in the order they joined,
+// which breaks ties between equal ratings.
+type Player struct {
+	ID     string  `json:"id"`
+	Rating float64 `json:"rating"`
+}
+
+// Pairing is one board of a round. Black is empty for a bye.
+type Pairing struct {
+	White  string `json:"white"`
+	Black  string `json:"black,omitempty"`
+	Result Result `json:"result"`
+}
+
+func (p Pairing) Bye() bool {
+	return p.Black == ""
+}
+
+// points is what each side scored, given the points for a bye.
+func (p Pairing) points(bye float64) (white, black float64) {
+	switch p.Result {
+	case WhiteWins, WhiteForfeitWin:
+		return 1, 0
+	case BlackWins, BlackForfeitWin:
+		return 0, 1
+	case Draw:
+		return 0.5, 0.5
+	case ByeResult:
+		return bye, 0
+	}
+	return 0, 0
+}
+
+type Round struct {
+	Pairings []Pairing `json:"pairings"`
+}
+
+// Complete reports whether every board of the round has a result.
+func (r Round) Complete() bool {
+	for _, p := range r.Pairings {
+		if p.Result == Pending {
+			return false
+		}
+	}
+	return true
+}
+
+// History is everything that has happened in a tournament so far.
+// InitialColor is the color the top seed gets in the first round.
+type History struct {
+	Players      []Player
+	Rounds       []Round
+	TotalRounds  int
+	ByePoints    float64
+	InitialColor chess.Color
+}
+
+// record is what pairing needs to know about one player.
+type record struct {
+	id        string
+	rating    float64
+	index     int
+	score     float64
+	colors    []chess.Color
+	opponents map[string]bool
+	hadBye    bool
+	// floated is the direction the player floated in the last round: -1
+	// down, +1 up.
+	floated int
+	pref    preference
+}
+
+func (h History) records() map[string]*record {
+	recs := make(map[string]*record, len(h.Players))
+	for i, p := range h.Players {
+		recs[p.ID] = &record{id: p.ID, rating: p.Rating, index: i, opponents: make(map[string]bool)}
+	}
+	for n, round := range h.Rounds {
+		before := make(map[string]float64, len(recs))
+		for id, r := range recs {
+			before[id] = r.score
+		}
+		last := n == len(h.Rounds)-1
+		for _, p := range round.Pairings {
+			white, black := recs[p.White], recs[p.Black]
+			wp, bp := p.points(h.ByePoints)
+			if white != nil {
+				white.score += wp
+			}
+			if black != nil {
+				black.score += bp
+			}
+			if p.Bye() {
+				if white != nil {
+					white.hadBye = true
+					if last {
+						white.floated = -1
+					}
+				}
+				continue
+			}
+			if white == nil || black == nil {
+				continue
+			}
+			if p.Result == WhiteForfeitWin {
+				white.hadBye = true
+			}
+			if p.Result == BlackForfeitWin {
+				black.hadBye = true
+			}
+			if !p.Result.Played() {
+				continue
+			}
+			white.colors = append(white.colors, chess.ColorWhite)
+			black.colors = append(black.colors, chess.ColorBlack)
+			white.opponents[black.id] = true
+			black.opponents[white.id] = true
+			if last {
+				switch {
+				case before[white.id] > before[black.id]:
+					white.floated, black.floated = -1, 1
+				case before[white.id] < before[black.id]:
+					white.floated, black.floated = 1, -1
+				}
+			}
+		}
+	}
+	return recs
+}
+
+// ranked orders players by score, then rating, then the order they joined.
+func ranked(recs []*record) {
+	slices.SortFunc(recs, func(a, b *record) int {
+		return cmp.Or(cmp.Compare(b.score, a.score), cmp.Compare(b.rating, a.rating), cmp.Compare(a.index, b.index))
+	})
+}
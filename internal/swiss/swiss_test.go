@@ -0,0 +1,194 @@
+package swiss
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"testing"
+
+	"github.com/THECHAMP95821/chess-backend/internal/chess"
+)
+
+func players(n int) ([]Player, []string) {
+	var ps []Player
+	var ids []string
+	for i := range n {
+		id := fmt.Sprintf("p%d", i+1)
+		ps = append(ps, Player{ID: id, Rating: float64(2400 - 10*i)})
+		ids = append(ids, id)
+	}
+	return ps, ids
+}
+
+func TestPairFirstRound(t *testing.T) {
+	ps, ids := players(8)
+	round, err := Pair(History{Players: ps, TotalRounds: 5, ByePoints: 1}, ids)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Pairing{{"p1", "p5", ""}, {"p6", "p2", ""}, {"p3", "p7", ""}, {"p8", "p4", ""}}
+	if fmt.Sprint(round.Pairings) != fmt.Sprint(want) {
+		t.Errorf("round 1 = %v, want %v", round.Pairings, want)
+	}
+
+	ps, ids = players(5)
+	round, _ = Pair(History{Players: ps, TotalRounds: 5, ByePoints: 1}, ids)
+	if bye := round.Pairings[len(round.Pairings)-1]; !bye.Bye() || bye.White != "p5" || bye.Result != ByeResult {
+		t.Errorf("round 1 with five players = %v, want p5 to get the bye", round.Pairings)
+	}
+}
+
+func TestPairSecondRound(t *testing.T) {
+	ps, ids := players(4)
+	h := History{Players: ps, TotalRounds: 3, ByePoints: 1}
+	h.Rounds = []Round{{Pairings: []Pairing{
+		{"p1", "p3", WhiteWins},
+		{"p4", "p2", BlackWins},
+	}}}
+	round, err := Pair(h, ids)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Winners meet, losers meet, and everyone alternates colors.
+	want := []Pairing{{"p2", "p1", ""}, {"p3", "p4", ""}}
+	if fmt.Sprint(round.Pairings) != fmt.Sprint(want) {
+		t.Errorf("round 2 = %v, want %v", round.Pairings, want)
+	}
+}
+
+// TestPairWholeTournaments plays out random tournaments and checks the
+// absolute criteria hold in every round.
+func TestPairWholeTournaments(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	for _, n := range []int{6, 9, 12, 17, 41, 64} {
+		ps, ids := players(n)
+		rounds := 5
+		h := History{Players: ps, TotalRounds: rounds, ByePoints: 1}
+		for r := range rounds {
+			round, err := Pair(h, ids)
+			if err != nil {
+				t.Fatalf("%d players, round %d: %v", n, r+1, err)
+			}
+			for i := range round.Pairings {
+				if !round.Pairings[i].Bye() {
+					round.Pairings[i].Result = []Result{WhiteWins, BlackWins, Draw}[rng.IntN(3)]
+				}
+			}
+			h.Rounds = append(h.Rounds, round)
+		}
+
+		recs := h.records()
+		met := make(map[[2]string]bool)
+		byes := make(map[string]int)
+		for _, round := range h.Rounds {
+			seen := make(map[string]bool)
+			for _, p := range round.Pairings {
+				for _, id := range []string{p.White, p.Black} {
+					if id == "" {
+						continue
+					}
+					if seen[id] {
+						t.Fatalf("%d players: %s paired twice in a round", n, id)
+					}
+					seen[id] = true
+				}
+				if p.Bye() {
+					byes[p.White]++
+					continue
+				}
+				key := [2]string{min(p.White, p.Black), max(p.White, p.Black)}
+				if met[key] {
+					t.Errorf("%d players: %s met twice", n, key)
+				}
+				met[key] = true
+			}
+			if len(seen) != n {
+				t.Errorf("%d players: only %d paired in a round", n, len(seen))
+			}
+		}
+		for id, count := range byes {
+			if count > 1 {
+				t.Errorf("%d players: %s had %d byes", n, id, count)
+			}
+		}
+		for _, r := range recs {
+			// Only the final round may break the color rules, for topscorers.
+			colors := r.colors[:len(r.colors)-1]
+			for i := 2; i < len(colors); i++ {
+				if colors[i] == colors[i-1] && colors[i] == colors[i-2] {
+					t.Errorf("%d players: %s had the same color three times running: %v", n, r.id, r.colors)
+				}
+			}
+			if d := (&record{colors: colors}).difference(); d > 2 || d < -2 {
+				t.Errorf("%d players: %s has color difference %d", n, r.id, d)
+			}
+		}
+	}
+}
+
+func TestPairImpossible(t *testing.T) {
+	ps, ids := players(2)
+	h := History{Players: ps, TotalRounds: 2, Rounds: []Round{{Pairings: []Pairing{{"p1", "p2", Draw}}}}}
+	if _, err := Pair(h, ids); !errors.Is(err, ErrNoPairing) {
+		t.Errorf("rematch only: err = %v, want ErrNoPairing", err)
+	}
+	if _, err := Pair(h, []string{"p1", "nobody"}); !errors.Is(err, ErrUnknownPlayer) {
+		t.Errorf("unknown player: err = %v", err)
+	}
+}
+
+func TestAllocateColors(t *testing.T) {
+	w, b := chess.ColorWhite, chess.ColorBlack
+	for _, tc := range []struct {
+		a, b      []chess.Color
+		wantWhite string
+	}{
+		{nil, nil, "a"},
+		{[]chess.Color{w}, []chess.Color{b}, "b"},
+		{[]chess.Color{w, b}, []chess.Color{b}, "b"},       // strong beats mild
+		{[]chess.Color{b, w}, []chess.Color{w, b}, "b"},    // different preferences are both granted
+		{[]chess.Color{b, b}, []chess.Color{w, b, b}, "a"}, // both absolute: the wider difference wins
+		{[]chess.Color{w, b, w}, []chess.Color{b, w}, "b"}, // strong black vs mild black: strong wins
+	} {
+		a := &record{id: "a", colors: tc.a}
+		bb := &record{id: "b", colors: tc.b}
+		a.pref, bb.pref = a.preference(), bb.preference()
+		white, _ := allocate(a, bb, chess.ColorWhite)
+		if white.id != tc.wantWhite {
+			t.Errorf("allocate(%v, %v): white = %s, want %s", tc.a, tc.b, white.id, tc.wantWhite)
+		}
+	}
+}
+
+func TestStandings(t *testing.T) {
+	ps, _ := players(4)
+	h := History{Players: ps, TotalRounds: 2, ByePoints: 1, Rounds: []Round{
+		{Pairings: []Pairing{{"p1", "p3", WhiteWins}, {"p4", "p2", Draw}}},
+		{Pairings: []Pairing{{"p2", "p1", BlackWins}, {"p3", "p4", WhiteForfeitWin}}},
+	}}
+	st := Standings(h, nil)
+	got := map[string]Standing{}
+	for _, s := range st {
+		got[s.PlayerID] = s
+	}
+	if st[0].PlayerID != "p1" || st[0].Score != 2 || st[0].Rank != 1 {
+		t.Errorf("leader = %+v, want p1 on 2", st[0])
+	}
+	// p1 beat p3 (adjusted 0 + 0.5 for the forfeit) and p2 (0.5).
+	if b := got["p1"].Tiebreaks[Buchholz]; b != 1 {
+		t.Errorf("p1 Buchholz = %v, want 1", b)
+	}
+	if sb := got["p1"].Tiebreaks[SonnebornBerger]; sb != 1 {
+		t.Errorf("p1 Sonneborn-Berger = %v, want 1", sb)
+	}
+	// p3 won round 2 by forfeit: a virtual opponent with 0 + (1 - 1) + 0.
+	if b := got["p3"].Tiebreaks[Buchholz]; b != 2 {
+		t.Errorf("p3 Buchholz = %v, want 2 (p1) + 0 (virtual)", b)
+	}
+	if c := got["p3"].Tiebreaks[BuchholzCut1]; c != 2 {
+		t.Errorf("p3 Buchholz cut 1 = %v, want 2", c)
+	}
+	if p := got["p1"].Tiebreaks[Progressive]; p != 3 {
+		t.Errorf("p1 progressive = %v, want 1 + 2", p)
+	}
+}
@@ -0,0 +1,148 @@
+package swiss
+
+import (
+	"cmp"
+	"slices"
+)
+
+// Tiebreak names a way of separating players on equal scores.
+type Tiebreak string
+
+const (
+	Buchholz        Tiebreak = "buchholz"
+	BuchholzCut1    Tiebreak = "buchholz_cut1"
+	SonnebornBerger Tiebreak = "sonneborn_berger"
+	Progressive     Tiebreak = "progressive"
+)
+
+// DefaultTiebreaks is the order tiebreaks apply in unless a tournament
+// says otherwise.
+var DefaultTiebreaks = []Tiebreak{BuchholzCut1, Buchholz, SonnebornBerger, Progressive}
+
+// Standing is one line of the crosstable.
+type Standing struct {
+	Rank      int                  `json:"rank"`
+	PlayerID  string               `json:"player_id"`
+	Score     float64              `json:"score"`
+	Tiebreaks map[Tiebreak]float64 `json:"tiebreaks"`
+}
+
+// played is one round from one player's side.
+type played struct {
+	opponent string
+	points   float64
+	// before is the player's score before the round.
+	before float64
+}
+
+// Standings ranks the players by score and then by tiebreaks, in order.
+// Tiebreaks only count complete rounds. Rounds a player did not play, by
+// bye, forfeit or absence, count against a virtual opponent as in the FIDE
+// rules: one with the player's score before the round, plus the points
+// the player lost in it, plus a draw for each remaining round. Opponents'
+// own unplayed rounds count as draws.
+func Standings(h History, tiebreaks []Tiebreak) []Standing {
+	if tiebreaks == nil {
+		tiebreaks = DefaultTiebreaks
+	}
+	complete := 0
+	for complete < len(h.Rounds) && h.Rounds[complete].Complete() {
+		complete++
+	}
+
+	score := make(map[string]float64, len(h.Players))
+	adjusted := make(map[string]float64, len(h.Players))
+	rounds := make(map[string][]played, len(h.Players))
+	for n, round := range h.Rounds {
+		seen := make(map[string]bool)
+		for _, p := range round.Pairings {
+			wp, bp := p.points(h.ByePoints)
+			realGame := p.Result.Played() && !p.Bye()
+			for _, side := range []struct {
+				id, opponent string
+				points       float64
+			}{{p.White, p.Black, wp}, {p.Black, p.White, bp}} {
+				if side.id == "" {
+					continue
+				}
+				seen[side.id] = true
+				if n < complete {
+					opponent := ""
+					if realGame {
+						opponent = side.opponent
+						adjusted[side.id] += side.points
+					} else {
+						adjusted[side.id] += 0.5
+					}
+					rounds[side.id] = append(rounds[side.id], played{opponent: opponent, points: side.points, before: score[side.id]})
+				}
+				score[side.id] += side.points
+			}
+		}
+		if n < complete {
+			for _, pl := range h.Players {
+				if !seen[pl.ID] {
+					adjusted[pl.ID] += 0.5
+					rounds[pl.ID] = append(rounds[pl.ID], played{before: score[pl.ID]})
+				}
+			}
+		}
+	}
+
+	standings := make([]Standing, 0, len(h.Players))
+	for _, pl := range h.Players {
+		var contributions, weighted []float64
+		progressive, running := 0.0, 0.0
+		for r, g := range rounds[pl.ID] {
+			opp := adjusted[g.opponent]
+			if g.opponent == "" {
+				opp = g.before + (1 - g.points) + 0.5*float64(complete-r-1)
+			}
+			contributions = append(contributions, opp)
+			weighted = append(weighted, opp*g.points)
+			running += g.points
+			progressive += running
+		}
+		values := make(map[Tiebreak]float64, len(tiebreaks))
+		for _, tb := range tiebreaks {
+			switch tb {
+			case Buchholz:
+				values[tb] = sum(contributions)
+			case BuchholzCut1:
+				values[tb] = sum(contributions)
+				if len(contributions) > 0 {
+					values[tb] -= slices.Min(contributions)
+				}
+			case SonnebornBerger:
+				values[tb] = sum(weighted)
+			case Progressive:
+				values[tb] = progressive
+			}
+		}
+		standings = append(standings, Standing{PlayerID: pl.ID, Score: score[pl.ID], Tiebreaks: values})
+	}
+
+	compare := func(a, b Standing) int {
+		c := cmp.Compare(b.Score, a.Score)
+		for _, tb := range tiebreaks {
+			c = cmp.Or(c, cmp.Compare(b.Tiebreaks[tb], a.Tiebreaks[tb]))
+		}
+		return c
+	}
+	slices.SortStableFunc(standings, compare)
+	for i := range standings {
+		standings[i].Rank = i + 1
+		if i > 0 && compare(standings[i-1], standings[i]) == 0 {
+			standings[i].Rank = standings[i-1].Rank
+		}
+	}
+	return standings
+}
+
+func sum(xs []float64) float64 {
+	total := 0.0
+	for _, x := range xs {
+		total += x
+	}
+	return total
+}
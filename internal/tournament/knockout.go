@@ -0,0 +1,350 @@
+package tournament
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+
+	"github.com/THECHAMP95821/chess-backend/internal/chess"
+	"github.com/THECHAMP95821/chess-backend/internal/clock"
+	"github.com/THECHAMP95821/chess-backend/internal/game"
+	"github.com/THECHAMP95821/chess-backend/internal/swiss"
+)
+
+// Knockout describes the matches of a knockout tournament. A match is Games
+// games at the tournament's time control. A tied match goes on to each
+// tiebreak stage in turn and then, if still tied, to an armageddon game;
+// without one, the higher seed goes through.
+type Knockout struct {
+	Games      int         `json:"games"`
+	Tiebreaks  []Stage     `json:"tiebreaks,omitempty"`
+	Armageddon *Armageddon `json:"armageddon,omitempty"`
+}
+
+// Stage is a set of tiebreak games at one time control.
+type Stage struct {
+	Games       int    `json:"games"`
+	TimeControl string `json:"time_control"`
+}
+
+// Armageddon is a single deciding game in which White has more time and
+// Black has draw odds.
+type Armageddon struct {
+	WhiteTimeControl string `json:"white_time_control"`
+	BlackTimeControl string `json:"black_time_control"`
+}
+
+func (k *Knockout) validate() error {
+	if k.Games < 1 {
+		return fmt.Errorf("%w: a match needs at least one game", ErrInvalidOptions)
+	}
+	// Normalized copies, not the caller's.
+	k.Tiebreaks = slices.Clone(k.Tiebreaks)
+	if k.Armageddon != nil {
+		a := *k.Armageddon
+		k.Armageddon = &a
+	}
+	for i, st := range k.Tiebreaks {
+		if st.Games < 1 {
+			return fmt.Errorf("%w: tiebreak %d has no games", ErrInvalidOptions, i+1)
+		}
+		tc, err := clock.ParseTimeControl(st.TimeControl)
+		if err != nil {
+			return fmt.Errorf("%w: tiebreak %d: %v", game.ErrInvalidTime, i+1, err)
+		}
+		k.Tiebreaks[i].TimeControl = tc.String()
+	}
+	if a := k.Armageddon; a != nil {
+		white, err := clock.ParseTimeControl(a.WhiteTimeControl)
+		if err != nil {
+			return fmt.Errorf("%w: armageddon: %v", game.ErrInvalidTime, err)
+		}
+		black, err := clock.ParseTimeControl(a.BlackTimeControl)
+		if err != nil {
+			return fmt.Errorf("%w: armageddon: %v", game.ErrInvalidTime, err)
+		}
+		if _, err := clock.NewOdds(white, black); err != nil {
+			return fmt.Errorf("%w: armageddon: %v", game.ErrInvalidTime, err)
+		}
+		a.WhiteTimeControl, a.BlackTimeControl = white.String(), black.String()
+	}
+	return nil
+}
+
+// Match is one pairing of a knockout round, played over as many games as it
+// takes. Player1 is the higher seed; a match without Player2 is a bye.
+type Match struct {
+	Player1 string      `json:"player1"`
+	Player2 string      `json:"player2,omitempty"`
+	Games   []MatchGame `json:"games"`
+	Winner  string      `json:"winner,omitempty"`
+}
+
+// MatchGame is one game of a match. Stage is zero for the match proper and
+// counts the tiebreak stages from one.
+type MatchGame struct {
+	Stage       int          `json:"stage"`
+	Armageddon  bool         `json:"armageddon,omitempty"`
+	White       string       `json:"white"`
+	Black       string       `json:"black"`
+	TimeControl string       `json:"time_control,omitempty"`
+	GameID      string       `json:"game_id,omitempty"`
+	Result      swiss.Result `json:"result"`
+}
+
+// seedOrder lists the seeds of a bracket of size players in bracket order,
+// so that adjacent seeds meet and the top seeds only meet late.
+func seedOrder(size int) []int {
+	order := []int{1}
+	for len(order) < size {
+		next := make([]int, 0, 2*len(order))
+		for _, s := range order {
+			next = append(next, s, 2*len(order)+1-s)
+		}
+		order = next
+	}
+	return order
+}
+
+func bracketSize(players int) int {
+	size := 1
+	for size < players {
+		size *= 2
+	}
+	return size
+}
+
+func bracketRounds(players int) int {
+	rounds := 0
+	for size := bracketSize(players); size > 1; size /= 2 {
+		rounds++
+	}
+	return rounds
+}
+
+// seed is a player's seed: players are seeded in the order they joined.
+func (t *tournament) seed(id string) int {
+	return slices.IndexFunc(t.players, func(p swiss.Player) bool { return p.ID == id })
+}
+
+func (t *tournament) bracketRoundComplete() bool {
+	if len(t.bracket) == 0 {
+		return true
+	}
+	for _, m := range t.bracket[len(t.bracket)-1] {
+		if m.Winner == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// nextBracketRound draws the first round from the seeds, or pairs the
+// winners of the last round, and starts the matches. After the final it
+// ends the tournament.
+func (s *Service) nextBracketRound(ctx context.Context, t *tournament) error {
+	var matches []*Match
+	if len(t.bracket) == 0 {
+		players := t.active()
+		for pair := range slices.Chunk(seedOrder(bracketSize(len(players))), 2) {
+			m := &Match{}
+			for _, seed := range pair {
+				if seed > len(players) {
+					continue
+				}
+				if m.Player1 == "" {
+					m.Player1 = players[seed-1]
+				} else {
+					m.Player2 = players[seed-1]
+				}
+			}
+			matches = append(matches, m)
+		}
+	} else {
+		last := t.bracket[len(t.bracket)-1]
+		if len(last) == 1 {
+			t.status = StatusFinished
+			return nil
+		}
+		for pair := range slices.Chunk(last, 2) {
+			m := &Match{Player1: pair[0].Winner, Player2: pair[1].Winner}
+			if t.seed(m.Player2) < t.seed(m.Player1) {
+				m.Player1, m.Player2 = m.Player2, m.Player1
+			}
+			matches = append(matches, m)
+		}
+	}
+	t.bracket = append(t.bracket, matches)
+	var errs []error
+	for i := range matches {
+		if err := s.playMatch(ctx, t, len(t.bracket)-1, i); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if t.bracketRoundComplete() {
+		return s.nextBracketRound(ctx, t)
+	}
+	return errors.Join(errs...)
+}
+
+// playMatch decides a match or starts its next game.
+func (s *Service) playMatch(ctx context.Context, t *tournament, round, board int) error {
+	m := t.bracket[round][board]
+	if !m.next(t.opts, t.withdrawn) {
+		return nil
+	}
+	n := len(m.Games) - 1
+	g := &m.Games[n]
+	opts := game.CreateOptions{
+		TimeControl: g.TimeControl,
+		WhiteID:     g.White,
+		BlackID:     g.Black,
+		Rated:       t.opts.Rated && !g.Armageddon,
+	}
+	if g.Armageddon {
+		opts.TimeControl = t.opts.Knockout.Armageddon.WhiteTimeControl
+		opts.BlackTimeControl = t.opts.Knockout.Armageddon.BlackTimeControl
+		opts.Rule = chess.ArmageddonRule
+	}
+	v, err := s.games.Create(opts)
+	if err != nil {
+		// The organizer can still enter a result for this game.
+		return fmt.Errorf("match %d: %w", board+1, err)
+	}
+	g.GameID = v.ID
+	if err := s.link(ctx, v.ID, boardRef{Tournament: t.id, Round: round, Board: board, Game: n}); err != nil {
+		return fmt.Errorf("match %d: %w", board+1, err)
+	}
+	return nil
+}
+
+// next moves a match on once its last game is over: it either names the
+// winner or adds the next game. It reports whether a game was added.
+func (m *Match) next(opts Options, withdrawn map[string]bool) bool {
+	if m.Winner != "" {
+		return false
+	}
+	switch {
+	case m.Player2 == "" || withdrawn[m.Player2]:
+		m.Winner = m.Player1
+		return false
+	case withdrawn[m.Player1]:
+		m.Winner = m.Player2
+		return false
+	}
+	k := opts.Knockout
+	stage, played := 0, 0
+	var score1, score2 float64
+	if len(m.Games) > 0 {
+		last := m.Games[len(m.Games)-1]
+		if last.Result == swiss.Pending {
+			return false
+		}
+		stage = last.Stage
+		if last.Armageddon {
+			m.Winner = m.Player1
+			if p1, p2 := m.points(last); p2 > p1 {
+				m.Winner = m.Player2
+			}
+			return false
+		}
+		for _, g := range m.Games {
+			if g.Stage == stage {
+				p1, p2 := m.points(g)
+				score1, score2 = score1+p1, score2+p2
+				played++
+			}
+		}
+	}
+
+	games, tc := k.Games, opts.TimeControl
+	if stage > 0 {
+		games, tc = k.Tiebreaks[stage-1].Games, k.Tiebreaks[stage-1].TimeControl
+	}
+	left := float64(games - played)
+	switch {
+	case score1-score2 > left:
+		m.Winner = m.Player1
+		return false
+	case score2-score1 > left:
+		m.Winner = m.Player2
+		return false
+	case left > 0:
+		m.add(stage, played, tc, false)
+		return true
+	case stage < len(k.Tiebreaks):
+		stage++
+		m.add(stage, 0, k.Tiebreaks[stage-1].TimeControl, false)
+		return true
+	case k.Armageddon != nil:
+		m.add(stage+1, 0, "", true)
+		return true
+	default:
+		m.Winner = m.Player1
+		return false
+	}
+}
+
+// add appends the nth game of a stage. Colors alternate within a stage,
+// starting with the higher seed as White, who also has White in armageddon.
+func (m *Match) add(stage, n int, tc string, armageddon bool) {
+	g := MatchGame{Stage: stage, Armageddon: armageddon, White: m.Player1, Black: m.Player2, TimeControl: tc}
+	if n%2 == 1 {
+		g.White, g.Black = g.Black, g.White
+	}
+	m.Games = append(m.Games, g)
+}
+
+// points is what each player of the match scored in g.
+func (m *Match) points(g MatchGame) (p1, p2 float64) {
+	var white, black float64
+	switch g.Result {
+	case swiss.WhiteWins, swiss.WhiteForfeitWin:
+		white = 1
+	case swiss.BlackWins, swiss.BlackForfeitWin:
+		black = 1
+	case swiss.Draw:
+		white, black = 0.5, 0.5
+	}
+	if g.White == m.Player1 {
+		return white, black
+	}
+	return black, white
+}
+
+func (s *Service) matchGameEnded(ctx context.Context, t *tournament, ref boardRef, result swiss.Result) {
+	g := &t.bracket[ref.Round][ref.Board].Games[ref.Game]
+	if g.Result != swiss.Pending {
+		return
+	}
+	g.Result = result
+	s.matchAdvance(ctx, t, ref.Round, ref.Board)
+}
+
+// setMatchResult records a result by hand for the game in progress of a
+// match. round and board count from one.
+func (s *Service) setMatchResult(ctx context.Context, t *tournament, round, board int, result swiss.Result) error {
+	if round < 1 || round > len(t.bracket) || board < 1 || board > len(t.bracket[round-1]) {
+		return ErrNoSuchBoard
+	}
+	m := t.bracket[round-1][board-1]
+	if m.Winner != "" || len(m.Games) == 0 {
+		return ErrNoSuchBoard
+	}
+	g := &m.Games[len(m.Games)-1]
+	if g.Armageddon && result == swiss.Draw {
+		result = swiss.BlackWins
+	}
+	g.Result = result
+	s.matchAdvance(ctx, t, round-1, board-1)
+	return nil
+}
+
+func (s *Service) matchAdvance(ctx context.Context, t *tournament, round, board int) {
+	if err := s.playMatch(ctx, t, round, board); err != nil {
+		log.Printf("tournament %s: round %d: %v", t.id, round+1, err)
+	}
+	s.advance(ctx, t)
+}
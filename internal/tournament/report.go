@@ -0,0 +1,162 @@
+package tournament
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/THECHAMP95821/chess-backend/internal/clock"
+	"github.com/THECHAMP95821/chess-backend/internal/store"
+	"github.com/THECHAMP95821/chess-backend/internal/swiss"
+	"github.com/THECHAMP95821/chess-backend/internal/trf"
+)
+
+// Report is a tournament's TRF report, of the rounds complete so far.
+// Knockouts have none.
+func (s *Service) Report(ctx context.Context, id string) (trf.Tournament, error) {
+	t, err := s.load(ctx, id)
+	if err != nil {
+		return trf.Tournament{}, err
+	}
+	typ := "Swiss Dutch"
+	switch t.opts.Format {
+	case FormatRoundRobin:
+		typ = "Round robin"
+	case FormatKnockout:
+		return trf.Tournament{}, fmt.Errorf("%w: knockouts have no TRF report", ErrInvalidOptions)
+	}
+	names := make(map[string]string, len(t.players))
+	for _, p := range t.players {
+		if s.users == nil {
+			break
+		}
+		u, err := s.users.User(ctx, p.ID)
+		if err != nil {
+			return trf.Tournament{}, err
+		}
+		names[p.ID] = u.Name
+	}
+	return trf.Tournament{
+		Name:        t.opts.Name,
+		Type:        typ,
+		TimeControl: t.opts.TimeControl,
+		Start:       t.createdAt,
+		History:     t.history(),
+		Names:       names,
+		Tiebreaks:   t.opts.Tiebreaks,
+	}, nil
+}
+
+// Import sets up a Swiss from a TRF report: its players, in starting rank
+// order, and every round in it. A report without rounds seeds a tournament
+// to start as usual; otherwise play carries on from the last round, and
+// games still without a result are left for organizer to enter. Every
+// player must have an account: the one with the ID the report kept for
+// them or, failing that, with their name.
+func (s *Service) Import(ctx context.Context, rep trf.Tournament, organizer string) (View, error) {
+	ids, err := s.accountIDs(ctx, rep)
+	if err != nil {
+		return View{}, err
+	}
+	h := withIDs(rep.History, ids)
+	opts := Options{
+		Name:      rep.Name,
+		Format:    FormatSwiss,
+		Rounds:    h.TotalRounds,
+		ByePoints: h.ByePoints,
+		Organizer: organizer,
+	}
+	// The report's time control is free text; keep it only if it is ours.
+	if tc, err := clock.ParseTimeControl(rep.TimeControl); err == nil {
+		opts.TimeControl = tc.String()
+	}
+	if opts.Rounds < 1 {
+		return View{}, fmt.Errorf("%w: need at least one round", ErrInvalidOptions)
+	}
+	if len(h.Players) < 2 {
+		return View{}, ErrNotEnoughPlayers
+	}
+	t := &tournament{
+		id:           newID(),
+		opts:         opts,
+		status:       StatusCreated,
+		players:      h.Players,
+		withdrawn:    make(map[string]bool),
+		initialColor: h.InitialColor,
+		createdAt:    s.now(),
+	}
+	for _, round := range h.Rounds {
+		boards := make([]Board, len(round.Pairings))
+		for i, p := range round.Pairings {
+			boards[i].Pairing = p
+		}
+		t.rounds = append(t.rounds, boards)
+	}
+
+	if err := s.save(ctx, t); err != nil {
+		return View{}, err
+	}
+	if len(t.rounds) == 0 {
+		return t.view(), nil
+	}
+	// Saved first, so that the games started for the next round find it.
+	return s.update(ctx, t.id, func(t *tournament) error {
+		t.status = StatusRunning
+		s.advance(ctx, t)
+		return nil
+	})
+}
+
+// accountIDs maps the players of rep to the IDs of their accounts.
+func (s *Service) accountIDs(ctx context.Context, rep trf.Tournament) (map[string]string, error) {
+	if s.users == nil {
+		return nil, fmt.Errorf("%w: no accounts to match players to", ErrUnknownPlayer)
+	}
+	ids := make(map[string]string, len(rep.History.Players))
+	taken := make(map[string]bool, len(rep.History.Players))
+	for _, p := range rep.History.Players {
+		name := cmp.Or(rep.Names[p.ID], p.ID)
+		u, err := s.users.User(ctx, p.ID)
+		if errors.Is(err, store.ErrNotFound) {
+			u, err = s.users.UserByName(ctx, name)
+		}
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownPlayer, name)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if taken[u.ID] {
+			return nil, fmt.Errorf("%w: %s is in the report twice", ErrInvalidOptions, u.Name)
+		}
+		taken[u.ID] = true
+		ids[p.ID] = u.ID
+	}
+	return ids, nil
+}
+
+// withIDs is h with its players renamed by ids.
+func withIDs(h swiss.History, ids map[string]string) swiss.History {
+	players := make([]swiss.Player, len(h.Players))
+	for i, p := range h.Players {
+		p.ID = ids[p.ID]
+		players[i] = p
+	}
+	h.Players = players
+	rounds := make([]swiss.Round, len(h.Rounds))
+	for i, round := range h.Rounds {
+		pairings := make([]swiss.Pairing, len(round.Pairings))
+		for j, p := range round.Pairings {
+			p.White = ids[p.White]
+			if !p.Bye() {
+				p.Black = ids[p.Black]
+			}
+			pairings[j] = p
+		}
+		round.Pairings = pairings
+		rounds[i] = round
+	}
+	h.Rounds = rounds
+	return h
+}
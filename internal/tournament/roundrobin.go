@@ -0,0 +1,79 @@
+package tournament
+
+import (
+	"github.com/THECHAMP95821/chess-backend/internal/swiss"
+)
+
+// bergerRounds schedules a round robin by the Berger tables, numbering the
+// players in the order given. With an odd number of players, whoever meets
+// the missing last number has a bye. A second cycle repeats the first with
+// colors reversed.
+func bergerRounds(players []string, cycles int) []swiss.Round {
+	ids := append([]string{}, players...)
+	if len(ids)%2 == 1 {
+		ids = append(ids, "")
+	}
+	n := len(ids)
+	last := ids[n-1]
+	var rounds []swiss.Round
+	for r := 0; r < n-1; r++ {
+		start := r * n / 2 % (n - 1)
+		at := func(i int) string { return ids[(start+i)%(n-1)] }
+		var round swiss.Round
+		// The last number alternates colors on the first board.
+		if r%2 == 0 {
+			round.Pairings = append(round.Pairings, pairing(at(0), last))
+		} else {
+			round.Pairings = append(round.Pairings, pairing(last, at(0)))
+		}
+		for i := 1; i < n/2; i++ {
+			round.Pairings = append(round.Pairings, pairing(at(i), at(n-1-i)))
+		}
+		rounds = append(rounds, round)
+	}
+	if cycles == 2 {
+		for _, first := range rounds[:n-1] {
+			var round swiss.Round
+			for _, p := range first.Pairings {
+				if !p.Bye() {
+					p = pairing(p.Black, p.White)
+				}
+				round.Pairings = append(round.Pairings, p)
+			}
+			rounds = append(rounds, round)
+		}
+	}
+	return rounds
+}
+
+// pairing pairs white and black, where an empty side means a bye for the
+// other.
+func pairing(white, black string) swiss.Pairing {
+	switch {
+	case black == "":
+		return swiss.Pairing{White: white, Result: swiss.ByeResult}
+	case white == "":
+		return swiss.Pairing{White: black, Result: swiss.ByeResult}
+	}
+	return swiss.Pairing{White: white, Black: black}
+}
+
+// scheduled is round n of a round robin, with the games of withdrawn players
+// forfeited.
+func (t *tournament) scheduled(n int) swiss.Round {
+	var round swiss.Round
+	for _, p := range t.schedule[n].Pairings {
+		if !p.Bye() {
+			switch w, b := t.withdrawn[p.White], t.withdrawn[p.Black]; {
+			case w && b:
+				p.Result = swiss.DoubleForfeit
+			case w:
+				p.Result = swiss.BlackForfeitWin
+			case b:
+				p.Result = swiss.WhiteForfeitWin
+			}
+		}
+		round.Pairings = append(round.Pairings, p)
+	}
+	return round
+}
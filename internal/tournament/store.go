@@ -0,0 +1,61 @@
+package tournament
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/THECHAMP95821/chess-backend/internal/chess"
+	"github.com/THECHAMP95821/chess-backend/internal/swiss"
+)
+
+// stored is a tournament as it is kept in the store.
+type stored struct {
+	ID           string          `json:"id"`
+	Options      Options         `json:"options"`
+	Status       Status          `json:"status"`
+	Players      []swiss.Player  `json:"players"`
+	Withdrawn    map[string]bool `json:"withdrawn,omitempty"`
+	Rounds       [][]Board       `json:"rounds,omitempty"`
+	Schedule     []swiss.Round   `json:"schedule,omitempty"`
+	Bracket      [][]*Match      `json:"bracket,omitempty"`
+	InitialColor chess.Color     `json:"initial_color"`
+	CreatedAt    time.Time       `json:"created_at"`
+}
+
+func (t *tournament) MarshalJSON() ([]byte, error) {
+	return json.Marshal(stored{
+		ID:           t.id,
+		Options:      t.opts,
+		Status:       t.status,
+		Players:      t.players,
+		Withdrawn:    t.withdrawn,
+		Rounds:       t.rounds,
+		Schedule:     t.schedule,
+		Bracket:      t.bracket,
+		InitialColor: t.initialColor,
+		CreatedAt:    t.createdAt,
+	})
+}
+
+func (t *tournament) UnmarshalJSON(data []byte) error {
+	var st stored
+	if err := json.Unmarshal(data, &st); err != nil {
+		return err
+	}
+	*t = tournament{
+		id:           st.ID,
+		opts:         st.Options,
+		status:       st.Status,
+		players:      st.Players,
+		withdrawn:    st.Withdrawn,
+		rounds:       st.Rounds,
+		schedule:     st.Schedule,
+		bracket:      st.Bracket,
+		initialColor: st.InitialColor,
+		createdAt:    st.CreatedAt,
+	}
+	if t.withdrawn == nil {
+		t.withdrawn = make(map[string]bool)
+	}
+	return nil
+}
@@ -0,0 +1,535 @@
+// Package tournament runs tournaments on top of the game service: it pairs
+// rounds, starts their games and collects the results as games end.
+package tournament
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/THECHAMP95821/chess-backend/internal/chess"
+	"github.com/THECHAMP95821/chess-backend/internal/clock"
+	"github.com/THECHAMP95821/chess-backend/internal/docstore"
+	"github.com/THECHAMP95821/chess-backend/internal/game"
+	"github.com/THECHAMP95821/chess-backend/internal/rating"
+	"github.com/THECHAMP95821/chess-backend/internal/store"
+	"github.com/THECHAMP95821/chess-backend/internal/swiss"
+)
+
+var (
+	ErrNotFound         = errors.New("tournament not found")
+	ErrInvalidOptions   = errors.New("invalid tournament options")
+	ErrStarted          = errors.New("tournament already started")
+	ErrNotStarted       = errors.New("tournament has not started")
+	ErrFinished         = errors.New("tournament is over")
+	ErrAlreadyJoined    = errors.New("already in the tournament")
+	ErrNotJoined        = errors.New("not in the tournament")
+	ErrNotEnoughPlayers = errors.New("not enough players")
+	ErrNoSuchBoard      = errors.New("no such board")
+	ErrNotOrganizer     = errors.New("only the organizer may do that")
+	ErrUnknownPlayer    = errors.New("no account for player")
+)
+
+type Format string
+
+const (
+	FormatSwiss      Format = "swiss"
+	FormatRoundRobin Format = "round_robin"
+	FormatKnockout   Format = "knockout"
+)
+
+type Status string
+
+const (
+	StatusCreated  Status = "created"
+	StatusRunning  Status = "running"
+	StatusFinished Status = "finished"
+)
+
+// Options describe a tournament. Rounds is only set for a Swiss, which ends
+// early if the players run out of opponents; the other formats work it out
+// from the number of players at the start. ByePoints is
+// what a bye scores: one point in a Swiss unless set, nothing in a round
+// robin. Cycles is how many times everyone meets everyone in a round robin,
+// one or two. Knockout describes the matches of a knockout.
+type Options struct {
+	Name        string           `json:"name"`
+	Format      Format           `json:"format"`
+	TimeControl string           `json:"time_control,omitempty"`
+	Rated       bool             `json:"rated"`
+	Rounds      int              `json:"rounds"`
+	ByePoints   float64          `json:"bye_points"`
+	Tiebreaks   []swiss.Tiebreak `json:"tiebreaks,omitempty"`
+	Cycles      int              `json:"cycles,omitempty"`
+	Knockout    Knockout         `json:"knockout"`
+	// Organizer is the user who runs the tournament: only they may start
+	// it and enter results. Anyone may when it is empty.
+	Organizer string `json:"organizer,omitempty"`
+}
+
+// Games starts tournament games.
+type Games interface {
+	Create(opts game.CreateOptions) (game.View, error)
+}
+
+// Ratings looks up a player's current rating in a rating pool.
+type Ratings interface {
+	Current(ctx context.Context, userID string, pool rating.Pool) (rating.Rating, int, error)
+}
+
+// Users finds players' accounts, to name them in reports and to match an
+// imported report's players to accounts.
+type Users interface {
+	User(ctx context.Context, id string) (store.User, error)
+	UserByName(ctx context.Context, name string) (store.User, error)
+}
+
+// Board is one pairing of a round together with the game played on it.
+type Board struct {
+	swiss.Pairing
+	GameID string `json:"game_id,omitempty"`
+}
+
+type tournament struct {
+	id        string
+	opts      Options
+	status    Status
+	players   []swiss.Player
+	withdrawn map[string]bool
+	rounds    [][]Board
+	// schedule is every round of a round robin, fixed at the start.
+	schedule []swiss.Round
+	// bracket holds the matches of each round of a knockout.
+	bracket [][]*Match
+	// initialColor is the top seed's color in the first round, which only
+	// an imported Swiss may have changed.
+	initialColor chess.Color
+	createdAt    time.Time
+}
+
+const (
+	tournamentsColl = "tournaments"
+	// boardsColl finds the tournament board a game is played on.
+	boardsColl = "tournament_boards"
+)
+
+// Service runs the tournaments kept in its store. Every change is made
+// under the tournament's lock, so any number of nodes may share a store.
+type Service struct {
+	docs    docstore.Store
+	games   Games
+	ratings Ratings
+	users   Users
+	now     func() time.Time
+}
+
+// boardRef locates a game. In a knockout, board is the match and game the
+// game within it.
+type boardRef struct {
+	Tournament string `json:"tournament"`
+	Round      int    `json:"round"`
+	Board      int    `json:"board"`
+	Game       int    `json:"game"`
+}
+
+// NewService runs tournaments whose games are created through games. To
+// collect results, GameEnded must learn of every finished game.
+func NewService(games Games) *Service {
+	return &Service{
+		docs:  docstore.NewMemory(),
+		games: games,
+		now:   time.Now,
+	}
+}
+
+// SetStore keeps tournaments in st. Without it they only live as long as
+// this process.
+func (s *Service) SetStore(st docstore.Store) {
+	s.docs = st
+}
+
+// SetRatings makes players join with their rating from r. Without it
+// everyone has the default rating.
+func (s *Service) SetRatings(r Ratings) {
+	s.ratings = r
+}
+
+// SetUsers names players in reports by their accounts in u, and lets
+// reports be imported. Without it reports name players by ID.
+func (s *Service) SetUsers(u Users) {
+	s.users = u
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func (s *Service) Create(ctx context.Context, opts Options) (View, error) {
+	if opts.Format == "" {
+		opts.Format = FormatSwiss
+	}
+	switch opts.Format {
+	case FormatSwiss:
+		if opts.Rounds < 1 {
+			return View{}, fmt.Errorf("%w: need at least one round", ErrInvalidOptions)
+		}
+		if opts.ByePoints == 0 {
+			opts.ByePoints = 1
+		}
+	case FormatRoundRobin:
+		if opts.Cycles == 0 {
+			opts.Cycles = 1
+		}
+		if opts.Cycles != 1 && opts.Cycles != 2 {
+			return View{}, fmt.Errorf("%w: a round robin has one or two cycles", ErrInvalidOptions)
+		}
+		if opts.Tiebreaks == nil {
+			opts.Tiebreaks = []swiss.Tiebreak{swiss.SonnebornBerger}
+		}
+		opts.Rounds = 0
+	case FormatKnockout:
+		if err := opts.Knockout.validate(); err != nil {
+			return View{}, err
+		}
+		opts.Rounds = 0
+	default:
+		return View{}, fmt.Errorf("%w: unknown format %q", ErrInvalidOptions, opts.Format)
+	}
+	if opts.TimeControl != "" {
+		tc, err := clock.ParseTimeControl(opts.TimeControl)
+		if err != nil {
+			return View{}, fmt.Errorf("%w: %v", game.ErrInvalidTime, err)
+		}
+		opts.TimeControl = tc.String()
+	}
+	t := &tournament{
+		id:        newID(),
+		opts:      opts,
+		status:    StatusCreated,
+		withdrawn: make(map[string]bool),
+		createdAt: s.now(),
+	}
+	if err := s.save(ctx, t); err != nil {
+		return View{}, err
+	}
+	return t.view(), nil
+}
+
+func (s *Service) save(ctx context.Context, t *tournament) error {
+	return docstore.Save(ctx, s.docs, tournamentsColl, t.id, t)
+}
+
+func (s *Service) load(ctx context.Context, id string) (*tournament, error) {
+	t := &tournament{}
+	err := docstore.Load(ctx, s.docs, tournamentsColl, id, t)
+	if errors.Is(err, docstore.ErrNotFound) {
+		return nil, ErrNotFound
+	}
+	return t, err
+}
+
+// update changes tournament id through fn under its lock, and saves it
+// unless fn fails.
+func (s *Service) update(ctx context.Context, id string, fn func(t *tournament) error) (View, error) {
+	t := &tournament{}
+	err := docstore.Update(ctx, s.docs, tournamentsColl, id, t, func() error { return fn(t) })
+	if errors.Is(err, docstore.ErrNotFound) {
+		return View{}, ErrNotFound
+	}
+	if err != nil {
+		return View{}, err
+	}
+	return t.view(), nil
+}
+
+// link records the board a game is played on, for GameEnded to find.
+func (s *Service) link(ctx context.Context, gameID string, ref boardRef) error {
+	return docstore.Save(ctx, s.docs, boardsColl, gameID, ref)
+}
+
+// organizedBy checks that user by may make a change only the organizer
+// may make.
+func (t *tournament) organizedBy(by string) error {
+	if t.opts.Organizer != "" && t.opts.Organizer != by {
+		return ErrNotOrganizer
+	}
+	return nil
+}
+
+func (s *Service) Get(ctx context.Context, id string) (View, error) {
+	t, err := s.load(ctx, id)
+	if err != nil {
+		return View{}, err
+	}
+	return t.view(), nil
+}
+
+// Join enters a player, before the start or, in a Swiss, as a late join
+// between rounds. Late joiners score nothing for the rounds they missed.
+func (s *Service) Join(ctx context.Context, id, userID string) (View, error) {
+	t, err := s.load(ctx, id)
+	if err != nil {
+		return View{}, err
+	}
+	pool, err := rating.PoolFor(t.opts.TimeControl, "")
+	if err != nil {
+		return View{}, err
+	}
+	r := rating.DefaultRating
+	if s.ratings != nil {
+		current, _, err := s.ratings.Current(ctx, userID, pool)
+		if err != nil {
+			return View{}, err
+		}
+		r = current.Rating
+	}
+
+	return s.update(ctx, id, func(t *tournament) error {
+		if t.status == StatusFinished {
+			return ErrFinished
+		}
+		if t.status != StatusCreated && t.opts.Format != FormatSwiss && !t.has(userID) {
+			return ErrStarted
+		}
+		if t.has(userID) {
+			if !t.withdrawn[userID] {
+				return ErrAlreadyJoined
+			}
+			// Coming back after withdrawing.
+			delete(t.withdrawn, userID)
+			return nil
+		}
+		t.players = append(t.players, swiss.Player{ID: userID, Rating: r})
+		return nil
+	})
+}
+
+// Withdraw takes a player out of every round not yet paired. A game they
+// are playing now still counts; in a round robin their later games are
+// forfeited, and in a knockout they forfeit the match at its next game.
+func (s *Service) Withdraw(ctx context.Context, id, userID string) (View, error) {
+	return s.update(ctx, id, func(t *tournament) error {
+		if !t.has(userID) || t.withdrawn[userID] {
+			return ErrNotJoined
+		}
+		if t.status == StatusFinished {
+			return ErrFinished
+		}
+		t.withdrawn[userID] = true
+		return nil
+	})
+}
+
+func (t *tournament) has(userID string) bool {
+	return slices.ContainsFunc(t.players, func(p swiss.Player) bool { return p.ID == userID })
+}
+
+func (t *tournament) active() []string {
+	var ids []string
+	for _, p := range t.players {
+		if !t.withdrawn[p.ID] {
+			ids = append(ids, p.ID)
+		}
+	}
+	return ids
+}
+
+func (t *tournament) history() swiss.History {
+	h := swiss.History{
+		Players:      t.players,
+		TotalRounds:  t.opts.Rounds,
+		ByePoints:    t.opts.ByePoints,
+		InitialColor: t.initialColor,
+	}
+	for _, boards := range t.rounds {
+		var round swiss.Round
+		for _, b := range boards {
+			round.Pairings = append(round.Pairings, b.Pairing)
+		}
+		h.Rounds = append(h.Rounds, round)
+	}
+	return h
+}
+
+func (t *tournament) roundComplete() bool {
+	if t.opts.Format == FormatKnockout {
+		return t.bracketRoundComplete()
+	}
+	if len(t.rounds) == 0 {
+		return true
+	}
+	for _, b := range t.rounds[len(t.rounds)-1] {
+		if b.Result == swiss.Pending {
+			return false
+		}
+	}
+	return true
+}
+
+// Start pairs and starts the first round on behalf of user by. Games that
+// fail to start are reported along with the tournament, which is running
+// regardless.
+func (s *Service) Start(ctx context.Context, id, by string) (View, error) {
+	var roundErr error
+	v, err := s.update(ctx, id, func(t *tournament) error {
+		if err := t.organizedBy(by); err != nil {
+			return err
+		}
+		if t.status != StatusCreated {
+			return ErrStarted
+		}
+		if len(t.active()) < 2 {
+			return ErrNotEnoughPlayers
+		}
+		switch t.opts.Format {
+		case FormatRoundRobin:
+			t.schedule = bergerRounds(t.active(), t.opts.Cycles)
+			t.opts.Rounds = len(t.schedule)
+		case FormatKnockout:
+			t.opts.Rounds = bracketRounds(len(t.active()))
+		}
+		t.status = StatusRunning
+		roundErr = s.nextRound(ctx, t)
+		return nil
+	})
+	if err != nil {
+		return View{}, err
+	}
+	return v, roundErr
+}
+
+// nextRound pairs the next round and starts its games, or ends the
+// tournament after the last one.
+func (s *Service) nextRound(ctx context.Context, t *tournament) error {
+	if t.opts.Format == FormatKnockout {
+		return s.nextBracketRound(ctx, t)
+	}
+	if len(t.rounds) >= t.opts.Rounds {
+		t.status = StatusFinished
+		return nil
+	}
+	var round swiss.Round
+	if t.opts.Format == FormatRoundRobin {
+		round = t.scheduled(len(t.rounds))
+	} else {
+		var err error
+		round, err = swiss.Pair(t.history(), t.active())
+		if errors.Is(err, swiss.ErrNoPairing) {
+			// Too few players left to pair without rematches: the
+			// tournament ends after the rounds played.
+			log.Printf("tournament %s: ends after round %d: %v", t.id, len(t.rounds), err)
+			t.opts.Rounds = len(t.rounds)
+			t.status = StatusFinished
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	n := len(t.rounds)
+	boards := make([]Board, len(round.Pairings))
+	t.rounds = append(t.rounds, boards)
+	var errs []error
+	for i, p := range round.Pairings {
+		boards[i].Pairing = p
+		if p.Bye() || p.Result != swiss.Pending {
+			continue
+		}
+		v, err := s.games.Create(game.CreateOptions{
+			TimeControl: t.opts.TimeControl,
+			WhiteID:     p.White,
+			BlackID:     p.Black,
+			Rated:       t.opts.Rated,
+		})
+		if err != nil {
+			// The organizer can still enter a result for this board.
+			errs = append(errs, fmt.Errorf("board %d: %w", i+1, err))
+			continue
+		}
+		boards[i].GameID = v.ID
+		if err := s.link(ctx, v.ID, boardRef{Tournament: t.id, Round: n, Board: i}); err != nil {
+			errs = append(errs, fmt.Errorf("board %d: %w", i+1, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// SetResult records a result by hand, for a forfeit or a game played over
+// the board. round and board count from one; in a knockout, board is the
+// match and the result is for its game in progress.
+func (s *Service) SetResult(ctx context.Context, id, by string, round, board int, result swiss.Result) (View, error) {
+	switch result {
+	case swiss.WhiteWins, swiss.BlackWins, swiss.Draw, swiss.WhiteForfeitWin, swiss.BlackForfeitWin, swiss.DoubleForfeit:
+	default:
+		return View{}, fmt.Errorf("%w: result %q", ErrInvalidOptions, result)
+	}
+	return s.update(ctx, id, func(t *tournament) error {
+		if err := t.organizedBy(by); err != nil {
+			return err
+		}
+		if t.status == StatusCreated {
+			return ErrNotStarted
+		}
+		if t.opts.Format == FormatKnockout {
+			return s.setMatchResult(ctx, t, round, board, result)
+		}
+		if round < 1 || round > len(t.rounds) || board < 1 || board > len(t.rounds[round-1]) || t.rounds[round-1][board-1].Bye() {
+			return ErrNoSuchBoard
+		}
+		t.rounds[round-1][board-1].Result = result
+		s.advance(ctx, t)
+		return nil
+	})
+}
+
+// GameEnded takes the result of a finished game, if it was played in a
+// tournament. Any node may take it, whichever node the game was played on;
+// a result that is already in counts once.
+func (s *Service) GameEnded(ctx context.Context, g game.View) error {
+	var ref boardRef
+	err := docstore.Load(ctx, s.docs, boardsColl, g.ID, &ref)
+	if errors.Is(err, docstore.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	result := swiss.Result(g.Outcome.Result)
+	if g.Outcome.Scored != "" {
+		result = swiss.Result(g.Outcome.Scored)
+	}
+	_, err = s.update(ctx, ref.Tournament, func(t *tournament) error {
+		if t.opts.Format == FormatKnockout {
+			s.matchGameEnded(ctx, t, ref, result)
+			return nil
+		}
+		b := &t.rounds[ref.Round][ref.Board]
+		if b.Result != swiss.Pending {
+			return nil
+		}
+		b.Result = result
+		s.advance(ctx, t)
+		return nil
+	})
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	return s.docs.Delete(ctx, boardsColl, g.ID)
+}
+
+// advance moves on to the next round once the current one is complete.
+func (s *Service) advance(ctx context.Context, t *tournament) {
+	if t.status != StatusRunning || !t.roundComplete() {
+		return
+	}
+	if err := s.nextRound(ctx, t); err != nil {
+		log.Printf("tournament %s: round %d: %v", t.id, len(t.rounds), err)
+	}
+}
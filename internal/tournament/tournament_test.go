@@ -0,0 +1,399 @@
+package tournament
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/THECHAMP95821/chess-backend/internal/chess"
+	"github.com/THECHAMP95821/chess-backend/internal/docstore"
+	"github.com/THECHAMP95821/chess-backend/internal/game"
+	"github.com/THECHAMP95821/chess-backend/internal/store"
+	"github.com/THECHAMP95821/chess-backend/internal/swiss"
+	"github.com/THECHAMP95821/chess-backend/internal/trf"
+)
+
+func newTestService() (*Service, *game.Service) {
+	games := game.NewService()
+	s := NewService(games)
+	games.OnGameEnd(func(v game.View) { s.GameEnded(context.Background(), v) })
+	return s, games
+}
+
+// finishRound makes White win every game of the current round.
+func finishRound(t *testing.T, games *game.Service, v View) {
+	t.Helper()
+	for _, b := range v.Pairings[len(v.Pairings)-1] {
+		if b.GameID == "" {
+			continue
+		}
+		if _, err := games.Resign(b.GameID, chess.ColorBlack); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestSwissTournament(t *testing.T) {
+	ctx := context.Background()
+	s, games := newTestService()
+	if _, err := s.Create(ctx, Options{Name: "weekly"}); !errors.Is(err, ErrInvalidOptions) {
+		t.Errorf("no rounds: err = %v", err)
+	}
+	v, err := s.Create(ctx, Options{Name: "weekly", TimeControl: "600+5", Rounds: 3, Organizer: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, user := range []string{"alice", "bob", "carol", "dave", "erin"} {
+		if _, err := s.Join(ctx, v.ID, user); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := s.Join(ctx, v.ID, "alice"); !errors.Is(err, ErrAlreadyJoined) {
+		t.Errorf("joining twice: err = %v", err)
+	}
+
+	if _, err := s.Start(ctx, v.ID, "bob"); !errors.Is(err, ErrNotOrganizer) {
+		t.Errorf("start by a player: err = %v", err)
+	}
+	v, err = s.Start(ctx, v.ID, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	round := v.Pairings[0]
+	if len(round) != 3 || !round[2].Bye() || round[2].Result != swiss.ByeResult {
+		t.Fatalf("round 1 = %+v, want two games and a bye", round)
+	}
+	g, _ := games.Get(round[0].GameID)
+	if g.WhiteID != round[0].White || g.Clock == nil || g.Clock.Control != "600+5" {
+		t.Errorf("round 1 game = %+v", g)
+	}
+
+	// A late join and a withdrawal between rounds.
+	s.Join(ctx, v.ID, "frank")
+	s.Withdraw(ctx, v.ID, "erin")
+	finishRound(t, games, v)
+	v, _ = s.Get(ctx, v.ID)
+	if len(v.Pairings) != 2 {
+		t.Fatalf("round 2 not paired after round 1 ended: %+v", v.Pairings)
+	}
+	for _, b := range v.Pairings[1] {
+		if b.White == "erin" || b.Black == "erin" {
+			t.Errorf("withdrawn player paired: %+v", b)
+		}
+	}
+
+	// The organizer records a forfeit for one board.
+	if _, err := s.SetResult(ctx, v.ID, "alice", 2, 1, swiss.WhiteForfeitWin); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.SetResult(ctx, v.ID, "alice", 2, 9, swiss.Draw); !errors.Is(err, ErrNoSuchBoard) {
+		t.Errorf("bad board: err = %v", err)
+	}
+	finishRound(t, games, v)
+	v, _ = s.Get(ctx, v.ID)
+	finishRound(t, games, v)
+	v, _ = s.Get(ctx, v.ID)
+	if v.Status != StatusFinished || len(v.Pairings) != 3 {
+		t.Fatalf("after three rounds: status %s, %d rounds", v.Status, len(v.Pairings))
+	}
+	if len(v.Standings) != 6 || v.Standings[0].Score < 2 {
+		t.Errorf("standings = %+v", v.Standings)
+	}
+	if _, err := s.Join(ctx, v.ID, "gina"); !errors.Is(err, ErrFinished) {
+		t.Errorf("joining a finished tournament: err = %v", err)
+	}
+}
+
+func TestSwissEndsWhenNoPairingIsLeft(t *testing.T) {
+	ctx := context.Background()
+	s, games := newTestService()
+	// Four players meet everyone else in three rounds.
+	v, _ := s.Create(ctx, Options{Rounds: 5})
+	for _, user := range []string{"alice", "bob", "carol", "dave"} {
+		s.Join(ctx, v.ID, user)
+	}
+	v, err := s.Start(ctx, v.ID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5 && v.Status == StatusRunning; i++ {
+		finishRound(t, games, v)
+		v, _ = s.Get(ctx, v.ID)
+	}
+	if v.Status != StatusFinished || len(v.Pairings) != 3 || v.Rounds != 3 {
+		t.Errorf("status %s after %d of %d rounds, want finished after 3", v.Status, len(v.Pairings), v.Rounds)
+	}
+}
+
+func TestTournamentSharedByNodes(t *testing.T) {
+	ctx := context.Background()
+	docs := docstore.NewMemory()
+	// Node A starts the games, but the endings reach node B.
+	gamesA := game.NewService()
+	a, b := NewService(gamesA), NewService(game.NewService())
+	a.SetStore(docs)
+	b.SetStore(docs)
+	var ended []game.View
+	gamesA.OnGameEnd(func(v game.View) { ended = append(ended, v) })
+
+	v, _ := a.Create(ctx, Options{Rounds: 2})
+	for _, user := range []string{"alice", "bob", "carol", "dave"} {
+		b.Join(ctx, v.ID, user)
+	}
+	v, err := a.Start(ctx, v.ID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	finishRound(t, gamesA, v)
+	for _, g := range append(ended, ended...) {
+		if err := b.GameEnded(ctx, g); err != nil {
+			t.Fatal(err)
+		}
+	}
+	v, _ = a.Get(ctx, v.ID)
+	if len(v.Pairings) != 2 || v.Standings[0].Score != 1 {
+		t.Errorf("pairings = %+v, standings %+v; want round 1 scored once and round 2 paired", v.Pairings, v.Standings)
+	}
+}
+
+func TestBergerTables(t *testing.T) {
+	rounds := bergerRounds([]string{"1", "2", "3", "4", "5", "6"}, 1)
+	want := [][]string{
+		{"1-6", "2-5", "3-4"},
+		{"6-4", "5-3", "1-2"},
+		{"2-6", "3-1", "4-5"},
+		{"6-5", "1-4", "2-3"},
+		{"3-6", "4-2", "5-1"},
+	}
+	for r, round := range rounds {
+		for b, p := range round.Pairings {
+			if got := p.White + "-" + p.Black; got != want[r][b] {
+				t.Errorf("round %d board %d = %s, want %s", r+1, b+1, got, want[r][b])
+			}
+		}
+	}
+
+	// Everyone meets everyone twice, once with each color, and sits out
+	// twice with an odd number of players.
+	rounds = bergerRounds([]string{"a", "b", "c", "d", "e"}, 2)
+	if len(rounds) != 10 {
+		t.Fatalf("%d rounds, want 10", len(rounds))
+	}
+	games := make(map[string]int)
+	byes := make(map[string]int)
+	for _, round := range rounds {
+		for _, p := range round.Pairings {
+			if p.Bye() {
+				byes[p.White]++
+			} else {
+				games[p.White+p.Black]++
+			}
+		}
+	}
+	for _, x := range "abcde" {
+		if byes[string(x)] != 2 {
+			t.Errorf("%c has %d byes", x, byes[string(x)])
+		}
+		for _, y := range "abcde" {
+			if x != y && games[string(x)+string(y)] != 1 {
+				t.Errorf("%c had White against %c %d times", x, y, games[string(x)+string(y)])
+			}
+		}
+	}
+}
+
+func TestRoundRobin(t *testing.T) {
+	ctx := context.Background()
+	s, games := newTestService()
+	if _, err := s.Create(ctx, Options{Format: FormatRoundRobin, Cycles: 3}); !errors.Is(err, ErrInvalidOptions) {
+		t.Errorf("three cycles: err = %v", err)
+	}
+	v, _ := s.Create(ctx, Options{Name: "invitational", Format: FormatRoundRobin, TimeControl: "900+10"})
+	for _, user := range []string{"alice", "bob", "carol", "dave"} {
+		s.Join(ctx, v.ID, user)
+	}
+	v, err := s.Start(ctx, v.ID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Rounds != 3 || len(v.Pairings[0]) != 2 || v.Pairings[0][0].White != "alice" || v.Pairings[0][0].Black != "dave" {
+		t.Fatalf("round robin = %+v", v)
+	}
+	if _, err := s.Join(ctx, v.ID, "erin"); !errors.Is(err, ErrStarted) {
+		t.Errorf("late join: err = %v", err)
+	}
+
+	// Dave withdraws: his remaining games are forfeited.
+	s.Withdraw(ctx, v.ID, "dave")
+	finishRound(t, games, v)
+	v, _ = s.Get(ctx, v.ID)
+	for _, b := range v.Pairings[1] {
+		if (b.White == "dave" && b.Result != swiss.BlackForfeitWin) || (b.Black == "dave" && b.Result != swiss.WhiteForfeitWin) {
+			t.Errorf("board with withdrawn player = %+v", b)
+		}
+	}
+	finishRound(t, games, v)
+	v, _ = s.Get(ctx, v.ID)
+	finishRound(t, games, v)
+	v, _ = s.Get(ctx, v.ID)
+	if v.Status != StatusFinished || len(v.Standings) != 4 {
+		t.Errorf("after three rounds: status %s, standings %+v", v.Status, v.Standings)
+	}
+}
+
+// playMatchGame ends the game in progress of a match.
+func playMatchGame(t *testing.T, s *Service, games *game.Service, id string, round, match int, draw bool) Match {
+	t.Helper()
+	ctx := context.Background()
+	v, _ := s.Get(ctx, id)
+	m := v.Bracket[round][match]
+	g := m.Games[len(m.Games)-1]
+	if draw {
+		games.OfferDraw(g.GameID, chess.ColorWhite)
+		if _, err := games.AcceptDraw(g.GameID, chess.ColorBlack); err != nil {
+			t.Fatal(err)
+		}
+	} else if _, err := games.Resign(g.GameID, chess.ColorBlack); err != nil {
+		t.Fatal(err)
+	}
+	v, _ = s.Get(ctx, id)
+	return v.Bracket[round][match]
+}
+
+func TestKnockout(t *testing.T) {
+	ctx := context.Background()
+	s, games := newTestService()
+	if _, err := s.Create(ctx, Options{Format: FormatKnockout}); !errors.Is(err, ErrInvalidOptions) {
+		t.Errorf("matches without games: err = %v", err)
+	}
+	v, err := s.Create(ctx, Options{
+		Name:        "cup",
+		Format:      FormatKnockout,
+		TimeControl: "900+10",
+		Knockout: Knockout{
+			Games:      2,
+			Tiebreaks:  []Stage{{Games: 2, TimeControl: "300+2"}},
+			Armageddon: &Armageddon{WhiteTimeControl: "300+0", BlackTimeControl: "240+0"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, user := range []string{"alice", "bob", "carol"} {
+		s.Join(ctx, v.ID, user)
+	}
+	v, err = s.Start(ctx, v.ID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(v.Bracket[0]) != 2 || v.Bracket[0][0].Winner != "alice" || v.Bracket[0][1].Player1 != "bob" {
+		t.Fatalf("first round = %+v", v.Bracket[0])
+	}
+
+	// Bob and Carol draw the match and the rapid tiebreak.
+	var m Match
+	for range 4 {
+		m = playMatchGame(t, s, games, v.ID, 0, 1, true)
+	}
+	last := m.Games[len(m.Games)-1]
+	if len(m.Games) != 5 || !last.Armageddon || last.White != "bob" || m.Games[2].Stage != 1 || m.Games[3].White != "carol" {
+		t.Fatalf("match after four draws = %+v", m)
+	}
+	g, _ := games.Get(last.GameID)
+	if g.Clock.WhiteMs != 300000 || g.Clock.BlackMs != 240000 || g.Rated {
+		t.Errorf("armageddon game = %+v", g.Clock)
+	}
+	// A drawn armageddon goes to Black.
+	m = playMatchGame(t, s, games, v.ID, 0, 1, true)
+	if m.Winner != "carol" || m.Games[4].Result != swiss.BlackWins {
+		t.Fatalf("armageddon: %+v", m)
+	}
+
+	v, _ = s.Get(ctx, v.ID)
+	if len(v.Bracket) != 2 || v.Bracket[1][0].Player1 != "alice" || v.Bracket[1][0].Player2 != "carol" {
+		t.Fatalf("final = %+v", v.Bracket)
+	}
+	// Alice wins with White and draws with Black.
+	playMatchGame(t, s, games, v.ID, 1, 0, false)
+	m = playMatchGame(t, s, games, v.ID, 1, 0, true)
+	if m.Winner != "alice" || len(m.Games) != 2 {
+		t.Errorf("final = %+v", m)
+	}
+	v, _ = s.Get(ctx, v.ID)
+	if v.Status != StatusFinished {
+		t.Errorf("status = %s", v.Status)
+	}
+}
+
+func TestReportAndImport(t *testing.T) {
+	ctx := context.Background()
+	s, games := newTestService()
+	users := store.NewMemory()
+	s.SetUsers(users)
+	v, _ := s.Create(ctx, Options{Name: "club", TimeControl: "600+5", Rounds: 3})
+	for _, user := range []string{"alice", "bob", "carol", "dave", "erin"} {
+		users.CreateUser(ctx, store.User{ID: user, Name: strings.ToUpper(user)})
+		s.Join(ctx, v.ID, user)
+	}
+	v, _ = s.Start(ctx, v.ID, "")
+	finishRound(t, games, v)
+	v, _ = s.Get(ctx, v.ID)
+
+	rep, err := s.Report(ctx, v.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b strings.Builder
+	if err := trf.Write(&b, rep); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), " ALICE ") {
+		t.Errorf("report does not name players:\n%s", b.String())
+	}
+	read, err := trf.Read(strings.NewReader(b.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported, err := s.Import(ctx, read, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if imported.Status != StatusRunning || imported.Rounds != 3 || len(imported.Players) != 5 || len(imported.Pairings) != 2 {
+		t.Fatalf("imported = %+v", imported)
+	}
+	for i, b := range imported.Pairings[0] {
+		if b.Pairing != v.Pairings[0][i].Pairing {
+			t.Errorf("imported board %d = %+v, want %+v", i+1, b.Pairing, v.Pairings[0][i].Pairing)
+		}
+	}
+	if imported.Pairings[1][0].GameID == "" {
+		t.Errorf("round 2 of the imported tournament has no games")
+	}
+
+	// Players of a report from elsewhere are matched by name.
+	foreign := trf.Tournament{History: swiss.History{
+		Players: []swiss.Player{{ID: "ALICE"}, {ID: "BOB"}},
+		Rounds: []swiss.Round{{Pairings: []swiss.Pairing{
+			{White: "ALICE", Black: "BOB", Result: swiss.Draw},
+		}}},
+		TotalRounds: 1,
+	}}
+	imported, err = s.Import(ctx, foreign, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := imported.Pairings[0][0].Pairing; p.White != "alice" || p.Black != "bob" {
+		t.Errorf("foreign pairing = %+v, want the players' accounts", p)
+	}
+	foreign.History.Players[1].ID = "MALLORY"
+	foreign.History.Rounds[0].Pairings[0].Black = "MALLORY"
+	if _, err := s.Import(ctx, foreign, ""); !errors.Is(err, ErrUnknownPlayer) {
+		t.Errorf("player without an account: err = %v", err)
+	}
+
+	ko, _ := s.Create(ctx, Options{Format: FormatKnockout, Knockout: Knockout{Games: 1}})
+	if _, err := s.Report(ctx, ko.ID); !errors.Is(err, ErrInvalidOptions) {
+		t.Errorf("knockout report: err = %v", err)
+	}
+}
@@ -0,0 +1,71 @@
+package tournament
+
+import (
+	"time"
+
+	"github.com/THECHAMP95821/chess-backend/internal/swiss"
+)
+
+type PlayerView struct {
+	ID        string  `json:"id"`
+	Rating    float64 `json:"rating"`
+	Withdrawn bool    `json:"withdrawn,omitempty"`
+}
+
+// View is the externally visible state of a tournament.
+type View struct {
+	ID          string           `json:"id"`
+	Name        string           `json:"name"`
+	Format      Format           `json:"format"`
+	TimeControl string           `json:"time_control,omitempty"`
+	Rated       bool             `json:"rated"`
+	Rounds      int              `json:"rounds"`
+	Cycles      int              `json:"cycles,omitempty"`
+	Knockout    *Knockout        `json:"knockout,omitempty"`
+	Organizer   string           `json:"organizer,omitempty"`
+	Status      Status           `json:"status"`
+	Players     []PlayerView     `json:"players"`
+	Pairings    [][]Board        `json:"pairings"`
+	Bracket     [][]Match        `json:"bracket,omitempty"`
+	Standings   []swiss.Standing `json:"standings"`
+	CreatedAt   time.Time        `json:"created_at"`
+}
+
+func (t *tournament) view() View {
+	v := View{
+		ID:          t.id,
+		Name:        t.opts.Name,
+		Format:      t.opts.Format,
+		TimeControl: t.opts.TimeControl,
+		Rated:       t.opts.Rated,
+		Rounds:      t.opts.Rounds,
+		Cycles:      t.opts.Cycles,
+		Organizer:   t.opts.Organizer,
+		Status:      t.status,
+		Players:     []PlayerView{},
+		Pairings:    [][]Board{},
+		Standings:   []swiss.Standing{},
+		CreatedAt:   t.createdAt,
+	}
+	for _, p := range t.players {
+		v.Players = append(v.Players, PlayerView{ID: p.ID, Rating: p.Rating, Withdrawn: t.withdrawn[p.ID]})
+	}
+	for _, boards := range t.rounds {
+		v.Pairings = append(v.Pairings, append([]Board{}, boards...))
+	}
+	for _, matches := range t.bracket {
+		round := make([]Match, len(matches))
+		for i, m := range matches {
+			round[i] = *m
+			round[i].Games = append([]MatchGame{}, m.Games...)
+		}
+		v.Bracket = append(v.Bracket, round)
+	}
+	if t.opts.Format == FormatKnockout {
+		k := t.opts.Knockout
+		v.Knockout = &k
+	} else {
+		v.Standings = swiss.Standings(t.history(), t.opts.Tiebreaks)
+	}
+	return v
+}
@@ -0,0 +1,359 @@
+// Package trf reads and writes tournament reports in the FIDE TRF16
+// format, as used for rating submissions and by pairing programs.
+package trf
+
+import (
+	"bufio"
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/THECHAMP95821/chess-backend/internal/chess"
+	"github.com/THECHAMP95821/chess-backend/internal/swiss"
+)
+
+var (
+	ErrSyntax      = errors.New("malformed TRF")
+	ErrUnsupported = errors.New("unsupported in TRF import")
+)
+
+const dateLayout = "2006/01/02"
+
+// Tournament is what a report holds that this server uses. Players are
+// numbered in the order of History.Players and go by their entry in Names,
+// or their ID if they have none, in the name field. Their IDs are kept in
+// XXU lines, which other programs ignore; a report read without them uses
+// each player's name as their ID. Tiebreaks rank the players for the report.
+type Tournament struct {
+	Name        string
+	Type        string
+	TimeControl string
+	Start, End  time.Time
+	History     swiss.History
+	Names       map[string]string
+	Tiebreaks   []swiss.Tiebreak
+}
+
+// Write writes t as a TRF16 report. Only complete rounds are reported, so
+// that a pairing program reading it pairs the next round.
+func Write(w io.Writer, t Tournament) error {
+	h := t.History
+	for len(h.Rounds) > 0 && !h.Rounds[len(h.Rounds)-1].Complete() {
+		h.Rounds = h.Rounds[:len(h.Rounds)-1]
+	}
+	bw := bufio.NewWriter(w)
+	header := func(code, value string) {
+		if value != "" {
+			fmt.Fprintf(bw, "%s %s\n", code, value)
+		}
+	}
+	date := func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.Format(dateLayout)
+	}
+	header("012", t.Name)
+	header("042", date(t.Start))
+	header("052", date(t.End))
+	header("062", strconv.Itoa(len(h.Players)))
+	header("092", t.Type)
+	header("122", t.TimeControl)
+	if h.TotalRounds > 0 {
+		header("XXR", strconv.Itoa(h.TotalRounds))
+	}
+	header("XXC", strings.ToLower(h.InitialColor.String())+"1")
+	if h.ByePoints != 1 {
+		header("BBU", strconv.FormatFloat(h.ByePoints, 'f', 1, 64))
+	}
+
+	number := make(map[string]int, len(h.Players))
+	for i, p := range h.Players {
+		number[p.ID] = i + 1
+	}
+	standing := make(map[string]swiss.Standing, len(h.Players))
+	for _, st := range swiss.Standings(h, t.Tiebreaks) {
+		standing[st.PlayerID] = st
+	}
+	for i, p := range h.Players {
+		rating := ""
+		if p.Rating > 0 {
+			rating = strconv.Itoa(int(math.Round(p.Rating)))
+		}
+		name := t.Names[p.ID]
+		if name == "" {
+			name = p.ID
+		}
+		st := standing[p.ID]
+		line := fmt.Sprintf("001 %4d %1s%3s %-33.33s %4s %3s %11s %10s %4.1f %4d",
+			i+1, "", "", name, rating, "", "", "", st.Score, st.Rank)
+		for _, round := range h.Rounds {
+			line += "  " + block(round, p.ID, number)
+		}
+		fmt.Fprintln(bw, line)
+	}
+	for i, p := range h.Players {
+		fmt.Fprintf(bw, "XXU %4d %s\n", i+1, p.ID)
+	}
+	return bw.Flush()
+}
+
+// block is one player's entry for a round: opponent, color and result.
+func block(round swiss.Round, id string, number map[string]int) string {
+	for _, p := range round.Pairings {
+		if p.Bye() {
+			if p.White == id {
+				return "0000 - U"
+			}
+			continue
+		}
+		if p.White != id && p.Black != id {
+			continue
+		}
+		white := p.White == id
+		opponent, color := number[p.Black], "w"
+		if !white {
+			opponent, color = number[p.White], "b"
+		}
+		return fmt.Sprintf("%04d %s %s", opponent, color, resultCode(p.Result, white))
+	}
+	return "0000 - Z"
+}
+
+func resultCode(r swiss.Result, white bool) string {
+	switch r {
+	case swiss.Draw:
+		return "="
+	case swiss.DoubleForfeit:
+		return "-"
+	}
+	won := r == swiss.WhiteWins || r == swiss.WhiteForfeitWin
+	if !white {
+		won = r == swiss.BlackWins || r == swiss.BlackForfeitWin
+	}
+	forfeit := !r.Played()
+	switch {
+	case won && forfeit:
+		return "+"
+	case won:
+		return "1"
+	case forfeit:
+		return "-"
+	default:
+		return "0"
+	}
+}
+
+// entry is one player's line for a round, as read.
+type entry struct {
+	opponent int
+	color    byte
+	result   byte
+}
+
+// line is a player's record as read.
+type line struct {
+	number  int
+	id      string
+	name    string
+	rating  float64
+	entries []entry
+}
+
+// Read parses a TRF16 report. Players are taken in starting rank order.
+// Half-point byes have no equivalent here and are refused; other byes a
+// player did not get from pairing count as absences.
+func Read(r io.Reader) (Tournament, error) {
+	t := Tournament{History: swiss.History{ByePoints: 1}, Names: make(map[string]string)}
+	var players []line
+	ids := make(map[int]string)
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		text := strings.TrimRight(sc.Text(), " \r")
+		if len(text) < 3 {
+			continue
+		}
+		code, value := text[:3], ""
+		if len(text) > 4 {
+			value = strings.TrimSpace(text[4:])
+		}
+		var err error
+		switch code {
+		case "012":
+			t.Name = value
+		case "092":
+			t.Type = value
+		case "122":
+			t.TimeControl = value
+		case "042":
+			t.Start, err = time.Parse(dateLayout, value)
+		case "052":
+			t.End, err = time.Parse(dateLayout, value)
+		case "XXR":
+			t.History.TotalRounds, err = strconv.Atoi(value)
+		case "XXC":
+			if strings.HasPrefix(value, "black") {
+				t.History.InitialColor = chess.ColorBlack
+			}
+		case "BBU":
+			t.History.ByePoints, err = strconv.ParseFloat(value, 64)
+		case "001":
+			var p line
+			p.number, p.name, p.rating, p.entries, err = parsePlayer(text)
+			players = append(players, p)
+		case "XXU":
+			number, id, _ := strings.Cut(value, " ")
+			var n int
+			if n, err = strconv.Atoi(number); err == nil {
+				ids[n] = strings.TrimSpace(id)
+			}
+		}
+		if err != nil {
+			return Tournament{}, fmt.Errorf("%w: line %d: %v", ErrSyntax, n, err)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return Tournament{}, err
+	}
+
+	slices.SortFunc(players, func(a, b line) int { return cmp.Compare(a.number, b.number) })
+	byNumber := make(map[int]line, len(players))
+	seen := make(map[string]bool, len(players))
+	rounds := 0
+	for i := range players {
+		p := &players[i]
+		p.id = ids[p.number]
+		if p.id == "" {
+			p.id = p.name
+		}
+		if p.name == "" || seen[p.id] {
+			return Tournament{}, fmt.Errorf("%w: player %d: missing or repeated name", ErrSyntax, p.number)
+		}
+		seen[p.id] = true
+		t.Names[p.id] = p.name
+		byNumber[p.number] = *p
+		rounds = max(rounds, len(p.entries))
+		t.History.Players = append(t.History.Players, swiss.Player{ID: p.id, Rating: p.rating})
+	}
+
+	for r := range rounds {
+		var round swiss.Round
+		var byes []swiss.Pairing
+		for _, p := range players {
+			e := entry{color: '-', result: 'Z'}
+			if r < len(p.entries) {
+				e = p.entries[r]
+			}
+			pairing, ok, err := readPairing(p.number, p.id, e, r, byNumber)
+			if err != nil {
+				return Tournament{}, fmt.Errorf("player %d, round %d: %w", p.number, r+1, err)
+			}
+			switch {
+			case ok && pairing.Bye():
+				byes = append(byes, pairing)
+			case ok:
+				round.Pairings = append(round.Pairings, pairing)
+			}
+		}
+		round.Pairings = append(round.Pairings, byes...)
+		t.History.Rounds = append(t.History.Rounds, round)
+	}
+	if t.History.TotalRounds < rounds {
+		t.History.TotalRounds = rounds
+	}
+	return t, nil
+}
+
+func parsePlayer(text string) (int, string, float64, []entry, error) {
+	for len(text) < 89 {
+		text += " "
+	}
+	number, err := strconv.Atoi(strings.TrimSpace(text[4:8]))
+	if err != nil {
+		return 0, "", 0, nil, fmt.Errorf("starting rank: %v", err)
+	}
+	name := strings.TrimSpace(text[14:47])
+	var rating float64
+	if s := strings.TrimSpace(text[48:52]); s != "" {
+		if rating, err = strconv.ParseFloat(s, 64); err != nil {
+			return 0, "", 0, nil, fmt.Errorf("rating: %v", err)
+		}
+	}
+	var entries []entry
+	for start := 91; start < len(text); start += 10 {
+		b := text[start:min(start+8, len(text))]
+		for len(b) < 8 {
+			b += " "
+		}
+		e := entry{color: b[5], result: b[7]}
+		if s := strings.TrimSpace(b[:4]); s != "" {
+			if e.opponent, err = strconv.Atoi(s); err != nil {
+				return 0, "", 0, nil, fmt.Errorf("round %d opponent: %v", len(entries)+1, err)
+			}
+		}
+		entries = append(entries, e)
+	}
+	return number, name, rating, entries, nil
+}
+
+// readPairing turns a player's entry into the board it stands for. Each
+// game is read from one side only, White's or, when no colors are given,
+// the lower starting rank's; ok is false for the other side and absences.
+func readPairing(number int, id string, e entry, round int, byNumber map[int]line) (swiss.Pairing, bool, error) {
+	if e.opponent == 0 {
+		switch e.result {
+		case 'U', 'F':
+			return swiss.Pairing{White: id, Result: swiss.ByeResult}, true, nil
+		case 'H':
+			return swiss.Pairing{}, false, fmt.Errorf("%w: half-point bye", ErrUnsupported)
+		case 'Z', '-', ' ':
+			return swiss.Pairing{}, false, nil
+		}
+		return swiss.Pairing{}, false, fmt.Errorf("%w: result %q without an opponent", ErrSyntax, e.result)
+	}
+	opp, ok := byNumber[e.opponent]
+	if !ok {
+		return swiss.Pairing{}, false, fmt.Errorf("%w: unknown opponent %d", ErrSyntax, e.opponent)
+	}
+	if round >= len(opp.entries) || opp.entries[round].opponent != number {
+		return swiss.Pairing{}, false, fmt.Errorf("%w: opponent %d does not list this game", ErrSyntax, e.opponent)
+	}
+	switch {
+	case e.color == 'b':
+		return swiss.Pairing{}, false, nil
+	case e.color == '-' && number > e.opponent:
+		return swiss.Pairing{}, false, nil
+	}
+	res, err := readResult(e.result, opp.entries[round].result)
+	if err != nil {
+		return swiss.Pairing{}, false, err
+	}
+	return swiss.Pairing{White: id, Black: opp.id, Result: res}, true, nil
+}
+
+// readResult reads a game's result from White's and Black's result codes.
+func readResult(white, black byte) (swiss.Result, error) {
+	switch {
+	case white == ' ' && black == ' ':
+		return swiss.Pending, nil
+	case white == '-' && black == '-':
+		return swiss.DoubleForfeit, nil
+	case white == '+':
+		return swiss.WhiteForfeitWin, nil
+	case black == '+':
+		return swiss.BlackForfeitWin, nil
+	case white == '1' || white == 'W':
+		return swiss.WhiteWins, nil
+	case white == '0' || white == 'L':
+		return swiss.BlackWins, nil
+	case white == '=' || white == 'D':
+		return swiss.Draw, nil
+	}
+	return "", fmt.Errorf("%w: result %q", ErrSyntax, white)
+}
@@ -0,0 +1,136 @@
+package trf
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/THECHAMP95821/chess-backend/internal/swiss"
+)
+
+func testHistory() swiss.History {
+	return swiss.History{
+		Players: []swiss.Player{
+			{ID: "alice", Rating: 2100},
+			{ID: "bob", Rating: 1950},
+			{ID: "carol", Rating: 1800},
+			{ID: "dave", Rating: 1700},
+			{ID: "erin"},
+		},
+		Rounds: []swiss.Round{
+			{Pairings: []swiss.Pairing{
+				{White: "alice", Black: "carol", Result: swiss.WhiteWins},
+				{White: "dave", Black: "bob", Result: swiss.Draw},
+				{White: "erin", Result: swiss.ByeResult},
+			}},
+			{Pairings: []swiss.Pairing{
+				{White: "bob", Black: "erin", Result: swiss.WhiteForfeitWin},
+				{White: "carol", Black: "dave", Result: swiss.BlackWins},
+				{White: "alice", Result: swiss.ByeResult},
+			}},
+			{Pairings: []swiss.Pairing{
+				{White: "alice", Black: "bob"},
+			}},
+		},
+		TotalRounds: 5,
+		ByePoints:   1,
+	}
+}
+
+func TestWrite(t *testing.T) {
+	var b strings.Builder
+	err := Write(&b, Tournament{
+		Name:    "Club Championship",
+		Type:    "Swiss Dutch",
+		Start:   time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+		History: testHistory(),
+		Names:   map[string]string{"alice": "Liddell, Alice"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(b.String(), "\n")
+	for _, want := range []string{
+		"012 Club Championship",
+		"042 2024/03/01",
+		"XXR 5",
+		"XXC white1",
+		"001    1      Liddell, Alice                    2100                             2.0    1  0003 w 1  0000 - U",
+		"001    5      erin                                                               1.0    4  0000 - U  0002 b -",
+		"XXU    1 alice",
+		"XXU    5 erin",
+	} {
+		found := false
+		for _, l := range lines {
+			found = found || l == want
+		}
+		if !found {
+			t.Errorf("report lacks %q:\n%s", want, b.String())
+		}
+	}
+	// Columns as the format fixes them.
+	for _, l := range lines {
+		if strings.HasPrefix(l, "001    4") {
+			if l[48:52] != "1700" || l[80:84] != " 1.5" || l[91:99] != "0002 w =" || l[101:109] != "0003 b 1" {
+				t.Errorf("misaligned record %q", l)
+			}
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	h := testHistory()
+	var b strings.Builder
+	names := map[string]string{"alice": "Liddell, Alice", "bob": "Alice"}
+	if err := Write(&b, Tournament{Name: "Club Championship", History: h, Names: names}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := Read(strings.NewReader(b.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "Club Championship" || got.History.TotalRounds != 5 {
+		t.Errorf("read %+v", got)
+	}
+	if got.Names["alice"] != "Liddell, Alice" || got.Names["bob"] != "Alice" || got.Names["carol"] != "carol" {
+		t.Errorf("names = %v", got.Names)
+	}
+	h.Rounds = h.Rounds[:2]
+	if !reflect.DeepEqual(got.History, h) {
+		t.Errorf("history after a round trip:\n got %+v\nwant %+v", got.History, h)
+	}
+}
+
+func TestReadWithoutIDs(t *testing.T) {
+	got, err := Read(strings.NewReader(
+		"001    1      Liddell, Alice                    2100                             1.0    1  0002 w 1\n" +
+			"001    2      Hatter                            2000                             0.0    2  0001 b 0\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := swiss.Pairing{White: "Liddell, Alice", Black: "Hatter", Result: swiss.WhiteWins}
+	if len(got.History.Rounds) != 1 || got.History.Rounds[0].Pairings[0] != want {
+		t.Errorf("rounds = %+v, want players named by name", got.History.Rounds)
+	}
+	if got.Names["Hatter"] != "Hatter" {
+		t.Errorf("names = %v", got.Names)
+	}
+}
+
+func TestReadRejects(t *testing.T) {
+	for name, report := range map[string]string{
+		"half-point bye": "001    1      alice                             2100                             0.5    1  0000 - H\n",
+		"one-sided game": "001    1      alice                             2100                             1.0    1  0002 w 1\n" +
+			"001    2      bob                               2000                             0.0    2  0000 - Z\n",
+	} {
+		_, err := Read(strings.NewReader(report))
+		if err == nil {
+			t.Errorf("%s: read without error", name)
+		}
+		if name == "half-point bye" && !errors.Is(err, ErrUnsupported) {
+			t.Errorf("%s: err = %v", name, err)
+		}
+	}
+}